@@ -24,6 +24,7 @@ import (
 	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc/credentials/insecure"
 	"net/http"
+	"os"
 	"path"
 	"strings"
 	"time"
@@ -44,9 +45,12 @@ import (
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/api/server/db/driver"
 	"github.com/tektoncd/results/pkg/api/server/logger"
 	v1alpha2 "github.com/tektoncd/results/pkg/api/server/v1alpha2"
 	"github.com/tektoncd/results/pkg/api/server/v1alpha2/auth"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/retention"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/summary"
 	v1alpha2pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
 	_ "go.uber.org/automaxprocs"
 	"google.golang.org/grpc"
@@ -54,7 +58,6 @@ import (
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	gormlogger "gorm.io/gorm/logger"
 )
@@ -65,6 +68,24 @@ func main() {
 	log := logger.Get(serverConfig.LOG_LEVEL)
 	defer log.Sync()
 
+	// Connect to the database.
+	gormConfig := &gorm.Config{}
+	if log.Level() != zap.DebugLevel {
+		gormConfig.Logger = gormlogger.Default.LogMode(gormlogger.Silent)
+	}
+	db, err := driver.Open(serverConfig, gormConfig)
+	if err != nil {
+		log.Fatalf("Failed to open the results.db: %v", err)
+	}
+
+	// Run the migrate subcommand instead of the server if requested.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, log, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// Load server TLS
 	certFile := path.Join(serverConfig.TLS_PATH, "tls.crt")
 	keyFile := path.Join(serverConfig.TLS_PATH, "tls.key")
@@ -75,21 +96,18 @@ func main() {
 		creds = insecure.NewCredentials()
 	}
 
-	if serverConfig.DB_USER == "" || serverConfig.DB_PASSWORD == "" {
-		log.Fatal("Must provide both DB_USER and DB_PASSWORD")
-	}
-	// Connect to the database.
-	// DSN derived from https://pkg.go.dev/gorm.io/driver/postgres
-
-	dbURI := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s", serverConfig.DB_HOST, serverConfig.DB_USER, serverConfig.DB_PASSWORD, serverConfig.DB_NAME, serverConfig.DB_PORT, serverConfig.DB_SSLMODE)
-
-	gormConfig := &gorm.Config{}
-	if log.Level() != zap.DebugLevel {
-		gormConfig.Logger = gormlogger.Default.LogMode(gormlogger.Silent)
-	}
-	db, err := gorm.Open(postgres.Open(dbURI), gormConfig)
-	if err != nil {
-		log.Fatalf("Failed to open the results.db: %v", err)
+	// Create k8s client, used for authorization checks and to elect the
+	// replica enforcing retention policies.
+	var k8s kubernetes.Interface
+	if !serverConfig.AUTH_DISABLE || serverConfig.RETENTION_POLICY_PATH != "" {
+		k8sConfig, err := rest.InClusterConfig()
+		if err != nil {
+			log.Fatal("Error getting kubernetes client config:", err)
+		}
+		k8s, err = kubernetes.NewForConfig(k8sConfig)
+		if err != nil {
+			log.Fatal("Error creating kubernetes clientset:", err)
+		}
 	}
 
 	// Create the authorization authCheck
@@ -100,16 +118,6 @@ func main() {
 		authCheck = &auth.AllowAll{}
 	} else {
 		log.Info("Kubernetes RBAC authorization check enabled")
-		// Create k8s client
-		k8sConfig, err := rest.InClusterConfig()
-		if err != nil {
-			log.Fatal("Error getting kubernetes client config:", err)
-		}
-		k8s, err := kubernetes.NewForConfig(k8sConfig)
-		if err != nil {
-			log.Fatal("Error creating kubernetes clientset:", err)
-		}
-
 		if serverConfig.AUTH_IMPERSONATE {
 			log.Info("Kubernetes RBAC impersonation enabled")
 			serverMuxOptions = append(serverMuxOptions, runtime.WithIncomingHeaderMatcher(impersonation.HeaderMatcher))
@@ -117,8 +125,17 @@ func main() {
 		authCheck = auth.NewRBAC(k8s, auth.WithImpersonation(serverConfig.AUTH_IMPERSONATE))
 	}
 
+	serverOptions := []v1alpha2.Option{v1alpha2.WithAuth(authCheck)}
+	if serverConfig.SUMMARY_CONFIG_PATH != "" {
+		summarizer, err := summary.Load(serverConfig.SUMMARY_CONFIG_PATH)
+		if err != nil {
+			log.Fatalf("Error loading summary config: %v", err)
+		}
+		serverOptions = append(serverOptions, v1alpha2.WithSummarizer(summarizer))
+	}
+
 	// Register API server(s)
-	v1a2, err := v1alpha2.New(serverConfig, log, db, v1alpha2.WithAuth(authCheck))
+	v1a2, err := v1alpha2.New(serverConfig, log, db, serverOptions...)
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
@@ -181,10 +198,31 @@ func main() {
 		}
 	}
 
-	// Register gRPC server endpoint for gRPC gateway
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
+
+	// Enforce retention policies in the background.
+	if serverConfig.RETENTION_POLICY_PATH != "" {
+		policies, err := retention.Load(serverConfig.RETENTION_POLICY_PATH)
+		if err != nil {
+			log.Fatalf("Error loading retention policies: %v", err)
+		}
+		pruner := retention.NewPruner(db, serverConfig, policies, log, retention.WithDeleteResult(v1a2.PruneResult))
+		go runRetention(ctx, k8s, serverConfig.RETENTION_LEASE_NAMESPACE, pruner, log)
+	}
+
+	// Delete the changes Watch streams can no longer be resumed from.
+	if serverConfig.WATCH_EVENT_RETENTION > 0 {
+		go prune(ctx, "watch changes", v1a2.PruneChanges, log)
+	}
+
+	// Forget the request IDs of idempotent create requests once expired.
+	if serverConfig.REQUEST_ID_TTL > 0 {
+		go prune(ctx, "request IDs", v1a2.PruneRequests, log)
+	}
+
+	// Register gRPC server endpoint for gRPC gateway
 	httpMux := runtime.NewServeMux(serverMuxOptions...)
 	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
 	err = v1alpha2pb.RegisterResultsHandlerFromEndpoint(ctx, httpMux, ":"+serverConfig.SERVER_PORT, opts)
@@ -0,0 +1,88 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/tektoncd/results/pkg/api/server/db/migrate"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// runMigrate implements the migrate subcommand:
+//
+//	api migrate [up|plan|version|down [-steps N]]
+//
+// up applies pending migrations and is the default, plan lists them without
+// applying them, down reverts the last applied migrations and version prints
+// the current and the required schema versions.
+func runMigrate(db *gorm.DB, log *zap.SugaredLogger, args []string) error {
+	cmd := "up"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("migrate "+cmd, flag.ContinueOnError)
+	steps := fs.Int("steps", 1, "number of migrations to revert with down")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	m := migrate.New(db)
+	switch cmd {
+	case "up":
+		done, err := m.Up()
+		for _, mig := range done {
+			log.Infof("Applied migration %s", mig)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			log.Info("Database schema is up to date")
+		}
+	case "plan":
+		pending, err := m.Plan()
+		if err != nil {
+			return err
+		}
+		for _, mig := range pending {
+			log.Infof("Pending migration %s", mig)
+		}
+		if len(pending) == 0 {
+			log.Info("Database schema is up to date")
+		}
+	case "down":
+		if *steps < 1 {
+			return fmt.Errorf("-steps must be at least 1")
+		}
+		done, err := m.Down(*steps)
+		for _, mig := range done {
+			log.Infof("Reverted migration %s", mig)
+		}
+		return err
+	case "version":
+		version, err := m.Version()
+		if err != nil {
+			return err
+		}
+		log.Infof("Database schema version %d, required version %d", version, m.Latest())
+	default:
+		return fmt.Errorf("unknown migrate command %q, must be one of up, plan, down or version", cmd)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// pruneInterval is how often expired watch changes and request IDs are
+// pruned.
+const pruneInterval = time.Minute
+
+// prune periodically deletes the expired rows of what with fn, until ctx is
+// done. Replicas prune concurrently, which is harmless.
+func prune(ctx context.Context, what string, fn func(context.Context) (int64, error), log *zap.SugaredLogger) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := fn(ctx); err != nil {
+				log.Errorf("Error pruning %s: %v", what, err)
+			} else if n > 0 {
+				log.Debugf("Pruned %d %s", n, what)
+			}
+		}
+	}
+}
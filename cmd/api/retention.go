@@ -0,0 +1,70 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/retention"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// retentionLeaseName is the name of the Lease electing the API server replica
+// which enforces retention policies.
+const retentionLeaseName = "tekton-results-api-retention"
+
+// runRetention enforces retention policies with the pruner while this replica
+// holds the retention Lease, until ctx is done.
+func runRetention(ctx context.Context, k8s kubernetes.Interface, namespace string, pruner *retention.Pruner, log *zap.SugaredLogger) {
+	id, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("Error getting hostname for leader election: %v", err)
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      retentionLeaseName,
+			Namespace: namespace,
+		},
+		Client: k8s.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: id,
+		},
+	}
+	// RunOrDie returns when leadership is lost, so keep campaigning.
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			ReleaseOnCancel: true,
+			LeaseDuration:   15 * time.Second,
+			RenewDeadline:   10 * time.Second,
+			RetryPeriod:     2 * time.Second,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) {
+					log.Infof("Enforcing retention policies as leader %s", id)
+					pruner.Run(ctx)
+				},
+				OnStoppedLeading: func() {
+					log.Infof("Stopped enforcing retention policies as %s", id)
+				},
+			},
+		})
+	}
+}
@@ -18,6 +18,7 @@ package cel
 import (
 	"context"
 	"log"
+	"time"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
@@ -33,6 +34,7 @@ import (
 // known types.
 func NewEnv() (*cel.Env, error) {
 	return cel.NewEnv(
+		cel.Lib(library{}),
 		cel.Types(&pb.Result{}, &pb.Record{}, &ppb.PipelineRun{}, &ppb.TaskRun{}),
 		cel.Declarations(decls.NewVar("result", decls.NewObjectType("tekton.results.v1alpha2.Result"))),
 		cel.Declarations(decls.NewVar("record", decls.NewObjectType("tekton.results.v1alpha2.Record"))),
@@ -45,12 +47,26 @@ func ParseFilter(env *cel.Env, filter string) (cel.Program, error) {
 		return allowAll{}, nil
 	}
 
+	ast, err := Compile(env, filter)
+	if err != nil {
+		return nil, err
+	}
+	return Program(env, ast, time.Now())
+}
+
+// Compile parses and type checks the given filter string.
+func Compile(env *cel.Env, filter string) (*cel.Ast, error) {
 	ast, issues := env.Compile(filter)
 	if issues != nil && issues.Err() != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "error parsing filter: %v", issues.Err())
 	}
+	return ast, nil
+}
 
-	prg, err := env.Program(ast)
+// Program creates a CEL program from a compiled filter, evaluating now() to
+// the given time.
+func Program(env *cel.Env, ast *cel.Ast, now time.Time) (cel.Program, error) {
+	prg, err := env.Program(ast, Functions(func() time.Time { return now }))
 	if err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "error creating filter query evaluator: %v", err)
 	}
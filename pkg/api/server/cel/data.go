@@ -0,0 +1,248 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cel
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"github.com/tektoncd/results/pkg/apis/v1alpha2"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// dataTypes maps the Record data types with a known schema to the Go types
+// their JSON data is decoded from.
+var dataTypes = map[string]reflect.Type{
+	"tekton.dev/v1beta1.TaskRun":      reflect.TypeOf(v1beta1.TaskRun{}),
+	"tekton.dev/v1beta1.PipelineRun":  reflect.TypeOf(v1beta1.PipelineRun{}),
+	"tekton.dev/v1beta1.CustomRun":    reflect.TypeOf(v1beta1.CustomRun{}),
+	"tekton.dev/v1.TaskRun":           reflect.TypeOf(v1.TaskRun{}),
+	"tekton.dev/v1.PipelineRun":       reflect.TypeOf(v1.PipelineRun{}),
+	"pipeline.tekton.dev/TaskRun":     reflect.TypeOf(v1beta1.TaskRun{}),
+	"pipeline.tekton.dev/PipelineRun": reflect.TypeOf(v1beta1.PipelineRun{}),
+	v1alpha2.LogRecordType:            reflect.TypeOf(v1alpha2.Log{}),
+}
+
+var (
+	schemaOnce sync.Once
+	schema     *dataSchema
+)
+
+// dataSchema holds the CEL object types describing the JSON encoding of the
+// known data types. Fields are named after their JSON keys, as in the data
+// filters are evaluated on.
+type dataSchema struct {
+	names   map[reflect.Type]string
+	objects map[string]map[string]*exprpb.Type
+}
+
+func loadSchema() *dataSchema {
+	schemaOnce.Do(func() {
+		schema = &dataSchema{
+			names:   make(map[reflect.Type]string),
+			objects: make(map[string]map[string]*exprpb.Type),
+		}
+		for _, t := range dataTypes {
+			schema.object(t, "")
+		}
+	})
+	return schema
+}
+
+var marshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// celType returns the CEL type of the JSON encoding of t. Values with a
+// custom encoding and numbers, which are decoded as doubles, are dynamic.
+func (s *dataSchema) celType(t reflect.Type, name string) *exprpb.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Implements(marshaler) || reflect.PtrTo(t).Implements(marshaler) {
+		return decls.Dyn
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return decls.String
+	case reflect.Bool:
+		return decls.Bool
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are encoded as base64 strings.
+			return decls.String
+		}
+		return decls.NewListType(s.celType(t.Elem(), name))
+	case reflect.Map:
+		return decls.NewMapType(decls.String, s.celType(t.Elem(), name))
+	case reflect.Struct:
+		return decls.NewObjectType(s.object(t, name))
+	}
+	return decls.Dyn
+}
+
+// object declares the object type of the struct t and returns its name.
+// Anonymous structs are named after the field holding them.
+func (s *dataSchema) object(t reflect.Type, name string) string {
+	if n, ok := s.names[t]; ok {
+		return n
+	}
+	if t.Name() != "" {
+		name = strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + t.Name()
+	}
+	fields := make(map[string]*exprpb.Type)
+	// Declared before the fields to terminate on recursive types.
+	s.names[t] = name
+	s.objects[name] = fields
+	s.fields(t, name, fields, false)
+	return name
+}
+
+// fields declares the fields of the struct t in out following the rules of
+// encoding/json: embedded structs without a JSON name are flattened, with
+// fields of the outer struct taking precedence.
+func (s *dataSchema) fields(t reflect.Type, name string, out map[string]*exprpb.Type, embedded bool) {
+	var inline []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		key, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && key == "" && ft.Kind() == reflect.Struct {
+			inline = append(inline, ft)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if key == "" {
+			key = f.Name
+		}
+		if _, ok := out[key]; ok && embedded {
+			continue
+		}
+		out[key] = s.celType(f.Type, name+"."+key)
+	}
+	for _, ft := range inline {
+		s.fields(ft, name, out, true)
+	}
+}
+
+// dataProvider resolves the object types of the data schema on top of the
+// protobuf types of the registry.
+type dataProvider struct {
+	ref.TypeRegistry
+	schema *dataSchema
+}
+
+func (p *dataProvider) FindType(name string) (*exprpb.Type, bool) {
+	if _, ok := p.schema.objects[name]; ok {
+		return decls.NewTypeType(decls.NewObjectType(name)), true
+	}
+	return p.TypeRegistry.FindType(name)
+}
+
+func (p *dataProvider) FindFieldType(messageType, fieldName string) (*ref.FieldType, bool) {
+	fields, ok := p.schema.objects[messageType]
+	if !ok {
+		return p.TypeRegistry.FindFieldType(messageType, fieldName)
+	}
+	t, ok := fields[fieldName]
+	if !ok {
+		return nil, false
+	}
+	return &ref.FieldType{Type: t}, true
+}
+
+// NewRecordEnv returns the CEL environment for Record filters. Fields are
+// broken up explicitly in order to support handling of the data field as a
+// key-value document. data is typed after the schema of dataType if it is
+// known and dynamic otherwise.
+func NewRecordEnv(dataType string) (*cel.Env, error) {
+	data := decls.Dyn
+	opts := []cel.EnvOption{}
+	if t, ok := dataTypes[dataType]; ok {
+		reg, err := types.NewRegistry()
+		if err != nil {
+			return nil, err
+		}
+		s := loadSchema()
+		data = decls.NewObjectType(s.names[t])
+		opts = append(opts, cel.CustomTypeProvider(&dataProvider{TypeRegistry: reg, schema: s}))
+	}
+	return cel.NewEnv(append(opts,
+		cel.Lib(library{}),
+		cel.Types(&pb.Record{}),
+		cel.Declarations(decls.NewVar("name", decls.String)),
+		cel.Declarations(decls.NewVar("data_type", decls.String)),
+		cel.Declarations(decls.NewVar("data", data)),
+	)...)
+}
+
+// CheckData type checks the data of a compiled Record filter against the
+// schema of the data type it constrains with `data_type == "<type>"`. It
+// returns the constrained type, empty if there is none.
+func CheckData(ast *cel.Ast) (string, error) {
+	dataType := DataType(ast)
+	if _, ok := dataTypes[dataType]; !ok {
+		return dataType, nil
+	}
+	env, err := NewRecordEnv(dataType)
+	if err != nil {
+		return "", err
+	}
+	_, err = Compile(env, ast.Source().Content())
+	return dataType, err
+}
+
+// DataType returns the data type all the Records matched by the filter have,
+// empty if the filter does not constrain it.
+func DataType(ast *cel.Ast) string {
+	return dataType(ast.Expr())
+}
+
+func dataType(e *exprpb.Expr) string {
+	call := e.GetCallExpr()
+	switch call.GetFunction() {
+	case operators.LogicalAnd:
+		for _, arg := range call.GetArgs() {
+			if t := dataType(arg); t != "" {
+				return t
+			}
+		}
+	case operators.Equals:
+		args := call.GetArgs()
+		for i, arg := range args {
+			if arg.GetIdentExpr().GetName() == "data_type" {
+				return args[1-i].GetConstExpr().GetStringValue()
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cel
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckData(t *testing.T) {
+	env, err := NewRecordEnv("")
+	if err != nil {
+		t.Fatalf("NewRecordEnv: %v", err)
+	}
+
+	for _, tc := range []struct {
+		filter   string
+		dataType string
+		// field is the undefined field reported, if any.
+		field string
+	}{
+		{filter: `data.status.conditon[0].status == "True"`},
+		{filter: `data_type == "example.dev/v1.Deploy" && data.anything == 1`, dataType: "example.dev/v1.Deploy"},
+		{
+			filter:   `data_type == "tekton.dev/v1beta1.TaskRun" && data.status.conditions[0].status == "True"`,
+			dataType: "tekton.dev/v1beta1.TaskRun",
+		},
+		{
+			filter:   `data.metadata.name.startsWith("a") && "tekton.dev/v1beta1.PipelineRun" == data_type && data.kind == "PipelineRun" && data.spec.pipelineRef.name == "p" && data.status.completionTime > "2023"`,
+			dataType: "tekton.dev/v1beta1.PipelineRun",
+		},
+		{
+			filter:   `data_type == "tekton.dev/v1.TaskRun" && data.metadata.labels["a"] == "b" && data.status.taskSpec.steps.exists(s, s.image == "x")`,
+			dataType: "tekton.dev/v1.TaskRun",
+		},
+		{
+			filter:   `data_type == "results.tekton.dev/v1alpha2.Log" && data.spec.resource.name == "a" && data.status.size > 0`,
+			dataType: "results.tekton.dev/v1alpha2.Log",
+		},
+		{
+			filter:   `data_type == "tekton.dev/v1beta1.TaskRun" && data.status.conditon[0].status == "True"`,
+			dataType: "tekton.dev/v1beta1.TaskRun",
+			field:    "conditon",
+		},
+		{
+			filter:   `data_type == "tekton.dev/v1.PipelineRun" && data.status.completion_time != ""`,
+			dataType: "tekton.dev/v1.PipelineRun",
+			field:    "completion_time",
+		},
+		{
+			filter:   `data_type == "pipeline.tekton.dev/TaskRun" && data.metadata.name == 1`,
+			dataType: "pipeline.tekton.dev/TaskRun",
+			field:    "_==_",
+		},
+	} {
+		t.Run(tc.filter, func(t *testing.T) {
+			ast, err := Compile(env, tc.filter)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			dataType, err := CheckData(ast)
+			if dataType != tc.dataType {
+				t.Errorf("data type: want %q, got %q", tc.dataType, dataType)
+			}
+			if tc.field == "" {
+				if err != nil {
+					t.Errorf("CheckData: %v", err)
+				}
+				return
+			}
+			if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), tc.field) {
+				t.Errorf("CheckData: want InvalidArgument error about %q, got %v", tc.field, err)
+			}
+		})
+	}
+}
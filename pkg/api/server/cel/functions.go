@@ -0,0 +1,206 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cel
+
+import (
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/interpreter/functions"
+)
+
+// Functions of the Results CEL library.
+const (
+	// DurationFunc returns the duration between two timestamps, zero if
+	// either is unset: duration(start, end).
+	DurationFunc = "duration"
+	// NowFunc returns the time of the request: now().
+	NowFunc = "now"
+	// AgoFunc returns the time of the request minus a duration: ago("24h").
+	AgoFunc = "ago"
+	// HasLabelFunc reports whether the data has a label: has_label(data, "app").
+	HasLabelFunc = "has_label"
+	// LabelFunc returns the value of a label of the data, empty if it is
+	// missing: label(data, "app").
+	LabelFunc = "label"
+	// HasAnnotationFunc reports whether the data has an annotation.
+	HasAnnotationFunc = "has_annotation"
+	// AnnotationFunc returns the value of an annotation of the data, empty if
+	// it is missing.
+	AnnotationFunc = "annotation"
+	// ConditionFunc returns the status condition of the data with the given
+	// type: condition(data, "Succeeded"). The status, reason and message of
+	// missing conditions are empty.
+	ConditionFunc = "condition"
+)
+
+// timestampsToDuration is the overload of duration() added by the library.
+const timestampsToDuration = "timestamp_timestamp_to_duration"
+
+// library is the Results CEL function library. now() and ago() are bound to
+// a clock when creating programs, see Functions.
+type library struct{}
+
+func (library) LibraryName() string {
+	return "tekton.results"
+}
+
+func (library) CompileOptions() []cel.EnvOption {
+	dataMap := cel.MapType(cel.StringType, cel.DynType)
+	return []cel.EnvOption{
+		cel.Function(DurationFunc,
+			cel.Overload(timestampsToDuration, []*cel.Type{cel.TimestampType, cel.TimestampType}, cel.DurationType)),
+		cel.Function(NowFunc,
+			cel.Overload(NowFunc, nil, cel.TimestampType)),
+		cel.Function(AgoFunc,
+			cel.Overload(AgoFunc, []*cel.Type{cel.StringType}, cel.TimestampType)),
+		cel.Function(HasLabelFunc,
+			cel.Overload(HasLabelFunc, []*cel.Type{cel.DynType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(hasKey("labels")))),
+		cel.Function(LabelFunc,
+			cel.Overload(LabelFunc, []*cel.Type{cel.DynType, cel.StringType}, cel.StringType,
+				cel.BinaryBinding(value("labels")))),
+		cel.Function(HasAnnotationFunc,
+			cel.Overload(HasAnnotationFunc, []*cel.Type{cel.DynType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(hasKey("annotations")))),
+		cel.Function(AnnotationFunc,
+			cel.Overload(AnnotationFunc, []*cel.Type{cel.DynType, cel.StringType}, cel.StringType,
+				cel.BinaryBinding(value("annotations")))),
+		cel.Function(ConditionFunc,
+			cel.Overload(ConditionFunc, []*cel.Type{cel.DynType, cel.StringType}, dataMap,
+				cel.BinaryBinding(condition))),
+	}
+}
+
+func (library) ProgramOptions() []cel.ProgramOption {
+	return nil
+}
+
+// Functions binds the functions of the library which the environment can't,
+// to be set on the programs of environments created by NewEnv. now() and ago()
+// are relative to the time returned by clock, called on each evaluation.
+// duration() is bound here as well since the environment can't hold bindings
+// for a function of the standard library.
+func Functions(clock func() time.Time) cel.ProgramOption {
+	return cel.Functions(
+		&functions.Overload{
+			Operator: timestampsToDuration,
+			Binary:   duration,
+		},
+		&functions.Overload{
+			Operator: NowFunc,
+			Function: func(...ref.Val) ref.Val {
+				return types.Timestamp{Time: clock()}
+			},
+		},
+		&functions.Overload{
+			Operator: AgoFunc,
+			Unary: func(v ref.Val) ref.Val {
+				s, ok := v.(types.String)
+				if !ok {
+					return types.MaybeNoSuchOverloadErr(v)
+				}
+				d, err := time.ParseDuration(string(s))
+				if err != nil {
+					return types.NewErr("invalid duration %q: %v", s, err)
+				}
+				return types.Timestamp{Time: clock().Add(-d)}
+			},
+		},
+	)
+}
+
+// duration returns the duration between the start and end timestamps. Unset
+// timestamp fields evaluate to the Unix epoch, in which case the duration is
+// zero as when the database evaluates it on NULL columns.
+func duration(start, end ref.Val) ref.Val {
+	s, ok := start.(types.Timestamp)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(start)
+	}
+	e, ok := end.(types.Timestamp)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(end)
+	}
+	if s.Unix() == 0 && s.Nanosecond() == 0 || e.Unix() == 0 && e.Nanosecond() == 0 {
+		return types.Duration{}
+	}
+	return types.Duration{Duration: e.Sub(s.Time)}
+}
+
+// hasKey reports whether the metadata map of the data holds a key.
+func hasKey(field string) functions.BinaryOp {
+	return func(data, key ref.Val) ref.Val {
+		_, ok := lookup(data, "metadata", field, string(key.(types.String)))
+		return types.Bool(ok)
+	}
+}
+
+// value returns the value of a key of the metadata map of the data.
+func value(field string) functions.BinaryOp {
+	return func(data, key ref.Val) ref.Val {
+		v, _ := lookup(data, "metadata", field, string(key.(types.String)))
+		if s, ok := v.(types.String); ok {
+			return s
+		}
+		return types.String("")
+	}
+}
+
+// condition returns the condition of the data with the given type.
+func condition(data, conditionType ref.Val) ref.Val {
+	out := map[ref.Val]ref.Val{
+		types.String("type"):    conditionType,
+		types.String("status"):  types.String(""),
+		types.String("reason"):  types.String(""),
+		types.String("message"): types.String(""),
+	}
+	if list, ok := lookup(data, "status", "conditions"); ok {
+		if l, ok := list.(traits.Lister); ok {
+			for it := l.Iterator(); it.HasNext() == types.True; {
+				c, ok := it.Next().(traits.Mapper)
+				if !ok {
+					continue
+				}
+				if t, ok := c.Find(types.String("type")); !ok || t.Equal(conditionType) != types.True {
+					continue
+				}
+				for keys := c.Iterator(); keys.HasNext() == types.True; {
+					k := keys.Next()
+					out[k] = c.Get(k)
+				}
+				break
+			}
+		}
+	}
+	return types.NewRefValMap(types.DefaultTypeAdapter, out)
+}
+
+// lookup returns the value at the path of keys in nested maps.
+func lookup(v ref.Val, keys ...string) (ref.Val, bool) {
+	for _, k := range keys {
+		m, ok := v.(traits.Mapper)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m.Find(types.String(k)); !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cel
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestFunctions(t *testing.T) {
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	var data interface{}
+	if err := json.Unmarshal([]byte(`{
+		"metadata": {"labels": {"app": "a"}, "annotations": {"ci": "b"}},
+		"status": {"conditions": [{"type": "Ready", "status": "True"}, {"type": "Succeeded", "status": "False", "reason": "Failed"}]}
+	}`), &data); err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]interface{}{
+		"data": data,
+		"result": &pb.Result{
+			CreateTime: timestamppb.New(now.Add(-time.Hour)),
+			Summary: &pb.RecordSummary{
+				StartTime: timestamppb.New(now.Add(-time.Hour)),
+				EndTime:   timestamppb.New(now.Add(-time.Hour + 11*time.Minute)),
+			},
+		},
+	}
+
+	env, err := NewEnv()
+	if err != nil {
+		t.Fatalf("NewEnv: %v", err)
+	}
+	env, err = env.Extend(cel.Variable("data", cel.DynType))
+	if err != nil {
+		t.Fatalf("Extend: %v", err)
+	}
+
+	for filter, want := range map[string]bool{
+		`duration(result.summary.start_time, result.summary.end_time) > duration("10m")`:   true,
+		`duration(result.summary.start_time, result.summary.end_time) > duration("11m")`:   false,
+		`duration(result.create_time, result.summary.end_time) == duration("11m")`:         true,
+		`duration(result.summary.start_time, result.update_time) == duration("0s")`:        true,
+		`now() == timestamp("2023-01-02T03:04:05Z")`:                                       true,
+		`result.create_time > ago("2h") && result.create_time < ago("30m")`:                true,
+		`has_label(data, "app") && !has_label(data, "ci")`:                                 true,
+		`label(data, "app") == "a" && label(data, "ci") == ""`:                             true,
+		`has_annotation(data, "ci") && annotation(data, "ci") == "b"`:                      true,
+		`condition(data, "Succeeded").status == "False"`:                                   true,
+		`condition(data, "Succeeded").reason == "Failed"`:                                  true,
+		`condition(data, "Ready").reason == ""`:                                            true,
+		`condition(data, "Unknown").status == "" && condition(data, "Unknown").type != ""`: true,
+	} {
+		t.Run(filter, func(t *testing.T) {
+			ast, err := Compile(env, filter)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			prg, err := Program(env, ast, now)
+			if err != nil {
+				t.Fatalf("Program: %v", err)
+			}
+			got, err := Match(prg, vars)
+			if err != nil {
+				t.Fatalf("Match: %v", err)
+			}
+			if got != want {
+				t.Errorf("want %t, got %t", want, got)
+			}
+		})
+	}
+}
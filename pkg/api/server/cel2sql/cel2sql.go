@@ -0,0 +1,716 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cel2sql translates CEL filters into SQL conditions so that they can
+// be evaluated by the database instead of row-by-row in memory.
+//
+// Only a subset of CEL is supported: comparisons between fields and
+// constants, logical operators, `in` with constant lists, the
+// startsWith/endsWith/contains string functions and the functions of the
+// Results CEL library. Callers are expected to fall back to in-memory
+// evaluation for anything that cannot be translated.
+package cel2sql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	resultscel "github.com/tektoncd/results/pkg/api/server/cel"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+const (
+	startsWith = overloads.StartsWith
+	endsWith   = overloads.EndsWith
+	contains   = overloads.Contains
+)
+
+// errUnsupported is returned for expressions that have no SQL equivalent.
+var errUnsupported = errors.New("unsupported expression")
+
+// Type is the SQL type of a Field.
+type Type int
+
+const (
+	// String columns are compared with string constants.
+	String Type = iota
+	// Int columns are compared with integer (or enum) constants.
+	Int
+	// Timestamp columns are compared with timestamp() constants.
+	Timestamp
+	// JSON columns hold documents whose sub-paths can be selected.
+	JSON
+	// Duration columns hold a number of seconds, compared with duration()
+	// constants.
+	Duration
+)
+
+// Field describes the SQL expression a CEL field path maps to.
+type Field struct {
+	// Column is the SQL expression selecting the field, e.g. a column name.
+	Column string
+	Type   Type
+	// Nullable reports whether the column may be NULL where the field is
+	// unset in memory, which CEL evaluates to the zero value of its type.
+	// Only Timestamp fields are supported, compared as the epoch when NULL.
+	Nullable bool
+}
+
+// Fields maps dotted CEL field paths (e.g. "result.summary.status") to their
+// SQL equivalent. Paths below a JSON field are resolved into the document.
+type Fields map[string]Field
+
+// Clause is a SQL condition with `?` placeholders and their arguments.
+type Clause struct {
+	SQL  string
+	Args []interface{}
+}
+
+// Option configures the translation of filters.
+type Option func(*translator)
+
+// Now sets the time now() and ago() evaluate to. Without it, expressions
+// using them are not translated.
+func Now(now time.Time) Option {
+	return func(t *translator) {
+		now := now.UTC()
+		t.now = &now
+	}
+}
+
+// Convert translates the checked CEL expression into a SQL condition.
+// Top-level conjunctions are translated term by term: terms that cannot be
+// expressed in SQL are left out of the returned clause. exact reports whether
+// the clause is equivalent to the whole expression, in which case the filter
+// does not need to be evaluated again in memory. A nil clause means nothing
+// could be translated.
+func Convert(d Dialect, fields Fields, ast *cel.Ast, opts ...Option) (clause *Clause, exact bool) {
+	if ast == nil || !d.Supported() {
+		return nil, false
+	}
+	checked, err := cel.AstToCheckedExpr(ast)
+	if err != nil {
+		return nil, false
+	}
+	t := &translator{
+		dialect: d,
+		fields:  fields,
+		refs:    checked.GetReferenceMap(),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	exact = true
+	var terms []string
+	var args []interface{}
+	for _, e := range conjuncts(checked.GetExpr()) {
+		sql, a, err := t.condition(e)
+		if err != nil {
+			exact = false
+			continue
+		}
+		terms = append(terms, sql)
+		args = append(args, a...)
+	}
+	if len(terms) == 0 {
+		return nil, false
+	}
+	return &Clause{SQL: strings.Join(terms, " AND "), Args: args}, exact
+}
+
+// conjuncts flattens a chain of && operators into its terms.
+func conjuncts(e *exprpb.Expr) []*exprpb.Expr {
+	if c := e.GetCallExpr(); c != nil && c.GetFunction() == operators.LogicalAnd && len(c.GetArgs()) == 2 {
+		return append(conjuncts(c.GetArgs()[0]), conjuncts(c.GetArgs()[1])...)
+	}
+	return []*exprpb.Expr{e}
+}
+
+type translator struct {
+	dialect Dialect
+	fields  Fields
+	refs    map[int64]*exprpb.Reference
+	now     *time.Time
+}
+
+var comparisons = map[string]string{
+	operators.Equals:        "=",
+	operators.NotEquals:     "<>",
+	operators.Less:          "<",
+	operators.LessEquals:    "<=",
+	operators.Greater:       ">",
+	operators.GreaterEquals: ">=",
+}
+
+// flipped holds the operator to use when the operands are swapped.
+var flipped = map[string]string{
+	"=":  "=",
+	"<>": "<>",
+	"<":  ">",
+	"<=": ">=",
+	">":  "<",
+	">=": "<=",
+}
+
+// condition translates a boolean expression.
+func (t *translator) condition(e *exprpb.Expr) (string, []interface{}, error) {
+	if c := e.GetConstExpr(); c != nil {
+		if _, ok := c.GetConstantKind().(*exprpb.Constant_BoolValue); ok {
+			if c.GetBoolValue() {
+				return "1 = 1", nil, nil
+			}
+			return "1 = 0", nil, nil
+		}
+		return "", nil, errUnsupported
+	}
+
+	c := e.GetCallExpr()
+	if c == nil {
+		return "", nil, errUnsupported
+	}
+	args := c.GetArgs()
+	switch fn := c.GetFunction(); fn {
+	case operators.LogicalAnd, operators.LogicalOr:
+		if len(args) != 2 {
+			return "", nil, errUnsupported
+		}
+		lhs, largs, err := t.condition(args[0])
+		if err != nil {
+			return "", nil, err
+		}
+		rhs, rargs, err := t.condition(args[1])
+		if err != nil {
+			return "", nil, err
+		}
+		op := "AND"
+		if fn == operators.LogicalOr {
+			op = "OR"
+		}
+		return fmt.Sprintf("(%s %s %s)", lhs, op, rhs), append(largs, rargs...), nil
+	case operators.LogicalNot:
+		if len(args) != 1 {
+			return "", nil, errUnsupported
+		}
+		sql, a, err := t.condition(args[0])
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("NOT (%s)", sql), a, nil
+	case operators.In, operators.OldIn:
+		if len(args) != 2 {
+			return "", nil, errUnsupported
+		}
+		return t.in(args[0], args[1])
+	case startsWith, endsWith, contains:
+		if c.GetTarget() == nil || len(args) != 1 {
+			return "", nil, errUnsupported
+		}
+		return t.match(fn, c.GetTarget(), args[0])
+	case resultscel.HasLabelFunc, resultscel.HasAnnotationFunc:
+		if len(args) != 2 {
+			return "", nil, errUnsupported
+		}
+		o, err := t.metadata(fn, args[0], args[1])
+		if err != nil {
+			return "", nil, err
+		}
+		expr, a, err := t.dialect.JSONText(o.field.Column, o.path)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("%s IS NOT NULL", expr), a, nil
+	}
+
+	if op, ok := comparisons[c.GetFunction()]; ok && len(args) == 2 {
+		if op == "=" {
+			if sql, a, err := t.conditionEquals(args[0], args[1]); err == nil {
+				return sql, a, nil
+			}
+			if sql, a, err := t.conditionEquals(args[1], args[0]); err == nil {
+				return sql, a, nil
+			}
+		}
+		return t.compare(op, args[0], args[1])
+	}
+	return "", nil, errUnsupported
+}
+
+// operand is a translated value expression: either a field or a constant.
+type operand struct {
+	field *Field
+	// path within a JSON field.
+	path []interface{}
+	// args of the placeholders of the field column, for fields computed
+	// from other fields.
+	args []interface{}
+	// constant value, set when field is nil.
+	value interface{}
+}
+
+func (t *translator) compare(op string, lhs, rhs *exprpb.Expr) (string, []interface{}, error) {
+	l, err := t.operand(lhs)
+	if err != nil {
+		return "", nil, err
+	}
+	r, err := t.operand(rhs)
+	if err != nil {
+		return "", nil, err
+	}
+	if l.field == nil && r.field == nil {
+		return "", nil, errUnsupported
+	}
+	// Normalize to field <op> value.
+	if l.field == nil {
+		l, r = r, l
+		op = flipped[op]
+	}
+
+	if r.field != nil {
+		// Comparisons between fields are only supported for plain
+		// columns of the same type.
+		if l.field.Type != r.field.Type || l.field.Type == JSON || len(l.path) > 0 || len(r.path) > 0 {
+			return "", nil, errUnsupported
+		}
+		lexpr, largs := l.column()
+		rexpr, rargs := r.column()
+		return fmt.Sprintf("%s %s %s", lexpr, op, rexpr), concat(largs, rargs), nil
+	}
+
+	if l.field.Type == JSON {
+		if len(l.path) == 0 {
+			return "", nil, errUnsupported
+		}
+		switch r.value.(type) {
+		case string, int64, uint64, float64, bool:
+		default:
+			return "", nil, errUnsupported
+		}
+		return t.dialect.JSONCompare(l.field.Column, l.path, op, r.value)
+	}
+	if !compatible(l.field.Type, r.value) {
+		return "", nil, errUnsupported
+	}
+	expr, args := l.column()
+	return fmt.Sprintf("%s %s ?", expr, op), concat(args, []interface{}{arg(r.value)}), nil
+}
+
+// epoch is the value of unset timestamps in CEL.
+var epoch = time.Unix(0, 0).UTC()
+
+// column returns the SQL expression of a field operand, with NULL values of
+// nullable timestamps replaced by the epoch so that they compare as in CEL.
+func (o *operand) column() (string, []interface{}) {
+	if o.field.Nullable && o.field.Type == Timestamp {
+		return fmt.Sprintf("COALESCE(%s, ?)", o.field.Column), concat(o.args, []interface{}{epoch})
+	}
+	return o.field.Column, o.args
+}
+
+// conditionEquals translates the comparison of a field of a condition()
+// with a non-empty string, e.g. condition(data, "Succeeded").reason == "X",
+// into a check that the list of conditions holds a matching element.
+// Comparisons with empty strings match missing conditions and fields, which
+// can't be expressed this way.
+func (t *translator) conditionEquals(lhs, rhs *exprpb.Expr) (string, []interface{}, error) {
+	sel := lhs.GetSelectExpr()
+	c := sel.GetOperand().GetCallExpr()
+	if sel.GetTestOnly() || c.GetFunction() != resultscel.ConditionFunc || len(c.GetArgs()) != 2 {
+		return "", nil, errUnsupported
+	}
+	base, err := t.operand(c.GetArgs()[0])
+	if err != nil {
+		return "", nil, err
+	}
+	ct, err := t.operand(c.GetArgs()[1])
+	if err != nil {
+		return "", nil, err
+	}
+	v, err := t.operand(rhs)
+	if err != nil {
+		return "", nil, err
+	}
+	conditionType, ok := ct.value.(string)
+	if !ok || ct.field != nil || conditionType == "" {
+		return "", nil, errUnsupported
+	}
+	value, ok := v.value.(string)
+	if !ok || v.field != nil || value == "" {
+		return "", nil, errUnsupported
+	}
+	if base.field == nil || base.field.Type != JSON {
+		return "", nil, errUnsupported
+	}
+	path := append(append([]interface{}{}, base.path...), "status", "conditions")
+	elem := map[string]string{"type": conditionType, sel.GetField(): value}
+	return t.dialect.JSONArrayContains(base.field.Column, path, elem)
+}
+
+// metadata resolves the label or annotation of a call to one of the
+// metadata functions of the Results CEL library to a JSON path.
+func (t *translator) metadata(fn string, data, key *exprpb.Expr) (*operand, error) {
+	o, err := t.operand(data)
+	if err != nil {
+		return nil, err
+	}
+	k, err := t.operand(key)
+	if err != nil {
+		return nil, err
+	}
+	name, ok := k.value.(string)
+	if !ok || k.field != nil || o.field == nil || o.field.Type != JSON {
+		return nil, errUnsupported
+	}
+	field := "labels"
+	if fn == resultscel.HasAnnotationFunc || fn == resultscel.AnnotationFunc {
+		field = "annotations"
+	}
+	o.path = append(append([]interface{}{}, o.path...), "metadata", field, name)
+	return o, nil
+}
+
+func (t *translator) in(elem, list *exprpb.Expr) (string, []interface{}, error) {
+	l, err := t.operand(elem)
+	if err != nil {
+		return "", nil, err
+	}
+	if l.field == nil || list.GetListExpr() == nil {
+		return "", nil, errUnsupported
+	}
+	var values []interface{}
+	for _, v := range list.GetListExpr().GetElements() {
+		o, err := t.operand(v)
+		if err != nil {
+			return "", nil, err
+		}
+		if o.field != nil {
+			return "", nil, errUnsupported
+		}
+		values = append(values, o.value)
+	}
+	if len(values) == 0 {
+		return "1 = 0", nil, nil
+	}
+
+	expr := l.field.Column
+	var args []interface{}
+	switch {
+	case l.field.Type == JSON:
+		// Only lists of strings are supported for JSON fields, which
+		// are compared as text.
+		for _, v := range values {
+			if _, ok := v.(string); !ok {
+				return "", nil, errUnsupported
+			}
+		}
+		if len(l.path) == 0 {
+			return "", nil, errUnsupported
+		}
+		expr, args, err = t.dialect.JSONText(l.field.Column, l.path)
+		if err != nil {
+			return "", nil, err
+		}
+	default:
+		expr, args = l.column()
+		for i, v := range values {
+			if !compatible(l.field.Type, v) {
+				return "", nil, errUnsupported
+			}
+			values[i] = arg(v)
+		}
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	return fmt.Sprintf("%s IN (%s)", expr, placeholders), append(args, values...), nil
+}
+
+func (t *translator) match(fn string, target, arg *exprpb.Expr) (string, []interface{}, error) {
+	f, err := t.operand(target)
+	if err != nil {
+		return "", nil, err
+	}
+	v, err := t.operand(arg)
+	if err != nil {
+		return "", nil, err
+	}
+	s, ok := v.value.(string)
+	if f.field == nil || v.field != nil || !ok {
+		return "", nil, errUnsupported
+	}
+
+	expr := f.field.Column
+	var args []interface{}
+	switch f.field.Type {
+	case String:
+		args = f.args
+	case JSON:
+		if len(f.path) == 0 {
+			return "", nil, errUnsupported
+		}
+		expr, args, err = t.dialect.JSONText(f.field.Column, f.path)
+		if err != nil {
+			return "", nil, err
+		}
+	default:
+		return "", nil, errUnsupported
+	}
+	sql, margs, err := t.dialect.Match(expr, fn, s)
+	if err != nil {
+		return "", nil, err
+	}
+	return sql, concat(args, margs), nil
+}
+
+// operand resolves an expression into either a field reference or a
+// constant value.
+func (t *translator) operand(e *exprpb.Expr) (*operand, error) {
+	// Enum values and other constants resolved by the type checker.
+	if ref, ok := t.refs[e.GetId()]; ok && ref.GetValue() != nil {
+		v, err := constant(ref.GetValue())
+		if err != nil {
+			return nil, err
+		}
+		return &operand{value: v}, nil
+	}
+
+	switch e.GetExprKind().(type) {
+	case *exprpb.Expr_ConstExpr:
+		v, err := constant(e.GetConstExpr())
+		if err != nil {
+			return nil, err
+		}
+		return &operand{value: v}, nil
+	case *exprpb.Expr_CallExpr:
+		c := e.GetCallExpr()
+		switch c.GetFunction() {
+		case overloads.TypeConvertTimestamp:
+			if len(c.GetArgs()) != 1 {
+				return nil, errUnsupported
+			}
+			s, ok := c.GetArgs()[0].GetConstExpr().GetConstantKind().(*exprpb.Constant_StringValue)
+			if !ok {
+				return nil, errUnsupported
+			}
+			ts, err := time.Parse(time.RFC3339Nano, s.StringValue)
+			if err != nil {
+				return nil, errUnsupported
+			}
+			return &operand{value: ts.UTC()}, nil
+		case overloads.TypeConvertDuration:
+			if len(c.GetArgs()) == 2 {
+				return t.duration(c.GetArgs()[0], c.GetArgs()[1])
+			}
+			if len(c.GetArgs()) != 1 {
+				return nil, errUnsupported
+			}
+			s, ok := c.GetArgs()[0].GetConstExpr().GetConstantKind().(*exprpb.Constant_StringValue)
+			if !ok {
+				return nil, errUnsupported
+			}
+			d, err := time.ParseDuration(s.StringValue)
+			if err != nil {
+				return nil, errUnsupported
+			}
+			return &operand{value: d}, nil
+		case resultscel.NowFunc:
+			if t.now == nil || len(c.GetArgs()) != 0 {
+				return nil, errUnsupported
+			}
+			return &operand{value: *t.now}, nil
+		case resultscel.AgoFunc:
+			if t.now == nil || len(c.GetArgs()) != 1 {
+				return nil, errUnsupported
+			}
+			s, ok := c.GetArgs()[0].GetConstExpr().GetConstantKind().(*exprpb.Constant_StringValue)
+			if !ok {
+				return nil, errUnsupported
+			}
+			d, err := time.ParseDuration(s.StringValue)
+			if err != nil {
+				return nil, errUnsupported
+			}
+			return &operand{value: t.now.Add(-d)}, nil
+		case resultscel.LabelFunc, resultscel.AnnotationFunc:
+			if len(c.GetArgs()) != 2 {
+				return nil, errUnsupported
+			}
+			o, err := t.metadata(c.GetFunction(), c.GetArgs()[0], c.GetArgs()[1])
+			if err != nil {
+				return nil, err
+			}
+			// Missing labels evaluate to empty strings.
+			expr, args, err := t.dialect.JSONText(o.field.Column, o.path)
+			if err != nil {
+				return nil, err
+			}
+			return &operand{field: &Field{Column: fmt.Sprintf("COALESCE(%s, '')", expr), Type: String}, args: args}, nil
+		case operators.Index:
+			if len(c.GetArgs()) != 2 {
+				return nil, errUnsupported
+			}
+			base, err := t.operand(c.GetArgs()[0])
+			if err != nil {
+				return nil, err
+			}
+			key, err := t.operand(c.GetArgs()[1])
+			if err != nil {
+				return nil, err
+			}
+			if base.field == nil || base.field.Type != JSON || key.field != nil {
+				return nil, errUnsupported
+			}
+			switch k := key.value.(type) {
+			case string, int64:
+				base.path = append(base.path, k)
+			default:
+				return nil, errUnsupported
+			}
+			return base, nil
+		}
+	case *exprpb.Expr_IdentExpr, *exprpb.Expr_SelectExpr:
+		if e.GetSelectExpr().GetTestOnly() {
+			return nil, errUnsupported
+		}
+		return t.field(e)
+	}
+	return nil, errUnsupported
+}
+
+// duration translates the duration between two timestamp columns into a
+// number of seconds, zero if either is NULL as in memory for unset
+// timestamps.
+func (t *translator) duration(start, end *exprpb.Expr) (*operand, error) {
+	s, err := t.operand(start)
+	if err != nil {
+		return nil, err
+	}
+	e, err := t.operand(end)
+	if err != nil {
+		return nil, err
+	}
+	if s.field == nil || e.field == nil || s.field.Type != Timestamp || e.field.Type != Timestamp {
+		return nil, errUnsupported
+	}
+	expr, err := t.dialect.Seconds(s.field.Column, e.field.Column)
+	if err != nil {
+		return nil, err
+	}
+	return &operand{
+		field: &Field{Column: fmt.Sprintf("COALESCE(%s, 0)", expr), Type: Duration},
+		args:  concat(s.args, e.args),
+	}, nil
+}
+
+// field resolves a chain of selections (e.g. result.summary.status) into a
+// Field, descending into JSON documents where needed.
+func (t *translator) field(e *exprpb.Expr) (*operand, error) {
+	var path []string
+	cur := e
+	for cur.GetSelectExpr() != nil {
+		sel := cur.GetSelectExpr()
+		if sel.GetTestOnly() {
+			return nil, errUnsupported
+		}
+		path = append([]string{sel.GetField()}, path...)
+		cur = sel.GetOperand()
+	}
+
+	switch {
+	case cur.GetIdentExpr() != nil:
+		path = append([]string{cur.GetIdentExpr().GetName()}, path...)
+	case cur.GetCallExpr() != nil:
+		// Selection on an index expression, e.g. a["b"].c.
+		base, err := t.operand(cur)
+		if err != nil {
+			return nil, err
+		}
+		if base.field == nil || base.field.Type != JSON {
+			return nil, errUnsupported
+		}
+		for _, p := range path {
+			base.path = append(base.path, p)
+		}
+		return base, nil
+	default:
+		return nil, errUnsupported
+	}
+
+	for i := len(path); i > 0; i-- {
+		f, ok := t.fields[strings.Join(path[:i], ".")]
+		if !ok {
+			continue
+		}
+		if i < len(path) && f.Type != JSON {
+			return nil, errUnsupported
+		}
+		o := &operand{field: &f}
+		for _, p := range path[i:] {
+			o.path = append(o.path, p)
+		}
+		return o, nil
+	}
+	return nil, errUnsupported
+}
+
+func constant(c *exprpb.Constant) (interface{}, error) {
+	switch k := c.GetConstantKind().(type) {
+	case *exprpb.Constant_StringValue:
+		return k.StringValue, nil
+	case *exprpb.Constant_Int64Value:
+		return k.Int64Value, nil
+	case *exprpb.Constant_Uint64Value:
+		return k.Uint64Value, nil
+	case *exprpb.Constant_DoubleValue:
+		return k.DoubleValue, nil
+	case *exprpb.Constant_BoolValue:
+		return k.BoolValue, nil
+	}
+	return nil, errUnsupported
+}
+
+func compatible(t Type, v interface{}) bool {
+	switch v.(type) {
+	case string:
+		return t == String
+	case int64, uint64:
+		return t == Int
+	case time.Time:
+		return t == Timestamp
+	case time.Duration:
+		return t == Duration
+	}
+	return false
+}
+
+// arg converts a constant to a SQL argument. Durations are compared as
+// numbers of seconds.
+func arg(v interface{}) interface{} {
+	if d, ok := v.(time.Duration); ok {
+		return d.Seconds()
+	}
+	return v
+}
+
+// concat returns a new slice holding the elements of the given slices.
+func concat(lists ...[]interface{}) []interface{} {
+	var all []interface{}
+	for _, l := range lists {
+		all = append(all, l...)
+	}
+	return all
+}
@@ -0,0 +1,311 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cel2sql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/go-cmp/cmp"
+	resultscel "github.com/tektoncd/results/pkg/api/server/cel"
+)
+
+var fields = Fields{
+	"result.id":                 {Column: "id", Type: String},
+	"result.create_time":        {Column: "created_time", Type: Timestamp},
+	"result.annotations":        {Column: "annotations", Type: JSON},
+	"result.summary.status":     {Column: "recordsummary_status", Type: Int},
+	"result.summary.start_time": {Column: "recordsummary_start_time", Type: Timestamp, Nullable: true},
+	"result.summary.end_time":   {Column: "recordsummary_end_time", Type: Timestamp, Nullable: true},
+	"data":                      {Column: "data", Type: JSON},
+}
+
+func newEnv(t *testing.T) *cel.Env {
+	t.Helper()
+	env, err := resultscel.NewEnv()
+	if err != nil {
+		t.Fatalf("NewEnv: %v", err)
+	}
+	env, err = env.Extend(cel.Declarations(decls.NewVar("data", decls.Dyn)))
+	if err != nil {
+		t.Fatalf("Extend: %v", err)
+	}
+	return env
+}
+
+func TestConvert(t *testing.T) {
+	env := newEnv(t)
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range []struct {
+		name    string
+		filter  string
+		dialect Dialect
+		want    *Clause
+		exact   bool
+	}{
+		{
+			name:    "equality",
+			filter:  `result.id == "a"`,
+			dialect: SQLite,
+			want:    &Clause{SQL: "id = ?", Args: []interface{}{"a"}},
+			exact:   true,
+		},
+		{
+			name:    "constant first",
+			filter:  `"a" < result.id`,
+			dialect: SQLite,
+			want:    &Clause{SQL: "id > ?", Args: []interface{}{"a"}},
+			exact:   true,
+		},
+		{
+			name:    "logical operators",
+			filter:  `result.id == "a" || !(result.id == "b")`,
+			dialect: SQLite,
+			want:    &Clause{SQL: "(id = ? OR NOT (id = ?))", Args: []interface{}{"a", "b"}},
+			exact:   true,
+		},
+		{
+			name:    "enum",
+			filter:  `result.summary.status == tekton.results.v1alpha2.RecordSummary.Status.SUCCESS`,
+			dialect: SQLite,
+			want:    &Clause{SQL: "recordsummary_status = ?", Args: []interface{}{int64(1)}},
+			exact:   true,
+		},
+		{
+			name:    "timestamp",
+			filter:  `result.create_time > timestamp("2023-01-02T03:04:05Z")`,
+			dialect: Postgres,
+			want:    &Clause{SQL: "created_time > ?", Args: []interface{}{time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)}},
+			exact:   true,
+		},
+		{
+			name:    "in",
+			filter:  `result.id in ["a", "b"]`,
+			dialect: Postgres,
+			want:    &Clause{SQL: "id IN (?, ?)", Args: []interface{}{"a", "b"}},
+			exact:   true,
+		},
+		{
+			name:    "startsWith",
+			filter:  `result.id.startsWith("a_%")`,
+			dialect: Postgres,
+			want:    &Clause{SQL: `id LIKE ? ESCAPE '\'`, Args: []interface{}{`a\_\%%`}},
+			exact:   true,
+		},
+		{
+			name:    "endsWith sqlite",
+			filter:  `result.id.endsWith("a*")`,
+			dialect: SQLite,
+			want:    &Clause{SQL: "id GLOB ?", Args: []interface{}{"*a[*]"}},
+			exact:   true,
+		},
+		{
+			name:    "annotations postgres",
+			filter:  `result.annotations["foo"] == "bar"`,
+			dialect: Postgres,
+			want:    &Clause{SQL: "(jsonb_typeof(annotations -> ?) IN (?) AND annotations ->> ? = ?)", Args: []interface{}{"foo", "string", "foo", "bar"}},
+			exact:   true,
+		},
+		{
+			name:    "json path postgres",
+			filter:  `data.status.conditions[0].reason == "Succeeded"`,
+			dialect: Postgres,
+			want: &Clause{
+				SQL:  "(jsonb_typeof(data -> ? -> ? -> 0 -> ?) IN (?) AND data -> ? -> ? -> 0 ->> ? = ?)",
+				Args: []interface{}{"status", "conditions", "reason", "string", "status", "conditions", "reason", "Succeeded"},
+			},
+			exact: true,
+		},
+		{
+			name:    "json number postgres",
+			filter:  `data.spec.retries > 2`,
+			dialect: Postgres,
+			want: &Clause{
+				SQL:  "(jsonb_typeof(data -> ? -> ?) IN (?) AND data -> ? -> ? > CAST(? AS jsonb))",
+				Args: []interface{}{"spec", "retries", "number", "spec", "retries", "2"},
+			},
+			exact: true,
+		},
+		{
+			name:    "json path sqlite",
+			filter:  `data.metadata.labels["app"] in ["a", "b"]`,
+			dialect: SQLite,
+			want:    &Clause{SQL: "json_extract(CAST(data AS TEXT), ?) IN (?, ?)", Args: []interface{}{`$."metadata"."labels"."app"`, "a", "b"}},
+			exact:   true,
+		},
+		{
+			name:    "json path mysql",
+			filter:  `data.metadata.name == "a"`,
+			dialect: MySQL,
+			want: &Clause{
+				SQL:  "(JSON_TYPE(JSON_EXTRACT(data, ?)) IN (?) AND JSON_UNQUOTE(JSON_EXTRACT(data, ?)) = ?)",
+				Args: []interface{}{`$."metadata"."name"`, "STRING", `$."metadata"."name"`, "a"},
+			},
+			exact: true,
+		},
+		{
+			name:    "json ordering sqlite",
+			filter:  `data.spec.retries < 3`,
+			dialect: SQLite,
+			want: &Clause{
+				SQL:  "(json_type(CAST(data AS TEXT), ?) IN (?, ?) AND json_extract(CAST(data AS TEXT), ?) < ?)",
+				Args: []interface{}{`$."spec"."retries"`, "integer", "real", `$."spec"."retries"`, int64(3)},
+			},
+			exact: true,
+		},
+		{
+			name:    "json inequality sqlite",
+			filter:  `data.metadata.name != "a"`,
+			dialect: SQLite,
+			want: &Clause{
+				SQL:  "json_extract(CAST(data AS TEXT), ?) IS NOT NULL AND NOT (json_type(CAST(data AS TEXT), ?) IN (?) AND json_extract(CAST(data AS TEXT), ?) = ?)",
+				Args: []interface{}{`$."metadata"."name"`, `$."metadata"."name"`, "text", `$."metadata"."name"`, "a"},
+			},
+			exact: true,
+		},
+		{
+			name:    "contains mysql",
+			filter:  `result.id.contains("a%")`,
+			dialect: MySQL,
+			want:    &Clause{SQL: "id LIKE BINARY ?", Args: []interface{}{`%a\%%`}},
+			exact:   true,
+		},
+		{
+			name:    "partial",
+			filter:  `result.id == "a" && size(result.annotations) > 1`,
+			dialect: SQLite,
+			want:    &Clause{SQL: "id = ?", Args: []interface{}{"a"}},
+			exact:   false,
+		},
+		{
+			name:    "unsupported disjunction",
+			filter:  `result.id == "a" || size(result.annotations) > 1`,
+			dialect: SQLite,
+		},
+		{
+			name:    "unknown field",
+			filter:  `result.etag == "a"`,
+			dialect: SQLite,
+		},
+		{
+			name:    "type mismatch",
+			filter:  `result.create_time == result.id`,
+			dialect: SQLite,
+		},
+		{
+			name:    "nullable timestamp",
+			filter:  `result.summary.end_time < timestamp("2021-01-01T00:00:00Z")`,
+			dialect: SQLite,
+			want: &Clause{
+				SQL:  "COALESCE(recordsummary_end_time, ?) < ?",
+				Args: []interface{}{time.Unix(0, 0).UTC(), time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+			},
+			exact: true,
+		},
+		{
+			name:    "duration",
+			filter:  `duration(result.summary.start_time, result.summary.end_time) > duration("10m")`,
+			dialect: SQLite,
+			want: &Clause{
+				SQL:  "COALESCE((julianday(recordsummary_end_time) - julianday(recordsummary_start_time)) * 86400, 0) > ?",
+				Args: []interface{}{float64(600)},
+			},
+			exact: true,
+		},
+		{
+			name:    "ago",
+			filter:  `result.create_time > ago("24h") && result.create_time <= now()`,
+			dialect: Postgres,
+			want:    &Clause{SQL: "created_time > ? AND created_time <= ?", Args: []interface{}{now.Add(-24 * time.Hour), now}},
+			exact:   true,
+		},
+		{
+			name:    "has_label",
+			filter:  `has_label(data, "app")`,
+			dialect: SQLite,
+			want:    &Clause{SQL: "json_extract(CAST(data AS TEXT), ?) IS NOT NULL", Args: []interface{}{`$."metadata"."labels"."app"`}},
+			exact:   true,
+		},
+		{
+			name:    "label",
+			filter:  `label(data, "app") in ["a", "b"]`,
+			dialect: Postgres,
+			want:    &Clause{SQL: "COALESCE(data -> ? -> ? ->> ?, '') IN (?, ?)", Args: []interface{}{"metadata", "labels", "app", "a", "b"}},
+			exact:   true,
+		},
+		{
+			name:    "annotation",
+			filter:  `annotation(data, "ci").startsWith("a")`,
+			dialect: MySQL,
+			want:    &Clause{SQL: "COALESCE(JSON_UNQUOTE(JSON_EXTRACT(data, ?)), '') LIKE BINARY ?", Args: []interface{}{`$."metadata"."annotations"."ci"`, "a%"}},
+			exact:   true,
+		},
+		{
+			name:    "condition postgres",
+			filter:  `condition(data, "Succeeded").reason == "Failed"`,
+			dialect: Postgres,
+			want:    &Clause{SQL: "data -> ? -> ? @> CAST(? AS jsonb)", Args: []interface{}{"status", "conditions", `[{"reason":"Failed","type":"Succeeded"}]`}},
+			exact:   true,
+		},
+		{
+			name:    "condition sqlite",
+			filter:  `"True" == condition(data, "Succeeded").status`,
+			dialect: SQLite,
+			want: &Clause{
+				SQL:  `EXISTS (SELECT 1 FROM json_each(CAST(data AS TEXT), ?) WHERE json_extract(json_each.value, ?) = ? AND json_extract(json_each.value, ?) = ?)`,
+				Args: []interface{}{`$."status"."conditions"`, `$."status"`, "True", `$."type"`, "Succeeded"},
+			},
+			exact: true,
+		},
+		{
+			name:    "condition mysql",
+			filter:  `condition(data, "Succeeded").status == "False"`,
+			dialect: MySQL,
+			want:    &Clause{SQL: "JSON_CONTAINS(JSON_EXTRACT(data, ?), ?)", Args: []interface{}{`$."status"."conditions"`, `[{"status":"False","type":"Succeeded"}]`}},
+			exact:   true,
+		},
+		{
+			name:    "condition inequality",
+			filter:  `condition(data, "Succeeded").status != "True"`,
+			dialect: SQLite,
+		},
+		{
+			name:    "unsupported dialect",
+			filter:  `result.id == "a"`,
+			dialect: Dialect("oracle"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ast, issues := env.Compile(tc.filter)
+			if issues != nil && issues.Err() != nil {
+				// Some cases are intentionally ill-typed, parse them
+				// without checking.
+				ast, issues = env.Parse(tc.filter)
+				if issues != nil && issues.Err() != nil {
+					t.Fatalf("Parse: %v", issues.Err())
+				}
+			}
+			got, exact := Convert(tc.dialect, fields, ast, Now(now))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("-want,+got: %s", diff)
+			}
+			if exact != tc.exact {
+				t.Errorf("exact: want %t, got %t", tc.exact, exact)
+			}
+		})
+	}
+}
@@ -0,0 +1,449 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cel2sql
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Dialect identifies the SQL flavor expressions are generated for. Values
+// match the names reported by gorm's Dialector.Name().
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+)
+
+// Supported reports whether expressions can be generated for the dialect.
+func (d Dialect) Supported() bool {
+	switch d {
+	case Postgres, MySQL, SQLite:
+		return true
+	}
+	return false
+}
+
+// Concat returns an expression concatenating the given SQL expressions.
+func (d Dialect) Concat(parts ...string) string {
+	if d == MySQL {
+		// || is a logical operator in MySQL.
+		return fmt.Sprintf("CONCAT(%s)", strings.Join(parts, ", "))
+	}
+	return strings.Join(parts, " || ")
+}
+
+// JSONText returns an expression extracting the value at path from the JSON
+// column as text.
+func (d Dialect) JSONText(column string, path []interface{}) (string, []interface{}, error) {
+	switch d {
+	case Postgres:
+		return postgresPath(column, path, true)
+	case MySQL:
+		expr, args, err := mysqlPath(column, path)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("JSON_UNQUOTE(%s)", expr), args, nil
+	case SQLite:
+		return sqlitePath(column, path)
+	}
+	return "", nil, fmt.Errorf("unsupported dialect %q", d)
+}
+
+// JSON returns an expression extracting the value at path from the JSON
+// column as JSON text, NULL if there is no value.
+func (d Dialect) JSON(column string, path []interface{}) (string, []interface{}, error) {
+	switch d {
+	case Postgres:
+		expr, args, err := postgresPath(column, path, false)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("CAST(%s AS TEXT)", expr), args, nil
+	case MySQL:
+		expr, args, err := mysqlPath(column, path)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("CAST(%s AS CHAR)", expr), args, nil
+	case SQLite:
+		// Unlike json_extract, the -> operator keeps the JSON representation
+		// of strings and booleans.
+		p, err := jsonPath(path)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("(CAST(%s AS TEXT) -> ?)", column), []interface{}{p}, nil
+	}
+	return "", nil, fmt.Errorf("unsupported dialect %q", d)
+}
+
+// JSONCompare returns a condition comparing the JSON value at path with the
+// given scalar value using the SQL operator op. Values of other JSON types
+// never compare as equal, less or greater, as in CEL, and only differ from
+// the value, rather than following the type ordering of the database.
+func (d Dialect) JSONCompare(column string, path []interface{}, op string, value interface{}) (string, []interface{}, error) {
+	typed, targs, err := d.jsonTyped(column, path, value)
+	if err != nil {
+		return "", nil, err
+	}
+	var (
+		expr string
+		args []interface{}
+	)
+	switch d {
+	case Postgres:
+		// Strings are compared as text, everything else as jsonb so that
+		// numbers and booleans keep their ordering semantics without
+		// risking cast errors on unexpected documents.
+		if s, ok := value.(string); ok {
+			expr, args, err = postgresPath(column, path, true)
+			value = s
+			break
+		}
+		expr, args, err = postgresPath(column, path, false)
+		if err != nil {
+			return "", nil, err
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			return "", nil, err
+		}
+		if op == "<>" {
+			return fmt.Sprintf("%s IS NOT NULL AND NOT (%s AND %s = CAST(? AS jsonb))", expr, typed, expr),
+				concat(args, targs, args, []interface{}{string(b)}), nil
+		}
+		return fmt.Sprintf("(%s AND %s %s CAST(? AS jsonb))", typed, expr, op), concat(targs, args, []interface{}{string(b)}), nil
+	case MySQL:
+		// JSON values compare with SQL scalars by their JSON type, except
+		// strings which have to be unquoted first.
+		expr, args, err = mysqlPath(column, path)
+		if _, ok := value.(string); ok {
+			expr = fmt.Sprintf("JSON_UNQUOTE(%s)", expr)
+		}
+	case SQLite:
+		// json_extract returns native SQL values, which compare directly.
+		expr, args, err = sqlitePath(column, path)
+	default:
+		return "", nil, fmt.Errorf("unsupported dialect %q", d)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if op == "<>" {
+		return fmt.Sprintf("%s IS NOT NULL AND NOT (%s AND %s = ?)", expr, typed, expr),
+			concat(args, targs, args, []interface{}{value}), nil
+	}
+	return fmt.Sprintf("(%s AND %s %s ?)", typed, expr, op), concat(targs, args, []interface{}{value}), nil
+}
+
+// jsonTyped returns a condition checking that the JSON value at path has the
+// JSON type of the given scalar value.
+func (d Dialect) jsonTyped(column string, path []interface{}, value interface{}) (string, []interface{}, error) {
+	var types []string
+	switch value.(type) {
+	case string:
+		types = map[Dialect][]string{Postgres: {"string"}, MySQL: {"STRING"}, SQLite: {"text"}}[d]
+	case int64, uint64, float64:
+		types = map[Dialect][]string{Postgres: {"number"}, MySQL: {"INTEGER", "UNSIGNED INTEGER", "DOUBLE", "DECIMAL"}, SQLite: {"integer", "real"}}[d]
+	case bool:
+		types = map[Dialect][]string{Postgres: {"boolean"}, MySQL: {"BOOLEAN"}, SQLite: {"true", "false"}}[d]
+	default:
+		return "", nil, fmt.Errorf("unsupported value %v", value)
+	}
+	expr, args, err := d.jsonType(column, path)
+	if err != nil {
+		return "", nil, err
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(types)), ", ")
+	for _, t := range types {
+		args = append(args, t)
+	}
+	return fmt.Sprintf("%s IN (%s)", expr, placeholders), args, nil
+}
+
+// jsonType returns an expression yielding the name of the JSON type of the
+// value at path, NULL if there is none. Names differ between dialects.
+func (d Dialect) jsonType(column string, path []interface{}) (string, []interface{}, error) {
+	switch d {
+	case Postgres:
+		expr, args, err := postgresPath(column, path, false)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("jsonb_typeof(%s)", expr), args, nil
+	case MySQL:
+		expr, args, err := mysqlPath(column, path)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("JSON_TYPE(%s)", expr), args, nil
+	case SQLite:
+		p, err := jsonPath(path)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("json_type(CAST(%s AS TEXT), ?)", column), []interface{}{p}, nil
+	}
+	return "", nil, fmt.Errorf("unsupported dialect %q", d)
+}
+
+// JSONIsObject returns a condition checking whether the JSON value at path
+// is an object, NULL if there is no value.
+func (d Dialect) JSONIsObject(column string, path []interface{}) (string, []interface{}, error) {
+	expr, args, err := d.jsonType(column, path)
+	if err != nil {
+		return "", nil, err
+	}
+	object := map[Dialect]string{Postgres: "object", MySQL: "OBJECT", SQLite: "object"}[d]
+	return fmt.Sprintf("%s = ?", expr), append(args, object), nil
+}
+
+// JSONArrayContains returns a condition checking whether the JSON array at
+// path holds an object with the given string fields, false if there is no
+// array.
+func (d Dialect) JSONArrayContains(column string, path []interface{}, elem map[string]string) (string, []interface{}, error) {
+	switch d {
+	case Postgres, MySQL:
+		b, err := json.Marshal([]map[string]string{elem})
+		if err != nil {
+			return "", nil, err
+		}
+		if d == Postgres {
+			expr, args, err := postgresPath(column, path, false)
+			if err != nil {
+				return "", nil, err
+			}
+			return fmt.Sprintf("%s @> CAST(? AS jsonb)", expr), append(args, string(b)), nil
+		}
+		expr, args, err := mysqlPath(column, path)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("JSON_CONTAINS(%s, ?)", expr), append(args, string(b)), nil
+	case SQLite:
+		p, err := jsonPath(path)
+		if err != nil {
+			return "", nil, err
+		}
+		keys := make([]string, 0, len(elem))
+		for k := range elem {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		args := []interface{}{p}
+		terms := make([]string, 0, len(keys))
+		for _, k := range keys {
+			kp, err := jsonPath([]interface{}{k})
+			if err != nil {
+				return "", nil, err
+			}
+			terms = append(terms, "json_extract(json_each.value, ?) = ?")
+			args = append(args, kp, elem[k])
+		}
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(CAST(%s AS TEXT), ?) WHERE %s)", column, strings.Join(terms, " AND ")), args, nil
+	}
+	return "", nil, fmt.Errorf("unsupported dialect %q", d)
+}
+
+// Match returns a case-sensitive condition checking whether expr starts
+// with, ends with or contains the literal s.
+func (d Dialect) Match(expr string, fn string, s string) (string, []interface{}, error) {
+	switch d {
+	case Postgres:
+		p := likeEscaper.Replace(s)
+		return fmt.Sprintf(`%s LIKE ? ESCAPE '\'`, expr), []interface{}{wrap(fn, p, "%")}, nil
+	case MySQL:
+		// Backslash is the default escape character. BINARY makes the
+		// comparison case-sensitive regardless of the column collation.
+		p := likeEscaper.Replace(s)
+		return fmt.Sprintf("%s LIKE BINARY ?", expr), []interface{}{wrap(fn, p, "%")}, nil
+	case SQLite:
+		// LIKE is case-insensitive in SQLite, GLOB is not.
+		p := globEscaper.Replace(s)
+		return fmt.Sprintf("%s GLOB ?", expr), []interface{}{wrap(fn, p, "*")}, nil
+	}
+	return "", nil, fmt.Errorf("unsupported dialect %q", d)
+}
+
+// Seconds returns an expression computing the number of seconds elapsed
+// between the start and end timestamp expressions, NULL if either is NULL.
+func (d Dialect) Seconds(start, end string) (string, error) {
+	switch d {
+	case Postgres:
+		return fmt.Sprintf("EXTRACT(EPOCH FROM (%s - %s))", end, start), nil
+	case MySQL:
+		return fmt.Sprintf("TIMESTAMPDIFF(MICROSECOND, %s, %s) / 1000000", start, end), nil
+	case SQLite:
+		return fmt.Sprintf("(julianday(%s) - julianday(%s)) * 86400", end, start), nil
+	}
+	return "", fmt.Errorf("unsupported dialect %q", d)
+}
+
+// Milliseconds returns an integer expression computing the number of
+// milliseconds elapsed between the start and end timestamp expressions, NULL
+// if either is NULL. Both timestamps are rounded to the nearest millisecond
+// first, so the result matches
+// end.Round(time.Millisecond).Sub(start.Round(time.Millisecond)).Milliseconds().
+func (d Dialect) Milliseconds(start, end string) (string, error) {
+	switch d {
+	case Postgres:
+		return fmt.Sprintf("CAST(ROUND(EXTRACT(EPOCH FROM %s) * 1000) - ROUND(EXTRACT(EPOCH FROM %s) * 1000) AS BIGINT)", end, start), nil
+	case MySQL:
+		return fmt.Sprintf("CAST(ROUND(UNIX_TIMESTAMP(%s) * 1000) - ROUND(UNIX_TIMESTAMP(%s) * 1000) AS SIGNED)", end, start), nil
+	case SQLite:
+		// julianday already rounds timestamps to milliseconds, the rounding
+		// only removes floating point errors.
+		return fmt.Sprintf("CAST(ROUND((julianday(%s) - julianday(%s)) * 86400000) AS INTEGER)", end, start), nil
+	}
+	return "", fmt.Errorf("unsupported dialect %q", d)
+}
+
+// Time buckets supported by TimeBucket.
+const (
+	Hour  = "hour"
+	Day   = "day"
+	Week  = "week"
+	Month = "month"
+)
+
+// TimeBucketLayout is the layout of the values returned by TimeBucket
+// expressions, in UTC.
+const TimeBucketLayout = "2006-01-02 15:04:05"
+
+// TimeBucket returns an expression truncating the timestamp expression to
+// the start of its hour, day, week (starting on Monday) or month, formatted
+// as text with TimeBucketLayout.
+func (d Dialect) TimeBucket(expr string, bucket string) (string, error) {
+	switch bucket {
+	case Hour, Day, Week, Month:
+	default:
+		return "", fmt.Errorf("unsupported time bucket %q", bucket)
+	}
+	switch d {
+	case Postgres:
+		return fmt.Sprintf("to_char(date_trunc('%s', %s AT TIME ZONE 'UTC'), 'YYYY-MM-DD HH24:MI:SS')", bucket, expr), nil
+	case MySQL:
+		switch bucket {
+		case Hour:
+			return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d %%H:00:00')", expr), nil
+		case Day:
+			return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d 00:00:00')", expr), nil
+		case Week:
+			return fmt.Sprintf("DATE_FORMAT(DATE_SUB(%s, INTERVAL WEEKDAY(%s) DAY), '%%Y-%%m-%%d 00:00:00')", expr, expr), nil
+		default:
+			return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-01 00:00:00')", expr), nil
+		}
+	case SQLite:
+		switch bucket {
+		case Hour:
+			return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:00:00', %s)", expr), nil
+		case Day:
+			return fmt.Sprintf("strftime('%%Y-%%m-%%d 00:00:00', %s)", expr), nil
+		case Week:
+			// Go back to the previous Monday, unless already on one.
+			return fmt.Sprintf("strftime('%%Y-%%m-%%d 00:00:00', %s, '-6 days', 'weekday 1')", expr), nil
+		default:
+			return fmt.Sprintf("strftime('%%Y-%%m-01 00:00:00', %s)", expr), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported dialect %q", d)
+}
+
+var (
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	globEscaper = strings.NewReplacer(`[`, `[[]`, `*`, `[*]`, `?`, `[?]`)
+)
+
+func wrap(fn, pattern, wildcard string) string {
+	switch fn {
+	case startsWith:
+		return pattern + wildcard
+	case endsWith:
+		return wildcard + pattern
+	default:
+		return wildcard + pattern + wildcard
+	}
+}
+
+// postgresPath builds a chain of -> operators. Object keys are passed as
+// arguments, list indexes are inlined since they are plain integers.
+func postgresPath(column string, path []interface{}, text bool) (string, []interface{}, error) {
+	var b strings.Builder
+	b.WriteString(column)
+	var args []interface{}
+	for i, p := range path {
+		op := " -> "
+		if text && i == len(path)-1 {
+			op = " ->> "
+		}
+		b.WriteString(op)
+		switch k := p.(type) {
+		case string:
+			b.WriteString("?")
+			args = append(args, k)
+		case int64:
+			fmt.Fprintf(&b, "%d", k)
+		default:
+			return "", nil, fmt.Errorf("unsupported path element %v", p)
+		}
+	}
+	return b.String(), args, nil
+}
+
+// sqlitePath builds a json_extract call, passing the JSON path as argument.
+// Columns are cast to text since JSON values are stored as blobs.
+func sqlitePath(column string, path []interface{}) (string, []interface{}, error) {
+	p, err := jsonPath(path)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("json_extract(CAST(%s AS TEXT), ?)", column), []interface{}{p}, nil
+}
+
+// mysqlPath builds a JSON_EXTRACT call, passing the JSON path as argument.
+func mysqlPath(column string, path []interface{}) (string, []interface{}, error) {
+	p, err := jsonPath(path)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("JSON_EXTRACT(%s, ?)", column), []interface{}{p}, nil
+}
+
+// jsonPath formats path as a JSON path expression understood by both SQLite
+// and MySQL.
+func jsonPath(path []interface{}) (string, error) {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, p := range path {
+		switch k := p.(type) {
+		case string:
+			if strings.ContainsAny(k, `"\`) {
+				return "", fmt.Errorf("unsupported key %q", k)
+			}
+			fmt.Fprintf(&b, `."%s"`, k)
+		case int64:
+			fmt.Fprintf(&b, "[%d]", k)
+		default:
+			return "", fmt.Errorf("unsupported path element %v", p)
+		}
+	}
+	return b.String(), nil
+}
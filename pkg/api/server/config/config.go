@@ -1,11 +1,14 @@
 package config
 
 import (
-	"github.com/spf13/viper"
 	"log"
+	"time"
+
+	"github.com/spf13/viper"
 )
 
 type Config struct {
+	DB_TYPE                  string `mapstructure:"DB_TYPE"`
 	DB_USER                  string `mapstructure:"DB_USER"`
 	DB_PASSWORD              string `mapstructure:"DB_PASSWORD"`
 	DB_HOST                  string `mapstructure:"DB_HOST"`
@@ -13,6 +16,7 @@ type Config struct {
 	DB_NAME                  string `mapstructure:"DB_NAME"`
 	DB_SSLMODE               string `mapstructure:"DB_SSLMODE"`
 	DB_ENABLE_AUTO_MIGRATION bool   `mapstructure:"DB_ENABLE_AUTO_MIGRATION"`
+	RECORD_REVISIONS         bool   `mapstructure:"RECORD_REVISIONS"`
 	SERVER_PORT              string `mapstructure:"SERVER_PORT"`
 	PROMETHEUS_PORT          string `mapstructure:"PROMETHEUS_PORT"`
 	LOG_LEVEL                string `mapstructure:"LOG_LEVEL"`
@@ -22,10 +26,12 @@ type Config struct {
 	AUTH_DISABLE     bool `mapstructure:"AUTH_DISABLE"`
 	AUTH_IMPERSONATE bool `mapstructure:"AUTH_IMPERSONATE"`
 
-	LOGS_API         bool   `mapstructure:"LOGS_API"`
-	LOGS_TYPE        string `mapstructure:"LOGS_TYPE"`
-	LOGS_BUFFER_SIZE int    `mapstructure:"LOGS_BUFFER_SIZE"`
-	LOGS_PATH        string `mapstructure:"LOGS_PATH"`
+	LOGS_API             bool          `mapstructure:"LOGS_API"`
+	LOGS_TYPE            string        `mapstructure:"LOGS_TYPE"`
+	LOGS_BUFFER_SIZE     int           `mapstructure:"LOGS_BUFFER_SIZE"`
+	LOGS_PATH            string        `mapstructure:"LOGS_PATH"`
+	LOGS_COMPRESSION     string        `mapstructure:"LOGS_COMPRESSION"`
+	LOGS_STORING_TIMEOUT time.Duration `mapstructure:"LOGS_STORING_TIMEOUT"`
 
 	S3_BUCKET_NAME        string `mapstructure:"S3_BUCKET_NAME"`
 	S3_ENDPOINT           string `mapstructure:"S3_ENDPOINT"`
@@ -34,6 +40,30 @@ type Config struct {
 	S3_ACCESS_KEY_ID      string `mapstructure:"S3_ACCESS_KEY_ID"`
 	S3_SECRET_ACCESS_KEY  string `mapstructure:"S3_SECRET_ACCESS_KEY"`
 	S3_MULTI_PART_SIZE    int64  `mapstructure:"S3_MULTI_PART_SIZE"`
+
+	GCS_BUCKET_NAME      string `mapstructure:"GCS_BUCKET_NAME"`
+	GCS_ENDPOINT         string `mapstructure:"GCS_ENDPOINT"`
+	GCS_CREDENTIALS_PATH string `mapstructure:"GCS_CREDENTIALS_PATH"`
+	GCS_CHUNK_SIZE       int    `mapstructure:"GCS_CHUNK_SIZE"`
+
+	AZURE_BLOB_CONTAINER    string `mapstructure:"AZURE_BLOB_CONTAINER"`
+	AZURE_BLOB_ENDPOINT     string `mapstructure:"AZURE_BLOB_ENDPOINT"`
+	AZURE_BLOB_ACCOUNT_NAME string `mapstructure:"AZURE_BLOB_ACCOUNT_NAME"`
+	AZURE_BLOB_ACCOUNT_KEY  string `mapstructure:"AZURE_BLOB_ACCOUNT_KEY"`
+	AZURE_BLOB_BLOCK_SIZE   int64  `mapstructure:"AZURE_BLOB_BLOCK_SIZE"`
+
+	RETENTION_POLICY_PATH     string        `mapstructure:"RETENTION_POLICY_PATH"`
+	RETENTION_INTERVAL        time.Duration `mapstructure:"RETENTION_INTERVAL"`
+	RETENTION_BATCH_SIZE      int           `mapstructure:"RETENTION_BATCH_SIZE"`
+	RETENTION_DRY_RUN         bool          `mapstructure:"RETENTION_DRY_RUN"`
+	RETENTION_LEASE_NAMESPACE string        `mapstructure:"RETENTION_LEASE_NAMESPACE"`
+
+	WATCH_EVENT_RETENTION time.Duration `mapstructure:"WATCH_EVENT_RETENTION"`
+	WATCH_POLL_INTERVAL   time.Duration `mapstructure:"WATCH_POLL_INTERVAL"`
+
+	REQUEST_ID_TTL time.Duration `mapstructure:"REQUEST_ID_TTL"`
+
+	SUMMARY_CONFIG_PATH string `mapstructure:"SUMMARY_CONFIG_PATH"`
 }
 
 func Get() *Config {
@@ -0,0 +1,131 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package driver opens connections to the databases supported by the API
+// server. Importing it registers the error spaces of all supported drivers.
+package driver
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/tektoncd/results/pkg/api/server/config"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	// Inject database specific error checking.
+	_ "github.com/tektoncd/results/pkg/api/server/db/errors/mysql"
+	_ "github.com/tektoncd/results/pkg/api/server/db/errors/postgres"
+	_ "github.com/tektoncd/results/pkg/api/server/db/errors/sqlite"
+)
+
+// Supported values of DB_TYPE.
+const (
+	Postgres = "postgres"
+	MySQL    = "mysql"
+	SQLite   = "sqlite"
+)
+
+// Open connects to the database configured by the DB_* settings. DB_TYPE
+// defaults to postgres.
+func Open(cfg *config.Config, gormConfig *gorm.Config) (*gorm.DB, error) {
+	d, err := dialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return gorm.Open(d, gormConfig)
+}
+
+func dialector(cfg *config.Config) (gorm.Dialector, error) {
+	switch strings.ToLower(cfg.DB_TYPE) {
+	case "", Postgres:
+		if err := checkCredentials(cfg); err != nil {
+			return nil, err
+		}
+		return postgres.Open(postgresDSN(cfg)), nil
+	case MySQL:
+		if err := checkCredentials(cfg); err != nil {
+			return nil, err
+		}
+		dsn, err := mysqlDSN(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return gormmysql.New(gormmysql.Config{
+			DSN: dsn,
+			// MySQL rejects current_timestamp defaults on columns with
+			// fractional seconds precision.
+			DisableDatetimePrecision: true,
+		}), nil
+	case SQLite:
+		if cfg.DB_NAME == "" {
+			return nil, fmt.Errorf("must provide DB_NAME, the path of the SQLite database file")
+		}
+		return sqlite.Open(sqliteDSN(cfg)), nil
+	}
+	return nil, fmt.Errorf("unsupported DB_TYPE %q, must be one of %s, %s or %s", cfg.DB_TYPE, Postgres, MySQL, SQLite)
+}
+
+func checkCredentials(cfg *config.Config) error {
+	if cfg.DB_USER == "" || cfg.DB_PASSWORD == "" {
+		return fmt.Errorf("must provide both DB_USER and DB_PASSWORD")
+	}
+	return nil
+}
+
+// postgresDSN returns the connection string for Postgres.
+// DSN derived from https://pkg.go.dev/gorm.io/driver/postgres
+func postgresDSN(cfg *config.Config) string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s", cfg.DB_HOST, cfg.DB_USER, cfg.DB_PASSWORD, cfg.DB_NAME, cfg.DB_PORT, cfg.DB_SSLMODE)
+}
+
+// mysqlDSN returns the connection string for MySQL. DB_HOST may be the path
+// of a unix socket. DB_SSLMODE takes the Postgres sslmode values, which are
+// mapped to their closest MySQL equivalent.
+func mysqlDSN(cfg *config.Config) (string, error) {
+	c := mysql.NewConfig()
+	c.User = cfg.DB_USER
+	c.Passwd = cfg.DB_PASSWORD
+	c.DBName = cfg.DB_NAME
+	c.ParseTime = true
+	if strings.HasPrefix(cfg.DB_HOST, "/") {
+		c.Net = "unix"
+		c.Addr = cfg.DB_HOST
+	} else {
+		c.Net = "tcp"
+		c.Addr = net.JoinHostPort(cfg.DB_HOST, cfg.DB_PORT)
+	}
+	switch cfg.DB_SSLMODE {
+	case "", "disable":
+	case "allow", "prefer":
+		c.TLSConfig = "preferred"
+	case "require":
+		c.TLSConfig = "skip-verify"
+	case "verify-ca", "verify-full":
+		c.TLSConfig = "true"
+	default:
+		return "", fmt.Errorf("unsupported DB_SSLMODE %q", cfg.DB_SSLMODE)
+	}
+	return c.FormatDSN(), nil
+}
+
+// sqliteDSN returns the connection string for SQLite. Foreign keys are
+// enabled on every connection so that deletes cascade.
+func sqliteDSN(cfg *config.Config) string {
+	return fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", cfg.DB_NAME)
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/tektoncd/results/pkg/api/server/config"
+	"gorm.io/gorm"
+)
+
+func TestOpenSQLite(t *testing.T) {
+	db, err := Open(&config.Config{
+		DB_TYPE: SQLite,
+		DB_NAME: filepath.Join(t.TempDir(), "results.db"),
+	}, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if name := db.Dialector.Name(); name != SQLite {
+		t.Errorf("want dialect %s, got %s", SQLite, name)
+	}
+	var fk int
+	if err := db.Raw("PRAGMA foreign_keys").Scan(&fk).Error; err != nil {
+		t.Fatalf("PRAGMA foreign_keys: %v", err)
+	}
+	if fk != 1 {
+		t.Errorf("foreign keys not enabled")
+	}
+}
+
+func TestDialectorErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  *config.Config
+	}{
+		{
+			name: "unknown type",
+			cfg:  &config.Config{DB_TYPE: "oracle"},
+		},
+		{
+			name: "postgres without credentials",
+			cfg:  &config.Config{DB_TYPE: Postgres},
+		},
+		{
+			name: "mysql without credentials",
+			cfg:  &config.Config{DB_TYPE: MySQL},
+		},
+		{
+			name: "mysql with unknown sslmode",
+			cfg:  &config.Config{DB_TYPE: MySQL, DB_USER: "u", DB_PASSWORD: "p", DB_SSLMODE: "maybe"},
+		},
+		{
+			name: "sqlite without file",
+			cfg:  &config.Config{DB_TYPE: SQLite},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := dialector(tc.cfg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestMySQLDSN(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  *config.Config
+		want string
+	}{
+		{
+			name: "tcp",
+			cfg:  &config.Config{DB_USER: "user", DB_PASSWORD: "hunter2", DB_HOST: "db", DB_PORT: "3306", DB_NAME: "results", DB_SSLMODE: "disable"},
+			want: "user:hunter2@tcp(db:3306)/results?parseTime=true",
+		},
+		{
+			name: "unix socket with tls",
+			cfg:  &config.Config{DB_USER: "user", DB_PASSWORD: "hunter2", DB_HOST: "/var/run/mysqld.sock", DB_NAME: "results", DB_SSLMODE: "verify-full"},
+			want: "user:hunter2@unix(/var/run/mysqld.sock)/results?parseTime=true&tls=true",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := mysqlDSN(tc.cfg)
+			if err != nil {
+				t.Fatalf("mysqlDSN: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
@@ -23,16 +23,19 @@ import (
 )
 
 var (
-	errorSpace ErrorSpace
+	errorSpaces []ErrorSpace
 )
 
 // ErrorSpace allows implementations to inject database specific error checking
-// to the application.
+// to the application. Implementations should return codes.Unknown for errors
+// they do not recognize.
 type ErrorSpace func(error) codes.Code
 
-// RegisterErrorSpace registers the ErrorSpace - last one wins.
+// RegisterErrorSpace registers the ErrorSpace of a database driver. Spaces are
+// consulted in registration order until one recognizes the error, so drivers
+// can be registered side by side.
 func RegisterErrorSpace(f ErrorSpace) {
-	errorSpace = f
+	errorSpaces = append(errorSpaces, f)
 }
 
 // Wrap converts database error codes into their corresponding gRPC status
@@ -49,8 +52,13 @@ func Wrap(err error) error {
 	}
 
 	// Fallback to implementation specific codes.
-	if errorSpace != nil {
-		return status.Error(errorSpace(err), err.Error())
+	for _, f := range errorSpaces {
+		if code := f(err); code != codes.Unknown {
+			return status.Error(code, err.Error())
+		}
+	}
+	if len(errorSpaces) > 0 {
+		return status.Error(codes.Unknown, err.Error())
 	}
 
 	return err
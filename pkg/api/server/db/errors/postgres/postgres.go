@@ -0,0 +1,54 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package postgres provides postgres-specific error checking.
+package postgres
+
+import (
+	goerrors "errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/tektoncd/results/pkg/api/server/db/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// postgres converts postgres error codes to gRPC status codes. This is not an
+// exhaustive list.
+// See https://www.postgresql.org/docs/current/errcodes-appendix.html for list
+// of error codes.
+func postgres(err error) codes.Code {
+	var perr *pgconn.PgError
+	if !goerrors.As(err, &perr) {
+		return status.Code(err)
+	}
+
+	switch perr.Code {
+	case "23505": // unique_violation
+		return codes.AlreadyExists
+	case "23503": // foreign_key_violation
+		return codes.FailedPrecondition
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return codes.Aborted
+	}
+	switch perr.Code[:2] {
+	case "22", "23": // data_exception, integrity_constraint_violation
+		return codes.InvalidArgument
+	}
+	return codes.Unknown
+}
+
+func init() {
+	errors.RegisterErrorSpace(postgres)
+}
@@ -38,7 +38,7 @@ func sqlite(err error) codes.Code {
 	switch serr.Code {
 	case sqlite3.ErrConstraint:
 		switch serr.ExtendedCode {
-		case sqlite3.ErrConstraintUnique:
+		case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
 			return codes.AlreadyExists
 		case sqlite3.ErrConstraintForeignKey:
 			return codes.FailedPrecondition
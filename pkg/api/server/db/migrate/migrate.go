@@ -0,0 +1,233 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package migrate applies versioned schema migrations to the Results
+// database. Applied migrations are tracked in the schema_migrations table.
+package migrate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a versioned change to the database schema.
+type Migration struct {
+	// Version orders migrations. Versions must be unique and are never
+	// reused once released.
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	// Down reverts Up. Migrations without Down cannot be reverted.
+	Down func(tx *gorm.DB) error
+	// DisableTransaction runs the migration outside of a transaction, which
+	// statements like CREATE INDEX CONCURRENTLY require.
+	DisableTransaction bool
+}
+
+func (m *Migration) String() string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
+
+// schemaMigration records an applied migration.
+type schemaMigration struct {
+	Version     int64  `gorm:"primaryKey;autoIncrement:false;"`
+	Name        string `gorm:"size:256;"`
+	AppliedTime time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// lockID identifies the Postgres advisory lock serializing migrations run by
+// concurrent API server replicas.
+const lockID = 0x74656b746f6e // "tekton"
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []*Migration
+}
+
+// New returns a Migrator for the migrations required by this version of the
+// API server.
+func New(db *gorm.DB) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Latest returns the schema version required by this version of the API
+// server.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the current schema version of the database, 0 if no
+// migration was applied yet.
+func (m *Migrator) Version() (int64, error) {
+	if !m.db.Migrator().HasTable(&schemaMigration{}) {
+		return 0, nil
+	}
+	var version int64
+	if err := m.db.Model(&schemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error; err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// Check returns an error if any of the migrations required by this version
+// of the API server is not applied to the database. It never writes to the
+// database, so that it can run with read-only credentials.
+func (m *Migrator) Check() error {
+	pending, err := m.Plan()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		names := make([]string, 0, len(pending))
+		for _, mig := range pending {
+			names = append(names, mig.String())
+		}
+		return fmt.Errorf("database schema is missing migrations %s, apply them with `api migrate up`", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// Plan returns the migrations Up would apply, in order.
+func (m *Migrator) Plan() ([]*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var pending []*Migration
+	for _, mig := range m.migrations {
+		if !applied[mig.Version] {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies all pending migrations in order and returns them.
+func (m *Migrator) Up() ([]*Migration, error) {
+	if err := m.db.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+	pending, err := m.Plan()
+	if err != nil {
+		return nil, err
+	}
+	var done []*Migration
+	for _, mig := range pending {
+		ok, err := m.run(mig, true)
+		if err != nil {
+			return done, fmt.Errorf("error applying migration %s: %w", mig, err)
+		}
+		if ok {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// Down reverts the last n applied migrations, most recent first, and returns
+// them.
+func (m *Migrator) Down(n int) ([]*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var revert []*Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(revert) < n; i-- {
+		if mig := m.migrations[i]; applied[mig.Version] {
+			if mig.Down == nil {
+				return nil, fmt.Errorf("migration %s cannot be reverted", mig)
+			}
+			revert = append(revert, mig)
+		}
+	}
+	var done []*Migration
+	for _, mig := range revert {
+		ok, err := m.run(mig, false)
+		if err != nil {
+			return done, fmt.Errorf("error reverting migration %s: %w", mig, err)
+		}
+		if ok {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// applied returns the set of applied migration versions, which is empty until
+// schema_migrations is created by Up.
+func (m *Migrator) applied() (map[int64]bool, error) {
+	if !m.db.Migrator().HasTable(&schemaMigration{}) {
+		return map[int64]bool{}, nil
+	}
+	var versions []int64
+	if err := m.db.Model(&schemaMigration{}).Pluck("version", &versions).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	return applied, nil
+}
+
+// run applies or reverts a single migration and updates schema_migrations.
+// It reports false if another process already did so concurrently.
+func (m *Migrator) run(mig *Migration, up bool) (bool, error) {
+	step := func(tx *gorm.DB) (bool, error) {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockID).Error; err != nil {
+				return false, err
+			}
+		}
+		var count int64
+		if err := tx.Model(&schemaMigration{}).Where("version = ?", mig.Version).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if (count > 0) == up {
+			return false, nil
+		}
+		if up {
+			if err := mig.Up(tx); err != nil {
+				return false, err
+			}
+			return true, tx.Create(&schemaMigration{Version: mig.Version, Name: mig.Name, AppliedTime: time.Now()}).Error
+		}
+		if err := mig.Down(tx); err != nil {
+			return false, err
+		}
+		return true, tx.Delete(&schemaMigration{Version: mig.Version}).Error
+	}
+
+	if mig.DisableTransaction {
+		return step(m.db)
+	}
+	var ok bool
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		ok, err = step(tx)
+		return err
+	})
+	return ok, err
+}
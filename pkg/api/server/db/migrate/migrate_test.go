@@ -0,0 +1,161 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/test"
+	"gorm.io/gorm"
+)
+
+func TestMigrations(t *testing.T) {
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Errorf("migration %s must have a higher version than %s", migrations[i], migrations[i-1])
+		}
+	}
+}
+
+func TestUp(t *testing.T) {
+	gdb := test.NewDB(t)
+	m := New(gdb)
+
+	if err := m.Check(); err == nil {
+		t.Error("Check: expected error for an empty database")
+	}
+	if gdb.Migrator().HasTable(&schemaMigration{}) {
+		t.Error("Check must not create schema_migrations")
+	}
+	plan, err := m.Plan()
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	done, err := m.Up()
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if diff := cmp.Diff(versions(plan), versions(done)); diff != "" {
+		t.Errorf("Up did not apply the planned migrations (-want,+got): %s", diff)
+	}
+	if err := m.Check(); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+	for _, model := range []interface{}{&db.Result{}, &db.Record{}, &db.RecordRevision{}, &db.Change{}, &db.Request{}, &db.LogChunk{}} {
+		if !gdb.Migrator().HasTable(model) {
+			t.Errorf("missing table for %T", model)
+		}
+	}
+
+	// Up is idempotent.
+	done, err = m.Up()
+	if err != nil || len(done) != 0 {
+		t.Errorf("Up: want no migration, got (%v, %v)", done, err)
+	}
+}
+
+func TestCheckMissingMigration(t *testing.T) {
+	step := func(*gorm.DB) error { return nil }
+	m := &Migrator{
+		db: test.NewDB(t),
+		migrations: []*Migration{
+			{Version: 1, Name: "a", Up: step},
+			{Version: 2, Name: "b", Up: step},
+			{Version: 3, Name: "c", Up: step},
+		},
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	// The latest migration is applied, but not an older one.
+	if err := m.db.Delete(&schemaMigration{Version: 2}).Error; err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := m.Check(); err == nil {
+		t.Error("Check: expected error for a missing migration")
+	}
+}
+
+func TestUpExistingSchema(t *testing.T) {
+	// Databases created with AutoMigrate before migrations were versioned.
+	gdb := test.NewDB(t)
+	if err := gdb.AutoMigrate(&db.Result{}, &db.Record{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	if err := gdb.Create(&db.Result{Parent: "a", ID: "1", Name: "b"}).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	m := New(gdb)
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	var count int64
+	if err := gdb.Model(&db.Result{}).Count(&count).Error; err != nil || count != 1 {
+		t.Errorf("want 1 result, got (%d, %v)", count, err)
+	}
+}
+
+func TestDown(t *testing.T) {
+	var log []string
+	step := func(s string) func(*gorm.DB) error {
+		return func(*gorm.DB) error {
+			log = append(log, s)
+			return nil
+		}
+	}
+	m := &Migrator{
+		db: test.NewDB(t),
+		migrations: []*Migration{
+			{Version: 1, Name: "a", Up: step("up 1"), Down: step("down 1")},
+			{Version: 2, Name: "b", Up: step("up 2"), Down: step("down 2")},
+			{Version: 3, Name: "c", Up: step("up 3")},
+		},
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if _, err := m.Down(1); err == nil {
+		t.Error("Down: expected error for irreversible migration")
+	}
+
+	m.migrations = m.migrations[:2]
+	done, err := m.Down(5)
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if diff := cmp.Diff([]int64{2, 1}, versions(done)); diff != "" {
+		t.Errorf("-want,+got: %s", diff)
+	}
+	version, err := m.Version()
+	if err != nil || version != 3 {
+		// Version 3 is unknown to the migrator and stays applied.
+		t.Errorf("Version: want 3, got (%d, %v)", version, err)
+	}
+	want := []string{"up 1", "up 2", "up 3", "down 2", "down 1"}
+	if diff := cmp.Diff(want, log); diff != "" {
+		t.Errorf("-want,+got: %s", diff)
+	}
+}
+
+func versions(migrations []*Migration) []int64 {
+	var out []int64
+	for _, m := range migrations {
+		out = append(out, m.Version)
+	}
+	return out
+}
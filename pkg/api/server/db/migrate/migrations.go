@@ -0,0 +1,256 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"time"
+
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"gorm.io/gorm"
+)
+
+// migrations are the schema migrations required by the API server, sorted by
+// version. Migrations define their own snapshots of the models they change
+// so that they keep working as the models in the db package evolve.
+var migrations = []*Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		// The schema previously managed by gorm's AutoMigrate. Existing
+		// databases are brought up to date instead of failing.
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&resultV1{}, &recordV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&recordV1{}, &resultV1{})
+		},
+	},
+	{
+		Version: 2,
+		Name:    "record_revisions",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&recordRevisionV2{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&recordRevisionV2{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "changes",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&changeV3{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&changeV3{})
+		},
+	},
+	{
+		Version: 4,
+		Name:    "requests",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&requestV4{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&requestV4{})
+		},
+	},
+	{
+		Version: 5,
+		Name:    "result_holds",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&resultV5{})
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"Retain", "HoldUntil"} {
+				if err := tx.Migrator().DropColumn(&resultV5{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 6,
+		Name:    "log_chunks",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&logChunkV6{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&logChunkV6{})
+		},
+	},
+	{
+		Version: 7,
+		Name:    "changes_by_parent",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&changeV7{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropIndex(&changeV7{}, "changes_by_parent")
+		},
+	},
+}
+
+type resultV1 struct {
+	Parent      string `gorm:"primaryKey;uniqueIndex:results_by_name,priority:1;size:64;"`
+	ID          string `gorm:"primaryKey;size:64;"`
+	Name        string `gorm:"uniqueIndex:results_by_name,priority:2;size:64;"`
+	Annotations db.Annotations
+
+	CreatedTime time.Time `gorm:"default:current_timestamp;"`
+	UpdatedTime time.Time `gorm:"default:current_timestamp;"`
+
+	Summary recordSummaryV1 `gorm:"embedded;embeddedPrefix:recordsummary_;"`
+
+	Etag string `gorm:"size:128;"`
+}
+
+func (resultV1) TableName() string {
+	return "results"
+}
+
+type recordSummaryV1 struct {
+	Record      string `gorm:"size:256;"`
+	Type        string `gorm:"size:768;"`
+	StartTime   *time.Time
+	EndTime     *time.Time
+	Status      int32
+	Annotations db.Annotations
+}
+
+type recordV1 struct {
+	Result     resultV1 `gorm:"foreignKey:Parent,ResultID;references:Parent,ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Parent     string   `gorm:"primaryKey;uniqueIndex:records_by_name,priority:1;size:64;"`
+	ResultID   string   `gorm:"primaryKey;size:64;"`
+	ResultName string   `gorm:"uniqueIndex:records_by_name,priority:2;size:64;"`
+
+	ID   string `gorm:"primaryKey;size:64;"`
+	Name string `gorm:"index:records_by_name,priority:3;size:64;"`
+
+	Type string `gorm:"size:768;"`
+	Data db.JSON
+
+	CreatedTime time.Time `gorm:"default:current_timestamp;"`
+	UpdatedTime time.Time `gorm:"default:current_timestamp;"`
+
+	Etag string `gorm:"size:128;"`
+}
+
+func (recordV1) TableName() string {
+	return "records"
+}
+
+type recordRevisionV2 struct {
+	Record   recordV1 `gorm:"foreignKey:Parent,ResultID,RecordID;references:Parent,ResultID,ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Parent   string   `gorm:"primaryKey;size:64;"`
+	ResultID string   `gorm:"primaryKey;size:64;"`
+	RecordID string   `gorm:"primaryKey;size:64;"`
+	ID       string   `gorm:"primaryKey;size:64;"`
+
+	Type        string `gorm:"size:768;"`
+	Data        db.JSON
+	Etag        string `gorm:"size:128;"`
+	UpdatedTime time.Time
+
+	CreatedTime time.Time `gorm:"default:current_timestamp;"`
+}
+
+func (recordRevisionV2) TableName() string {
+	return "record_revisions"
+}
+
+type changeV3 struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement;"`
+
+	Resource   string `gorm:"size:16;"`
+	Type       string `gorm:"size:16;"`
+	Parent     string `gorm:"size:64;"`
+	ResultName string `gorm:"size:64;"`
+
+	Object []byte
+
+	CreatedTime time.Time `gorm:"default:current_timestamp;index;"`
+}
+
+func (changeV3) TableName() string {
+	return "changes"
+}
+
+type requestV4 struct {
+	Parent string `gorm:"primaryKey;size:256;"`
+	ID     string `gorm:"primaryKey;size:128;"`
+
+	Name string `gorm:"size:256;"`
+	Hash string `gorm:"size:64;"`
+
+	CreatedTime time.Time `gorm:"default:current_timestamp;index;"`
+}
+
+func (requestV4) TableName() string {
+	return "requests"
+}
+
+type resultV5 struct {
+	Parent      string `gorm:"primaryKey;uniqueIndex:results_by_name,priority:1;size:64;"`
+	ID          string `gorm:"primaryKey;size:64;"`
+	Name        string `gorm:"uniqueIndex:results_by_name,priority:2;size:64;"`
+	Annotations db.Annotations
+
+	CreatedTime time.Time `gorm:"default:current_timestamp;"`
+	UpdatedTime time.Time `gorm:"default:current_timestamp;"`
+
+	Summary recordSummaryV1 `gorm:"embedded;embeddedPrefix:recordsummary_;"`
+
+	Retain    bool
+	HoldUntil *time.Time
+
+	Etag string `gorm:"size:128;"`
+}
+
+func (resultV5) TableName() string {
+	return "results"
+}
+
+type logChunkV6 struct {
+	Record   recordV1 `gorm:"foreignKey:Parent,ResultID,RecordID;references:Parent,ResultID,ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Parent   string   `gorm:"primaryKey;size:64;"`
+	ResultID string   `gorm:"primaryKey;size:64;"`
+	RecordID string   `gorm:"primaryKey;size:64;"`
+	Index    int64    `gorm:"primaryKey;autoIncrement:false;column:chunk_index;"`
+
+	Data []byte
+}
+
+func (logChunkV6) TableName() string {
+	return "log_chunks"
+}
+
+type changeV7 struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement;index:changes_by_parent,priority:4;"`
+
+	Resource   string `gorm:"size:16;index:changes_by_parent,priority:1;"`
+	Type       string `gorm:"size:16;"`
+	Parent     string `gorm:"size:64;index:changes_by_parent,priority:2;"`
+	ResultName string `gorm:"size:64;index:changes_by_parent,priority:3;"`
+
+	Object []byte
+
+	CreatedTime time.Time `gorm:"default:current_timestamp;index;"`
+}
+
+func (changeV7) TableName() string {
+	return "changes"
+}
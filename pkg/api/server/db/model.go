@@ -21,20 +21,27 @@ import (
 	"errors"
 	"fmt"
 	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
 // Result is the database model of a Result.
 type Result struct {
-	Parent      string      `gorm:"primaryKey;uniqueIndex:results_by_name,priority:1;size:64;"`
-	ID          string      `gorm:"primaryKey;size:64;"`
-	Name        string      `gorm:"uniqueIndex:results_by_name,priority:2;size:64;"`
-	Annotations Annotations `gorm:"type:jsonb;"`
+	Parent      string `gorm:"primaryKey;uniqueIndex:results_by_name,priority:1;size:64;"`
+	ID          string `gorm:"primaryKey;size:64;"`
+	Name        string `gorm:"uniqueIndex:results_by_name,priority:2;size:64;"`
+	Annotations Annotations
 
 	CreatedTime time.Time `gorm:"default:current_timestamp;"`
 	UpdatedTime time.Time `gorm:"default:current_timestamp;"`
 
 	Summary RecordSummary `gorm:"embedded;embeddedPrefix:recordsummary_;"`
 
+	// Retain and HoldUntil hold the Result, preventing its deletion.
+	Retain    bool
+	HoldUntil *time.Time
+
 	Etag string `gorm:"size:128;"`
 }
 
@@ -47,7 +54,7 @@ type RecordSummary struct {
 	StartTime   *time.Time
 	EndTime     *time.Time
 	Status      int32
-	Annotations Annotations `gorm:"type:jsonb;"`
+	Annotations Annotations
 }
 
 func (r Result) String() string {
@@ -70,7 +77,7 @@ type Record struct {
 	// Napkin Math (with a bit of buffer): 256 (DNS Subdomain) * 3 (Group +
 	// Version + Kind).
 	Type string `gorm:"size:768;"`
-	Data []byte `gorm:"type:jsonb;"`
+	Data JSON
 
 	CreatedTime time.Time `gorm:"default:current_timestamp;"`
 	UpdatedTime time.Time `gorm:"default:current_timestamp;"`
@@ -78,9 +85,89 @@ type Record struct {
 	Etag string `gorm:"size:128;"`
 }
 
+// RecordRevision is the database model of a previous revision of a Record,
+// stored when the Record is updated.
+type RecordRevision struct {
+	// Record is used to create the relationship between the Record and
+	// RecordRevisions table.
+	Record   Record `gorm:"foreignKey:Parent,ResultID,RecordID;references:Parent,ResultID,ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Parent   string `gorm:"primaryKey;size:64;"`
+	ResultID string `gorm:"primaryKey;size:64;"`
+	RecordID string `gorm:"primaryKey;size:64;"`
+	ID       string `gorm:"primaryKey;size:64;"`
+
+	// Type, Data, Etag and UpdatedTime hold the Record as of the revision.
+	Type        string `gorm:"size:768;"`
+	Data        JSON
+	Etag        string `gorm:"size:128;"`
+	UpdatedTime time.Time
+
+	// CreatedTime is the time the Record was updated, replacing the
+	// revision.
+	CreatedTime time.Time `gorm:"default:current_timestamp;"`
+}
+
+// Change is the database model of a change made to a Result or a Record,
+// read by Watch streams. Changes are ordered by ID.
+type Change struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement;index:changes_by_parent,priority:4;"`
+
+	// Resource is the kind of resource changed, results or records.
+	Resource   string `gorm:"size:16;index:changes_by_parent,priority:1;"`
+	Type       string `gorm:"size:16;"`
+	Parent     string `gorm:"size:64;index:changes_by_parent,priority:2;"`
+	ResultName string `gorm:"size:64;index:changes_by_parent,priority:3;"`
+
+	// Object is the serialized API message of the resource after the change,
+	// or before it for deletions.
+	Object []byte
+
+	CreatedTime time.Time `gorm:"default:current_timestamp;index;"`
+}
+
+// Request is the database model of a create request identified by a
+// client-provided request ID, remembered so that retries return the resource
+// created by the first attempt.
+type Request struct {
+	// Parent is the parent of the created resource, request IDs are unique
+	// within it.
+	Parent string `gorm:"primaryKey;size:256;"`
+	ID     string `gorm:"primaryKey;size:128;"`
+
+	// Name is the name of the resource created by the request.
+	Name string `gorm:"size:256;"`
+	// Hash is the hash of the request, telling apart retries from requests
+	// reusing an ID.
+	Hash string `gorm:"size:64;"`
+
+	CreatedTime time.Time `gorm:"default:current_timestamp;index;"`
+}
+
+// LogChunk is the database model of a chunk of a log stored by the Database
+// log type. Logs are split into chunks of the same size, in order, the last
+// chunk being filled up as the log is appended to.
+type LogChunk struct {
+	// Record is used to create the relationship between the Record and
+	// LogChunks table.
+	Record   Record `gorm:"foreignKey:Parent,ResultID,RecordID;references:Parent,ResultID,ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Parent   string `gorm:"primaryKey;size:64;"`
+	ResultID string `gorm:"primaryKey;size:64;"`
+	RecordID string `gorm:"primaryKey;size:64;"`
+	// Index is the position of the chunk in the log.
+	Index int64 `gorm:"primaryKey;autoIncrement:false;column:chunk_index;"`
+
+	Data []byte
+}
+
 // Annotations is a custom-defined type of a gorm model field.
 type Annotations map[string]string
 
+// GormDBDataType returns the column type of Annotations for the database
+// dialect. This implements the schema.GormDBDataTypeInterface.
+func (Annotations) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	return jsonDataType(db)
+}
+
 // Scan resolves serialized data read from database into an Annotation.
 // This implements the sql.Scanner interface.
 func (ann *Annotations) Scan(value interface{}) error {
@@ -107,3 +194,37 @@ func (ann Annotations) Value() (driver.Value, error) {
 	}
 	return bytes, nil
 }
+
+// JSON is a JSON document stored in its serialized form.
+type JSON []byte
+
+// GormDBDataType returns the column type of JSON for the database dialect.
+// This implements the schema.GormDBDataTypeInterface.
+func (JSON) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	return jsonDataType(db)
+}
+
+// Scan reads a JSON document stored as bytes or returned as text by a
+// database expression. This implements sql.Scanner.
+func (j *JSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append(JSON(nil), v...)
+	case string:
+		*j = JSON(v)
+	default:
+		return fmt.Errorf("wanted []byte or string, got %T: %+v", value, value)
+	}
+	return nil
+}
+
+// jsonDataType returns the column type used for JSON documents. Postgres
+// stores them as jsonb, MySQL has no jsonb type and uses json instead.
+func jsonDataType(db *gorm.DB) string {
+	if db.Dialector.Name() == "mysql" {
+		return "json"
+	}
+	return "jsonb"
+}
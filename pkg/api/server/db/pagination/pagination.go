@@ -16,17 +16,46 @@ package pagination
 
 import (
 	"encoding/base64"
+	"fmt"
 	"math"
+	"time"
 
 	pb "github.com/tektoncd/results/pkg/api/server/db/pagination/proto/internal_go_proto"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// EncodeToken encodes a name + filter to an opaque page token
-func EncodeToken(name, filter string) (token string, err error) {
+// tokenVersion is the format of the tokens issued. Tokens of version 0 named
+// the first item of the next page, rather than the last item returned, and
+// can't be resumed from.
+const tokenVersion = 1
+
+// Token identifies where a List query left off.
+type Token struct {
+	// Name is the ID of the last item returned.
+	Name   string
+	Filter string
+	// OrderBy is the order_by of the query the token was issued for.
+	OrderBy string
+	// Keys are the values of the order_by fields of the last item returned.
+	// Supported types are nil, string, int64, float64 and time.Time.
+	Keys []interface{}
+}
+
+// EncodeToken encodes a token to an opaque page token string.
+func EncodeToken(t *Token) (token string, err error) {
 	pi := &pb.ListPageIdentifier{
-		Name:   name,
-		Filter: filter,
+		Name:    t.Name,
+		Filter:  t.Filter,
+		OrderBy: t.OrderBy,
+		Version: tokenVersion,
+	}
+	for _, k := range t.Keys {
+		key, err := encodeKey(k)
+		if err != nil {
+			return "", err
+		}
+		pi.Keys = append(pi.Keys, key)
 	}
 	var tokenByte []byte
 	if tokenByte, err = proto.Marshal(pi); err != nil {
@@ -37,21 +66,71 @@ func EncodeToken(name, filter string) (token string, err error) {
 	return base64.RawURLEncoding.EncodeToString(encodedResult), nil
 }
 
-// DecodeToken decodes an opaque page token into its name and filter parts.
-func DecodeToken(token string) (name, filter string, err error) {
-	var encodedToken []byte
-	if encodedToken, err = base64.RawURLEncoding.DecodeString(token); err != nil {
-		return "", "", err
+// DecodeToken decodes an opaque page token.
+func DecodeToken(token string) (*Token, error) {
+	encodedToken, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
 	}
 	tokenByte := make([]byte, base64.RawURLEncoding.DecodedLen(len(encodedToken)))
 	if _, err = base64.RawURLEncoding.Decode(tokenByte, encodedToken); err != nil {
-		return "", "", err
+		return nil, err
 	}
 	pi := &pb.ListPageIdentifier{}
 	if err = proto.Unmarshal(tokenByte, pi); err != nil {
-		return "", "", err
+		return nil, err
+	}
+	if pi.GetVersion() != tokenVersion {
+		return nil, fmt.Errorf("unsupported token version %d, restart listing without it", pi.GetVersion())
+	}
+	t := &Token{
+		Name:    pi.GetName(),
+		Filter:  pi.GetFilter(),
+		OrderBy: pi.GetOrderBy(),
+	}
+	for _, key := range pi.GetKeys() {
+		k, err := decodeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		t.Keys = append(t.Keys, k)
+	}
+	return t, nil
+}
+
+func encodeKey(k interface{}) (*pb.SortKey, error) {
+	switch v := k.(type) {
+	case nil:
+		return &pb.SortKey{Value: &pb.SortKey_NullValue{NullValue: true}}, nil
+	case string:
+		return &pb.SortKey{Value: &pb.SortKey_StringValue{StringValue: v}}, nil
+	case int64:
+		return &pb.SortKey{Value: &pb.SortKey_IntValue{IntValue: v}}, nil
+	case float64:
+		return &pb.SortKey{Value: &pb.SortKey_DoubleValue{DoubleValue: v}}, nil
+	case time.Time:
+		return &pb.SortKey{Value: &pb.SortKey_TimeValue{TimeValue: timestamppb.New(v)}}, nil
+	}
+	return nil, fmt.Errorf("unsupported sort key type %T", k)
+}
+
+func decodeKey(key *pb.SortKey) (interface{}, error) {
+	switch v := key.GetValue().(type) {
+	case *pb.SortKey_NullValue:
+		return nil, nil
+	case *pb.SortKey_StringValue:
+		return v.StringValue, nil
+	case *pb.SortKey_IntValue:
+		return v.IntValue, nil
+	case *pb.SortKey_DoubleValue:
+		return v.DoubleValue, nil
+	case *pb.SortKey_TimeValue:
+		if err := v.TimeValue.CheckValid(); err != nil {
+			return nil, err
+		}
+		return v.TimeValue.AsTime(), nil
 	}
-	return pi.GetName(), pi.GetFilter(), err
+	return nil, fmt.Errorf("unsupported sort key %v", key)
 }
 
 // Batcher suggests dynamic batch sizes for list queries.
@@ -17,14 +17,19 @@ package pagination
 import (
 	"strconv"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestEncodeDecodeToken(t *testing.T) {
-	name := "foo"
-	filter := "bar"
-	token := "Q2dObWIyOFNBMkpoY2c"
+	in := &Token{
+		Name:   "foo",
+		Filter: "bar",
+	}
+	token := "Q2dObWIyOFNBMkpoY2lnQg"
 
-	gotToken, err := EncodeToken(name, filter)
+	gotToken, err := EncodeToken(in)
 	if err != nil {
 		t.Fatalf("EncodeToken: %v", err)
 	}
@@ -32,12 +37,43 @@ func TestEncodeDecodeToken(t *testing.T) {
 		t.Errorf("EncodeToken want: %s, got %s", token, gotToken)
 	}
 
-	gotName, gotFilter, err := DecodeToken(gotToken)
+	got, err := DecodeToken(gotToken)
 	if err != nil {
 		t.Fatalf("DecodeToken: %v", err)
 	}
-	if (name != gotName) || (filter != gotFilter) {
-		t.Errorf("EncodeToken want: (%s, %s), got (%s, %s)", name, gotName, filter, gotFilter)
+	if diff := cmp.Diff(in, got); diff != "" {
+		t.Errorf("-want,+got: %s", diff)
+	}
+}
+
+func TestDecodeTokenVersion(t *testing.T) {
+	// Issued before tokens were versioned, for the first item of the next
+	// page.
+	if _, err := DecodeToken("Q2dObWIyOFNBMkpoY2c"); err == nil {
+		t.Error("DecodeToken: expected error for a token without version")
+	}
+}
+
+func TestEncodeDecodeTokenKeys(t *testing.T) {
+	in := &Token{
+		Name:    "foo",
+		OrderBy: "created_time desc",
+		Keys:    []interface{}{nil, "a", int64(1), 1.5, time.Date(2023, 1, 2, 3, 4, 5, 6, time.UTC)},
+	}
+	token, err := EncodeToken(in)
+	if err != nil {
+		t.Fatalf("EncodeToken: %v", err)
+	}
+	got, err := DecodeToken(token)
+	if err != nil {
+		t.Fatalf("DecodeToken: %v", err)
+	}
+	if diff := cmp.Diff(in, got); diff != "" {
+		t.Errorf("-want,+got: %s", diff)
+	}
+
+	if _, err := EncodeToken(&Token{Keys: []interface{}{struct{}{}}}); err == nil {
+		t.Error("EncodeToken: expected error for unsupported key type")
 	}
 }
 
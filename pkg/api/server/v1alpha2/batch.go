@@ -0,0 +1,309 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/auth"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/record"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/result"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// maxBatchSize is the maximum number of items of a batch request.
+const maxBatchSize = 1000
+
+// BatchCreateRecords creates several Records in a single transaction.
+func (s *Server) BatchCreateRecords(ctx context.Context, req *pb.BatchCreateRecordsRequest) (*pb.BatchCreateRecordsResponse, error) {
+	b, err := newBatch(req.GetParent(), len(req.GetRequests()), req.GetAllOrNothing())
+	if err != nil {
+		return nil, err
+	}
+	check := b.checker(ctx, s.auth, auth.PermissionCreate)
+	getResultID := b.resultIDs(s.getResultID)
+
+	stores := make([]*db.Record, len(req.GetRequests()))
+	out := make([]*pb.Record, len(req.GetRequests()))
+	crs := make([]*createRequest, len(req.GetRequests()))
+	for i, r := range req.GetRequests() {
+		var err error
+		if !b.matches(r.GetParent()) {
+			err = status.Error(codes.InvalidArgument, "requested parent does not match the batch parent")
+		} else if crs[i], err = s.newCreateRequest(r.GetParent(), r.GetRequestId(), r); err == nil {
+			stores[i], out[i], err = s.newRecord(ctx, r, check, getResultID)
+		}
+		if err := b.fail(i, err); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, store := range stores {
+			if store == nil {
+				continue
+			}
+			err := b.run(tx, func(tx *gorm.DB) error {
+				var err error
+				out[i], err = s.createRecord(tx, crs[i], store, out[i])
+				return err
+			})
+			if !b.allOrNothing {
+				// A concurrent retry of the request may have created
+				// the Record first, which tx can still read since
+				// only the nested transaction was rolled back.
+				if prev, ok := crs[i].concurrent(tx, err); ok {
+					out[i], err = storedRecord(tx, prev)
+				}
+			}
+			if err := b.fail(i, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BatchCreateRecordsResponse{
+		Records: b.records(out),
+		Errors:  b.statuses(),
+	}, nil
+}
+
+// BatchGetRecords reads several Records in a single transaction.
+func (s *Server) BatchGetRecords(ctx context.Context, req *pb.BatchGetRecordsRequest) (*pb.BatchGetRecordsResponse, error) {
+	b, err := newBatch(req.GetParent(), len(req.GetNames()), req.GetAllOrNothing())
+	if err != nil {
+		return nil, err
+	}
+	mask, err := parseReadMask(req.GetReadMask(), &pb.Record{})
+	if err != nil {
+		return nil, err
+	}
+	check := b.checker(ctx, s.auth, auth.PermissionGet)
+
+	out := make([]*pb.Record, len(req.GetNames()))
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		omitData := !mask.has("data", "value")
+		q, sel, err := selectRecordData(tx, mask, false)
+		if err != nil {
+			return err
+		}
+		q = q.Session(&gorm.Session{})
+		get := func(name string) (*pb.Record, error) {
+			r, err := b.getRecord(q, check, name)
+			if err != nil {
+				return nil, err
+			}
+			if sel != nil {
+				if err := sel.assemble(r); err != nil {
+					return nil, err
+				}
+			}
+			api, err := maskedRecordToAPI(r, omitData)
+			if err != nil {
+				return nil, err
+			}
+			return api, mask.apply(api)
+		}
+		for i, name := range req.GetNames() {
+			var err error
+			out[i], err = get(name)
+			if err := b.fail(i, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BatchGetRecordsResponse{
+		Records: b.records(out),
+		Errors:  b.statuses(),
+	}, nil
+}
+
+// BatchDeleteRecords deletes several Records in a single transaction.
+func (s *Server) BatchDeleteRecords(ctx context.Context, req *pb.BatchDeleteRecordsRequest) (*pb.BatchDeleteRecordsResponse, error) {
+	b, err := newBatch(req.GetParent(), len(req.GetNames()), req.GetAllOrNothing())
+	if err != nil {
+		return nil, err
+	}
+	check := b.checker(ctx, s.auth, auth.PermissionDelete)
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, name := range req.GetNames() {
+			err := b.run(tx, func(tx *gorm.DB) error {
+				r, err := b.getRecord(tx, check, name)
+				if err != nil {
+					return err
+				}
+				return s.deleteRecord(tx, r)
+			})
+			if err := b.fail(i, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BatchDeleteRecordsResponse{Errors: b.statuses()}, nil
+}
+
+// batch holds the state of a batch request on Records.
+type batch struct {
+	// parent and result are the components of the batch parent, either of
+	// which may be `-`.
+	parent       string
+	result       string
+	allOrNothing bool
+	// errs are the errors of the items, by index.
+	errs []error
+}
+
+func newBatch(parent string, n int, allOrNothing bool) (*batch, error) {
+	if parent == "" {
+		return nil, status.Error(codes.InvalidArgument, "parent missing")
+	}
+	p, r, err := result.ParseName(parent)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, status.Error(codes.InvalidArgument, "the batch is empty")
+	}
+	if n > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "the batch exceeds %d items", maxBatchSize)
+	}
+	return &batch{
+		parent:       p,
+		result:       r,
+		allOrNothing: allOrNothing,
+		errs:         make([]error, n),
+	}, nil
+}
+
+// matches reports whether the Result name is within the batch parent.
+func (b *batch) matches(name string) bool {
+	parent, result, err := result.ParseName(name)
+	if err != nil {
+		return false
+	}
+	return (b.parent == "-" || b.parent == parent) && (b.result == "-" || b.result == result)
+}
+
+// fail records the error of the i-th item, if any. It returns the error if
+// the whole batch must fail.
+func (b *batch) fail(i int, err error) error {
+	if err == nil {
+		return nil
+	}
+	if b.allOrNothing {
+		return err
+	}
+	b.errs[i] = err
+	return nil
+}
+
+// run calls f in the transaction tx. Unless the batch is all or nothing, f
+// runs in a nested transaction so that its failure doesn't abort tx.
+func (b *batch) run(tx *gorm.DB, f func(*gorm.DB) error) error {
+	if b.allOrNothing {
+		return f(tx)
+	}
+	return tx.Transaction(f)
+}
+
+// checker returns a function checking the permission on Records once per
+// parent of the batch.
+func (b *batch) checker(ctx context.Context, c auth.Checker, permission string) func(parent string) error {
+	checked := map[string]error{}
+	return func(parent string) error {
+		err, ok := checked[parent]
+		if !ok {
+			err = c.Check(ctx, parent, auth.ResourceRecords, permission)
+			checked[parent] = err
+		}
+		return err
+	}
+}
+
+// resultIDs caches the Result IDs looked up by the batch.
+func (b *batch) resultIDs(get getResultID) getResultID {
+	type id struct {
+		id  string
+		err error
+	}
+	ids := map[string]id{}
+	return func(ctx context.Context, parent, name string) (string, error) {
+		key := result.FormatName(parent, name)
+		if v, ok := ids[key]; ok {
+			return v.id, v.err
+		}
+		v, err := get(ctx, parent, name)
+		ids[key] = id{id: v, err: err}
+		return v, err
+	}
+}
+
+// getRecord reads the Record of the batch with the given name.
+func (b *batch) getRecord(tx *gorm.DB, check func(parent string) error, name string) (*db.Record, error) {
+	parent, resultName, name, err := record.ParseName(name)
+	if err != nil {
+		return nil, err
+	}
+	if !b.matches(result.FormatName(parent, resultName)) {
+		return nil, status.Error(codes.InvalidArgument, "requested name does not match the batch parent")
+	}
+	if err := check(parent); err != nil {
+		return nil, err
+	}
+	return getRecord(tx, parent, resultName, name)
+}
+
+// records replaces the Records of the items which failed with empty ones.
+func (b *batch) records(in []*pb.Record) []*pb.Record {
+	for i, r := range in {
+		if r == nil || b.errs[i] != nil {
+			in[i] = &pb.Record{}
+		}
+	}
+	return in
+}
+
+// statuses returns the statuses of the items if some of them failed.
+func (b *batch) statuses() []*spb.Status {
+	failed := false
+	for _, err := range b.errs {
+		failed = failed || err != nil
+	}
+	if !failed {
+		return nil
+	}
+	out := make([]*spb.Status, 0, len(b.errs))
+	for _, err := range b.errs {
+		out = append(out, status.Convert(err).Proto())
+	}
+	return out
+}
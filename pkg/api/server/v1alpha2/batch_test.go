@@ -0,0 +1,284 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/api/server/logger"
+	"github.com/tektoncd/results/pkg/api/server/test"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// parentChecker denies access to the given parents and counts the checks
+// made per parent.
+type parentChecker struct {
+	denied map[string]bool
+	checks map[string]int
+}
+
+func (c *parentChecker) Check(_ context.Context, parent, _, _ string) error {
+	c.checks[parent]++
+	if c.denied[parent] {
+		return status.Error(codes.PermissionDenied, "denied")
+	}
+	return nil
+}
+
+func newBatchServer(t *testing.T, opts ...Option) *Server {
+	t.Helper()
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t), opts...)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	for _, name := range []string{"foo/results/a", "foo/results/b", "bar/results/a"} {
+		if _, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+			Parent: name[:3],
+			Result: &pb.Result{Name: name},
+		}); err != nil {
+			t.Fatalf("CreateResult: %v", err)
+		}
+	}
+	return srv
+}
+
+func createRecordRequest(name string) *pb.CreateRecordRequest {
+	return &pb.CreateRecordRequest{
+		Parent: name[:len("foo/results/a")],
+		Record: &pb.Record{Name: name, Data: &pb.Any{Type: "TaskRun", Value: []byte(`{"a":1}`)}},
+	}
+}
+
+func codesOf(statuses []*spb.Status) []codes.Code {
+	if statuses == nil {
+		return nil
+	}
+	out := make([]codes.Code, 0, len(statuses))
+	for _, s := range statuses {
+		out = append(out, codes.Code(s.GetCode()))
+	}
+	return out
+}
+
+func namesOf(records []*pb.Record) []string {
+	out := make([]string, 0, len(records))
+	for _, r := range records {
+		out = append(out, r.GetName())
+	}
+	return out
+}
+
+func TestBatchCreateRecords(t *testing.T) {
+	checker := &parentChecker{denied: map[string]bool{}, checks: map[string]int{}}
+	srv := newBatchServer(t, WithAuth(checker))
+	checker.denied["bar"] = true
+	checker.checks = map[string]int{}
+	ctx := context.Background()
+
+	got, err := srv.BatchCreateRecords(ctx, &pb.BatchCreateRecordsRequest{
+		Parent: "-/results/-",
+		Requests: []*pb.CreateRecordRequest{
+			createRecordRequest("foo/results/a/records/1"),
+			createRecordRequest("foo/results/b/records/2"),
+			// Already exists.
+			createRecordRequest("foo/results/a/records/1"),
+			// The Result does not exist.
+			createRecordRequest("foo/results/c/records/3"),
+			// Denied.
+			createRecordRequest("bar/results/a/records/4"),
+			createRecordRequest("bar/results/a/records/5"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("BatchCreateRecords: %v", err)
+	}
+	wantNames := []string{"foo/results/a/records/1", "foo/results/b/records/2", "", "", "", ""}
+	if diff := cmp.Diff(wantNames, namesOf(got.GetRecords())); diff != "" {
+		t.Errorf("records mismatch (-want +got):\n%s", diff)
+	}
+	wantCodes := []codes.Code{codes.OK, codes.OK, codes.AlreadyExists, codes.NotFound, codes.PermissionDenied, codes.PermissionDenied}
+	if diff := cmp.Diff(wantCodes, codesOf(got.GetErrors())); diff != "" {
+		t.Errorf("errors mismatch (-want +got):\n%s", diff)
+	}
+	// Each parent is only checked once.
+	if diff := cmp.Diff(map[string]int{"foo": 1, "bar": 1}, checker.checks); diff != "" {
+		t.Errorf("auth checks mismatch (-want +got):\n%s", diff)
+	}
+	for _, name := range wantNames[:2] {
+		if _, err := srv.GetRecord(ctx, &pb.GetRecordRequest{Name: name}); err != nil {
+			t.Errorf("GetRecord(%s): %v", name, err)
+		}
+	}
+
+	t.Run("all or nothing", func(t *testing.T) {
+		_, err := srv.BatchCreateRecords(ctx, &pb.BatchCreateRecordsRequest{
+			Parent:       "foo/results/a",
+			AllOrNothing: true,
+			Requests: []*pb.CreateRecordRequest{
+				createRecordRequest("foo/results/a/records/6"),
+				createRecordRequest("foo/results/a/records/1"),
+			},
+		})
+		if status.Code(err) != codes.AlreadyExists {
+			t.Fatalf("BatchCreateRecords: got %v, want %v", err, codes.AlreadyExists)
+		}
+		if _, err := srv.GetRecord(ctx, &pb.GetRecordRequest{Name: "foo/results/a/records/6"}); status.Code(err) != codes.NotFound {
+			t.Errorf("GetRecord: got %v, want %v", err, codes.NotFound)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		got, err := srv.BatchCreateRecords(ctx, &pb.BatchCreateRecordsRequest{
+			Parent:       "foo/results/a",
+			AllOrNothing: true,
+			Requests: []*pb.CreateRecordRequest{
+				createRecordRequest("foo/results/a/records/7"),
+				createRecordRequest("foo/results/a/records/8"),
+			},
+		})
+		if err != nil {
+			t.Fatalf("BatchCreateRecords: %v", err)
+		}
+		if diff := cmp.Diff([]string{"foo/results/a/records/7", "foo/results/a/records/8"}, namesOf(got.GetRecords())); diff != "" {
+			t.Errorf("records mismatch (-want +got):\n%s", diff)
+		}
+		if got.GetErrors() != nil {
+			t.Errorf("errors: got %v, want none", got.GetErrors())
+		}
+	})
+
+	t.Run("parent mismatch", func(t *testing.T) {
+		got, err := srv.BatchCreateRecords(ctx, &pb.BatchCreateRecordsRequest{
+			Parent:   "foo/results/b",
+			Requests: []*pb.CreateRecordRequest{createRecordRequest("foo/results/a/records/9")},
+		})
+		if err != nil {
+			t.Fatalf("BatchCreateRecords: %v", err)
+		}
+		if diff := cmp.Diff([]codes.Code{codes.InvalidArgument}, codesOf(got.GetErrors())); diff != "" {
+			t.Errorf("errors mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	tooMany := make([]*pb.CreateRecordRequest, maxBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = createRecordRequest(fmt.Sprintf("foo/results/a/records/x%d", i))
+	}
+	for _, req := range []*pb.BatchCreateRecordsRequest{
+		{Requests: []*pb.CreateRecordRequest{createRecordRequest("foo/results/a/records/10")}},
+		{Parent: "foo"},
+		{Parent: "foo/results/a"},
+		{Parent: "foo/results/a", Requests: tooMany},
+	} {
+		if _, err := srv.BatchCreateRecords(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("BatchCreateRecords(%d requests, parent %q): got %v, want %v", len(req.GetRequests()), req.GetParent(), err, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestBatchGetRecords(t *testing.T) {
+	srv := newBatchServer(t)
+	ctx := context.Background()
+	for _, name := range []string{"foo/results/a/records/1", "foo/results/b/records/2"} {
+		if _, err := srv.CreateRecord(ctx, createRecordRequest(name)); err != nil {
+			t.Fatalf("CreateRecord: %v", err)
+		}
+	}
+
+	got, err := srv.BatchGetRecords(ctx, &pb.BatchGetRecordsRequest{
+		Parent:   "foo/results/-",
+		Names:    []string{"foo/results/b/records/2", "foo/results/a/records/3", "foo/results/a/records/1", "bar/results/a/records/1"},
+		ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"name", "data.type"}},
+	})
+	if err != nil {
+		t.Fatalf("BatchGetRecords: %v", err)
+	}
+	want := []*pb.Record{
+		{Name: "foo/results/b/records/2", Data: &pb.Any{Type: "TaskRun"}},
+		{},
+		{Name: "foo/results/a/records/1", Data: &pb.Any{Type: "TaskRun"}},
+		{},
+	}
+	if diff := cmp.Diff(want, got.GetRecords(), protocmp.Transform()); diff != "" {
+		t.Errorf("records mismatch (-want +got):\n%s", diff)
+	}
+	wantCodes := []codes.Code{codes.OK, codes.NotFound, codes.OK, codes.InvalidArgument}
+	if diff := cmp.Diff(wantCodes, codesOf(got.GetErrors())); diff != "" {
+		t.Errorf("errors mismatch (-want +got):\n%s", diff)
+	}
+
+	_, err = srv.BatchGetRecords(ctx, &pb.BatchGetRecordsRequest{
+		Parent:       "foo/results/a",
+		Names:        []string{"foo/results/a/records/1", "foo/results/a/records/3"},
+		AllOrNothing: true,
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("BatchGetRecords: got %v, want %v", err, codes.NotFound)
+	}
+}
+
+func TestBatchDeleteRecords(t *testing.T) {
+	srv := newBatchServer(t)
+	ctx := context.Background()
+	for _, name := range []string{"foo/results/a/records/1", "foo/results/a/records/2", "foo/results/b/records/3"} {
+		if _, err := srv.CreateRecord(ctx, createRecordRequest(name)); err != nil {
+			t.Fatalf("CreateRecord: %v", err)
+		}
+	}
+
+	// A missing Record fails the whole batch.
+	_, err := srv.BatchDeleteRecords(ctx, &pb.BatchDeleteRecordsRequest{
+		Parent:       "foo/results/a",
+		Names:        []string{"foo/results/a/records/1", "foo/results/a/records/4"},
+		AllOrNothing: true,
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("BatchDeleteRecords: got %v, want %v", err, codes.NotFound)
+	}
+	if _, err := srv.GetRecord(ctx, &pb.GetRecordRequest{Name: "foo/results/a/records/1"}); err != nil {
+		t.Errorf("GetRecord: %v", err)
+	}
+
+	got, err := srv.BatchDeleteRecords(ctx, &pb.BatchDeleteRecordsRequest{
+		Parent: "foo/results/-",
+		Names:  []string{"foo/results/a/records/1", "foo/results/a/records/4", "foo/results/b/records/3"},
+	})
+	if err != nil {
+		t.Fatalf("BatchDeleteRecords: %v", err)
+	}
+	wantCodes := []codes.Code{codes.OK, codes.NotFound, codes.OK}
+	if diff := cmp.Diff(wantCodes, codesOf(got.GetErrors())); diff != "" {
+		t.Errorf("errors mismatch (-want +got):\n%s", diff)
+	}
+	for name, want := range map[string]codes.Code{
+		"foo/results/a/records/1": codes.NotFound,
+		"foo/results/a/records/2": codes.OK,
+		"foo/results/b/records/3": codes.NotFound,
+	} {
+		if _, err := srv.GetRecord(ctx, &pb.GetRecordRequest{Name: name}); status.Code(err) != want {
+			t.Errorf("GetRecord(%s): got %v, want %v", name, err, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"math"
+
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/db/errors"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/auth"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/result"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// CountResults counts the Results matching a filter.
+func (s *Server) CountResults(ctx context.Context, req *pb.CountResultsRequest) (*pb.CountResponse, error) {
+	if req.GetParent() == "" {
+		return nil, status.Error(codes.InvalidArgument, "parent missing")
+	}
+	if err := s.auth.Check(ctx, req.GetParent(), auth.ResourceResults, auth.PermissionList); err != nil {
+		return nil, err
+	}
+	f, err := s.parseFilter(s.env, resultFields, req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	n, err := countResults(s.db.WithContext(ctx), req.GetParent(), f)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CountResponse{Count: n, Approximate: f.prg != nil}, nil
+}
+
+// CountRecords counts the Records matching a filter.
+func (s *Server) CountRecords(ctx context.Context, req *pb.CountRecordsRequest) (*pb.CountResponse, error) {
+	if req.GetParent() == "" {
+		return nil, status.Error(codes.InvalidArgument, "parent missing")
+	}
+	parent, _, err := result.ParseName(req.GetParent())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.auth.Check(ctx, parent, auth.ResourceRecords, auth.PermissionList); err != nil {
+		return nil, err
+	}
+	f, err := s.parseRecordFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	n, err := countRecords(s.db.WithContext(ctx), req.GetParent(), f)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CountResponse{Count: n, Approximate: f.prg != nil}, nil
+}
+
+// countResults counts the Results of parent selected by q which match the
+// SQL translation of the filter. The count is an upper bound if the filter
+// is not fully translated.
+func countResults(q *gorm.DB, parent string, f *filter) (int64, error) {
+	q = q.Model(&db.Result{})
+	// Specifying `-` allows users to read Results from any parent.
+	// See https://google.aip.dev/159 for more details.
+	if parent != "-" {
+		q = q.Where("parent = ?", parent)
+	}
+	var n int64
+	err := errors.Wrap(f.apply(q).Count(&n).Error)
+	return n, err
+}
+
+// countRecords counts the Records of the Result parent selected by q which
+// match the SQL translation of the filter. The count is an upper bound if the
+// filter is not fully translated.
+func countRecords(q *gorm.DB, parent string, f *filter) (int64, error) {
+	parent, result, err := result.ParseName(parent)
+	if err != nil {
+		return 0, err
+	}
+	q = q.Model(&db.Record{})
+	// Specifying `-` allows users to read Records across Results.
+	// See https://google.aip.dev/159 for more details.
+	if parent != "-" {
+		q = q.Where("parent = ?", parent)
+	}
+	if result != "-" {
+		q = q.Where("result_name = ?", result)
+	}
+	var n int64
+	err = errors.Wrap(f.apply(q).Count(&n).Error)
+	return n, err
+}
+
+// totalSize converts a count to the total_size of a List response.
+func totalSize(n int64) int32 {
+	if n > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(n)
+}
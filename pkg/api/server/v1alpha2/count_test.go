@@ -0,0 +1,205 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/api/server/logger"
+	"github.com/tektoncd/results/pkg/api/server/test"
+	"github.com/tektoncd/results/pkg/internal/jsonutil"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestCount(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+
+	for i, r := range []struct {
+		parent      string
+		annotations map[string]string
+		records     int
+	}{
+		{parent: "foo", annotations: map[string]string{"a": "1", "b": "2"}, records: 2},
+		{parent: "foo", annotations: map[string]string{"a": "1"}, records: 1},
+		{parent: "foo"},
+		{parent: "bar", records: 1},
+	} {
+		res, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+			Parent: r.parent,
+			Result: &pb.Result{
+				Name:        fmt.Sprintf("%s/results/%d", r.parent, i),
+				Annotations: r.annotations,
+			},
+		})
+		if err != nil {
+			t.Fatalf("CreateResult: %v", err)
+		}
+		for j := 0; j < r.records; j++ {
+			if _, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+				Parent: res.GetName(),
+				Record: &pb.Record{
+					Name: fmt.Sprintf("%s/records/%d", res.GetName(), j),
+					Data: &pb.Any{Type: "TaskRun", Value: jsonutil.AnyBytes(t, map[string]string{"n": fmt.Sprint(j)})},
+				},
+			}); err != nil {
+				t.Fatalf("CreateRecord: %v", err)
+			}
+		}
+	}
+
+	t.Run("results", func(t *testing.T) {
+		for _, tc := range []struct {
+			name string
+			req  *pb.CountResultsRequest
+			want *pb.CountResponse
+		}{
+			{
+				name: "all",
+				req:  &pb.CountResultsRequest{Parent: "foo"},
+				want: &pb.CountResponse{Count: 3},
+			},
+			{
+				name: "any parent",
+				req:  &pb.CountResultsRequest{Parent: "-"},
+				want: &pb.CountResponse{Count: 4},
+			},
+			{
+				name: "exact filter",
+				req:  &pb.CountResultsRequest{Parent: "foo", Filter: `result.annotations["a"] == "1"`},
+				want: &pb.CountResponse{Count: 2},
+			},
+			{
+				// Only the annotation is compared in SQL, so the count is
+				// an upper bound.
+				name: "approximate filter",
+				req:  &pb.CountResultsRequest{Parent: "foo", Filter: `result.annotations["a"] == "1" && size(result.annotations) > 1`},
+				want: &pb.CountResponse{Count: 2, Approximate: true},
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				got, err := srv.CountResults(ctx, tc.req)
+				if err != nil {
+					t.Fatalf("CountResults: %v", err)
+				}
+				if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
+					t.Errorf("-want, +got: %s", diff)
+				}
+			})
+		}
+	})
+
+	t.Run("records", func(t *testing.T) {
+		for _, tc := range []struct {
+			name string
+			req  *pb.CountRecordsRequest
+			want *pb.CountResponse
+		}{
+			{
+				name: "result",
+				req:  &pb.CountRecordsRequest{Parent: "foo/results/0"},
+				want: &pb.CountResponse{Count: 2},
+			},
+			{
+				name: "any result",
+				req:  &pb.CountRecordsRequest{Parent: "foo/results/-"},
+				want: &pb.CountResponse{Count: 3},
+			},
+			{
+				name: "any parent",
+				req:  &pb.CountRecordsRequest{Parent: "-/results/-"},
+				want: &pb.CountResponse{Count: 4},
+			},
+			{
+				name: "filter",
+				req:  &pb.CountRecordsRequest{Parent: "-/results/-", Filter: `data.n == "0"`},
+				want: &pb.CountResponse{Count: 3},
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				got, err := srv.CountRecords(ctx, tc.req)
+				if err != nil {
+					t.Fatalf("CountRecords: %v", err)
+				}
+				if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
+					t.Errorf("-want, +got: %s", diff)
+				}
+			})
+		}
+	})
+
+	t.Run("total size", func(t *testing.T) {
+		results, err := srv.ListResults(ctx, &pb.ListResultsRequest{
+			Parent:           "foo",
+			PageSize:         1,
+			Filter:           `size(result.annotations) > 0`,
+			IncludeTotalSize: true,
+		})
+		if err != nil {
+			t.Fatalf("ListResults: %v", err)
+		}
+		if results.GetTotalSize() != 3 || !results.GetTotalSizeApproximate() {
+			t.Errorf("ListResults: want approximate total size 3, got %d (approximate: %t)", results.GetTotalSize(), results.GetTotalSizeApproximate())
+		}
+
+		records, err := srv.ListRecords(ctx, &pb.ListRecordsRequest{
+			Parent:           "-/results/-",
+			PageSize:         1,
+			IncludeTotalSize: true,
+		})
+		if err != nil {
+			t.Fatalf("ListRecords: %v", err)
+		}
+		if records.GetTotalSize() != 4 || records.GetTotalSizeApproximate() {
+			t.Errorf("ListRecords: want exact total size 4, got %d (approximate: %t)", records.GetTotalSize(), records.GetTotalSizeApproximate())
+		}
+
+		records, err = srv.ListRecords(ctx, &pb.ListRecordsRequest{Parent: "-/results/-"})
+		if err != nil {
+			t.Fatalf("ListRecords: %v", err)
+		}
+		if records.GetTotalSize() != 0 {
+			t.Errorf("ListRecords: want no total size, got %d", records.GetTotalSize())
+		}
+	})
+}
+
+func TestCount_Errors(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := srv.CountResults(ctx, &pb.CountResultsRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CountResults without parent: want %v, got %v", codes.InvalidArgument, err)
+	}
+	if _, err := srv.CountResults(ctx, &pb.CountResultsRequest{Parent: "foo", Filter: "result.id =="}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CountResults with invalid filter: want %v, got %v", codes.InvalidArgument, err)
+	}
+	if _, err := srv.CountRecords(ctx, &pb.CountRecordsRequest{Parent: "foo"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CountRecords with invalid parent: want %v, got %v", codes.InvalidArgument, err)
+	}
+}
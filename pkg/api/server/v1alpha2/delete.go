@@ -0,0 +1,287 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/db/errors"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/auth"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/log"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/record"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/result"
+	"github.com/tektoncd/results/pkg/apis/v1alpha2"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+const (
+	// deleteBatchSize is the number of resources read, and deleted in a
+	// single transaction, at a time by filtered deletes.
+	deleteBatchSize = 100
+	// maxDeletePreview is the number of names previewed by dry runs.
+	maxDeletePreview = 100
+)
+
+// DeleteResults deletes the Results matching a filter in batches, along with
+// their Records and the storage of their logs. Held Results are skipped.
+func (s *Server) DeleteResults(ctx context.Context, req *pb.DeleteResultsRequest) (*pb.DeleteResponse, error) {
+	if req.GetParent() == "" {
+		return nil, status.Error(codes.InvalidArgument, "parent missing")
+	}
+	if req.GetFilter() == "" {
+		return nil, status.Error(codes.InvalidArgument, "filter missing")
+	}
+	if err := s.auth.Check(ctx, req.GetParent(), auth.ResourceResults, auth.PermissionDelete); err != nil {
+		return nil, err
+	}
+	f, err := s.parseFilter(s.env, resultFields, req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+
+	now := clock.Now()
+	out := &pb.DeleteResponse{}
+	var after string
+	for {
+		var batch []*db.Result
+		q := s.db.WithContext(ctx).Where("id > ?", after)
+		// Specifying `-` allows users to delete Results from any parent.
+		if req.GetParent() != "-" {
+			q = q.Where("parent = ?", req.GetParent())
+		}
+		q = f.apply(q).Order("id").Limit(deleteBatchSize).Find(&batch)
+		if err := errors.Wrap(q.Error); err != nil {
+			return nil, err
+		}
+
+		var matched []*db.Result
+		for _, r := range batch {
+			api := result.ToAPI(r)
+			ok, err := result.Match(api, f.prg)
+			if err != nil {
+				return nil, err
+			}
+			if ok && !result.Held(api, now) {
+				matched = append(matched, r)
+				preview(out, req.GetDryRun(), api.GetName())
+			}
+		}
+		if !req.GetDryRun() && len(matched) > 0 {
+			if err := s.deleteResults(ctx, matched); err != nil {
+				return nil, err
+			}
+		}
+		out.Count += int64(len(matched))
+
+		if len(batch) < deleteBatchSize {
+			return out, nil
+		}
+		after = batch[len(batch)-1].ID
+	}
+}
+
+// deleteResults deletes the Results in a single transaction, then the storage
+// of their logs once it commits.
+func (s *Server) deleteResults(ctx context.Context, results []*db.Result) error {
+	ids := make([]string, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	var storage []*logStorage
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var logs []*db.Record
+		q := tx.Where("result_id IN ? AND type = ?", ids, v1alpha2.LogRecordType).Find(&logs)
+		if err := errors.Wrap(q.Error); err != nil {
+			return err
+		}
+		var err error
+		if storage, err = s.openLogStorage(ctx, logs); err != nil {
+			return err
+		}
+		for _, r := range results {
+			if err := s.deleteResult(tx, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return deleteLogStorage(storage)
+}
+
+// DeleteRecords deletes the Records matching a filter in batches, along with
+// the storage of the logs among them. Records of held Results are skipped.
+func (s *Server) DeleteRecords(ctx context.Context, req *pb.DeleteRecordsRequest) (*pb.DeleteResponse, error) {
+	if req.GetParent() == "" {
+		return nil, status.Error(codes.InvalidArgument, "parent missing")
+	}
+	if req.GetFilter() == "" {
+		return nil, status.Error(codes.InvalidArgument, "filter missing")
+	}
+	parent, resultName, err := result.ParseName(req.GetParent())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.auth.Check(ctx, parent, auth.ResourceRecords, auth.PermissionDelete); err != nil {
+		return nil, err
+	}
+	f, err := s.parseRecordFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+
+	out := &pb.DeleteResponse{}
+	var after string
+	for {
+		var batch []*db.Record
+		q := s.db.WithContext(ctx).Where("id > ?", after)
+		// Specifying `-` allows users to delete Records across Results.
+		if parent != "-" {
+			q = q.Where("parent = ?", parent)
+		}
+		if resultName != "-" {
+			q = q.Where("result_name = ?", resultName)
+		}
+		q = f.apply(q).Order("id").Limit(deleteBatchSize).Find(&batch)
+		if err := errors.Wrap(q.Error); err != nil {
+			return nil, err
+		}
+
+		held, err := s.heldResults(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		var matched []*db.Record
+		for _, r := range batch {
+			if held[r.Parent+"/"+r.ResultID] {
+				continue
+			}
+			api, err := record.ToAPI(r)
+			if err != nil {
+				return nil, err
+			}
+			ok, err := record.Match(api, f.prg)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				matched = append(matched, r)
+				preview(out, req.GetDryRun(), api.GetName())
+			}
+		}
+		if !req.GetDryRun() && len(matched) > 0 {
+			if err := s.deleteRecords(ctx, matched); err != nil {
+				return nil, err
+			}
+		}
+		out.Count += int64(len(matched))
+
+		if len(batch) < deleteBatchSize {
+			return out, nil
+		}
+		after = batch[len(batch)-1].ID
+	}
+}
+
+// heldResults returns the Results of the Records which are held, keyed by
+// parent and ID.
+func (s *Server) heldResults(ctx context.Context, records []*db.Record) (map[string]bool, error) {
+	ids := make([]string, 0, len(records))
+	for _, r := range records {
+		ids = append(ids, r.ResultID)
+	}
+	var results []*db.Result
+	q := s.db.WithContext(ctx).
+		Where("id IN ? AND (retain = ? OR hold_until > ?)", ids, true, clock.Now()).
+		Find(&results)
+	if err := errors.Wrap(q.Error); err != nil {
+		return nil, err
+	}
+	held := make(map[string]bool, len(results))
+	for _, r := range results {
+		held[r.Parent+"/"+r.ID] = true
+	}
+	return held, nil
+}
+
+// deleteRecords deletes the Records in a single transaction, then the storage
+// of the logs among them once it commits.
+func (s *Server) deleteRecords(ctx context.Context, records []*db.Record) error {
+	storage, err := s.openLogStorage(ctx, records)
+	if err != nil {
+		return err
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, r := range records {
+			if err := s.deleteRecord(tx, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return deleteLogStorage(storage)
+}
+
+// logStorage is the storage of the log of a Log Record.
+type logStorage struct {
+	name   string
+	stream log.Stream
+}
+
+// openLogStorage opens the storage of the logs among the Records, to delete
+// it once they are deleted, so that it is kept if they can't be.
+func (s *Server) openLogStorage(ctx context.Context, records []*db.Record) ([]*logStorage, error) {
+	var out []*logStorage
+	for _, rec := range records {
+		if rec.Type != v1alpha2.LogRecordType {
+			continue
+		}
+		name := record.FormatName(result.FormatName(rec.Parent, rec.ResultName), rec.Name)
+		stream, _, err := log.ToStream(ctx, s.db, rec, s.config)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to delete log of %s: %v", name, err)
+		}
+		out = append(out, &logStorage{name: name, stream: stream})
+	}
+	return out, nil
+}
+
+// deleteLogStorage deletes the storage of the logs of deleted Records,
+// returning the first error.
+func deleteLogStorage(storage []*logStorage) error {
+	var err error
+	for _, l := range storage {
+		if e := l.stream.Delete(); e != nil && err == nil {
+			err = status.Errorf(codes.Internal, "failed to delete log of %s: %v", l.name, e)
+		}
+	}
+	return err
+}
+
+// preview adds the name of a resource to the preview of a dry run.
+func preview(out *pb.DeleteResponse, dryRun bool, name string) {
+	if dryRun && len(out.Preview) < maxDeletePreview {
+		out.Preview = append(out.Preview, name)
+	}
+}
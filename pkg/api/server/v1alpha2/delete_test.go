@@ -0,0 +1,292 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/logger"
+	"github.com/tektoncd/results/pkg/api/server/test"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/record"
+	"github.com/tektoncd/results/pkg/apis/v1alpha2"
+	"github.com/tektoncd/results/pkg/internal/jsonutil"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestDeleteResults(t *testing.T) {
+	srv, err := New(&config.Config{
+		LOGS_API:                 true,
+		LOGS_TYPE:                "File",
+		DB_ENABLE_AUTO_MIGRATION: true,
+	}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+
+	// Span more than one batch, half of the Results being stale.
+	var stale []string
+	for i := 0; i < deleteBatchSize+10; i++ {
+		team := "active"
+		if i%2 == 0 {
+			team = "stale"
+		}
+		res, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+			Parent: "foo",
+			Result: &pb.Result{
+				Name:        fmt.Sprintf("foo/results/%03d", i),
+				Annotations: map[string]string{"team": team},
+			},
+		})
+		if err != nil {
+			t.Fatalf("CreateResult: %v", err)
+		}
+		if team == "stale" {
+			stale = append(stale, res.GetName())
+		}
+	}
+	logFile := createLogFile(t)
+	rec := createLogRecord(t, srv, stale[0], logFile)
+
+	filter := `result.annotations["team"] == "stale"`
+
+	t.Run("dry run", func(t *testing.T) {
+		got, err := srv.DeleteResults(ctx, &pb.DeleteResultsRequest{
+			Parent: "foo",
+			Filter: filter,
+			DryRun: true,
+		})
+		if err != nil {
+			t.Fatalf("DeleteResults: %v", err)
+		}
+		want := &pb.DeleteResponse{
+			Count:   int64(len(stale)),
+			Preview: stale,
+		}
+		// Results are deleted in the order of their IDs.
+		sortStrings := cmpopts.SortSlices(func(a, b string) bool { return a < b })
+		if diff := cmp.Diff(want, got, protocmp.Transform(), sortStrings); diff != "" {
+			t.Errorf("-want, +got: %s", diff)
+		}
+		if _, err := srv.GetResult(ctx, &pb.GetResultRequest{Name: stale[0]}); err != nil {
+			t.Errorf("GetResult: %v", err)
+		}
+		if _, err := os.Stat(logFile); err != nil {
+			t.Errorf("log file was deleted by a dry run: %v", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		got, err := srv.DeleteResults(ctx, &pb.DeleteResultsRequest{
+			Parent: "foo",
+			Filter: filter,
+		})
+		if err != nil {
+			t.Fatalf("DeleteResults: %v", err)
+		}
+		if diff := cmp.Diff(&pb.DeleteResponse{Count: int64(len(stale))}, got, protocmp.Transform()); diff != "" {
+			t.Errorf("-want, +got: %s", diff)
+		}
+		list, err := srv.ListResults(ctx, &pb.ListResultsRequest{Parent: "foo", Filter: filter})
+		if err != nil {
+			t.Fatalf("ListResults: %v", err)
+		}
+		if n := len(list.GetResults()); n != 0 {
+			t.Errorf("%d stale Results left", n)
+		}
+		if r, err := srv.GetRecord(ctx, &pb.GetRecordRequest{Name: rec.GetName()}); status.Code(err) != codes.NotFound {
+			t.Errorf("expected record to be deleted, got: %+v, %v", r, err)
+		}
+		if _, err := os.Stat(logFile); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected log file to be deleted: %v", err)
+		}
+		count, err := srv.CountResults(ctx, &pb.CountResultsRequest{Parent: "foo"})
+		if err != nil {
+			t.Fatalf("CountResults: %v", err)
+		}
+		if want := int64(deleteBatchSize + 10 - len(stale)); count.GetCount() != want {
+			t.Errorf("want %d Results left, got %d", want, count.GetCount())
+		}
+	})
+
+	t.Run("missing filter", func(t *testing.T) {
+		if _, err := srv.DeleteResults(ctx, &pb.DeleteResultsRequest{Parent: "foo"}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected INVALID_ARGUMENT, got: %v", err)
+		}
+	})
+}
+
+func TestDeleteRecords(t *testing.T) {
+	srv, err := New(&config.Config{
+		LOGS_API:                 true,
+		LOGS_TYPE:                "File",
+		DB_ENABLE_AUTO_MIGRATION: true,
+	}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+
+	res, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{Name: "foo/results/bar"},
+	})
+	if err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+	logFile := createLogFile(t)
+	logRecord := createLogRecord(t, srv, res.GetName(), logFile)
+	taskRun, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+		Parent: res.GetName(),
+		Record: &pb.Record{
+			Name: record.FormatName(res.GetName(), "taskrun"),
+			Data: &pb.Any{
+				Type:  "tekton.dev/v1beta1.TaskRun",
+				Value: []byte(`{"metadata": {"name": "taskrun"}}`),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateRecord: %v", err)
+	}
+
+	filter := fmt.Sprintf(`data_type == %q`, v1alpha2.LogRecordType)
+
+	t.Run("dry run", func(t *testing.T) {
+		got, err := srv.DeleteRecords(ctx, &pb.DeleteRecordsRequest{
+			Parent: res.GetName(),
+			Filter: filter,
+			DryRun: true,
+		})
+		if err != nil {
+			t.Fatalf("DeleteRecords: %v", err)
+		}
+		want := &pb.DeleteResponse{
+			Count:   1,
+			Preview: []string{logRecord.GetName()},
+		}
+		if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+			t.Errorf("-want, +got: %s", diff)
+		}
+		if _, err := os.Stat(logFile); err != nil {
+			t.Errorf("log file was deleted by a dry run: %v", err)
+		}
+	})
+
+	t.Run("held since selected", func(t *testing.T) {
+		rec, err := getRecord(srv.db, "foo", "bar", "log")
+		if err != nil {
+			t.Fatalf("getRecord: %v", err)
+		}
+		if err := srv.db.Model(&db.Result{}).Where("parent = ? AND name = ?", "foo", "bar").Update("retain", true).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.deleteRecords(ctx, []*db.Record{rec}); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("expected FAILED_PRECONDITION, got: %v", err)
+		}
+		if _, err := os.Stat(logFile); err != nil {
+			t.Errorf("log file of a held Result was deleted: %v", err)
+		}
+		if err := srv.db.Model(&db.Result{}).Where("parent = ? AND name = ?", "foo", "bar").Update("retain", false).Error; err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		got, err := srv.DeleteRecords(ctx, &pb.DeleteRecordsRequest{
+			Parent: res.GetName(),
+			Filter: filter,
+		})
+		if err != nil {
+			t.Fatalf("DeleteRecords: %v", err)
+		}
+		if diff := cmp.Diff(&pb.DeleteResponse{Count: 1}, got, protocmp.Transform()); diff != "" {
+			t.Errorf("-want, +got: %s", diff)
+		}
+		if r, err := srv.GetRecord(ctx, &pb.GetRecordRequest{Name: logRecord.GetName()}); status.Code(err) != codes.NotFound {
+			t.Errorf("expected record to be deleted, got: %+v, %v", r, err)
+		}
+		if _, err := os.Stat(logFile); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected log file to be deleted: %v", err)
+		}
+		if _, err := srv.GetRecord(ctx, &pb.GetRecordRequest{Name: taskRun.GetName()}); err != nil {
+			t.Errorf("GetRecord: %v", err)
+		}
+	})
+
+	t.Run("missing filter", func(t *testing.T) {
+		if _, err := srv.DeleteRecords(ctx, &pb.DeleteRecordsRequest{Parent: res.GetName()}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected INVALID_ARGUMENT, got: %v", err)
+		}
+	})
+}
+
+// createLogFile creates a temporary log file and returns its path.
+func createLogFile(t *testing.T) string {
+	t.Helper()
+	f, err := os.CreateTemp("", "test-log-taskrun-*.log")
+	if err != nil {
+		t.Fatalf("failed to create tempfile: %v", err)
+	}
+	t.Cleanup(func() {
+		os.Remove(f.Name())
+	})
+	defer f.Close()
+	if _, err := f.Write([]byte("test data")); err != nil {
+		t.Fatalf("failed to write to tempfile: %v", err)
+	}
+	return f.Name()
+}
+
+// createLogRecord creates a Log Record in parent stored in the file at path.
+func createLogRecord(t *testing.T, srv *Server, parent, path string) *pb.Record {
+	t.Helper()
+	rec, err := srv.CreateRecord(context.Background(), &pb.CreateRecordRequest{
+		Parent: parent,
+		Record: &pb.Record{
+			Name: record.FormatName(parent, "log"),
+			Data: &pb.Any{
+				Type: v1alpha2.LogRecordType,
+				Value: jsonutil.AnyBytes(t, &v1alpha2.Log{
+					Spec: v1alpha2.LogSpec{
+						Resource: v1alpha2.Resource{
+							Namespace: "foo",
+							Name:      "log",
+						},
+						Type: v1alpha2.FileLogType,
+					},
+					Status: v1alpha2.LogStatus{
+						Path: path,
+					},
+				}),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateRecord: %v", err)
+	}
+	return rec
+}
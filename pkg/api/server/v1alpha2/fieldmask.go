@@ -0,0 +1,205 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"strings"
+
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// splitPath splits a field mask path into its segments. Segments quoted with
+// backticks may contain any character, a literal backtick is written as two
+// backticks. See https://google.aip.dev/161 for more information.
+func splitPath(path string) ([]string, error) {
+	var (
+		segments []string
+		b        strings.Builder
+		quoted   bool
+	)
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c == '`' && quoted && i+1 < len(path) && path[i+1] == '`':
+			b.WriteByte(c)
+			i++
+		case c == '`':
+			quoted = !quoted
+		case c == '.' && !quoted:
+			segments = append(segments, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	segments = append(segments, b.String())
+	if quoted {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid field path %q: unterminated backtick", path)
+	}
+	for _, s := range segments {
+		if s == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid field path %q", path)
+		}
+	}
+	return segments, nil
+}
+
+// immutableFields are set by the server or identify the resource. They are
+// ignored in update masks, since REST clients derive masks from the request
+// body which usually includes them.
+var immutableFields = map[string]bool{
+	"name":         true,
+	"id":           true,
+	"uid":          true,
+	"etag":         true,
+	"created_time": true,
+	"create_time":  true,
+	"updated_time": true,
+	"update_time":  true,
+}
+
+// updateResult applies the fields of src selected by mask to dst. Without a
+// mask, annotations and summary are replaced. Holds are only updated when
+// selected explicitly, so that clients unaware of them don't release them.
+func updateResult(dst, src *pb.Result, mask *fieldmaskpb.FieldMask) error {
+	if len(mask.GetPaths()) == 0 {
+		mask = &fieldmaskpb.FieldMask{Paths: []string{"*"}}
+	}
+	for _, path := range mask.GetPaths() {
+		if path == "*" {
+			dst.Annotations = src.GetAnnotations()
+			dst.Summary = src.GetSummary()
+			continue
+		}
+		segments, err := splitPath(path)
+		if err != nil {
+			return err
+		}
+		switch field := segments[0]; {
+		case field == "annotations":
+			if dst.Annotations, err = updateMap(dst.GetAnnotations(), src.GetAnnotations(), segments[1:]); err != nil {
+				return status.Errorf(codes.InvalidArgument, "invalid field path %q: %v", path, err)
+			}
+		case field == "summary":
+			if err := updateSummary(dst, src, segments[1:]); err != nil {
+				return status.Errorf(codes.InvalidArgument, "invalid field path %q: %v", path, err)
+			}
+		case field == "retain" && len(segments) == 1:
+			dst.Retain = src.GetRetain()
+		case field == "hold_until" && len(segments) == 1:
+			dst.HoldUntil = src.GetHoldUntil()
+		case immutableFields[field]:
+		default:
+			return status.Errorf(codes.InvalidArgument, "invalid field path %q: unknown field", path)
+		}
+	}
+	return nil
+}
+
+func updateSummary(dst, src *pb.Result, path []string) error {
+	if len(path) == 0 {
+		dst.Summary = src.GetSummary()
+		return nil
+	}
+	if dst.Summary == nil {
+		dst.Summary = &pb.RecordSummary{}
+	}
+	if path[0] == "annotations" {
+		var err error
+		dst.Summary.Annotations, err = updateMap(dst.Summary.GetAnnotations(), src.GetSummary().GetAnnotations(), path[1:])
+		return err
+	}
+	if len(path) > 1 {
+		return status.Errorf(codes.InvalidArgument, "%s has no subfields", path[0])
+	}
+	return copyField(dst.Summary, src.GetSummary(), path[0])
+}
+
+// updateRecord applies the fields of src selected by mask to dst. Without a
+// mask, the fields set in src are merged into dst.
+func updateRecord(dst, src *pb.Record, mask *fieldmaskpb.FieldMask) error {
+	if len(mask.GetPaths()) == 0 {
+		proto.Merge(dst, src)
+		return nil
+	}
+	for _, path := range mask.GetPaths() {
+		if path == "*" {
+			dst.Data = src.GetData()
+			continue
+		}
+		segments, err := splitPath(path)
+		if err != nil {
+			return err
+		}
+		switch field := segments[0]; {
+		case field == "data" && len(segments) == 1:
+			dst.Data = src.GetData()
+		case field == "data" && len(segments) == 2:
+			if dst.Data == nil {
+				dst.Data = &pb.Any{}
+			}
+			if err := copyField(dst.Data, src.GetData(), segments[1]); err != nil {
+				return status.Errorf(codes.InvalidArgument, "invalid field path %q: %v", path, err)
+			}
+		case immutableFields[field] && len(segments) == 1:
+		default:
+			return status.Errorf(codes.InvalidArgument, "invalid field path %q: unknown field", path)
+		}
+	}
+	return nil
+}
+
+// updateMap returns dst updated with src. An empty path replaces dst, a
+// single key copies the entry for that key, deleting it if src has none.
+func updateMap(dst, src map[string]string, path []string) (map[string]string, error) {
+	switch len(path) {
+	case 0:
+		return src, nil
+	case 1:
+		key := path[0]
+		v, ok := src[key]
+		if !ok {
+			delete(dst, key)
+			return dst, nil
+		}
+		if dst == nil {
+			dst = make(map[string]string)
+		}
+		dst[key] = v
+		return dst, nil
+	}
+	return nil, status.Error(codes.InvalidArgument, "map values have no subfields")
+}
+
+// copyField copies the field with the given name from src to dst, clearing it
+// in dst if it is not set in src. src may be nil.
+func copyField(dst, src proto.Message, name string) error {
+	d := dst.ProtoReflect()
+	fd := d.Descriptor().Fields().ByName(protoreflect.Name(name))
+	if fd == nil {
+		return status.Errorf(codes.InvalidArgument, "unknown field %q", name)
+	}
+	if s := src.ProtoReflect(); s.IsValid() && s.Has(fd) {
+		d.Set(fd, s.Get(fd))
+	} else {
+		d.Clear(fd)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/pipeline/test/diff"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestSplitPath(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{{
+		in:   "annotations",
+		want: []string{"annotations"},
+	}, {
+		in:   "summary.annotations.foo",
+		want: []string{"summary", "annotations", "foo"},
+	}, {
+		in:   "annotations.`tekton.dev/pipeline`",
+		want: []string{"annotations", "tekton.dev/pipeline"},
+	}, {
+		in:   "annotations.`a``b`",
+		want: []string{"annotations", "a`b"},
+	}} {
+		got, err := splitPath(tc.in)
+		if err != nil {
+			t.Fatalf("splitPath(%q): %v", tc.in, err)
+		}
+		if d := cmp.Diff(tc.want, got); d != "" {
+			t.Errorf(diff.PrintWantGot(d))
+		}
+	}
+
+	for _, in := range []string{"", "annotations.", "annotations.`foo", "a..b"} {
+		if _, err := splitPath(in); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("splitPath(%q): want InvalidArgument, got %v", in, err)
+		}
+	}
+}
+
+func TestUpdateResultMask(t *testing.T) {
+	src := &pb.Result{
+		Annotations: map[string]string{"a": "new"},
+		Summary: &pb.RecordSummary{
+			Record:      "foo/results/bar/records/baz",
+			Status:      pb.RecordSummary_SUCCESS,
+			Annotations: map[string]string{"x": "new"},
+		},
+		Retain: true,
+	}
+	for _, tc := range []struct {
+		name  string
+		paths []string
+		want  *pb.Result
+	}{{
+		// Holds are only updated explicitly.
+		name: "no mask",
+		want: &pb.Result{
+			Annotations: src.Annotations,
+			Summary:     src.Summary,
+		},
+	}, {
+		name:  "holds",
+		paths: []string{"retain", "hold_until"},
+		want: &pb.Result{
+			Annotations: map[string]string{"b": "old", "c": "old"},
+			Summary:     &pb.RecordSummary{Record: "old", Type: "old"},
+			Retain:      true,
+		},
+	}, {
+		name:  "single annotation",
+		paths: []string{"annotations.a", "annotations.b"},
+		want: &pb.Result{
+			Annotations: map[string]string{"a": "new", "c": "old"},
+			Summary:     &pb.RecordSummary{Record: "old", Type: "old"},
+		},
+	}, {
+		name:  "summary fields",
+		paths: []string{"summary.status", "summary.type", "summary.annotations.x"},
+		want: &pb.Result{
+			Annotations: map[string]string{"b": "old", "c": "old"},
+			Summary: &pb.RecordSummary{
+				Record:      "old",
+				Status:      pb.RecordSummary_SUCCESS,
+				Annotations: map[string]string{"x": "new"},
+			},
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := &pb.Result{
+				Annotations: map[string]string{"b": "old", "c": "old"},
+				Summary:     &pb.RecordSummary{Record: "old", Type: "old"},
+			}
+			if err := updateResult(dst, src, &fieldmaskpb.FieldMask{Paths: tc.paths}); err != nil {
+				t.Fatalf("updateResult: %v", err)
+			}
+			if d := cmp.Diff(tc.want, dst, protocmp.Transform()); d != "" {
+				t.Errorf(diff.PrintWantGot(d))
+			}
+		})
+	}
+
+	for _, path := range []string{"foo", "annotations.a.b", "summary.status.foo", "summary.foo", "retain.foo"} {
+		if err := updateResult(&pb.Result{}, src, &fieldmaskpb.FieldMask{Paths: []string{path}}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("updateResult(%q): want InvalidArgument, got %v", path, err)
+		}
+	}
+}
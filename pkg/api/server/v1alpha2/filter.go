@@ -0,0 +1,163 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"github.com/google/cel-go/cel"
+	celenv "github.com/tektoncd/results/pkg/api/server/cel"
+	"github.com/tektoncd/results/pkg/api/server/cel2sql"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// resultFields maps the fields of the Result CEL environment to columns of
+// the results table.
+func resultFields(d cel2sql.Dialect) cel2sql.Fields {
+	return cel2sql.Fields{
+		"result.name":                {Column: d.Concat("parent", "'/results/'", "name"), Type: cel2sql.String},
+		"result.id":                  {Column: "id", Type: cel2sql.String},
+		"result.uid":                 {Column: "id", Type: cel2sql.String},
+		"result.etag":                {Column: "etag", Type: cel2sql.String},
+		"result.created_time":        {Column: "created_time", Type: cel2sql.Timestamp},
+		"result.create_time":         {Column: "created_time", Type: cel2sql.Timestamp},
+		"result.updated_time":        {Column: "updated_time", Type: cel2sql.Timestamp},
+		"result.update_time":         {Column: "updated_time", Type: cel2sql.Timestamp},
+		"result.annotations":         {Column: "annotations", Type: cel2sql.JSON},
+		"result.summary.record":      {Column: "recordsummary_record", Type: cel2sql.String},
+		"result.summary.type":        {Column: "recordsummary_type", Type: cel2sql.String},
+		"result.summary.start_time":  {Column: "recordsummary_start_time", Type: cel2sql.Timestamp, Nullable: true},
+		"result.summary.end_time":    {Column: "recordsummary_end_time", Type: cel2sql.Timestamp, Nullable: true},
+		"result.summary.status":      {Column: "recordsummary_status", Type: cel2sql.Int},
+		"result.summary.annotations": {Column: "recordsummary_annotations", Type: cel2sql.JSON},
+	}
+}
+
+// recordFields maps the fields of the Record CEL environment to columns of
+// the records table.
+func recordFields(d cel2sql.Dialect) cel2sql.Fields {
+	return cel2sql.Fields{
+		"name":      {Column: d.Concat("parent", "'/results/'", "result_name", "'/records/'", "name"), Type: cel2sql.String},
+		"data_type": {Column: "type", Type: cel2sql.String},
+		"data":      {Column: "data", Type: cel2sql.JSON},
+	}
+}
+
+// filter is a parsed List filter. The parts of the filter that could be
+// translated to SQL are applied to the database query, prg evaluates the
+// filter in memory when the translation is not exact.
+type filter struct {
+	// prg is nil when the clause fully covers the filter.
+	prg    cel.Program
+	clause *cel2sql.Clause
+}
+
+// parseFilter compiles the filter and pushes down as much of it as possible
+// to the database.
+func (s *Server) parseFilter(env *cel.Env, fields func(cel2sql.Dialect) cel2sql.Fields, expr string) (*filter, error) {
+	if expr == "" {
+		return &filter{}, nil
+	}
+	ast, err := celenv.Compile(env, expr)
+	if err != nil {
+		return nil, err
+	}
+	return s.newFilter(env, fields, ast)
+}
+
+// parseRecordFilter parses a Record filter, type checking data against the
+// schema of the data type the filter constrains, if any.
+func (s *Server) parseRecordFilter(expr string) (*filter, error) {
+	if expr == "" {
+		return &filter{}, nil
+	}
+	env, ast, _, err := compileRecordFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	return s.newFilter(env, recordFields, ast)
+}
+
+// compileRecordFilter compiles a Record filter and type checks its data. It
+// also returns the data type the filter constrains, if any.
+func compileRecordFilter(expr string) (*cel.Env, *cel.Ast, string, error) {
+	env, err := recordCEL()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	ast, err := celenv.Compile(env, expr)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	dataType, err := celenv.CheckData(ast)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return env, ast, dataType, nil
+}
+
+// newFilter pushes down as much of the compiled filter as possible to the
+// database. now() evaluates to the time of the request in both the database
+// and in memory.
+func (s *Server) newFilter(env *cel.Env, fields func(cel2sql.Dialect) cel2sql.Fields, ast *cel.Ast) (*filter, error) {
+	now := clock.Now()
+	d := cel2sql.Dialect(s.db.Dialector.Name())
+	clause, exact := cel2sql.Convert(d, fields(d), ast, cel2sql.Now(now))
+	if exact {
+		return &filter{clause: clause}, nil
+	}
+	prg, err := celenv.Program(env, ast, now)
+	if err != nil {
+		return nil, err
+	}
+	return &filter{prg: prg, clause: clause}, nil
+}
+
+// apply adds the SQL translation of the filter to the query.
+func (f *filter) apply(q *gorm.DB) *gorm.DB {
+	if f.clause == nil {
+		return q
+	}
+	return q.Where(f.clause.SQL, f.clause.Args...)
+}
+
+// ValidateFilter parses and type checks a filter. Invalid filters are
+// reported in the response rather than as an error. No data is read, so the
+// request is not authorized against a parent.
+func (s *Server) ValidateFilter(ctx context.Context, req *pb.ValidateFilterRequest) (*pb.ValidateFilterResponse, error) {
+	var (
+		dataType string
+		err      error
+	)
+	switch req.GetResource() {
+	case pb.ValidateFilterRequest_RESULTS:
+		if req.GetFilter() != "" {
+			_, err = celenv.Compile(s.env, req.GetFilter())
+		}
+	case pb.ValidateFilterRequest_RECORDS:
+		if req.GetFilter() != "" {
+			_, _, dataType, err = compileRecordFilter(req.GetFilter())
+		}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported resource %v", req.GetResource())
+	}
+	if err != nil {
+		return &pb.ValidateFilterResponse{Error: status.Convert(err).Message()}, nil
+	}
+	return &pb.ValidateFilterResponse{Valid: true, DataType: dataType}, nil
+}
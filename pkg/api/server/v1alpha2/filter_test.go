@@ -0,0 +1,201 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/api/server/logger"
+	"github.com/tektoncd/results/pkg/api/server/test"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestValidateFilter(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name     string
+		req      *pb.ValidateFilterRequest
+		dataType string
+		// err is part of the expected error, empty for valid filters.
+		err string
+	}{
+		{
+			name: "empty",
+			req:  &pb.ValidateFilterRequest{Resource: pb.ValidateFilterRequest_RECORDS},
+		},
+		{
+			name: "result",
+			req:  &pb.ValidateFilterRequest{Resource: pb.ValidateFilterRequest_RESULTS, Filter: `result.summary.status == tekton.results.v1alpha2.RecordSummary.Status.SUCCESS`},
+		},
+		{
+			name: "invalid result",
+			req:  &pb.ValidateFilterRequest{Resource: pb.ValidateFilterRequest_RESULTS, Filter: `result.nme == "a"`},
+			err:  "undefined field 'nme'",
+		},
+		{
+			name: "untyped record",
+			req:  &pb.ValidateFilterRequest{Resource: pb.ValidateFilterRequest_RECORDS, Filter: `data.status.conditon[0].status == "True"`},
+		},
+		{
+			name: "typed record",
+			req: &pb.ValidateFilterRequest{
+				Resource: pb.ValidateFilterRequest_RECORDS,
+				Filter:   `data_type == "tekton.dev/v1beta1.TaskRun" && data.status.conditions[0].status == "True"`,
+			},
+			dataType: "tekton.dev/v1beta1.TaskRun",
+		},
+		{
+			name: "invalid typed record",
+			req: &pb.ValidateFilterRequest{
+				Resource: pb.ValidateFilterRequest_RECORDS,
+				Filter:   `data_type == "tekton.dev/v1beta1.TaskRun" && data.status.conditon[0].status == "True"`,
+			},
+			err: "undefined field 'conditon'",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := srv.ValidateFilter(ctx, tc.req)
+			if err != nil {
+				t.Fatalf("ValidateFilter: %v", err)
+			}
+			if got.GetValid() != (tc.err == "") || !strings.Contains(got.GetError(), tc.err) || got.GetDataType() != tc.dataType {
+				t.Errorf("want valid: %t, error containing %q and data type %q, got %+v", tc.err == "", tc.err, tc.dataType, got)
+			}
+		})
+	}
+
+	if _, err := srv.ValidateFilter(ctx, &pb.ValidateFilterRequest{Filter: "true"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ValidateFilter without resource: want InvalidArgument, got %v", err)
+	}
+}
+
+func TestParseRecordFilter_TypeCheck(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	filter := `data_type == "tekton.dev/v1beta1.TaskRun" && data.status.conditon[0].status == "True"`
+
+	if _, err := srv.ListRecords(ctx, &pb.ListRecordsRequest{Parent: "foo/results/-", Filter: filter}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListRecords: want InvalidArgument, got %v", err)
+	}
+	if _, err := srv.CountRecords(ctx, &pb.CountRecordsRequest{Parent: "foo/results/-", Filter: filter}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CountRecords: want InvalidArgument, got %v", err)
+	}
+}
+
+func TestFilterFunctions(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+
+	start := fakeClock.Now().Add(-time.Hour)
+	for name, d := range map[string]time.Duration{"long": 11 * time.Minute, "short": 2 * time.Minute} {
+		if _, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+			Parent: "foo",
+			Result: &pb.Result{
+				Name: "foo/results/" + name,
+				Summary: &pb.RecordSummary{
+					Record:    "foo/results/" + name + "/records/a",
+					Type:      "example.dev/v1.Thing",
+					StartTime: timestamppb.New(start),
+					EndTime:   timestamppb.New(start.Add(d)),
+				},
+			},
+		}); err != nil {
+			t.Fatalf("CreateResult: %v", err)
+		}
+	}
+	for name, data := range map[string]string{
+		"failed":    `{"metadata": {"labels": {"app": "a"}}, "status": {"conditions": [{"type": "Succeeded", "status": "False", "reason": "Failed"}]}}`,
+		"succeeded": `{"metadata": {"labels": {"app": "b"}}, "status": {"conditions": [{"type": "Succeeded", "status": "True"}]}}`,
+		"empty":     `{}`,
+	} {
+		if _, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+			Parent: "foo/results/long",
+			Record: &pb.Record{
+				Name: "foo/results/long/records/" + name,
+				Data: &pb.Any{Type: "example.dev/v1.Thing", Value: []byte(data)},
+			},
+		}); err != nil {
+			t.Fatalf("CreateRecord: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		filter string
+		want   []string
+	}{
+		{filter: `duration(result.summary.start_time, result.summary.end_time) > duration("10m")`, want: []string{"foo/results/long"}},
+		{filter: `result.create_time > ago("1h")`, want: []string{"foo/results/long", "foo/results/short"}},
+		{filter: `result.create_time > now()`},
+	} {
+		t.Run(tc.filter, func(t *testing.T) {
+			res, err := srv.ListResults(ctx, &pb.ListResultsRequest{Parent: "foo", Filter: tc.filter, OrderBy: "name"})
+			if err != nil {
+				t.Fatalf("ListResults: %v", err)
+			}
+			var got []string
+			for _, r := range res.GetResults() {
+				got = append(got, r.GetName())
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("-want, +got: %s", diff)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		filter string
+		want   []string
+	}{
+		{filter: `has_label(data, "app")`, want: []string{"failed", "succeeded"}},
+		{filter: `label(data, "app") == "a"`, want: []string{"failed"}},
+		{filter: `label(data, "app") != "a"`, want: []string{"empty", "succeeded"}},
+		{filter: `condition(data, "Succeeded").reason == "Failed"`, want: []string{"failed"}},
+		{filter: `condition(data, "Succeeded").status == "True"`, want: []string{"succeeded"}},
+		{filter: `condition(data, "Succeeded").status != "True"`, want: []string{"empty", "failed"}},
+	} {
+		t.Run(tc.filter, func(t *testing.T) {
+			res, err := srv.ListRecords(ctx, &pb.ListRecordsRequest{Parent: "foo/results/long", Filter: tc.filter, OrderBy: "name"})
+			if err != nil {
+				t.Fatalf("ListRecords: %v", err)
+			}
+			var got []string
+			for _, r := range res.GetRecords() {
+				got = append(got, strings.TrimPrefix(r.GetName(), "foo/results/long/records/"))
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("-want, +got: %s", diff)
+			}
+		})
+	}
+}
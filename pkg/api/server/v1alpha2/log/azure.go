@@ -0,0 +1,218 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	"github.com/google/uuid"
+	server "github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/apis/v1alpha2"
+)
+
+const (
+	DefaultAzureBlobBlockSize = 1024 * 1024 * 4
+)
+
+type azureBlobClient interface {
+	CommitBlockList(context.Context, []string, *blockblob.CommitBlockListOptions) (blockblob.CommitBlockListResponse, error)
+	Delete(context.Context, *blob.DeleteOptions) (blob.DeleteResponse, error)
+	DownloadStream(context.Context, *blob.DownloadStreamOptions) (blob.DownloadStreamResponse, error)
+	GetBlockList(context.Context, blockblob.BlockListType, *blockblob.GetBlockListOptions) (blockblob.GetBlockListResponse, error)
+	GetProperties(context.Context, *blob.GetPropertiesOptions) (blob.GetPropertiesResponse, error)
+	StageBlock(context.Context, string, io.ReadSeekCloser, *blockblob.StageBlockOptions) (blockblob.StageBlockResponse, error)
+}
+
+type azureBlobStream struct {
+	ctx       context.Context
+	client    azureBlobClient
+	blockSize int64
+	buffer    bytes.Buffer
+	// session prefixes the IDs of the blocks staged by the Stream, so that
+	// they don't collide with the blocks committed by other sessions.
+	session string
+	staged  int
+}
+
+// NewAzureBlobStream returns a LogStreamer that stores logs in an Azure Blob
+// Storage container, as block blobs.
+func NewAzureBlobStream(ctx context.Context, log *v1alpha2.Log, config *server.Config) (Stream, error) {
+	if log.Status.Path == "" {
+		filePath, err := FilePath(log)
+		if err != nil {
+			return nil, err
+		}
+		log.Status.Path = filePath
+	}
+
+	client, err := newAzureBlobClient(config, filepath.Join(config.LOGS_PATH, log.Status.Path))
+	if err != nil {
+		return nil, err
+	}
+
+	blockSize := config.AZURE_BLOB_BLOCK_SIZE
+	if blockSize < 1 {
+		blockSize = DefaultAzureBlobBlockSize
+	}
+
+	return &azureBlobStream{
+		ctx:       ctx,
+		client:    client,
+		blockSize: blockSize,
+		session:   uuid.NewString(),
+	}, nil
+}
+
+// newAzureBlobClient returns a client of the blob at key in the configured
+// container. The endpoint is used without credentials, e.g. for URLs holding
+// a SAS token, when no account key is configured.
+func newAzureBlobClient(cfg *server.Config, key string) (azureBlobClient, error) {
+	endpoint := cfg.AZURE_BLOB_ENDPOINT
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net/", cfg.AZURE_BLOB_ACCOUNT_NAME)
+	}
+
+	var (
+		client *service.Client
+		err    error
+	)
+	if cfg.AZURE_BLOB_ACCOUNT_KEY == "" {
+		client, err = service.NewClientWithNoCredential(endpoint, nil)
+	} else {
+		var cred *service.SharedKeyCredential
+		cred, err = service.NewSharedKeyCredential(cfg.AZURE_BLOB_ACCOUNT_NAME, cfg.AZURE_BLOB_ACCOUNT_KEY)
+		if err != nil {
+			return nil, err
+		}
+		client, err = service.NewClientWithSharedKeyCredential(endpoint, cred, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return client.NewContainerClient(cfg.AZURE_BLOB_CONTAINER).NewBlockBlobClient(key), nil
+}
+
+func (*azureBlobStream) Type() string {
+	return string(v1alpha2.AzureBlobLogType)
+}
+
+// ReadFrom buffers the logs read from r, and appends them to the blob in
+// blocks of blockSize bytes, the rest when the Stream is flushed.
+func (as *azureBlobStream) ReadFrom(r io.Reader) (int64, error) {
+	n, err := as.buffer.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+	for int64(as.buffer.Len()) >= as.blockSize {
+		if err := as.appendBlock(as.buffer.Next(int(as.blockSize))); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// appendBlock stages data as a block of the blob, then commits it after the
+// committed blocks, which appends it to the blob. Committing discards the
+// blocks staged by concurrent sessions, so blocks are committed one at a time,
+// and only if the blob is unchanged since its blocks were listed. Otherwise
+// the block is staged and committed again.
+func (as *azureBlobStream) appendBlock(data []byte) error {
+	// The IDs of the blocks of a blob must all have the same length.
+	id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s-%010d", as.session, as.staged)))
+	as.staged++
+	for {
+		if _, err := as.client.StageBlock(as.ctx, id, streaming.NopCloser(bytes.NewReader(data)), nil); err != nil {
+			return err
+		}
+
+		conditions := &blob.ModifiedAccessConditions{}
+		list, err := as.client.GetBlockList(as.ctx, blockblob.BlockListTypeCommitted, nil)
+		switch {
+		case bloberror.HasCode(err, bloberror.BlobNotFound):
+			etag := azcore.ETagAny
+			conditions.IfNoneMatch = &etag
+		case err != nil:
+			return err
+		default:
+			conditions.IfMatch = list.ETag
+		}
+		var ids []string
+		for _, block := range list.CommittedBlocks {
+			ids = append(ids, *block.Name)
+		}
+		_, err = as.client.CommitBlockList(as.ctx, append(ids, id), &blockblob.CommitBlockListOptions{
+			AccessConditions: &blob.AccessConditions{ModifiedAccessConditions: conditions},
+		})
+		if bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobAlreadyExists, bloberror.InvalidBlockList) {
+			continue
+		}
+		return err
+	}
+}
+
+func (as *azureBlobStream) WriteTo(w io.Writer) (int64, error) {
+	return as.WriteRangeTo(w, 0, 0)
+}
+
+// WriteRangeTo writes at most limit bytes of the blob, starting at offset, to
+// w through a ranged download.
+func (as *azureBlobStream) WriteRangeTo(w io.Writer, offset, limit int64) (int64, error) {
+	resp, err := as.client.DownloadStream(as.ctx, &blob.DownloadStreamOptions{
+		Range: blob.HTTPRange{
+			Offset: offset,
+			Count:  limit,
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, resp.Body)
+}
+
+// Size returns the size of the blob, 0 if it isn't stored yet, which is the
+// case until its first block is committed.
+func (as *azureBlobStream) Size() (int64, error) {
+	props, err := as.client.GetProperties(as.ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if props.ContentLength == nil {
+		return 0, nil
+	}
+	return *props.ContentLength, nil
+}
+
+// Wait waits for PollInterval, since Azure Blob Storage can't notify of blob
+// changes.
+func (as *azureBlobStream) Wait(ctx context.Context, size int64) error {
+	return poll(ctx)
+}
+
+// Flush appends the buffered logs to the blob.
+func (as *azureBlobStream) Flush() error {
+	if as.buffer.Len() == 0 {
+		return nil
+	}
+	return as.appendBlock(as.buffer.Next(as.buffer.Len()))
+}
+
+func (as *azureBlobStream) Delete() error {
+	_, err := as.client.Delete(as.ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,266 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+)
+
+// mockAzureBlobClient stores a block blob. Committing blocks discards the
+// other staged blocks, as Azure Blob Storage does.
+type mockAzureBlobClient struct {
+	t         *testing.T
+	staged    map[string][]byte
+	blocks    map[string][]byte
+	committed []string
+	etag      int
+	// beforeCommit is called before blocks are committed.
+	beforeCommit func()
+}
+
+func newMockAzureBlobClient(t *testing.T) *mockAzureBlobClient {
+	return &mockAzureBlobClient{
+		t:      t,
+		staged: map[string][]byte{},
+		blocks: map[string][]byte{},
+	}
+}
+
+func (m *mockAzureBlobClient) notFound() error {
+	if m.committed == nil {
+		return &azcore.ResponseError{ErrorCode: string(bloberror.BlobNotFound), StatusCode: http.StatusNotFound}
+	}
+	return nil
+}
+
+func (m *mockAzureBlobClient) data() []byte {
+	var data []byte
+	for _, id := range m.committed {
+		data = append(data, m.blocks[id]...)
+	}
+	return data
+}
+
+func (m *mockAzureBlobClient) currentETag() *azcore.ETag {
+	etag := azcore.ETag(strconv.Itoa(m.etag))
+	return &etag
+}
+
+func (m *mockAzureBlobClient) CommitBlockList(ctx context.Context, ids []string, options *blockblob.CommitBlockListOptions) (blockblob.CommitBlockListResponse, error) {
+	if m.beforeCommit != nil {
+		m.beforeCommit()
+	}
+	conditions := options.AccessConditions.ModifiedAccessConditions
+	switch {
+	case conditions.IfNoneMatch != nil && *conditions.IfNoneMatch == azcore.ETagAny:
+		if m.committed != nil {
+			return blockblob.CommitBlockListResponse{}, &azcore.ResponseError{ErrorCode: string(bloberror.BlobAlreadyExists), StatusCode: http.StatusConflict}
+		}
+	case conditions.IfMatch != nil:
+		if m.committed == nil || *conditions.IfMatch != *m.currentETag() {
+			return blockblob.CommitBlockListResponse{}, &azcore.ResponseError{ErrorCode: string(bloberror.ConditionNotMet), StatusCode: http.StatusPreconditionFailed}
+		}
+	default:
+		m.t.Fatal("want blocks committed conditionally")
+	}
+
+	blocks := map[string][]byte{}
+	for _, id := range ids {
+		data, ok := m.staged[id]
+		if !ok {
+			data, ok = m.blocks[id]
+		}
+		if !ok {
+			return blockblob.CommitBlockListResponse{}, &azcore.ResponseError{ErrorCode: string(bloberror.InvalidBlockList), StatusCode: http.StatusBadRequest}
+		}
+		if len(id) != len(ids[0]) {
+			m.t.Fatalf("want block IDs of the same length, got %s and %s", ids[0], id)
+		}
+		blocks[id] = data
+	}
+	m.staged, m.blocks, m.committed = map[string][]byte{}, blocks, ids
+	m.etag++
+	return blockblob.CommitBlockListResponse{}, nil
+}
+
+func (m *mockAzureBlobClient) Delete(ctx context.Context, options *blob.DeleteOptions) (blob.DeleteResponse, error) {
+	if err := m.notFound(); err != nil {
+		return blob.DeleteResponse{}, err
+	}
+	m.staged, m.blocks, m.committed = map[string][]byte{}, map[string][]byte{}, nil
+	return blob.DeleteResponse{}, nil
+}
+
+func (m *mockAzureBlobClient) DownloadStream(ctx context.Context, options *blob.DownloadStreamOptions) (blob.DownloadStreamResponse, error) {
+	if err := m.notFound(); err != nil {
+		return blob.DownloadStreamResponse{}, err
+	}
+	data := m.data()[options.Range.Offset:]
+	if options.Range.Count > 0 && options.Range.Count < int64(len(data)) {
+		data = data[:options.Range.Count]
+	}
+	return blob.DownloadStreamResponse{
+		DownloadResponse: blob.DownloadResponse{
+			Body: io.NopCloser(bytes.NewReader(data)),
+		},
+	}, nil
+}
+
+func (m *mockAzureBlobClient) GetBlockList(ctx context.Context, listType blockblob.BlockListType, options *blockblob.GetBlockListOptions) (blockblob.GetBlockListResponse, error) {
+	resp := blockblob.GetBlockListResponse{}
+	if err := m.notFound(); err != nil {
+		return resp, err
+	}
+	resp.ETag = m.currentETag()
+	for _, id := range m.committed {
+		id, size := id, int64(len(m.blocks[id]))
+		resp.CommittedBlocks = append(resp.CommittedBlocks, &blockblob.Block{Name: &id, Size: &size})
+	}
+	return resp, nil
+}
+
+func (m *mockAzureBlobClient) GetProperties(ctx context.Context, options *blob.GetPropertiesOptions) (blob.GetPropertiesResponse, error) {
+	if err := m.notFound(); err != nil {
+		return blob.GetPropertiesResponse{}, err
+	}
+	size := int64(len(m.data()))
+	return blob.GetPropertiesResponse{ContentLength: &size}, nil
+}
+
+func (m *mockAzureBlobClient) StageBlock(ctx context.Context, id string, body io.ReadSeekCloser, options *blockblob.StageBlockOptions) (blockblob.StageBlockResponse, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		m.t.Fatalf("error staging block %s: %v", id, err)
+	}
+	m.staged[id] = data
+	return blockblob.StageBlockResponse{}, nil
+}
+
+func TestAzureBlobStream(t *testing.T) {
+	client := newMockAzureBlobClient(t)
+	newStream := func(session string) *azureBlobStream {
+		return &azureBlobStream{
+			ctx:       context.Background(),
+			client:    client,
+			blockSize: 4,
+			session:   session,
+		}
+	}
+
+	s := newStream("one")
+	for _, chunk := range []string{"0123", "456789"} {
+		if n, err := s.ReadFrom(strings.NewReader(chunk)); err != nil || n != int64(len(chunk)) {
+			t.Fatalf("ReadFrom: want (%d, nil), got (%d, %v)", len(chunk), n, err)
+		}
+	}
+	// Full blocks are appended to the blob as they are read.
+	if len(client.committed) != 2 {
+		t.Errorf("want 2 blocks committed, got %d", len(client.committed))
+	}
+	if size, err := s.Size(); err != nil || size != 8 {
+		t.Fatalf("Size: want (8, nil), got (%d, %v)", size, err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.committed) != 3 {
+		t.Errorf("want 3 blocks committed, got %d", len(client.committed))
+	}
+
+	// Later sessions append to the blob.
+	s = newStream("two")
+	if _, err := s.ReadFrom(strings.NewReader("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "0123456789abc"
+	if size, err := s.Size(); err != nil || size != int64(len(want)) {
+		t.Errorf("Size: want (%d, nil), got (%d, %v)", len(want), size, err)
+	}
+
+	for _, tc := range []struct {
+		offset, limit int64
+		want          string
+	}{
+		{offset: 0, limit: 0, want: want},
+		{offset: 2, limit: 3, want: "234"},
+		{offset: 8, limit: 0, want: "89abc"},
+	} {
+		buffer := &bytes.Buffer{}
+		if _, err := s.WriteRangeTo(buffer, tc.offset, tc.limit); err != nil {
+			t.Fatal(err)
+		}
+		if got := buffer.String(); got != tc.want {
+			t.Errorf("WriteRangeTo(%d, %d): want: %s, got: %s", tc.offset, tc.limit, tc.want, got)
+		}
+	}
+
+	if err := s.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if size, err := s.Size(); err != nil || size != 0 {
+		t.Errorf("Size: want (0, nil), got (%d, %v)", size, err)
+	}
+	if err := s.Delete(); err != nil {
+		t.Errorf("Delete: want no error for a deleted log, got %v", err)
+	}
+}
+
+func TestAzureBlobStream_Concurrent(t *testing.T) {
+	client := newMockAzureBlobClient(t)
+	newStream := func(session string) *azureBlobStream {
+		return &azureBlobStream{
+			ctx:       context.Background(),
+			client:    client,
+			blockSize: 4,
+			session:   session,
+		}
+	}
+
+	one, two := newStream("one"), newStream("two")
+	// Another session commits a block between the listing of the blocks of
+	// the blob and their commit, which discards the staged block, so it is
+	// staged and committed again.
+	client.beforeCommit = func() {
+		client.beforeCommit = nil
+		if _, err := two.ReadFrom(strings.NewReader("2222")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := one.ReadFrom(strings.NewReader("1111")); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(client.data()); got != "22221111" {
+		t.Errorf("want the logs of both sessions stored, got %q", got)
+	}
+
+	client.beforeCommit = func() {
+		client.beforeCommit = nil
+		if _, err := two.ReadFrom(strings.NewReader("2")); err != nil {
+			t.Fatal(err)
+		}
+		if err := two.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := one.ReadFrom(strings.NewReader("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := one.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(client.data()); got != "2222111121" {
+		t.Errorf("want the logs of both sessions stored, got %q", got)
+	}
+}
@@ -0,0 +1,351 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/tektoncd/results/pkg/apis/v1alpha2"
+)
+
+// compressor is implemented by the writers of the supported compressions.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressedStream compresses the logs stored by a Stream, and decompresses
+// them when they are read.
+type compressedStream struct {
+	Stream
+	ctx         context.Context
+	compression v1alpha2.LogCompression
+	buffer      bytes.Buffer
+	writer      compressor
+	// decoder decompresses the log as it grows, so that Size only
+	// decompresses the logs stored since it was last called.
+	decoder *decoder
+}
+
+// newCompressedStream wraps s to store logs with the given compression.
+func newCompressedStream(ctx context.Context, s Stream, compression v1alpha2.LogCompression) (Stream, error) {
+	switch compression {
+	case v1alpha2.GzipLogCompression, v1alpha2.ZstdLogCompression:
+		return &compressedStream{
+			Stream:      s,
+			ctx:         ctx,
+			compression: compression,
+		}, nil
+	}
+	return nil, fmt.Errorf("log compression %s is not supported", compression)
+}
+
+// Stored returns the Stream of the log as it is stored, compressed if it is.
+func Stored(s Stream) Stream {
+	if cs, ok := s.(*compressedStream); ok {
+		return cs.Stream
+	}
+	return s
+}
+
+// ReadFrom compresses the logs read from r and stores them. The compressor is
+// flushed once r is read, so that the logs stored so far can be read while
+// more are being stored.
+func (cs *compressedStream) ReadFrom(r io.Reader) (int64, error) {
+	if cs.writer == nil {
+		w, err := cs.newWriter(&cs.buffer)
+		if err != nil {
+			return 0, err
+		}
+		cs.writer = w
+	}
+	n, err := io.Copy(cs.writer, r)
+	if err != nil {
+		return n, err
+	}
+	if err := cs.writer.Flush(); err != nil {
+		return n, err
+	}
+	return n, cs.store()
+}
+
+// store stores the compressed bytes written so far.
+func (cs *compressedStream) store() error {
+	_, err := cs.Stream.ReadFrom(&cs.buffer)
+	cs.buffer.Reset()
+	return err
+}
+
+// Flush ends the compressed stream, then flushes the underlying Stream.
+// Logs stored later by the same Stream are compressed as a new stream, which
+// are read one after the other.
+func (cs *compressedStream) Flush() error {
+	if cs.writer != nil {
+		if err := cs.writer.Close(); err != nil {
+			return err
+		}
+		cs.writer = nil
+		if err := cs.store(); err != nil {
+			return err
+		}
+	}
+	return cs.Stream.Flush()
+}
+
+func (cs *compressedStream) WriteTo(w io.Writer) (int64, error) {
+	return cs.WriteRangeTo(w, 0, 0)
+}
+
+// WriteRangeTo writes the requested range of the log to w. Ranges within the
+// logs decompressed by the last call to Size are written from them, others
+// are decompressed from the start of the log, since compressed logs can't be
+// seeked.
+func (cs *compressedStream) WriteRangeTo(w io.Writer, offset, limit int64) (int64, error) {
+	if d := cs.decoder; d != nil && limit > 0 {
+		data := d.output.data.Bytes()
+		start := d.output.size - int64(len(data))
+		if offset >= start && offset+limit <= d.output.size {
+			n, err := w.Write(data[offset-start : offset-start+limit])
+			return int64(n), err
+		}
+	}
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		_, err := cs.Stream.WriteTo(pw)
+		pw.CloseWithError(err)
+	}()
+
+	r, err := cs.newReader(pr)
+	if err != nil {
+		return 0, truncated(err)
+	}
+	defer r.Close()
+	if _, err := io.CopyN(io.Discard, r, offset); err != nil {
+		return 0, truncated(err)
+	}
+	if limit > 0 {
+		n, err := io.CopyN(w, r, limit)
+		return n, truncated(err)
+	}
+	n, err := io.Copy(w, r)
+	return n, truncated(err)
+}
+
+// truncated ignores the errors returned when reaching the end of a log,
+// including logs which are still being stored and end in the middle of their
+// compressed stream.
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+	return err
+}
+
+// Size returns the size of the log uncompressed. The compressed size tells
+// whether the log grew since the last call, in which case only the logs
+// stored since are decompressed, and kept for WriteRangeTo.
+func (cs *compressedStream) Size() (int64, error) {
+	stored, err := cs.Stream.Size()
+	if err != nil || stored == 0 {
+		return 0, err
+	}
+	d := cs.decoder
+	if d != nil && stored < d.stored {
+		// The log was stored again from its start.
+		d.close()
+		d = nil
+	}
+	if d == nil {
+		d = newDecoder(cs.ctx, cs.newReader)
+		cs.decoder = d
+	}
+	if stored == d.stored {
+		return d.output.size, nil
+	}
+	// The whole log is decompressed by the first call, only keep the logs
+	// appended later.
+	d.output.data.Reset()
+	d.output.keep = d.stored > 0
+	if _, err := cs.Stream.WriteRangeTo(d, d.stored, stored-d.stored); err != nil {
+		return 0, err
+	}
+	return d.output.size, nil
+}
+
+// Wait waits for the stored log to grow, since size is uncompressed.
+func (cs *compressedStream) Wait(ctx context.Context, size int64) error {
+	stored, err := cs.Stream.Size()
+	if err != nil {
+		return err
+	}
+	return cs.Stream.Wait(ctx, stored)
+}
+
+func (cs *compressedStream) newWriter(w io.Writer) (compressor, error) {
+	if cs.compression == v1alpha2.ZstdLogCompression {
+		return zstd.NewWriter(w)
+	}
+	return gzip.NewWriter(w), nil
+}
+
+// decoder is a pipe to a decompressor, which keeps its state between the
+// compressed logs written to the decoder. The decompressor runs in its own
+// goroutine, reading from the decoder, until the decoder is closed or its
+// context is done.
+type decoder struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	input  chan []byte
+	idle   chan struct{}
+	done   chan error
+	err    error
+	// stored is the number of compressed bytes written to the decoder.
+	stored int64
+	output decoded
+
+	// pending and reading are only used by the decompressor.
+	pending []byte
+	reading bool
+}
+
+// decoded counts the bytes decompressed by a decoder, and keeps them if keep
+// is set.
+type decoded struct {
+	data bytes.Buffer
+	size int64
+	keep bool
+}
+
+func (d *decoded) Write(p []byte) (int, error) {
+	d.size += int64(len(p))
+	if d.keep {
+		return d.data.Write(p)
+	}
+	return len(p), nil
+}
+
+func newDecoder(ctx context.Context, newReader func(io.Reader) (io.ReadCloser, error)) *decoder {
+	ctx, cancel := context.WithCancel(ctx)
+	d := &decoder{
+		ctx:    ctx,
+		cancel: cancel,
+		input:  make(chan []byte),
+		idle:   make(chan struct{}),
+		done:   make(chan error, 1),
+	}
+	go func() {
+		r, err := newReader(d)
+		if err == nil {
+			_, err = io.Copy(&d.output, r)
+			r.Close()
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		d.done <- err
+	}()
+	return d
+}
+
+// Write passes p to the decompressor, and returns once it is decompressed,
+// which is when the decompressor waits for more.
+func (d *decoder) Write(p []byte) (int, error) {
+	if d.err != nil || len(p) == 0 {
+		return 0, d.err
+	}
+	select {
+	case d.input <- p:
+	case d.err = <-d.done:
+		return 0, d.err
+	}
+	select {
+	case <-d.idle:
+	case d.err = <-d.done:
+		return 0, d.err
+	}
+	d.stored += int64(len(p))
+	return len(p), nil
+}
+
+// Read is called by the decompressor for the compressed logs, blocking until
+// more are written.
+func (d *decoder) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if d.reading {
+			select {
+			case d.idle <- struct{}{}:
+			case <-d.ctx.Done():
+				return 0, d.ctx.Err()
+			}
+		}
+		select {
+		case d.pending = <-d.input:
+			d.reading = true
+		case <-d.ctx.Done():
+			return 0, d.ctx.Err()
+		}
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// close stops the decompressor.
+func (d *decoder) close() {
+	d.cancel()
+}
+
+func (cs *compressedStream) newReader(r io.Reader) (io.ReadCloser, error) {
+	if cs.compression == v1alpha2.ZstdLogCompression {
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return gzip.NewReader(r)
+}
+
+// tailOffsetFrom returns the offset of the last lines lines of a log by
+// reading it from its start, for logs which are expensive to read backwards.
+func tailOffsetFrom(s Stream, lines int64) (int64, error) {
+	t := &lineStarts{lines: lines, starts: []int64{0}}
+	if _, err := s.WriteRangeTo(t, 0, 0); err != nil {
+		return 0, err
+	}
+	// A final newline terminates the last line rather than starting a new one.
+	if n := len(t.starts); n > 1 && t.starts[n-1] == t.size {
+		t.starts = t.starts[:n-1]
+	}
+	if int64(len(t.starts)) < lines {
+		return 0, nil
+	}
+	return t.starts[int64(len(t.starts))-lines], nil
+}
+
+// lineStarts records the offsets of the last lines+1 lines written to it.
+type lineStarts struct {
+	lines  int64
+	size   int64
+	starts []int64
+}
+
+func (t *lineStarts) Write(p []byte) (int, error) {
+	for i, b := range p {
+		if b != '\n' {
+			continue
+		}
+		t.starts = append(t.starts, t.size+int64(i)+1)
+		if int64(len(t.starts)) > t.lines+1 {
+			t.starts = t.starts[1:]
+		}
+	}
+	t.size += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,257 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/apis/v1alpha2"
+)
+
+func TestCompressedStream(t *testing.T) {
+	for _, compression := range []v1alpha2.LogCompression{v1alpha2.GzipLogCompression, v1alpha2.ZstdLogCompression} {
+		t.Run(string(compression), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "log")
+			stream, err := newCompressedStream(context.Background(), &fileStream{path: path, size: DefaultBufferSize}, compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, chunk := range []string{"one\n", "two\n"} {
+				if _, err := stream.ReadFrom(strings.NewReader(chunk)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Logs can be read while they are being stored.
+			if got := readRange(t, stream, 0, 0); got != "one\ntwo\n" {
+				t.Errorf("want: %q, got: %q", "one\ntwo\n", got)
+			}
+			if err := stream.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Logs stored later are appended as a new compressed stream.
+			if _, err := stream.ReadFrom(strings.NewReader("three\n")); err != nil {
+				t.Fatal(err)
+			}
+			if err := stream.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			want := "one\ntwo\nthree\n"
+			if got := readRange(t, stream, 0, 0); got != want {
+				t.Errorf("want: %q, got: %q", want, got)
+			}
+			if got := readRange(t, stream, 4, 5); got != "two\nt" {
+				t.Errorf("want: %q, got: %q", "two\nt", got)
+			}
+			if got := readRange(t, stream, 20, 0); got != "" {
+				t.Errorf("want: %q, got: %q", "", got)
+			}
+			if size, err := stream.Size(); err != nil || size != int64(len(want)) {
+				t.Errorf("Size: want (%d, nil), got (%d, %v)", len(want), size, err)
+			}
+			offset, err := TailOffset(stream, int64(len(want)), 2)
+			if err != nil || offset != 4 {
+				t.Errorf("TailOffset: want (4, nil), got (%d, %v)", offset, err)
+			}
+
+			stored, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := readRange(t, Stored(stream), 0, 0); got != string(stored) {
+				t.Errorf("Stored: want: %q, got: %q", stored, got)
+			}
+
+			path = filepath.Join(t.TempDir(), "log")
+			stream, err = newCompressedStream(context.Background(), &fileStream{path: path, size: DefaultBufferSize}, compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := strings.Repeat("Step 1/3 : building\n", 1000)
+			if _, err := stream.ReadFrom(strings.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+			if err := stream.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() > int64(len(data)/10) {
+				t.Errorf("want the log stored in at most %d bytes, got %d", len(data)/10, info.Size())
+			}
+		})
+	}
+}
+
+// rangeStream records the offsets of the ranges read from a Stream.
+type rangeStream struct {
+	Stream
+	offsets []int64
+}
+
+func (s *rangeStream) WriteRangeTo(w io.Writer, offset, limit int64) (int64, error) {
+	s.offsets = append(s.offsets, offset)
+	return s.Stream.WriteRangeTo(w, offset, limit)
+}
+
+func TestCompressedStream_Follow(t *testing.T) {
+	for _, compression := range []v1alpha2.LogCompression{v1alpha2.GzipLogCompression, v1alpha2.ZstdLogCompression} {
+		t.Run(string(compression), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			path := filepath.Join(t.TempDir(), "log")
+			writer, err := newCompressedStream(ctx, &fileStream{path: path, size: DefaultBufferSize}, compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored := &rangeStream{Stream: &fileStream{path: path, size: DefaultBufferSize}}
+			reader, err := newCompressedStream(ctx, stored, compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// read is the size of the stored log when it was last read.
+			var want string
+			var read int64
+			for _, chunk := range []string{"one\n", "two\n", "", "three\n"} {
+				if chunk == "" {
+					// Logs stored later are appended as a new compressed
+					// stream.
+					if err := writer.Flush(); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				if _, err := writer.ReadFrom(strings.NewReader(chunk)); err != nil {
+					t.Fatal(err)
+				}
+				want += chunk
+
+				stored.offsets = nil
+				size, err := reader.Size()
+				if err != nil || size != int64(len(want)) {
+					t.Fatalf("Size: want (%d, nil), got (%d, %v)", len(want), size, err)
+				}
+				start := size - int64(len(chunk))
+				if got := readRange(t, reader, start, size-start); got != chunk {
+					t.Errorf("want: %q, got: %q", chunk, got)
+				}
+				// Only the logs stored since the last call are decompressed.
+				if len(stored.offsets) != 1 || stored.offsets[0] != read {
+					t.Errorf("want the log read from %d, got ranges at %v", read, stored.offsets)
+				}
+				if read, err = stored.Size(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// The size of a log which didn't grow is known without reading it.
+			stored.offsets = nil
+			if size, err := reader.Size(); err != nil || size != int64(len(want)) {
+				t.Errorf("Size: want (%d, nil), got (%d, %v)", len(want), size, err)
+			}
+			if len(stored.offsets) != 0 {
+				t.Errorf("want the log not read, got ranges at %v", stored.offsets)
+			}
+			if got := readRange(t, reader, 0, 0); got != want {
+				t.Errorf("want: %q, got: %q", want, got)
+			}
+		})
+	}
+}
+
+func readRange(t *testing.T, s Stream, offset, limit int64) string {
+	t.Helper()
+	buffer := &bytes.Buffer{}
+	if _, err := s.WriteRangeTo(buffer, offset, limit); err != nil {
+		t.Fatalf("WriteRangeTo(%d, %d): %v", offset, limit, err)
+	}
+	return buffer.String()
+}
+
+func TestNewStream_Compression(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		LOGS_PATH:        dir,
+		LOGS_COMPRESSION: string(v1alpha2.GzipLogCompression),
+	}
+
+	// Logs with nothing stored are compressed with the configured compression.
+	log := &v1alpha2.Log{
+		Spec: v1alpha2.LogSpec{
+			Type: v1alpha2.FileLogType,
+		},
+		Status: v1alpha2.LogStatus{
+			Path: "new",
+		},
+	}
+	stream, err := NewStream(context.Background(), log, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.Status.Compression != v1alpha2.GzipLogCompression {
+		t.Errorf("want compression %s, got %q", v1alpha2.GzipLogCompression, log.Status.Compression)
+	}
+	if _, err := stream.ReadFrom(strings.NewReader("test data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(dir, "new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(r); err != nil || string(b) != "test data" {
+		t.Errorf("want (%q, nil), got (%q, %v)", "test data", b, err)
+	}
+
+	// Logs stored uncompressed are still read as they are.
+	if err := os.WriteFile(filepath.Join(dir, "old"), []byte("test data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	log = &v1alpha2.Log{
+		Spec: v1alpha2.LogSpec{
+			Type: v1alpha2.FileLogType,
+		},
+		Status: v1alpha2.LogStatus{
+			Path: "old",
+			Size: 9,
+		},
+	}
+	stream, err = NewStream(context.Background(), log, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.Status.Compression != "" {
+		t.Errorf("want no compression, got %q", log.Status.Compression)
+	}
+	if got := readRange(t, stream, 0, 0); got != "test data" {
+		t.Errorf("want: %q, got: %q", "test data", got)
+	}
+
+	cfg.LOGS_COMPRESSION = "lz4"
+	log = &v1alpha2.Log{
+		Spec: v1alpha2.LogSpec{
+			Type: v1alpha2.FileLogType,
+		},
+	}
+	if _, err := NewStream(context.Background(), log, cfg); err == nil {
+		t.Error("want an error for an unsupported compression")
+	}
+}
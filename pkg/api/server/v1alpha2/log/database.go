@@ -0,0 +1,204 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/db/errors"
+	"github.com/tektoncd/results/pkg/apis/v1alpha2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// databaseChunkSize is the size of the chunks logs are stored in by the
+	// Database log type. The chunk holding an offset is found from it, so it
+	// can't change once logs are stored.
+	databaseChunkSize = 32 * 1024
+	// databaseReadBatch is the number of chunks read at a time.
+	databaseReadBatch = 32
+)
+
+type databaseStream struct {
+	ctx      context.Context
+	db       *gorm.DB
+	parent   string
+	resultID string
+	recordID string
+}
+
+// NewDatabaseStream returns a LogStreamer that stores logs in the database, as
+// chunks of the Log Record.
+func NewDatabaseStream(ctx context.Context, gdb *gorm.DB, record *db.Record) (Stream, error) {
+	if gdb == nil || record == nil {
+		return nil, fmt.Errorf("log streamer type %s requires the database and the Log Record", v1alpha2.DatabaseLogType)
+	}
+	return &databaseStream{
+		ctx:      ctx,
+		db:       gdb,
+		parent:   record.Parent,
+		resultID: record.ResultID,
+		recordID: record.ID,
+	}, nil
+}
+
+func (*databaseStream) Type() string {
+	return string(v1alpha2.DatabaseLogType)
+}
+
+// chunks returns a query of the chunks of the log.
+func (ds *databaseStream) chunks(tx *gorm.DB) *gorm.DB {
+	return tx.Model(&db.LogChunk{}).
+		Where("parent = ? AND result_id = ? AND record_id = ?", ds.parent, ds.resultID, ds.recordID)
+}
+
+// ReadFrom appends the logs read from r to the last chunk of the log, until
+// it is full, then to new chunks. The append is retried if a concurrent
+// session added the same chunk meanwhile.
+func (ds *databaseStream) ReadFrom(r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil || len(data) == 0 {
+		return 0, err
+	}
+	for {
+		err = errors.Wrap(ds.db.WithContext(ds.ctx).Transaction(func(tx *gorm.DB) error {
+			return ds.append(tx, data)
+		}))
+		if status.Code(err) != codes.AlreadyExists {
+			break
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// append appends data to the log in the transaction tx. The last chunk is
+// locked, so that concurrent sessions append to it in turn.
+func (ds *databaseStream) append(tx *gorm.DB, data []byte) error {
+	var last []*db.LogChunk
+	q := ds.chunks(tx).Clauses(clause.Locking{Strength: "UPDATE"}).Order("chunk_index DESC").Limit(1).Find(&last)
+	if q.Error != nil {
+		return q.Error
+	}
+	var index int64
+	if len(last) > 0 {
+		chunk := last[0]
+		if free := databaseChunkSize - len(chunk.Data); free > 0 {
+			if free > len(data) {
+				free = len(data)
+			}
+			q := ds.chunks(tx).Where("chunk_index = ?", chunk.Index).
+				Update("data", append(chunk.Data, data[:free]...))
+			if q.Error != nil {
+				return q.Error
+			}
+			data = data[free:]
+		}
+		index = chunk.Index + 1
+	}
+
+	var chunks []*db.LogChunk
+	for ; len(data) > 0; index++ {
+		size := databaseChunkSize
+		if size > len(data) {
+			size = len(data)
+		}
+		chunks = append(chunks, &db.LogChunk{
+			Parent:   ds.parent,
+			ResultID: ds.resultID,
+			RecordID: ds.recordID,
+			Index:    index,
+			Data:     data[:size],
+		})
+		data = data[size:]
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(chunks, databaseReadBatch).Error
+}
+
+func (ds *databaseStream) WriteTo(w io.Writer) (int64, error) {
+	return ds.WriteRangeTo(w, 0, 0)
+}
+
+// WriteRangeTo reads the chunks of the log from the one holding offset, in
+// batches, and writes at most limit bytes from offset to w.
+func (ds *databaseStream) WriteRangeTo(w io.Writer, offset, limit int64) (int64, error) {
+	var n int64
+	index, skip := offset/databaseChunkSize, offset%databaseChunkSize
+	for {
+		var chunks []*db.LogChunk
+		q := ds.chunks(ds.db.WithContext(ds.ctx)).
+			Where("chunk_index >= ?", index).
+			Order("chunk_index").
+			Limit(databaseReadBatch).
+			Find(&chunks)
+		if q.Error != nil {
+			return n, q.Error
+		}
+		for _, chunk := range chunks {
+			data := chunk.Data
+			if skip > int64(len(data)) {
+				skip = int64(len(data))
+			}
+			data, skip = data[skip:], 0
+			if limit > 0 && n+int64(len(data)) > limit {
+				data = data[:limit-n]
+			}
+			m, err := w.Write(data)
+			n += int64(m)
+			if err != nil {
+				return n, err
+			}
+			if limit > 0 && n == limit {
+				return n, nil
+			}
+		}
+		if len(chunks) < databaseReadBatch {
+			return n, nil
+		}
+		index = chunks[len(chunks)-1].Index + 1
+	}
+}
+
+// Size returns the size of the log from its last chunk.
+func (ds *databaseStream) Size() (int64, error) {
+	var last []struct {
+		ChunkIndex int64
+		Size       int64
+	}
+	q := ds.chunks(ds.db.WithContext(ds.ctx)).
+		Select("chunk_index, length(data) AS size").
+		Order("chunk_index DESC").
+		Limit(1).
+		Find(&last)
+	if q.Error != nil {
+		return 0, q.Error
+	}
+	if len(last) == 0 {
+		return 0, nil
+	}
+	return last[0].ChunkIndex*databaseChunkSize + last[0].Size, nil
+}
+
+// Wait waits for PollInterval, since the database can't notify of new chunks.
+func (ds *databaseStream) Wait(ctx context.Context, size int64) error {
+	return poll(ctx)
+}
+
+// Delete deletes the chunks of the log, which are deleted along with their
+// Record otherwise.
+func (ds *databaseStream) Delete() error {
+	return ds.chunks(ds.db.WithContext(ds.ctx)).Delete(&db.LogChunk{}).Error
+}
+
+func (*databaseStream) Flush() error {
+	return nil
+}
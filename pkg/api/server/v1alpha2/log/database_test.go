@@ -0,0 +1,163 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/db/migrate"
+	"github.com/tektoncd/results/pkg/api/server/test"
+	"github.com/tektoncd/results/pkg/apis/v1alpha2"
+	"gorm.io/gorm"
+)
+
+func newLogRecord(t *testing.T, gdb *gorm.DB) *db.Record {
+	t.Helper()
+	if _, err := migrate.New(gdb).Up(); err != nil {
+		t.Fatalf("failed to migrate the database: %v", err)
+	}
+	if err := gdb.Create(&db.Result{Parent: "foo", ID: "1", Name: "bar"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	rec := &db.Record{
+		Parent:     "foo",
+		ResultID:   "1",
+		ResultName: "bar",
+		ID:         "2",
+		Name:       "baz",
+		Type:       v1alpha2.LogRecordType,
+		Data:       []byte("{}"),
+	}
+	if err := gdb.Create(rec).Error; err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestDatabaseStream(t *testing.T) {
+	gdb := test.NewDB(t)
+	rec := newLogRecord(t, gdb)
+	stream, err := NewDatabaseStream(context.Background(), gdb, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, err := stream.Size(); err != nil || size != 0 {
+		t.Fatalf("Size: want (0, nil), got (%d, %v)", size, err)
+	}
+
+	// The second write fills up the last chunk before adding new ones, as do
+	// writes of later UpdateLog sessions.
+	data := strings.Repeat("0123456789", 7000)
+	for _, chunk := range []string{data[:40000], data[40000:]} {
+		if n, err := stream.ReadFrom(strings.NewReader(chunk)); err != nil || n != int64(len(chunk)) {
+			t.Fatalf("ReadFrom: want (%d, nil), got (%d, %v)", len(chunk), n, err)
+		}
+	}
+	var chunks []*db.LogChunk
+	if err := gdb.Order("chunk_index").Find(&chunks).Error; err != nil {
+		t.Fatal(err)
+	}
+	var sizes []int
+	for _, c := range chunks {
+		sizes = append(sizes, len(c.Data))
+	}
+	if len(sizes) != 3 || sizes[0] != databaseChunkSize || sizes[1] != databaseChunkSize || sizes[2] != len(data)-2*databaseChunkSize {
+		t.Errorf("want chunks of %d, %d and %d bytes, got %v", databaseChunkSize, databaseChunkSize, len(data)-2*databaseChunkSize, sizes)
+	}
+	if size, err := stream.Size(); err != nil || size != int64(len(data)) {
+		t.Errorf("Size: want (%d, nil), got (%d, %v)", len(data), size, err)
+	}
+
+	for _, tc := range []struct {
+		offset, limit int64
+	}{
+		{offset: 0, limit: 0},
+		{offset: databaseChunkSize - 5, limit: 10},
+		{offset: 2*databaseChunkSize + 1, limit: 0},
+		{offset: 100, limit: 2 * databaseChunkSize},
+		{offset: int64(len(data)), limit: 0},
+	} {
+		want := data[tc.offset:]
+		if tc.limit > 0 {
+			want = want[:tc.limit]
+		}
+		buffer := &bytes.Buffer{}
+		n, err := stream.WriteRangeTo(buffer, tc.offset, tc.limit)
+		if err != nil || n != int64(len(want)) {
+			t.Errorf("WriteRangeTo(%d, %d): want (%d, nil), got (%d, %v)", tc.offset, tc.limit, len(want), n, err)
+		}
+		if buffer.String() != want {
+			t.Errorf("WriteRangeTo(%d, %d): got the wrong data", tc.offset, tc.limit)
+		}
+	}
+
+	if err := stream.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if size, err := stream.Size(); err != nil || size != 0 {
+		t.Errorf("Size: want (0, nil), got (%d, %v)", size, err)
+	}
+}
+
+func TestDatabaseStream_DeleteRecord(t *testing.T) {
+	gdb := test.NewDB(t)
+	rec := newLogRecord(t, gdb)
+	stream, err := NewDatabaseStream(context.Background(), gdb, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.ReadFrom(strings.NewReader("test data")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Chunks are deleted along with their Record.
+	if err := gdb.Delete(rec).Error; err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if err := gdb.Model(&db.LogChunk{}).Count(&count).Error; err != nil || count != 0 {
+		t.Errorf("want no chunk left, got (%d, %v)", count, err)
+	}
+}
+
+func TestDatabaseStream_Conflict(t *testing.T) {
+	gdb := test.NewDB(t)
+	rec := newLogRecord(t, gdb)
+	stream, err := NewDatabaseStream(context.Background(), gdb, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A concurrent session adds the chunk the stream is adding, which makes
+	// the first attempt conflict.
+	attempts := 0
+	err = gdb.Callback().Create().Before("gorm:create").Register("test:conflict", func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Dest.([]*db.LogChunk); !ok {
+			return
+		}
+		attempts++
+		if attempts > 1 {
+			return
+		}
+		q := tx.Session(&gorm.Session{NewDB: true}).Exec("INSERT INTO log_chunks (parent, result_id, record_id, chunk_index, data) VALUES (?, ?, ?, ?, ?)",
+			rec.Parent, rec.ResultID, rec.ID, 0, []byte("other"))
+		if q.Error != nil {
+			t.Fatal(q.Error)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := stream.ReadFrom(strings.NewReader("test data")); err != nil || n != 9 {
+		t.Fatalf("ReadFrom: want (9, nil), got (%d, %v)", n, err)
+	}
+	if attempts != 2 {
+		t.Errorf("want the conflicting append retried once, got %d attempts", attempts)
+	}
+	buffer := &bytes.Buffer{}
+	if _, err := stream.WriteTo(buffer); err != nil || buffer.String() != "test data" {
+		t.Errorf("WriteTo: want (test data, nil), got (%s, %v)", buffer.String(), err)
+	}
+}
@@ -3,11 +3,15 @@ package log
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/tektoncd/results/pkg/api/server/config"
 	"io"
 	"os"
 	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
 
 	"github.com/tektoncd/results/pkg/apis/v1alpha2"
 )
@@ -67,6 +71,74 @@ func (fs *fileStream) WriteTo(w io.Writer) (n int64, err error) {
 	return
 }
 
+// WriteRangeTo seeks to offset in the log file and writes at most limit bytes
+// from there to the provided writer.
+func (fs *fileStream) WriteRangeTo(w io.Writer, offset, limit int64) (n int64, err error) {
+	file, err := os.Open(fs.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file %s: %v", fs.path, err)
+	}
+	defer func() {
+		closeErr := file.Close()
+		if err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}()
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek file %s: %v", fs.path, err)
+	}
+	var r io.Reader = file
+	if limit > 0 {
+		r = io.LimitReader(file, limit)
+	}
+	return bufio.NewReaderSize(r, fs.size).WriteTo(w)
+}
+
+// Size returns the size of the log file, 0 if it doesn't exist yet.
+func (fs *fileStream) Size() (int64, error) {
+	info, err := os.Stat(fs.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat %s: %v", fs.path, err)
+	}
+	return info.Size(), nil
+}
+
+// Wait watches the directory of the log file, which may not exist yet, until
+// the file grows past size.
+func (fs *fileStream) Wait(ctx context.Context, size int64) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %v", fs.path, err)
+	}
+	defer watcher.Close()
+	// Missing directories are polled for, as for other backends.
+	_ = watcher.Add(filepath.Dir(fs.path))
+
+	// Check after watching to not miss writes made in between.
+	if n, err := fs.Size(); err != nil || n > size {
+		return err
+	}
+	timer := time.NewTimer(PollInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		case e := <-watcher.Events:
+			if e.Name == fs.path {
+				return nil
+			}
+		case err := <-watcher.Errors:
+			return err
+		}
+	}
+}
+
 // ReadFrom reads the log contents from the provided io.Reader, and writes them to the TaskRun log
 // file on disk.
 func (fs *fileStream) ReadFrom(r io.Reader) (n int64, err error) {
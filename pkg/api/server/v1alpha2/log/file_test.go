@@ -2,10 +2,13 @@ package log
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFileStream_WriteTo(t *testing.T) {
@@ -77,3 +80,64 @@ func TestFileStream_Delete(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestFileStream_WriteRangeTo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	if err := os.WriteFile(path, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stream := &fileStream{
+		path: path,
+	}
+	for _, tc := range []struct {
+		offset, limit int64
+		want          string
+	}{
+		{offset: 0, limit: 0, want: "0123456789"},
+		{offset: 2, limit: 3, want: "234"},
+		{offset: 7, limit: 10, want: "789"},
+		{offset: 12, limit: 0, want: ""},
+	} {
+		buffer := &bytes.Buffer{}
+		if _, err := stream.WriteRangeTo(buffer, tc.offset, tc.limit); err != nil {
+			t.Fatal(err)
+		}
+		if got := buffer.String(); got != tc.want {
+			t.Errorf("WriteRangeTo(%d, %d): want: %s, got: %s", tc.offset, tc.limit, tc.want, got)
+		}
+	}
+}
+
+func TestFileStream_Wait(t *testing.T) {
+	stream := &fileStream{
+		path: filepath.Join(t.TempDir(), "log"),
+	}
+	if size, err := stream.Size(); err != nil || size != 0 {
+		t.Fatalf("Size: want (0, nil), got (%d, %v)", size, err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- stream.Wait(context.Background(), 0)
+	}()
+	if _, err := stream.ReadFrom(strings.NewReader("test data")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Wait: %v", err)
+		}
+	case <-time.After(2 * PollInterval):
+		t.Fatal("Wait did not return once the file grew")
+	}
+	if size, err := stream.Size(); err != nil || size != 9 {
+		t.Fatalf("Size: want (9, nil), got (%d, %v)", size, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := stream.Wait(ctx, 9); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait: want context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	server "github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/apis/v1alpha2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+const (
+	// DefaultGCSChunkSize is the default size of the chunks of resumable
+	// uploads, which must be a multiple of 256 KiB.
+	DefaultGCSChunkSize = 16 * 1024 * 1024
+)
+
+// gcsClient is the subset of the operations on the objects of a Cloud Storage
+// bucket used to store logs.
+type gcsClient interface {
+	// NewWriter returns a writer which uploads an object in chunks of
+	// chunkSize through a resumable upload, completed when it is closed.
+	NewWriter(ctx context.Context, key string, chunkSize int) io.WriteCloser
+	// NewRangeReader reads length bytes of an object from offset, until its
+	// end if length is negative.
+	NewRangeReader(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	Attrs(ctx context.Context, key string) (*storage.ObjectAttrs, error)
+	// Compose concatenates sources into key, if the generation of key is
+	// generation, or if key doesn't exist when generation is 0.
+	Compose(ctx context.Context, key string, generation int64, sources ...string) error
+	Delete(ctx context.Context, key string) error
+}
+
+// gcsBucket implements gcsClient with the Cloud Storage client library.
+type gcsBucket struct {
+	*storage.BucketHandle
+}
+
+func (b *gcsBucket) NewWriter(ctx context.Context, key string, chunkSize int) io.WriteCloser {
+	w := b.Object(key).NewWriter(ctx)
+	w.ChunkSize = chunkSize
+	return w
+}
+
+func (b *gcsBucket) NewRangeReader(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	return b.Object(key).NewRangeReader(ctx, offset, length)
+}
+
+func (b *gcsBucket) Attrs(ctx context.Context, key string) (*storage.ObjectAttrs, error) {
+	return b.Object(key).Attrs(ctx)
+}
+
+func (b *gcsBucket) Compose(ctx context.Context, key string, generation int64, sources ...string) error {
+	var objects []*storage.ObjectHandle
+	for _, source := range sources {
+		objects = append(objects, b.Object(source))
+	}
+	conditions := storage.Conditions{GenerationMatch: generation}
+	if generation == 0 {
+		conditions = storage.Conditions{DoesNotExist: true}
+	}
+	_, err := b.Object(key).If(conditions).ComposerFrom(objects...).Run(ctx)
+	return err
+}
+
+func (b *gcsBucket) Delete(ctx context.Context, key string) error {
+	return b.Object(key).Delete(ctx)
+}
+
+type gcsStream struct {
+	ctx       context.Context
+	client    gcsClient
+	key       string
+	chunkSize int
+	writer    io.WriteCloser
+	// upload is the object being uploaded. Objects can't be appended to, so
+	// logs are uploaded to another object, composed with the log object once
+	// uploaded.
+	upload string
+}
+
+// NewGCSStream returns a LogStreamer that stores logs in a Google Cloud
+// Storage bucket, through resumable uploads.
+func NewGCSStream(ctx context.Context, log *v1alpha2.Log, config *server.Config) (Stream, error) {
+	if log.Status.Path == "" {
+		filePath, err := FilePath(log)
+		if err != nil {
+			return nil, err
+		}
+		log.Status.Path = filePath
+	}
+
+	client, err := newGCSClient(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := config.GCS_CHUNK_SIZE
+	if chunkSize < 1 {
+		chunkSize = DefaultGCSChunkSize
+	}
+
+	return &gcsStream{
+		ctx:       ctx,
+		client:    client,
+		key:       filepath.Join(config.LOGS_PATH, log.Status.Path),
+		chunkSize: chunkSize,
+	}, nil
+}
+
+// newGCSClient returns a client of the configured bucket. The client library
+// uses the emulator set by STORAGE_EMULATOR_HOST, without authentication.
+func newGCSClient(ctx context.Context, cfg *server.Config) (gcsClient, error) {
+	var opts []option.ClientOption
+	if cfg.GCS_ENDPOINT != "" {
+		opts = append(opts, option.WithEndpoint(cfg.GCS_ENDPOINT))
+	}
+	if cfg.GCS_CREDENTIALS_PATH != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.GCS_CREDENTIALS_PATH))
+	}
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &gcsBucket{client.Bucket(cfg.GCS_BUCKET_NAME)}, nil
+}
+
+func (*gcsStream) Type() string {
+	return string(v1alpha2.GCSLogType)
+}
+
+// ReadFrom uploads the logs read from r. Chunks are uploaded as they fill up,
+// and the upload completes when the Stream is flushed.
+func (gs *gcsStream) ReadFrom(r io.Reader) (int64, error) {
+	if gs.writer == nil {
+		gs.upload = fmt.Sprintf("%s.%s", gs.key, uuid.NewString())
+		gs.writer = gs.client.NewWriter(gs.ctx, gs.upload, gs.chunkSize)
+	}
+	return io.Copy(gs.writer, r)
+}
+
+func (gs *gcsStream) WriteTo(w io.Writer) (int64, error) {
+	return gs.WriteRangeTo(w, 0, 0)
+}
+
+// WriteRangeTo writes at most limit bytes of the object, starting at offset,
+// to w through a ranged read.
+func (gs *gcsStream) WriteRangeTo(w io.Writer, offset, limit int64) (int64, error) {
+	length := limit
+	if length == 0 {
+		length = -1
+	}
+	r, err := gs.client.NewRangeReader(gs.ctx, gs.key, offset, length)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return io.Copy(w, r)
+}
+
+// Size returns the size of the object, 0 if it isn't stored yet, which is the
+// case until its first upload completes.
+func (gs *gcsStream) Size() (int64, error) {
+	attrs, err := gs.client.Attrs(gs.ctx, gs.key)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return attrs.Size, nil
+}
+
+// Wait waits for PollInterval, since Cloud Storage can't notify of object
+// changes.
+func (gs *gcsStream) Wait(ctx context.Context, size int64) error {
+	return poll(ctx)
+}
+
+// Flush completes the upload, and appends it to the log object.
+func (gs *gcsStream) Flush() error {
+	if gs.writer == nil {
+		return nil
+	}
+	err := gs.writer.Close()
+	gs.writer = nil
+	if err != nil {
+		return err
+	}
+	if err := gs.append(); err != nil {
+		return err
+	}
+	return gs.client.Delete(gs.ctx, gs.upload)
+}
+
+// append composes the log object with the uploaded object, creating it if it
+// doesn't exist. The composition fails if the log object changed since it was
+// looked up, and is then retried, so that concurrent sessions don't overwrite
+// the logs appended by each other.
+func (gs *gcsStream) append() error {
+	for {
+		var generation int64
+		sources := []string{gs.key, gs.upload}
+		attrs, err := gs.client.Attrs(gs.ctx, gs.key)
+		switch {
+		case errors.Is(err, storage.ErrObjectNotExist):
+			sources = sources[1:]
+		case err != nil:
+			return err
+		default:
+			generation = attrs.Generation
+		}
+		err = gs.client.Compose(gs.ctx, gs.key, generation, sources...)
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+			continue
+		}
+		return err
+	}
+}
+
+func (gs *gcsStream) Delete() error {
+	err := gs.client.Delete(gs.ctx, gs.key)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil
+	}
+	return err
+}
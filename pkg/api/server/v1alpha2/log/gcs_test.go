@@ -0,0 +1,211 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+type mockGCSClient struct {
+	t           *testing.T
+	chunkSize   int
+	objects     map[string][]byte
+	generations map[string]int64
+	generation  int64
+	// beforeCompose is called before objects are composed.
+	beforeCompose func()
+}
+
+// put stores an object, with a new generation.
+func (m *mockGCSClient) put(key string, data []byte) {
+	m.generation++
+	m.objects[key] = data
+	m.generations[key] = m.generation
+}
+
+type mockGCSWriter struct {
+	bytes.Buffer
+	client *mockGCSClient
+	key    string
+}
+
+// Close completes the upload, which makes the object visible.
+func (w *mockGCSWriter) Close() error {
+	w.client.put(w.key, w.Bytes())
+	return nil
+}
+
+func (m *mockGCSClient) NewWriter(ctx context.Context, key string, chunkSize int) io.WriteCloser {
+	if chunkSize != m.chunkSize {
+		m.t.Errorf("want chunks of %d bytes, got %d", m.chunkSize, chunkSize)
+	}
+	return &mockGCSWriter{client: m, key: key}
+}
+
+func (m *mockGCSClient) NewRangeReader(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, storage.ErrObjectNotExist
+	}
+	data = data[offset:]
+	if length >= 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *mockGCSClient) Attrs(ctx context.Context, key string) (*storage.ObjectAttrs, error) {
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, storage.ErrObjectNotExist
+	}
+	return &storage.ObjectAttrs{Name: key, Size: int64(len(data)), Generation: m.generations[key]}, nil
+}
+
+func (m *mockGCSClient) Compose(ctx context.Context, key string, generation int64, sources ...string) error {
+	if m.beforeCompose != nil {
+		m.beforeCompose()
+	}
+	if m.generations[key] != generation {
+		return &googleapi.Error{Code: http.StatusPreconditionFailed}
+	}
+	var data []byte
+	for _, source := range sources {
+		d, ok := m.objects[source]
+		if !ok {
+			return storage.ErrObjectNotExist
+		}
+		data = append(data, d...)
+	}
+	m.put(key, data)
+	return nil
+}
+
+func (m *mockGCSClient) Delete(ctx context.Context, key string) error {
+	if _, ok := m.objects[key]; !ok {
+		return storage.ErrObjectNotExist
+	}
+	delete(m.objects, key)
+	delete(m.generations, key)
+	return nil
+}
+
+func newMockGCSClient(t *testing.T) *mockGCSClient {
+	return &mockGCSClient{
+		t:           t,
+		chunkSize:   DefaultGCSChunkSize,
+		objects:     map[string][]byte{},
+		generations: map[string]int64{},
+	}
+}
+
+func TestGCSStream(t *testing.T) {
+	client := newMockGCSClient(t)
+	newStream := func() *gcsStream {
+		return &gcsStream{
+			ctx:       context.Background(),
+			client:    client,
+			key:       "test",
+			chunkSize: DefaultGCSChunkSize,
+		}
+	}
+
+	s := newStream()
+	for _, chunk := range []string{"0123", "456789"} {
+		if n, err := s.ReadFrom(strings.NewReader(chunk)); err != nil || n != int64(len(chunk)) {
+			t.Fatalf("ReadFrom: want (%d, nil), got (%d, %v)", len(chunk), n, err)
+		}
+	}
+	// The object doesn't exist until its upload completes.
+	if size, err := s.Size(); err != nil || size != 0 {
+		t.Fatalf("Size: want (0, nil), got (%d, %v)", size, err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Later sessions append to the object.
+	s = newStream()
+	if _, err := s.ReadFrom(strings.NewReader("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "0123456789abc"
+	if size, err := s.Size(); err != nil || size != int64(len(want)) {
+		t.Errorf("Size: want (%d, nil), got (%d, %v)", len(want), size, err)
+	}
+	if len(client.objects) != 1 {
+		t.Errorf("want the uploaded object deleted once composed, got %d objects", len(client.objects))
+	}
+
+	for _, tc := range []struct {
+		offset, limit int64
+		want          string
+	}{
+		{offset: 0, limit: 0, want: want},
+		{offset: 2, limit: 3, want: "234"},
+		{offset: 8, limit: 0, want: "89abc"},
+	} {
+		buffer := &bytes.Buffer{}
+		if _, err := s.WriteRangeTo(buffer, tc.offset, tc.limit); err != nil {
+			t.Fatal(err)
+		}
+		if got := buffer.String(); got != tc.want {
+			t.Errorf("WriteRangeTo(%d, %d): want: %s, got: %s", tc.offset, tc.limit, tc.want, got)
+		}
+	}
+
+	if err := s.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.objects) != 0 {
+		t.Errorf("want no object left, got %d", len(client.objects))
+	}
+	if err := s.Delete(); err != nil {
+		t.Errorf("Delete: want no error for a deleted log, got %v", err)
+	}
+}
+
+func TestGCSStream_Concurrent(t *testing.T) {
+	client := newMockGCSClient(t)
+	newStream := func() *gcsStream {
+		return &gcsStream{
+			ctx:       context.Background(),
+			client:    client,
+			key:       "test",
+			chunkSize: DefaultGCSChunkSize,
+		}
+	}
+
+	one, two := newStream(), newStream()
+	for s, chunk := range map[*gcsStream]string{one: "one", two: "two"} {
+		if _, err := s.ReadFrom(strings.NewReader(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The log object is stored by another session between the lookup of the
+	// log object and its composition, which is retried.
+	client.beforeCompose = func() {
+		client.beforeCompose = nil
+		if err := two.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := one.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(client.objects["test"]); got != "twoone" {
+		t.Errorf("want the logs of both sessions stored, got %q", got)
+	}
+	if len(client.objects) != 1 {
+		t.Errorf("want the uploaded objects deleted once composed, got %d objects", len(client.objects))
+	}
+}
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -10,9 +11,11 @@ import (
 	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
 	"io"
 	"path/filepath"
 	"regexp"
+	"time"
 )
 
 const (
@@ -46,11 +49,68 @@ func FormatName(parent, name string) string {
 type Stream interface {
 	io.ReaderFrom
 	io.WriterTo
+	// WriteRangeTo writes at most limit bytes of the log, starting at offset,
+	// to w. A limit of 0 writes until the end of the log.
+	WriteRangeTo(w io.Writer, offset, limit int64) (int64, error)
+	// Size returns the number of bytes stored, 0 if nothing is stored yet.
+	Size() (int64, error)
+	// Wait blocks until the log grows past size, the context is done or
+	// PollInterval elapses, for backends which can't notify of growth.
+	Wait(ctx context.Context, size int64) error
 	Type() string
 	Delete() error
 	Flush() error
 }
 
+// PollInterval is the longest time Stream.Wait blocks for.
+const PollInterval = time.Second
+
+// poll waits for PollInterval, or until the context is done.
+func poll(ctx context.Context) error {
+	timer := time.NewTimer(PollInterval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// TailOffset returns the offset of the last lines lines of a log of the
+// given size. A final newline terminates the last line rather than starting
+// a new one.
+func TailOffset(s Stream, size, lines int64) (int64, error) {
+	if _, ok := s.(*compressedStream); ok {
+		return tailOffsetFrom(s, lines)
+	}
+	var (
+		buf   bytes.Buffer
+		count int64
+	)
+	for end := size; end > 0; {
+		start := end - DefaultBufferSize
+		if start < 0 {
+			start = 0
+		}
+		buf.Reset()
+		if _, err := s.WriteRangeTo(&buf, start, end-start); err != nil {
+			return 0, err
+		}
+		b := buf.Bytes()
+		for i := len(b) - 1; i >= 0; i-- {
+			if b[i] != '\n' || start+int64(i) == size-1 {
+				continue
+			}
+			if count++; count == lines {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
+
 // NewStream returns a LogStreamer for the given Log.
 // LogStreamers do the following:
 //
@@ -62,14 +122,52 @@ type Stream interface {
 //
 // NewStream may mutate the Log object's status, to provide implementation information
 // for reading and writing files.
+//
+// Logs with nothing stored yet are compressed with the configured compression,
+// which is recorded in their status so that they are read with it.
+//
+// Database logs are stored along with their Record, use ToStream for them.
 func NewStream(ctx context.Context, log *v1alpha2.Log, config *config.Config) (Stream, error) {
+	return newStream(ctx, nil, nil, log, config)
+}
+
+func newStream(ctx context.Context, gdb *gorm.DB, record *db.Record, log *v1alpha2.Log, config *config.Config) (Stream, error) {
+	var (
+		stream Stream
+		err    error
+	)
 	switch log.Spec.Type {
 	case v1alpha2.FileLogType:
-		return NewFileStream(ctx, log, config)
+		stream, err = NewFileStream(ctx, log, config)
 	case v1alpha2.S3LogType:
-		return NewS3Stream(ctx, log, config)
+		stream, err = NewS3Stream(ctx, log, config)
+	case v1alpha2.GCSLogType:
+		stream, err = NewGCSStream(ctx, log, config)
+	case v1alpha2.AzureBlobLogType:
+		stream, err = NewAzureBlobStream(ctx, log, config)
+	case v1alpha2.DatabaseLogType:
+		stream, err = NewDatabaseStream(ctx, gdb, record)
+	default:
+		return nil, fmt.Errorf("log streamer type %s is not supported", log.Spec.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	compression := log.Status.Compression
+	if log.Status.Size == 0 && log.Status.CompressedSize == 0 && !log.Status.Storing {
+		compression = v1alpha2.LogCompression(config.LOGS_COMPRESSION)
+	}
+	if compression == "" {
+		log.Status.Compression = ""
+		return stream, nil
+	}
+	stream, err = newCompressedStream(ctx, stream, compression)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("log streamer type %s is not supported", log.Spec.Type)
+	log.Status.Compression = compression
+	return stream, nil
 }
 
 func ToStorage(record *pb.Record, config *config.Config) ([]byte, error) {
@@ -91,7 +189,9 @@ func ToStorage(record *pb.Record, config *config.Config) ([]byte, error) {
 	return json.Marshal(log)
 }
 
-func ToStream(ctx context.Context, record *db.Record, config *config.Config) (Stream, *v1alpha2.Log, error) {
+// ToStream returns a LogStreamer for the Log of a Record, stored in gdb for
+// the Database log type.
+func ToStream(ctx context.Context, gdb *gorm.DB, record *db.Record, config *config.Config) (Stream, *v1alpha2.Log, error) {
 	if record.Type != v1alpha2.LogRecordType {
 		return nil, nil, fmt.Errorf("record type %s cannot stream logs", record.Type)
 	}
@@ -100,7 +200,7 @@ func ToStream(ctx context.Context, record *db.Record, config *config.Config) (St
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not decode Log record: %v", err)
 	}
-	stream, err := NewStream(ctx, log, config)
+	stream, err := newStream(ctx, gdb, record, log, config)
 	return stream, log, err
 }
 
@@ -12,6 +12,7 @@ import (
 	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
 	"io"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
 	"testing"
 )
 
@@ -142,12 +143,30 @@ func TestToStorage(t *testing.T) {
 
 type mockStream struct {
 	streamType string
+	data       string
 }
 
 func (s *mockStream) WriteTo(io.Writer) (int64, error) {
 	return 0, fmt.Errorf("not implemented")
 }
 
+func (s *mockStream) WriteRangeTo(w io.Writer, offset, limit int64) (int64, error) {
+	end := int64(len(s.data))
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	n, err := io.WriteString(w, s.data[offset:end])
+	return int64(n), err
+}
+
+func (s *mockStream) Size() (int64, error) {
+	return int64(len(s.data)), nil
+}
+
+func (s *mockStream) Wait(context.Context, int64) error {
+	return fmt.Errorf("not implemented")
+}
+
 func (s *mockStream) ReadFrom(io.Reader) (int64, error) {
 	return 0, fmt.Errorf("not implemented")
 }
@@ -226,7 +245,7 @@ func TestToStream(t *testing.T) {
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			streamer, _, err := ToStream(context.TODO(), tc.in, &config.Config{})
+			streamer, _, err := ToStream(context.TODO(), nil, tc.in, &config.Config{})
 			if err != nil {
 				if !tc.expectErr {
 					t.Errorf("unexpected error: %v", err)
@@ -239,3 +258,53 @@ func TestToStream(t *testing.T) {
 		})
 	}
 }
+
+func TestTailOffset(t *testing.T) {
+	long := strings.Repeat("x", DefaultBufferSize) + "\n"
+	for _, tc := range []struct {
+		name  string
+		data  string
+		lines int64
+		want  string
+	}{
+		{
+			name:  "last line",
+			data:  "a\nb\nc\n",
+			lines: 1,
+			want:  "c\n",
+		},
+		{
+			name:  "unterminated last line",
+			data:  "a\nb\nc",
+			lines: 2,
+			want:  "b\nc",
+		},
+		{
+			name:  "more lines than the log",
+			data:  "a\nb\n",
+			lines: 5,
+			want:  "a\nb\n",
+		},
+		{
+			name:  "lines across reads",
+			data:  "a\n" + long + long,
+			lines: 2,
+			want:  long + long,
+		},
+		{
+			name:  "empty log",
+			lines: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &mockStream{data: tc.data}
+			offset, err := TailOffset(s, int64(len(tc.data)), tc.lines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tc.data[offset:]; got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
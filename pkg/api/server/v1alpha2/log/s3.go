@@ -7,14 +7,17 @@ import (
 	"path/filepath"
 
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	server "github.com/tektoncd/results/pkg/api/server/config"
 	"github.com/tektoncd/results/pkg/apis/v1alpha2"
 )
@@ -29,6 +32,8 @@ type s3Client interface {
 	CreateMultipartUpload(context.Context, *s3.CreateMultipartUploadInput, ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
 	DeleteObject(context.Context, *s3.DeleteObjectInput, ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
 	GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(context.Context, *s3.HeadObjectInput, ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	PutObject(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error)
 	UploadPart(context.Context, *s3.UploadPartInput, ...func(*s3.Options)) (*s3.UploadPartOutput, error)
 }
 
@@ -45,6 +50,20 @@ type s3Stream struct {
 	uploadId      string
 	parts         []types.CompletedPart
 	multiPartSize int64
+	// path is the path of the log. Objects can't be appended to, so logs
+	// are stored in segments, one per upload, the first at path and the
+	// following ones at path suffixed with their index. Segments are
+	// claimed by storing them empty before they are uploaded, so that
+	// concurrent uploads store distinct segments.
+	path     string
+	segments []v1alpha2.LogSegment
+	// segment is the path of the segment being uploaded, and uploaded the
+	// number of bytes uploaded to it.
+	segment  string
+	uploaded int64
+	// status is updated with the segments of the log once an upload
+	// completes.
+	status *v1alpha2.LogStatus
 }
 
 func NewS3Stream(ctx context.Context, log *v1alpha2.Log, config *server.Config) (Stream, error) {
@@ -56,23 +75,11 @@ func NewS3Stream(ctx context.Context, log *v1alpha2.Log, config *server.Config)
 		log.Status.Path = filePath
 	}
 
-	filePath := filepath.Join(config.LOGS_PATH, log.Status.Path)
-
 	client, err := initConfig(ctx, config)
 	if err != nil {
 		return nil, err
 	}
 
-	multipartUpload, err := client.CreateMultipartUpload(ctx,
-		&s3.CreateMultipartUploadInput{
-			Bucket: &config.S3_BUCKET_NAME,
-			Key:    &filePath,
-		},
-	)
-	if err != nil {
-		return nil, err
-	}
-
 	multiPartSize := config.S3_MULTI_PART_SIZE
 	if multiPartSize == 0 {
 		multiPartSize = DefaultS3MultiPartSize
@@ -88,12 +95,13 @@ func NewS3Stream(ctx context.Context, log *v1alpha2.Log, config *server.Config)
 		ctx:           ctx,
 		size:          size,
 		bucket:        config.S3_BUCKET_NAME,
-		key:           filePath,
 		buffer:        bytes.Buffer{},
-		uploadId:      *multipartUpload.UploadId,
 		client:        client,
 		partNumber:    1,
 		multiPartSize: multiPartSize,
+		path:          log.Status.Path,
+		segments:      append([]v1alpha2.LogSegment(nil), log.Status.Segments...),
+		status:        &log.Status,
 	}
 
 	return s3s, nil
@@ -132,25 +140,137 @@ func (*s3Stream) Type() string {
 }
 
 func (s3s *s3Stream) WriteTo(w io.Writer) (n int64, err error) {
+	return s3s.WriteRangeTo(w, 0, 0)
+}
+
+// WriteRangeTo writes at most limit bytes of the log, starting at offset, to
+// w through ranged GetObjects of the segments holding them.
+func (s3s *s3Stream) WriteRangeTo(w io.Writer, offset, limit int64) (int64, error) {
+	if err := s3s.findSegments(); err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, segment := range s3s.segments {
+		if offset >= segment.Size {
+			offset -= segment.Size
+			continue
+		}
+		length := segment.Size - offset
+		if limit > 0 && length > limit-n {
+			length = limit - n
+		}
+		m, err := s3s.writeObjectRangeTo(w, s3s.objectKey(segment.Path), offset, length)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		if limit > 0 && n == limit {
+			break
+		}
+		offset = 0
+	}
+	return n, nil
+}
+
+// writeObjectRangeTo writes length bytes of the object, starting at offset,
+// to w.
+func (s3s *s3Stream) writeObjectRangeTo(w io.Writer, key string, offset, length int64) (int64, error) {
+	rng := fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
 	outPut, err := s3s.client.GetObject(s3s.ctx, &s3.GetObjectInput{
 		Bucket: &s3s.bucket,
-		Key:    &s3s.key,
+		Key:    &key,
+		Range:  &rng,
 	})
 	if err != nil {
-		return 0, fmt.Errorf(err.Error())
+		return 0, err
 	}
-
 	defer outPut.Body.Close()
 
-	reader := bufio.NewReaderSize(outPut.Body, s3s.size)
-	n, err = reader.WriteTo(w)
+	return bufio.NewReaderSize(outPut.Body, s3s.size).WriteTo(w)
+}
+
+// Size returns the size of the segments of the log, 0 if none is stored yet,
+// which is the case until the first upload completes.
+func (s3s *s3Stream) Size() (int64, error) {
+	if err := s3s.findSegments(); err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, segment := range s3s.segments {
+		size += segment.Size
+	}
+	return size, nil
+}
+
+// findSegments finds the segments stored after the ones the stream knows of,
+// i.e. stored by uploads which completed after it was created, or before
+// segments were recorded in the status of logs. The sizes of the empty
+// segments, which may have been claimed by uploads which completed since, are
+// updated.
+func (s3s *s3Stream) findSegments() error {
+	for i, segment := range s3s.segments {
+		if segment.Size > 0 {
+			continue
+		}
+		size, _, err := s3s.headSegment(segment.Path)
+		if err != nil {
+			return err
+		}
+		s3s.segments[i].Size = size
+	}
+	for {
+		path := s3s.segmentPath(len(s3s.segments))
+		size, found, err := s3s.headSegment(path)
+		if err != nil || !found {
+			return err
+		}
+		s3s.segments = append(s3s.segments, v1alpha2.LogSegment{Path: path, Size: size})
+	}
+}
+
+// headSegment returns the size of the segment at path, and whether it exists.
+func (s3s *s3Stream) headSegment(path string) (int64, bool, error) {
+	key := s3s.objectKey(path)
+	head, err := s3s.client.HeadObject(s3s.ctx, &s3.HeadObjectInput{
+		Bucket: &s3s.bucket,
+		Key:    &key,
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return 0, false, nil
+	}
 	if err != nil {
-		return 0, fmt.Errorf(err.Error())
+		return 0, false, err
 	}
-	return
+	return head.ContentLength, true, nil
 }
 
+// segmentPath returns the path of the segment of the log at index.
+func (s3s *s3Stream) segmentPath(index int) string {
+	if index == 0 {
+		return s3s.path
+	}
+	return fmt.Sprintf("%s.%03d", s3s.path, index)
+}
+
+func (s3s *s3Stream) objectKey(path string) string {
+	return filepath.Join(s3s.config.LOGS_PATH, path)
+}
+
+// Wait waits for PollInterval, since S3 can't notify of object changes.
+func (s3s *s3Stream) Wait(ctx context.Context, size int64) error {
+	return poll(ctx)
+}
+
+// ReadFrom buffers the logs read from r, and uploads them as parts of a new
+// segment once the buffer reaches multiPartSize.
 func (s3s *s3Stream) ReadFrom(r io.Reader) (int64, error) {
+	if s3s.uploadId == "" {
+		if err := s3s.createUpload(); err != nil {
+			return 0, err
+		}
+	}
+
 	n, err := s3s.buffer.ReadFrom(r)
 	if err != nil {
 		return 0, err
@@ -158,7 +278,7 @@ func (s3s *s3Stream) ReadFrom(r io.Reader) (int64, error) {
 
 	size := s3s.partSize + n
 	if size >= s3s.multiPartSize {
-		err = s3s.uploadMultiPart(&s3s.buffer, s3s.partNumber, n)
+		err = s3s.uploadMultiPart(&s3s.buffer, s3s.partNumber, int64(s3s.buffer.Len()))
 		if err != nil {
 			return 0, err
 		}
@@ -168,7 +288,58 @@ func (s3s *s3Stream) ReadFrom(r io.Reader) (int64, error) {
 		s3s.partSize = size
 	}
 
-	return s3s.partSize, err
+	return n, err
+}
+
+// createUpload starts the multipart upload of the segment following the ones
+// already stored.
+func (s3s *s3Stream) createUpload() error {
+	segment, err := s3s.claimSegment()
+	if err != nil {
+		return err
+	}
+	key := s3s.objectKey(segment)
+	multipartUpload, err := s3s.client.CreateMultipartUpload(s3s.ctx, &s3.CreateMultipartUploadInput{
+		Bucket: &s3s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return err
+	}
+	s3s.segment = segment
+	s3s.key = key
+	s3s.uploadId = *multipartUpload.UploadId
+	s3s.partNumber = 1
+	s3s.parts = nil
+	s3s.uploaded = 0
+	return nil
+}
+
+// claimSegment claims the segment following the ones already stored, by
+// storing it empty unless it exists, and returns its path. Segments claimed
+// by concurrent uploads are skipped.
+func (s3s *s3Stream) claimSegment() (string, error) {
+	for {
+		if err := s3s.findSegments(); err != nil {
+			return "", err
+		}
+		segment := s3s.segmentPath(len(s3s.segments))
+		key := s3s.objectKey(segment)
+		_, err := s3s.client.PutObject(s3s.ctx, &s3.PutObjectInput{
+			Bucket: &s3s.bucket,
+			Key:    &key,
+			Body:   bytes.NewReader(nil),
+		}, s3.WithAPIOptions(smithyhttp.SetHeaderValue("If-None-Match", "*")))
+		var re *smithyhttp.ResponseError
+		if errors.As(err, &re) && (re.HTTPStatusCode() == http.StatusPreconditionFailed || re.HTTPStatusCode() == http.StatusConflict) {
+			// Another upload claimed the segment, or is claiming it.
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return segment, nil
+	}
 }
 
 func (s3s *s3Stream) uploadMultiPart(reader io.Reader, partNumber int32, partSize int64) error {
@@ -194,14 +365,22 @@ func (s3s *s3Stream) uploadMultiPart(reader io.Reader, partNumber int32, partSiz
 
 	s3s.parts = append(s3s.parts, types.CompletedPart{PartNumber: partNumber, ETag: part.ETag})
 	s3s.partNumber += 1
+	s3s.uploaded += partSize
 
 	return err
 }
 
+// Flush completes the upload of the current segment, if any, and records it
+// in the status of the log.
 func (s3s *s3Stream) Flush() error {
+	if s3s.uploadId == "" {
+		return nil
+	}
 	if err := s3s.uploadMultiPart(&s3s.buffer, s3s.partNumber, int64(s3s.buffer.Len())); err != nil {
 		return err
 	}
+	s3s.partSize = 0
+	s3s.buffer.Reset()
 
 	_, err := s3s.client.CompleteMultipartUpload(s3s.ctx, &s3.CompleteMultipartUploadInput{
 		Bucket:   &s3s.bucket,
@@ -211,13 +390,45 @@ func (s3s *s3Stream) Flush() error {
 			Parts: s3s.parts,
 		},
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	s3s.uploadId = ""
+
+	// The segment was found empty if the stream looked it up since it was
+	// claimed.
+	i := 0
+	for i < len(s3s.segments) && s3s.segments[i].Path != s3s.segment {
+		i++
+	}
+	if i == len(s3s.segments) {
+		s3s.segments = append(s3s.segments, v1alpha2.LogSegment{Path: s3s.segment})
+	}
+	s3s.segments[i].Size = s3s.uploaded
+	if s3s.status != nil {
+		// Segments claimed by concurrent uploads may have been stored since.
+		if err := s3s.findSegments(); err != nil {
+			return err
+		}
+		s3s.status.Segments = append([]v1alpha2.LogSegment(nil), s3s.segments...)
+	}
+	return nil
 }
 
+// Delete deletes the segments of the log.
 func (s3s *s3Stream) Delete() error {
-	_, err := s3s.client.DeleteObject(s3s.ctx, &s3.DeleteObjectInput{
-		Bucket: &s3s.bucket,
-		Key:    &s3s.key,
-	})
-	return err
+	if err := s3s.findSegments(); err != nil {
+		return err
+	}
+	for _, segment := range s3s.segments {
+		key := s3s.objectKey(segment.Path)
+		if _, err := s3s.client.DeleteObject(s3s.ctx, &s3.DeleteObjectInput{
+			Bucket: &s3s.bucket,
+			Key:    &key,
+		}); err != nil {
+			return err
+		}
+	}
+	s3s.segments = nil
+	return nil
 }
@@ -3,10 +3,18 @@ package log
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/google/go-cmp/cmp"
 	server "github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/apis/v1alpha2"
 	"io"
+	"net/http"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -41,11 +49,39 @@ func (m *mockS3Client) DeleteObject(ctx context.Context, params *s3.DeleteObject
 
 func (m *mockS3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
 	m.checkParams(params.Bucket, params.Key)
+	body := m.body
+	if params.Range != nil {
+		var start, end int
+		if n, _ := fmt.Sscanf(*params.Range, "bytes=%d-%d", &start, &end); n < 2 {
+			end = len(body) - 1
+		}
+		if end >= len(body) {
+			end = len(body) - 1
+		}
+		body = body[start : end+1]
+	}
 	return &s3.GetObjectOutput{
-		Body: io.NopCloser(bytes.NewReader(m.body)),
+		Body: io.NopCloser(bytes.NewReader(body)),
 	}, nil
 }
 
+func (m *mockS3Client) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	// Segments following the first are looked up until one isn't found.
+	if params.Key != nil && *params.Key == m.key+".001" {
+		return nil, &types.NotFound{}
+	}
+	m.checkParams(params.Bucket, params.Key)
+	if m.body == nil {
+		return nil, &types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{ContentLength: int64(len(m.body))}, nil
+}
+
+func (m *mockS3Client) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	m.checkParams(params.Bucket, params.Key)
+	return &s3.PutObjectOutput{}, nil
+}
+
 func (m *mockS3Client) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
 	buffer := bytes.Buffer{}
 	_, err := buffer.ReadFrom(params.Body)
@@ -82,7 +118,7 @@ func TestS3Stream_WriteTo(t *testing.T) {
 	s := &s3Stream{
 		config: c,
 		bucket: c.S3_BUCKET_NAME,
-		key:    filePath,
+		path:   filePath,
 		client: &mockS3Client{
 			t:      t,
 			bucket: c.S3_BUCKET_NAME,
@@ -101,6 +137,50 @@ func TestS3Stream_WriteTo(t *testing.T) {
 	}
 }
 
+func TestS3Stream_WriteRangeTo(t *testing.T) {
+	c := &server.Config{
+		S3_BUCKET_NAME: "test-bucket",
+	}
+	filePath := "test"
+	client := &mockS3Client{
+		t:      t,
+		bucket: c.S3_BUCKET_NAME,
+		key:    filePath,
+	}
+	s := &s3Stream{
+		config: c,
+		bucket: c.S3_BUCKET_NAME,
+		path:   filePath,
+		client: client,
+	}
+
+	// The object doesn't exist until its upload completes.
+	if size, err := s.Size(); err != nil || size != 0 {
+		t.Fatalf("Size: want (0, nil), got (%d, %v)", size, err)
+	}
+
+	client.body = []byte("0123456789")
+	if size, err := s.Size(); err != nil || size != 10 {
+		t.Fatalf("Size: want (10, nil), got (%d, %v)", size, err)
+	}
+	for _, tc := range []struct {
+		offset, limit int64
+		want          string
+	}{
+		{offset: 0, limit: 0, want: "0123456789"},
+		{offset: 2, limit: 3, want: "234"},
+		{offset: 7, limit: 0, want: "789"},
+	} {
+		buffer := &bytes.Buffer{}
+		if _, err := s.WriteRangeTo(buffer, tc.offset, tc.limit); err != nil {
+			t.Fatal(err)
+		}
+		if got := buffer.String(); got != tc.want {
+			t.Errorf("WriteRangeTo(%d, %d): want: %s, got: %s", tc.offset, tc.limit, tc.want, got)
+		}
+	}
+}
+
 func TestS3Stream_ReadFrom(t *testing.T) {
 	want := "test body of multi-part upload"
 	const DefaultBufferSize = 10
@@ -171,11 +251,12 @@ func TestS3Stream_Delete(t *testing.T) {
 	s := &s3Stream{
 		config: c,
 		bucket: c.S3_BUCKET_NAME,
-		key:    filePath,
+		path:   filePath,
 		client: &mockS3Client{
 			t:      t,
 			bucket: c.S3_BUCKET_NAME,
 			key:    filePath,
+			body:   []byte("test data"),
 		},
 	}
 
@@ -184,3 +265,218 @@ func TestS3Stream_Delete(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// mockS3Bucket stores the objects uploaded through multipart uploads, which
+// are only visible once their upload completes, and the objects put, which
+// must be put conditionally, unless they exist.
+type mockS3Bucket struct {
+	s3Client
+	t       *testing.T
+	objects map[string][]byte
+	uploads map[string][]byte
+}
+
+func (m *mockS3Bucket) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	m.uploads[*params.Key] = []byte{}
+	return &s3.CreateMultipartUploadOutput{UploadId: params.Key}, nil
+}
+
+func (m *mockS3Bucket) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		m.t.Fatalf("error uploading part: %d", params.PartNumber)
+	}
+	if int64(len(data)) != params.ContentLength {
+		m.t.Errorf("want part %d of %d bytes, got %d", params.PartNumber, params.ContentLength, len(data))
+	}
+	m.uploads[*params.UploadId] = append(m.uploads[*params.UploadId], data...)
+	e := strconv.Itoa(int(params.PartNumber))
+	return &s3.UploadPartOutput{ETag: &e}, nil
+}
+
+func (m *mockS3Bucket) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	m.objects[*params.Key] = m.uploads[*params.UploadId]
+	delete(m.uploads, *params.UploadId)
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (m *mockS3Bucket) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if got := requestHeader(m.t, optFns...).Get("If-None-Match"); got != "*" {
+		m.t.Errorf("want %s put if none matches *, got %q", *params.Key, got)
+	}
+	if _, ok := m.objects[*params.Key]; ok {
+		return nil, &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusPreconditionFailed}},
+			Err:      fmt.Errorf("%s exists", *params.Key),
+		}
+	}
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		m.t.Fatalf("error putting %s: %v", *params.Key, err)
+	}
+	m.objects[*params.Key] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+// requestHeader returns the header of the requests sent with optFns.
+func requestHeader(t *testing.T, optFns ...func(*s3.Options)) http.Header {
+	t.Helper()
+	options := s3.Options{}
+	for _, fn := range optFns {
+		fn(&options)
+	}
+	stack := middleware.NewStack("test", smithyhttp.NewStackRequest)
+	for _, fn := range options.APIOptions {
+		if err := fn(stack); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var header http.Header
+	send := middleware.HandlerFunc(func(ctx context.Context, input interface{}) (interface{}, middleware.Metadata, error) {
+		header = input.(*smithyhttp.Request).Header
+		return nil, middleware.Metadata{}, nil
+	})
+	if _, _, err := middleware.DecorateHandler(send, stack).Handle(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	return header
+}
+
+func (m *mockS3Bucket) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	data, ok := m.objects[*params.Key]
+	if !ok {
+		return nil, &types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{ContentLength: int64(len(data))}, nil
+}
+
+func (m *mockS3Bucket) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := m.objects[*params.Key]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	var start, end int
+	if _, err := fmt.Sscanf(*params.Range, "bytes=%d-%d", &start, &end); err != nil {
+		m.t.Fatalf("invalid range %s: %v", *params.Range, err)
+	}
+	return &s3.GetObjectOutput{
+		Body: io.NopCloser(bytes.NewReader(data[start : end+1])),
+	}, nil
+}
+
+func (m *mockS3Bucket) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	delete(m.objects, *params.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestS3Stream_Segments(t *testing.T) {
+	c := &server.Config{
+		S3_BUCKET_NAME: "test-bucket",
+		LOGS_PATH:      "logs",
+	}
+	client := &mockS3Bucket{
+		t:       t,
+		objects: map[string][]byte{},
+		uploads: map[string][]byte{},
+	}
+	log := &v1alpha2.Log{
+		Status: v1alpha2.LogStatus{
+			Path: "test",
+		},
+	}
+	newStream := func() *s3Stream {
+		return &s3Stream{
+			config:        c,
+			ctx:           context.Background(),
+			size:          DefaultBufferSize,
+			client:        client,
+			bucket:        c.S3_BUCKET_NAME,
+			multiPartSize: 4,
+			path:          log.Status.Path,
+			segments:      append([]v1alpha2.LogSegment(nil), log.Status.Segments...),
+			status:        &log.Status,
+		}
+	}
+
+	// Each session stores the logs it receives in a new segment.
+	reader := newStream()
+	for _, session := range [][]string{{"0123", "45"}, {"6789"}, {"ab"}} {
+		s := newStream()
+		for _, chunk := range session {
+			if n, err := s.ReadFrom(strings.NewReader(chunk)); err != nil || n != int64(len(chunk)) {
+				t.Fatalf("ReadFrom: want (%d, nil), got (%d, %v)", len(chunk), n, err)
+			}
+		}
+		if err := s.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wantSegments := []v1alpha2.LogSegment{
+		{Path: "test", Size: 6},
+		{Path: "test.001", Size: 4},
+		{Path: "test.002", Size: 2},
+	}
+	if diff := cmp.Diff(wantSegments, log.Status.Segments); diff != "" {
+		t.Errorf("Segments: -want, +got: %s", diff)
+	}
+	if string(client.objects["logs/test.001"]) != "6789" {
+		t.Errorf("want the second session stored in logs/test.001, got %q", client.objects["logs/test.001"])
+	}
+
+	// Segments stored after a stream is created are found when reading it,
+	// as are those of logs stored before segments were recorded.
+	want := "0123456789ab"
+	for _, s := range []*s3Stream{reader, newStream()} {
+		if size, err := s.Size(); err != nil || size != int64(len(want)) {
+			t.Errorf("Size: want (%d, nil), got (%d, %v)", len(want), size, err)
+		}
+		for _, tc := range []struct {
+			offset, limit int64
+		}{
+			{offset: 0, limit: 0},
+			{offset: 5, limit: 2},
+			{offset: 4, limit: 7},
+			{offset: 6, limit: 0},
+			{offset: 11, limit: 5},
+			{offset: 12, limit: 0},
+		} {
+			w := want[tc.offset:]
+			if tc.limit > 0 && tc.limit < int64(len(w)) {
+				w = w[:tc.limit]
+			}
+			if got := readRange(t, s, tc.offset, tc.limit); got != w {
+				t.Errorf("WriteRangeTo(%d, %d): want: %q, got: %q", tc.offset, tc.limit, w, got)
+			}
+		}
+	}
+
+	// Concurrent sessions store the logs they receive in distinct segments.
+	sessions := []*s3Stream{newStream(), newStream()}
+	for i, s := range sessions {
+		if _, err := s.ReadFrom(strings.NewReader(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, s := range sessions {
+		if err := s.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wantSegments = append(wantSegments,
+		v1alpha2.LogSegment{Path: "test.003", Size: 1},
+		v1alpha2.LogSegment{Path: "test.004", Size: 1},
+	)
+	if diff := cmp.Diff(wantSegments, log.Status.Segments); diff != "" {
+		t.Errorf("Segments: -want, +got: %s", diff)
+	}
+	if got := readRange(t, reader, 0, 0); got != want+"01" {
+		t.Errorf("WriteRangeTo(0, 0): want: %q, got: %q", want+"01", got)
+	}
+
+	if err := newStream().Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.objects) != 0 {
+		t.Errorf("want every segment deleted, got %d objects left", len(client.objects))
+	}
+}
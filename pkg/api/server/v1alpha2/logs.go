@@ -6,8 +6,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/golang/protobuf/ptypes/empty"
-	"github.com/google/cel-go/cel"
-	celenv "github.com/tektoncd/results/pkg/api/server/cel"
 	"github.com/tektoncd/results/pkg/api/server/db/errors"
 	"github.com/tektoncd/results/pkg/api/server/db/pagination"
 	"github.com/tektoncd/results/pkg/api/server/v1alpha2/result"
@@ -15,7 +13,9 @@ import (
 	"google.golang.org/grpc/status"
 	"gorm.io/gorm"
 	"io"
+	"time"
 
+	"github.com/tektoncd/results/pkg/api/server/cel2sql"
 	"github.com/tektoncd/results/pkg/api/server/db"
 	"github.com/tektoncd/results/pkg/api/server/v1alpha2/auth"
 	"github.com/tektoncd/results/pkg/api/server/v1alpha2/log"
@@ -32,6 +32,15 @@ func (s *Server) GetLog(req *pb.GetLogRequest, srv pb.Logs_GetLogServer) error {
 		s.logger.Error(err)
 		return status.Error(codes.InvalidArgument, "Invalid Name")
 	}
+	if req.GetOffset() < 0 || req.GetLimit() < 0 || req.GetTailLines() < 0 {
+		return status.Error(codes.InvalidArgument, "offset, limit and tail_lines must not be negative")
+	}
+	if req.GetTailLines() > 0 && req.GetOffset() > 0 {
+		return status.Error(codes.InvalidArgument, "tail_lines cannot be combined with offset")
+	}
+	if req.GetTailLines() > 0 && req.GetCompressed() {
+		return status.Error(codes.InvalidArgument, "tail_lines cannot be combined with compressed")
+	}
 
 	if err := s.auth.Check(srv.Context(), parent, auth.ResourceLogs, auth.PermissionGet); err != nil {
 		s.logger.Error(err)
@@ -52,34 +61,105 @@ func (s *Server) GetLog(req *pb.GetLogRequest, srv pb.Logs_GetLogServer) error {
 		}
 	}
 
-	stream, object, err := log.ToStream(srv.Context(), rec, s.config)
+	stream, object, err := log.ToStream(srv.Context(), s.db, rec, s.config)
 	if err != nil {
 		s.logger.Error(err)
 		return status.Error(codes.Internal, "Error streaming log")
 	}
-	if object.Status.Size == 0 {
+	if object.Status.Size == 0 && !object.Status.Storing {
 		s.logger.Errorf("no logs exist for %s", req.GetName())
 		return status.Error(codes.NotFound, "Log doesn't exist")
 	}
-
-	writer := logs.NewBufferedWriter(srv, req.GetName(), s.config.LOGS_BUFFER_SIZE)
-	if _, err = stream.WriteTo(writer); err != nil {
-		s.logger.Error(err)
-		return status.Error(codes.Internal, "Error streaming log")
+	if req.GetCompressed() {
+		stream = log.Stored(stream)
 	}
-	_, err = writer.Flush()
+
+	size, err := stream.Size()
 	if err != nil {
 		s.logger.Error(err)
 		return status.Error(codes.Internal, "Error streaming log")
 	}
-	return nil
+	start := req.GetOffset()
+	if req.GetTailLines() > 0 {
+		start, err = log.TailOffset(stream, size, req.GetTailLines())
+		if err != nil {
+			s.logger.Error(err)
+			return status.Error(codes.Internal, "Error streaming log")
+		}
+	}
+
+	// The log last changed when its Record was updated, unless it was seen
+	// growing since.
+	changed := rec.UpdatedTime
+	writer := logs.NewBufferedWriter(srv, req.GetName(), s.config.LOGS_BUFFER_SIZE)
+	for offset := start; ; {
+		end := size
+		if limit := req.GetLimit(); limit > 0 && start+limit < end {
+			end = start + limit
+		}
+		if offset < end {
+			n, err := stream.WriteRangeTo(writer, offset, end-offset)
+			if err != nil {
+				s.logger.Error(err)
+				return status.Error(codes.Internal, "Error streaming log")
+			}
+			offset += n
+		}
+		if _, err := writer.Flush(); err != nil {
+			s.logger.Error(err)
+			return status.Error(codes.Internal, "Error streaming log")
+		}
+		if !req.GetFollow() || !object.Status.Storing || req.GetLimit() > 0 && offset >= start+req.GetLimit() || s.storingStale(changed) {
+			return nil
+		}
+
+		// Follow the log until UpdateLog stops appending to it.
+		if err := stream.Wait(srv.Context(), size); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		rec, err = getRecord(s.db.WithContext(srv.Context()), rec.Parent, rec.ResultName, rec.Name)
+		if err != nil {
+			s.logger.Error(err)
+			return err
+		}
+		object = &v1alpha2.Log{}
+		if err := json.Unmarshal(rec.Data, object); err != nil {
+			s.logger.Error(err)
+			return status.Error(codes.Internal, "Error streaming log")
+		}
+		if rec.UpdatedTime.After(changed) {
+			changed = rec.UpdatedTime
+		}
+		// The size is read after the status so the end of the log is not missed
+		// once it is no longer being stored.
+		previous := size
+		if size, err = stream.Size(); err != nil {
+			s.logger.Error(err)
+			return status.Error(codes.Internal, "Error streaming log")
+		}
+		if size > previous {
+			changed = clock.Now()
+		}
+	}
+}
+
+// storingStale reports whether a log marked as being stored hasn't changed
+// since changed for longer than LOGS_STORING_TIMEOUT, e.g. because the
+// UpdateLog stream storing it was interrupted before unmarking it.
+func (s *Server) storingStale(changed time.Time) bool {
+	timeout := s.config.LOGS_STORING_TIMEOUT
+	return timeout > 0 && clock.Since(changed) > timeout
 }
 
 func getLogRecord(txn *gorm.DB, parent, result, name string) (*db.Record, error) {
+	uid, args, err := cel2sql.Dialect(txn.Dialector.Name()).JSONText("data", []interface{}{"spec", "resource", "uid"})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
 	store := &db.Record{}
 	q := txn.
 		Where(&db.Record{Result: db.Result{Parent: parent, Name: result}}).
-		Where("data -> 'spec' -> 'resource' ->> 'uid' =  ?", name).
+		Where(uid+" = ?", append(args, name)...).
 		First(store)
 	if err := errors.Wrap(q.Error); err != nil {
 		return nil, err
@@ -93,18 +173,11 @@ func (s *Server) UpdateLog(srv pb.Logs_UpdateLogServer) error {
 	var rec *db.Record
 	var object *v1alpha2.Log
 	var stream log.Stream
-	defer func() {
-		if stream != nil {
-			if err := stream.Flush(); err != nil {
-				s.logger.Error(err)
-			}
-		}
-	}()
 	for {
 		recv, err := srv.Recv()
 		// If we reach the end of the srv, we receive an io.EOF error
 		if err != nil {
-			return s.handleReturn(srv, rec, object, bytesWritten, err)
+			return s.handleReturn(srv, rec, object, stream, bytesWritten, err)
 		}
 		// Ensure that we are receiving logs for the same record
 		if name == "" {
@@ -116,31 +189,37 @@ func (s *Server) UpdateLog(srv pb.Logs_UpdateLogServer) error {
 			return s.handleReturn(srv,
 				rec,
 				object,
+				stream,
 				bytesWritten,
 				err)
 		}
 
 		parent, resultName, recordName, err := log.ParseName(name)
 		if err != nil {
-			return s.handleReturn(srv, rec, object, bytesWritten, err)
+			return s.handleReturn(srv, rec, object, stream, bytesWritten, err)
 		}
 
 		if err := s.auth.Check(srv.Context(), parent, auth.ResourceLogs, auth.PermissionUpdate); err != nil {
-			return s.handleReturn(srv, rec, object, bytesWritten, err)
+			return s.handleReturn(srv, rec, object, stream, bytesWritten, err)
 		}
 
 		if rec == nil {
 			rec, err = getRecord(s.db.WithContext(srv.Context()), parent, resultName, recordName)
 			if err != nil {
-				return s.handleReturn(srv, rec, object, bytesWritten, err)
+				return s.handleReturn(srv, rec, object, stream, bytesWritten, err)
 			}
 
 		}
 
 		if stream == nil {
-			stream, object, err = log.ToStream(srv.Context(), rec, s.config)
+			stream, object, err = log.ToStream(srv.Context(), s.db, rec, s.config)
 			if err != nil {
-				return s.handleReturn(srv, rec, object, bytesWritten, err)
+				return s.handleReturn(srv, rec, object, stream, bytesWritten, err)
+			}
+			// Mark the log as being stored, so that GetLog can follow it.
+			object.Status.Storing = true
+			if err := s.markStoring(srv.Context(), rec, object); err != nil {
+				return s.handleReturn(srv, rec, object, stream, bytesWritten, err)
 			}
 		}
 
@@ -149,48 +228,41 @@ func (s *Server) UpdateLog(srv pb.Logs_UpdateLogServer) error {
 		bytesWritten += written
 
 		if err != nil {
-			return s.handleReturn(srv, rec, object, bytesWritten, err)
+			return s.handleReturn(srv, rec, object, stream, bytesWritten, err)
 		}
 	}
 }
 
-func (s *Server) handleReturn(srv pb.Logs_UpdateLogServer, rec *db.Record, log *v1alpha2.Log, written int64, returnErr error) error {
+func (s *Server) handleReturn(srv pb.Logs_UpdateLogServer, rec *db.Record, object *v1alpha2.Log, stream log.Stream, written int64, returnErr error) error {
 	// When the srv reaches the end, srv.Recv() returns an io.EOF error
 	// Therefore we should not return io.EOF if it is received in this function.
 	// Otherwise, we should return the original error and not mask any subsequent errors handling cleanup/return.
 
 	// If no database record or Log, return the original error
-	if rec == nil || log == nil {
+	if rec == nil || object == nil {
 		return returnErr
 	}
-	apiRec, err := record.ToAPI(rec)
-	if err != nil {
-		if !isNilOrEOF(returnErr) {
-			return returnErr
+	// Flush the stream before updating the Log, so that it is complete once
+	// it is no longer being stored.
+	if stream != nil {
+		if err := stream.Flush(); err != nil {
+			s.logger.Error(err)
+			if isNilOrEOF(returnErr) {
+				returnErr = err
+			}
 		}
-		return err
 	}
-	apiRec.UpdateTime = timestamppb.Now()
-	if written > 0 {
-		log.Status.Size = written
-	}
-	data, err := json.Marshal(log)
-	if err != nil {
-		if !isNilOrEOF(returnErr) {
-			return returnErr
+	// Logs are appended to by each UpdateLog stream.
+	object.Status.Size += written
+	if stream != nil && object.Status.Compression != "" {
+		if size, err := log.Stored(stream).Size(); err != nil {
+			s.logger.Error(err)
+		} else {
+			object.Status.CompressedSize = size
 		}
-		return err
-	}
-	apiRec.Data = &pb.Any{
-		Type:  rec.Type,
-		Value: data,
 	}
-
-	_, err = s.UpdateRecord(srv.Context(), &pb.UpdateRecordRequest{
-		Record: apiRec,
-		Etag:   rec.Etag,
-	})
-
+	object.Status.Storing = false
+	apiRec, err := s.saveLog(srv.Context(), rec, object)
 	if err != nil {
 		if !isNilOrEOF(returnErr) {
 			return returnErr
@@ -208,6 +280,61 @@ func (s *Server) handleReturn(srv pb.Logs_UpdateLogServer, rec *db.Record, log *
 	return returnErr
 }
 
+// markStoring stores the Log in its Record directly, rather than through
+// UpdateRecord, so that each UpdateLog stream only updates the Record, with a
+// revision and a change, once it is done. rec is updated in place, with the
+// etag expected by that update.
+func (s *Server) markStoring(ctx context.Context, rec *db.Record, log *v1alpha2.Log) error {
+	data, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+	etag := rec.Etag
+	rec.Data = data
+	rec.UpdatedTime = clock.Now()
+	if err := record.UpdateEtag(rec); err != nil {
+		return err
+	}
+	q := s.db.WithContext(ctx).Model(&db.Record{}).
+		Where(&db.Record{Parent: rec.Parent, ResultID: rec.ResultID, ID: rec.ID, Etag: etag}).
+		Updates(map[string]interface{}{
+			"data":         rec.Data,
+			"updated_time": rec.UpdatedTime,
+			"etag":         rec.Etag,
+		})
+	if err := errors.Wrap(q.Error); err != nil {
+		return err
+	}
+	if q.RowsAffected == 0 {
+		return status.Error(codes.FailedPrecondition, "the etag mismatches")
+	}
+	return nil
+}
+
+// saveLog stores the Log in its Record.
+func (s *Server) saveLog(ctx context.Context, rec *db.Record, log *v1alpha2.Log) (*pb.Record, error) {
+	apiRec, err := record.ToAPI(rec)
+	if err != nil {
+		return nil, err
+	}
+	apiRec.UpdateTime = timestamppb.Now()
+	data, err := json.Marshal(log)
+	if err != nil {
+		return nil, err
+	}
+	apiRec.Data = &pb.Any{
+		Type:  rec.Type,
+		Value: data,
+	}
+	if _, err := s.UpdateRecord(ctx, &pb.UpdateRecordRequest{
+		Record: apiRec,
+		Etag:   rec.Etag,
+	}); err != nil {
+		return nil, err
+	}
+	return apiRec, nil
+}
+
 func isNilOrEOF(err error) bool {
 	return err == nil || err == io.EOF
 }
@@ -232,64 +359,67 @@ func (s *Server) ListLogs(ctx context.Context, req *pb.ListRecordsRequest) (*pb.
 		return nil, err
 	}
 
-	start, err := pageStart(req.GetPageToken(), req.GetFilter())
+	sortOrder, err := orderBy(cel2sql.Dialect(s.db.Dialector.Name()), recordOrderFields, req.GetOrderBy())
 	if err != nil {
 		return nil, err
 	}
 
-	sortOrder, err := orderBy(req.GetOrderBy())
+	start, err := pageStart(req.GetPageToken(), req.GetFilter(), req.GetOrderBy(), sortOrder)
 	if err != nil {
 		return nil, err
 	}
 
-	env, err := recordCEL()
+	f, err := s.parseRecordFilter(req.GetFilter())
 	if err != nil {
 		return nil, err
 	}
-	prg, err := celenv.ParseFilter(env, req.GetFilter())
+	rec, next, err := s.getFilteredPaginatedSortedLogRecords(ctx, req.GetParent(), start, userPageSize, f, sortOrder)
 	if err != nil {
 		return nil, err
 	}
-	// Fetch n+1 items to get the next token.
-	rec, err := s.getFilteredPaginatedSortedLogRecords(ctx, req.GetParent(), start, userPageSize+1, prg, sortOrder)
+
+	nextToken, err := nextPageToken(next, req.GetFilter(), req.GetOrderBy())
 	if err != nil {
 		return nil, err
 	}
 
-	// If we returned the full n+1 items, use the last element as the next page
-	// token.
-	var nextToken string
-	if len(rec) > userPageSize {
-		next := rec[len(rec)-1]
-		var err error
-		nextToken, err = pagination.EncodeToken(next.GetUid(), req.GetFilter())
+	resp := &pb.ListRecordsResponse{
+		Records:       rec,
+		NextPageToken: nextToken,
+	}
+	if req.GetIncludeTotalSize() {
+		q := s.db.WithContext(ctx).Where("type = ?", v1alpha2.LogRecordType)
+		n, err := countRecords(q, req.GetParent(), f)
 		if err != nil {
 			return nil, err
 		}
-		rec = rec[:len(rec)-1]
+		resp.TotalSize = totalSize(n)
+		resp.TotalSizeApproximate = f.prg != nil
 	}
-
-	return &pb.ListRecordsResponse{
-		Records:       rec,
-		NextPageToken: nextToken,
-	}, nil
+	return resp, nil
 }
 
-// getFilteredPaginatedSortedLogRecords returns the specified number of results that
-// match the given CEL program.
-func (s *Server) getFilteredPaginatedSortedLogRecords(ctx context.Context, parent, start string, pageSize int, prg cel.Program, sortOrder string) ([]*pb.Record, error) {
+// getFilteredPaginatedSortedLogRecords returns the specified number of log records that
+// match the given filter, starting after the start position. If there are
+// more records, the position of the last record returned is returned as well.
+func (s *Server) getFilteredPaginatedSortedLogRecords(ctx context.Context, parent string, start *pagination.Token, pageSize int, f *filter, order sortOrder) ([]*pb.Record, *pagination.Token, error) {
 	parent, resultName, err := result.ParseName(parent)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	rec := make([]*pb.Record, 0, pageSize)
-	batcher := pagination.NewBatcher(pageSize, minPageSize, maxPageSize)
-	for len(rec) < pageSize {
+	// Fetch n+1 items to know whether there is a next page.
+	rec := make([]*pb.Record, 0, pageSize+1)
+	var last *db.Record
+	batcher := pagination.NewBatcher(pageSize+1, minPageSize, maxPageSize)
+	for {
 		batchSize := batcher.Next()
 		dbrecords := make([]*db.Record, 0, batchSize)
 		q := s.db.WithContext(ctx).Where("type = ?", v1alpha2.LogRecordType)
-		q = q.Where("id > ?", start)
+		if start != nil {
+			cond, args := order.after(start.Keys, start.Name)
+			q = q.Where(cond, args...)
+		}
 		// Specifying `-` allows users to read Records across Results.
 		// See https://google.aip.dev/159 for more details.
 		if parent != "-" {
@@ -298,23 +428,21 @@ func (s *Server) getFilteredPaginatedSortedLogRecords(ctx context.Context, paren
 		if resultName != "-" {
 			q = q.Where("result_name = ?", resultName)
 		}
-		if sortOrder != "" {
-			q = q.Order(sortOrder)
-		}
-		q = q.Limit(batchSize).Find(&dbrecords)
+		q = f.apply(q)
+		q = order.apply(q).Limit(batchSize).Find(&dbrecords)
 		if err := errors.Wrap(q.Error); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		// Only return results that match the filter.
 		for _, r := range dbrecords {
 			api, err := record.ToAPI(r)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
-			ok, err := record.Match(api, prg)
+			ok, err := record.Match(api, f.prg)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			if !ok {
 				continue
@@ -323,14 +451,15 @@ func (s *Server) getFilteredPaginatedSortedLogRecords(ctx context.Context, paren
 			// Change resource name to log format
 			parent, resultName, recordName, err := record.ParseName(api.Name)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			api.Name = log.FormatName(result.FormatName(parent, resultName), recordName)
 
-			rec = append(rec, api)
-			if len(rec) >= pageSize {
-				return rec, nil
+			if len(rec) == pageSize {
+				return rec, &pagination.Token{Name: last.ID, Keys: order.values(last)}, nil
 			}
+			rec = append(rec, api)
+			last = r
 		}
 
 		// We fetched fewer results than requested - this means we've exhausted all items.
@@ -339,10 +468,11 @@ func (s *Server) getFilteredPaginatedSortedLogRecords(ctx context.Context, paren
 		}
 
 		// Set params for next batch.
-		start = dbrecords[len(dbrecords)-1].ID
+		end := dbrecords[len(dbrecords)-1]
+		start = &pagination.Token{Name: end.ID, Keys: order.values(end)}
 		batcher.Update(len(dbrecords), batchSize)
 	}
-	return rec, nil
+	return rec, nil, nil
 }
 
 // DeleteLog deletes a given record and the stored log.
@@ -367,12 +497,15 @@ func (s *Server) DeleteLog(ctx context.Context, req *pb.DeleteLogRequest) (*empt
 			return &empty.Empty{}, err
 		}
 	}
-
-	streamer, _, err := log.ToStream(ctx, rec, s.config)
-	err = streamer.Delete()
+	storage, err := s.openLogStorage(ctx, []*db.Record{rec})
 	if err != nil {
-		return nil, err
+		return &empty.Empty{}, err
 	}
-
-	return &empty.Empty{}, errors.Wrap(s.db.WithContext(ctx).Delete(&db.Record{}, rec).Error)
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return s.deleteRecord(tx, rec)
+	})
+	if err != nil {
+		return &empty.Empty{}, err
+	}
+	return &empty.Empty{}, deleteLogStorage(storage)
 }
@@ -3,10 +3,12 @@ package server
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/go-cmp/cmp"
 	"github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/api/server/db"
 	"github.com/tektoncd/results/pkg/api/server/db/pagination"
 	"github.com/tektoncd/results/pkg/api/server/logger"
 	"github.com/tektoncd/results/pkg/api/server/test"
@@ -24,6 +26,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -131,6 +134,7 @@ func TestGetLog(t *testing.T) {
 						Resource: v1alpha2.Resource{
 							Namespace: "foo",
 							Name:      "baz",
+							UID:       "qux",
 						},
 						Type: v1alpha2.FileLogType,
 					},
@@ -157,6 +161,276 @@ func TestGetLog(t *testing.T) {
 	if expectedData != actualData {
 		t.Errorf("expected to have received %q, got %q", expectedData, actualData)
 	}
+
+	// Logs can also be fetched by the name of the record they belong to.
+	_, err = srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+		Parent: res.GetName(),
+		Record: &pb.Record{
+			Name: record.FormatName(res.GetName(), "qux"),
+			Data: &pb.Any{
+				Type:  "TaskRun",
+				Value: []byte("{}"),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateRecord: %v", err)
+	}
+	mock = &mockGetLogServer{
+		ctx: ctx,
+	}
+	err = srv.GetLog(&pb.GetLogRequest{
+		Name: log.FormatName(res.GetName(), "qux"),
+	}, mock)
+	if err != nil {
+		t.Fatalf("failed to get log by record: %v", err)
+	}
+	if actualData := mock.receivedData.String(); expectedData != actualData {
+		t.Errorf("expected to have received %q, got %q", expectedData, actualData)
+	}
+}
+
+func TestGetLog_Range(t *testing.T) {
+	srv, err := New(&config.Config{
+		LOGS_API:                 true,
+		LOGS_TYPE:                "File",
+		DB_ENABLE_AUTO_MIGRATION: true,
+	}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	res, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{
+			Name: "foo/results/bar",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+	rec := createLogRecord(t, srv, res.GetName(), filepath.Join(t.TempDir(), "log"))
+	if err := srv.UpdateLog(&mockUpdateLogServer{
+		ctx:       ctx,
+		record:    rec,
+		logStream: []string{"one\ntwo\n", "three\n"},
+	}); err != nil {
+		t.Fatalf("UpdateLog: %v", err)
+	}
+
+	name := log.FormatName(res.GetName(), "log")
+	for _, tc := range []struct {
+		name string
+		req  *pb.GetLogRequest
+		want string
+		code codes.Code
+	}{
+		{
+			name: "offset",
+			req:  &pb.GetLogRequest{Name: name, Offset: 4},
+			want: "two\nthree\n",
+		},
+		{
+			name: "offset and limit",
+			req:  &pb.GetLogRequest{Name: name, Offset: 4, Limit: 3},
+			want: "two",
+		},
+		{
+			name: "offset past the end",
+			req:  &pb.GetLogRequest{Name: name, Offset: 100},
+		},
+		{
+			name: "tail lines",
+			req:  &pb.GetLogRequest{Name: name, TailLines: 2},
+			want: "two\nthree\n",
+		},
+		{
+			name: "tail lines and limit",
+			req:  &pb.GetLogRequest{Name: name, TailLines: 1, Limit: 2},
+			want: "th",
+		},
+		{
+			name: "follow a stored log",
+			req:  &pb.GetLogRequest{Name: name, Follow: true},
+			want: "one\ntwo\nthree\n",
+		},
+		{
+			name: "negative offset",
+			req:  &pb.GetLogRequest{Name: name, Offset: -1},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "tail lines with offset",
+			req:  &pb.GetLogRequest{Name: name, Offset: 1, TailLines: 1},
+			code: codes.InvalidArgument,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &mockGetLogServer{
+				ctx:          ctx,
+				receivedData: &bytes.Buffer{},
+			}
+			err := srv.GetLog(tc.req, mock)
+			if status.Code(err) != tc.code {
+				t.Fatalf("GetLog: want code %v, got %v", tc.code, err)
+			}
+			if got := mock.receivedData.String(); got != tc.want {
+				t.Errorf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// chanUpdateLogServer receives the chunks of a log from a channel until it is
+// closed.
+type chanUpdateLogServer struct {
+	mockUpdateLogServer
+	chunks chan string
+}
+
+func (m *chanUpdateLogServer) Recv() (*pb.Log, error) {
+	data, ok := <-m.chunks
+	if !ok {
+		return nil, io.EOF
+	}
+	m.logStream = []string{data}
+	return m.mockUpdateLogServer.Recv()
+}
+
+func TestGetLog_Follow(t *testing.T) {
+	srv, err := New(&config.Config{
+		LOGS_API:                 true,
+		LOGS_TYPE:                "File",
+		DB_ENABLE_AUTO_MIGRATION: true,
+	}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	res, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{
+			Name: "foo/results/bar",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+	rec := createLogRecord(t, srv, res.GetName(), filepath.Join(t.TempDir(), "log"))
+	name := log.FormatName(res.GetName(), "log")
+
+	// Logs that were never stored can't be followed.
+	if err := srv.GetLog(&pb.GetLogRequest{Name: name, Follow: true}, &mockGetLogServer{ctx: ctx}); status.Code(err) != codes.NotFound {
+		t.Fatalf("GetLog: want NotFound, got %v", err)
+	}
+
+	update := &chanUpdateLogServer{
+		mockUpdateLogServer: mockUpdateLogServer{
+			ctx:    ctx,
+			record: rec,
+		},
+		chunks: make(chan string),
+	}
+	updated := make(chan error)
+	go func() {
+		updated <- srv.UpdateLog(update)
+	}()
+	update.chunks <- "one\n"
+	// Receiving the second chunk means the first one has been stored.
+	update.chunks <- "two\n"
+
+	mock := &mockGetLogServer{
+		ctx:          ctx,
+		receivedData: &bytes.Buffer{},
+	}
+	got := make(chan error)
+	go func() {
+		got <- srv.GetLog(&pb.GetLogRequest{Name: name, Offset: 4, Follow: true}, mock)
+	}()
+	update.chunks <- "three\n"
+	close(update.chunks)
+	if err := <-updated; err != nil {
+		t.Fatalf("UpdateLog: %v", err)
+	}
+
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatalf("GetLog: %v", err)
+		}
+	case <-time.After(5 * log.PollInterval):
+		t.Fatal("GetLog kept following a stored log")
+	}
+	if want, got := "two\nthree\n", mock.receivedData.String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+}
+
+func TestGetLog_FollowStale(t *testing.T) {
+	srv, err := New(&config.Config{
+		LOGS_API:                 true,
+		LOGS_TYPE:                "File",
+		LOGS_STORING_TIMEOUT:     time.Hour,
+		DB_ENABLE_AUTO_MIGRATION: true,
+	}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	res, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{
+			Name: "foo/results/bar",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "log")
+	if err := os.WriteFile(path, []byte("one\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	rec := createLogRecord(t, srv, res.GetName(), path)
+
+	// An UpdateLog stream was interrupted before unmarking the log.
+	rec.Data.Value = jsonutil.AnyBytes(t, &v1alpha2.Log{
+		Spec: v1alpha2.LogSpec{
+			Resource: v1alpha2.Resource{
+				Namespace: "foo",
+				Name:      "log",
+			},
+			Type: v1alpha2.FileLogType,
+		},
+		Status: v1alpha2.LogStatus{
+			Path:    path,
+			Size:    4,
+			Storing: true,
+		},
+	})
+	if _, err := srv.UpdateRecord(ctx, &pb.UpdateRecordRequest{Record: rec}); err != nil {
+		t.Fatalf("UpdateRecord: %v", err)
+	}
+	fakeClock.Advance(2 * time.Hour)
+
+	mock := &mockGetLogServer{
+		ctx:          ctx,
+		receivedData: &bytes.Buffer{},
+	}
+	got := make(chan error)
+	go func() {
+		got <- srv.GetLog(&pb.GetLogRequest{Name: log.FormatName(res.GetName(), "log"), Follow: true}, mock)
+	}()
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatalf("GetLog: %v", err)
+		}
+	case <-time.After(5 * log.PollInterval):
+		t.Fatal("GetLog kept following a stale log")
+	}
+	if want, got := "one\n", mock.receivedData.String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
 }
 
 func TestUpdateLog(t *testing.T) {
@@ -165,6 +439,7 @@ func TestUpdateLog(t *testing.T) {
 		LOGS_TYPE:                "File",
 		LOGS_API:                 true,
 		DB_ENABLE_AUTO_MIGRATION: true,
+		RECORD_REVISIONS:         true,
 	}
 	srv, err := New(c, logger.Get("info"), test.NewDB(t))
 	if err != nil {
@@ -241,6 +516,184 @@ func TestUpdateLog(t *testing.T) {
 	if mock.bytesReceived != int64(len(expectedData)) {
 		t.Errorf("expected to have received %d bytes, got %d", len(expectedData), mock.bytesReceived)
 	}
+	// The Record is updated once per stream.
+	var revisions int64
+	if err := srv.db.Model(&db.RecordRevision{}).Count(&revisions).Error; err != nil {
+		t.Fatal(err)
+	}
+	if revisions != 1 {
+		t.Errorf("want 1 revision of the Record, got %d", revisions)
+	}
+}
+
+func TestUpdateLog_Compressed(t *testing.T) {
+	srv, err := New(&config.Config{
+		LOGS_API:                 true,
+		LOGS_TYPE:                "File",
+		LOGS_COMPRESSION:         "zstd",
+		DB_ENABLE_AUTO_MIGRATION: true,
+	}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	res, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{
+			Name: "foo/results/bar",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "log")
+	rec := createLogRecord(t, srv, res.GetName(), path)
+	data := strings.Repeat("Hello world!\n", 100)
+	if err := srv.UpdateLog(&mockUpdateLogServer{
+		ctx:       ctx,
+		record:    rec,
+		logStream: []string{data[:650], data[650:]},
+	}); err != nil {
+		t.Fatalf("UpdateLog: %v", err)
+	}
+
+	stored, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read data from file: %v", err)
+	}
+	rec, err = srv.GetRecord(ctx, &pb.GetRecordRequest{Name: rec.GetName()})
+	if err != nil {
+		t.Fatalf("GetRecord: %v", err)
+	}
+	object := &v1alpha2.Log{}
+	if err := json.Unmarshal(rec.GetData().GetValue(), object); err != nil {
+		t.Fatal(err)
+	}
+	want := v1alpha2.LogStatus{
+		Path:           path,
+		Size:           int64(len(data)),
+		Compression:    v1alpha2.ZstdLogCompression,
+		CompressedSize: int64(len(stored)),
+	}
+	if diff := cmp.Diff(want, object.Status); diff != "" {
+		t.Errorf("Log status mismatch (-want +got):\n%s", diff)
+	}
+
+	name := log.FormatName(res.GetName(), "log")
+	mock := &mockGetLogServer{
+		ctx:          ctx,
+		receivedData: &bytes.Buffer{},
+	}
+	if err := srv.GetLog(&pb.GetLogRequest{Name: name}, mock); err != nil {
+		t.Fatalf("GetLog: %v", err)
+	}
+	if got := mock.receivedData.String(); got != data {
+		t.Errorf("want: %q, got: %q", data, got)
+	}
+
+	mock = &mockGetLogServer{
+		ctx:          ctx,
+		receivedData: &bytes.Buffer{},
+	}
+	if err := srv.GetLog(&pb.GetLogRequest{Name: name, Compressed: true}, mock); err != nil {
+		t.Fatalf("GetLog: %v", err)
+	}
+	if got := mock.receivedData.Bytes(); !bytes.Equal(got, stored) {
+		t.Errorf("want the stored log %q, got: %q", stored, got)
+	}
+
+	err = srv.GetLog(&pb.GetLogRequest{Name: name, Compressed: true, TailLines: 1}, &mockGetLogServer{ctx: ctx})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetLog: want InvalidArgument, got %v", err)
+	}
+}
+
+func TestUpdateLog_Database(t *testing.T) {
+	srv, err := New(&config.Config{
+		LOGS_API:                 true,
+		LOGS_TYPE:                "Database",
+		DB_ENABLE_AUTO_MIGRATION: true,
+	}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	res, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{
+			Name: "foo/results/bar",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+	// The log type defaults to LOGS_TYPE.
+	rec, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+		Parent: res.GetName(),
+		Record: &pb.Record{
+			Name: record.FormatName(res.GetName(), "log"),
+			Data: &pb.Any{
+				Type: v1alpha2.LogRecordType,
+				Value: jsonutil.AnyBytes(t, &v1alpha2.Log{
+					Spec: v1alpha2.LogSpec{
+						Resource: v1alpha2.Resource{
+							Namespace: "foo",
+							Name:      "log",
+						},
+					},
+				}),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateRecord: %v", err)
+	}
+
+	// Each UpdateLog session appends to the log.
+	for _, stream := range [][]string{{"Hello world! "}, {"This is ", "Tekton Results."}} {
+		rec, err = srv.GetRecord(ctx, &pb.GetRecordRequest{Name: rec.GetName()})
+		if err != nil {
+			t.Fatalf("GetRecord: %v", err)
+		}
+		if err := srv.UpdateLog(&mockUpdateLogServer{
+			ctx:       ctx,
+			record:    rec,
+			logStream: stream,
+		}); err != nil {
+			t.Fatalf("UpdateLog: %v", err)
+		}
+	}
+
+	mock := &mockGetLogServer{
+		ctx:          ctx,
+		receivedData: &bytes.Buffer{},
+	}
+	if err := srv.GetLog(&pb.GetLogRequest{Name: log.FormatName(res.GetName(), "log")}, mock); err != nil {
+		t.Fatalf("GetLog: %v", err)
+	}
+	want := "Hello world! This is Tekton Results."
+	if got := mock.receivedData.String(); got != want {
+		t.Errorf("want: %q, got: %q", want, got)
+	}
+	rec, err = srv.GetRecord(ctx, &pb.GetRecordRequest{Name: rec.GetName()})
+	if err != nil {
+		t.Fatalf("GetRecord: %v", err)
+	}
+	object := &v1alpha2.Log{}
+	if err := json.Unmarshal(rec.GetData().GetValue(), object); err != nil {
+		t.Fatal(err)
+	}
+	if object.Status.Size != int64(len(want)) {
+		t.Errorf("want the size of every session, %d, got %d", len(want), object.Status.Size)
+	}
+
+	if _, err := srv.DeleteLog(ctx, &pb.DeleteLogRequest{Name: log.FormatName(res.GetName(), "log")}); err != nil {
+		t.Fatalf("DeleteLog: %v", err)
+	}
+	var count int64
+	if err := srv.db.Model(&db.LogChunk{}).Count(&count).Error; err != nil || count != 0 {
+		t.Errorf("want no chunk left, got (%d, %v)", count, err)
+	}
 }
 
 func TestListLogs(t *testing.T) {
@@ -377,7 +830,7 @@ func TestListLogs(t *testing.T) {
 			},
 			want: &pb.ListRecordsResponse{
 				Records:       records[:1],
-				NextPageToken: pagetoken(t, records[1].GetUid(), `data_type == "results.tekton.dev/v1alpha2.Log"`),
+				NextPageToken: pagetoken(t, records[0].GetUid(), `data_type == "results.tekton.dev/v1alpha2.Log"`),
 			},
 		},
 		{
@@ -388,7 +841,7 @@ func TestListLogs(t *testing.T) {
 			},
 			want: &pb.ListRecordsResponse{
 				Records:       records[:1],
-				NextPageToken: pagetoken(t, records[1].GetUid(), ""),
+				NextPageToken: pagetoken(t, records[0].GetUid(), ""),
 			},
 		},
 		// Order By
@@ -492,8 +945,8 @@ func TestListLogs(t *testing.T) {
 
 			if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
 				t.Errorf("-want, +got: %s", diff)
-				if name, filter, err := pagination.DecodeToken(got.GetNextPageToken()); err == nil {
-					t.Logf("Next (name, filter) = (%s, %s)", name, filter)
+				if next, err := pagination.DecodeToken(got.GetNextPageToken()); err == nil {
+					t.Logf("Next token = %+v", next)
 				}
 			}
 		})
@@ -650,4 +1103,28 @@ func TestDeleteLog(t *testing.T) {
 			t.Fatalf("expected NOT_FOUND, got: %v", err)
 		}
 	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		rec, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+			Parent: res.GetName(),
+			Record: &pb.Record{
+				Name: record.FormatName(res.GetName(), "unknown"),
+				Data: &pb.Any{
+					Type:  v1alpha2.LogRecordType,
+					Value: []byte(`{"spec":{"type":"Unknown"}}`),
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("CreateRecord(): %v", err)
+		}
+		name := log.FormatName(res.GetName(), "unknown")
+		if _, err := srv.DeleteLog(ctx, &pb.DeleteLogRequest{Name: name}); status.Code(err) != codes.Internal {
+			t.Fatalf("expected INTERNAL, got: %v", err)
+		}
+		// The record is kept since its log couldn't be deleted.
+		if _, err := srv.GetRecord(ctx, &pb.GetRecordRequest{Name: rec.GetName()}); err != nil {
+			t.Fatalf("GetRecord(): %v", err)
+		}
+	})
 }
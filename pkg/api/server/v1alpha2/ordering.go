@@ -17,36 +17,196 @@ package server
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/tektoncd/results/pkg/api/server/cel2sql"
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/record"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/result"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-var allowedOrderByFields = []string{"created_time", "updated_time"}
+// orderField is a field List results can be sorted by.
+type orderField struct {
+	// column is the SQL expression the field is sorted by, with args as
+	// the arguments of its placeholders.
+	column string
+	args   []interface{}
+	// nullable is set if the column may be NULL. NULLs sort before any other
+	// value.
+	nullable bool
+	// value returns the value of the field for a database model, nil if
+	// NULL.
+	value func(m interface{}) interface{}
+}
+
+// orderFields returns the field a path of order_by refers to, and false if
+// sorting by the path is not supported.
+type orderFields func(d cel2sql.Dialect, path string) (orderField, bool)
 
-// orderBy validates and returns a string formatted suitably for
-// a sql order by clause.
-func orderBy(fields string) (string, error) {
+var (
+	createdTimeField = orderField{column: "created_time", value: createdTime}
+	updatedTimeField = orderField{column: "updated_time", value: updatedTime}
+)
+
+// resultOrderFields lists the fields Results can be sorted by.
+func resultOrderFields(d cel2sql.Dialect, path string) (orderField, bool) {
+	switch path {
+	case "created_time", "create_time":
+		return createdTimeField, true
+	case "updated_time", "update_time":
+		return updatedTimeField, true
+	case "name":
+		return orderField{column: d.Concat("parent", "'/results/'", "name"), value: func(m interface{}) interface{} {
+			r := m.(*db.Result)
+			return result.FormatName(r.Parent, r.Name)
+		}}, true
+	case "summary.type":
+		return orderField{column: "recordsummary_type", value: func(m interface{}) interface{} {
+			return m.(*db.Result).Summary.Type
+		}}, true
+	case "summary.status":
+		return orderField{column: "recordsummary_status", value: func(m interface{}) interface{} {
+			return int64(m.(*db.Result).Summary.Status)
+		}}, true
+	case "summary.start_time":
+		return orderField{column: "recordsummary_start_time", nullable: true, value: func(m interface{}) interface{} {
+			return timeValue(m.(*db.Result).Summary.StartTime)
+		}}, true
+	case "summary.end_time":
+		return orderField{column: "recordsummary_end_time", nullable: true, value: func(m interface{}) interface{} {
+			return timeValue(m.(*db.Result).Summary.EndTime)
+		}}, true
+	case "summary.duration":
+		column, err := d.Milliseconds("recordsummary_start_time", "recordsummary_end_time")
+		if err != nil {
+			return orderField{}, false
+		}
+		return orderField{column: column, nullable: true, value: func(m interface{}) interface{} {
+			s := m.(*db.Result).Summary
+			if s.StartTime == nil || s.EndTime == nil {
+				return nil
+			}
+			return s.EndTime.Round(time.Millisecond).Sub(s.StartTime.Round(time.Millisecond)).Milliseconds()
+		}}, true
+	}
+	if key := strings.TrimPrefix(path, "annotations."); key != path {
+		return annotationField(d, "annotations", key, func(m interface{}) db.Annotations {
+			return m.(*db.Result).Annotations
+		})
+	}
+	if key := strings.TrimPrefix(path, "summary.annotations."); key != path {
+		return annotationField(d, "recordsummary_annotations", key, func(m interface{}) db.Annotations {
+			return m.(*db.Result).Summary.Annotations
+		})
+	}
+	return orderField{}, false
+}
+
+// recordOrderFields lists the fields Records can be sorted by.
+func recordOrderFields(d cel2sql.Dialect, path string) (orderField, bool) {
+	switch path {
+	case "created_time", "create_time":
+		return createdTimeField, true
+	case "updated_time", "update_time":
+		return updatedTimeField, true
+	case "name":
+		return orderField{column: d.Concat("parent", "'/results/'", "result_name", "'/records/'", "name"), value: func(m interface{}) interface{} {
+			r := m.(*db.Record)
+			return record.FormatName(result.FormatName(r.Parent, r.ResultName), r.Name)
+		}}, true
+	case "data_type":
+		return orderField{column: "type", value: func(m interface{}) interface{} {
+			return m.(*db.Record).Type
+		}}, true
+	}
+	return orderField{}, false
+}
+
+// annotationField returns the field sorting by the value of an annotation
+// stored in a JSON column. Objects without the annotation sort first.
+func annotationField(d cel2sql.Dialect, column, key string, annotations func(m interface{}) db.Annotations) (orderField, bool) {
+	if key == "" {
+		return orderField{}, false
+	}
+	expr, args, err := d.JSONText(column, []interface{}{key})
+	if err != nil {
+		return orderField{}, false
+	}
+	return orderField{column: expr, args: args, nullable: true, value: func(m interface{}) interface{} {
+		v, ok := annotations(m)[key]
+		if !ok {
+			return nil
+		}
+		return v
+	}}, true
+}
+
+func timeValue(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+func createdTime(m interface{}) interface{} {
+	switch m := m.(type) {
+	case *db.Result:
+		return m.CreatedTime
+	case *db.Record:
+		return m.CreatedTime
+	case *db.RecordRevision:
+		return m.CreatedTime
+	}
+	return nil
+}
+
+func updatedTime(m interface{}) interface{} {
+	switch m := m.(type) {
+	case *db.Result:
+		return m.UpdatedTime
+	case *db.Record:
+		return m.UpdatedTime
+	}
+	return nil
+}
+
+// sortKey is a single term of a sort order.
+type sortKey struct {
+	orderField
+	desc bool
+}
+
+// sortOrder is the validated order_by of a List query. Rows are always
+// sorted by id last, which makes the ordering total so that pages can be
+// resumed from the sort key of the last item returned (keyset pagination).
+type sortOrder []sortKey
+
+// orderBy validates the order_by of a List request against the fields the
+// listed resource can be sorted by.
+func orderBy(d cel2sql.Dialect, allowed orderFields, fields string) (sortOrder, error) {
 	if strings.TrimSpace(fields) == "" {
-		return "", nil
+		return nil, nil
 	}
 
-	var orderBy []string
+	var order sortOrder
 	for _, field := range strings.Split(fields, ",") {
-		ob, err := normalizeOrderByField(field)
+		key, err := normalizeOrderByField(d, allowed, field)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		orderBy = append(orderBy, ob)
+		order = append(order, key)
 	}
-
-	return strings.Join(orderBy, ","), nil
+	return order, nil
 }
 
-// normalizeOrderByField takes a field string, validating and formatting
-// it for use in a sql query. An error is returned if the format of the string
-// doesn't match either "field_name" or "field_name direction".
-func normalizeOrderByField(field string) (string, error) {
+// normalizeOrderByField takes a field string and validates it. An error is
+// returned if the format of the string doesn't match either "field_name" or
+// "field_name direction".
+func normalizeOrderByField(d cel2sql.Dialect, allowed orderFields, field string) (sortKey, error) {
 	f := strings.Fields(field)
 	fieldName := ""
 	direction := ""
@@ -57,35 +217,145 @@ func normalizeOrderByField(field string) (string, error) {
 		fieldName = f[0]
 		direction = f[1]
 	default:
-		return "", status.Errorf(codes.InvalidArgument, "invalid order_by %q", field)
+		return sortKey{}, status.Errorf(codes.InvalidArgument, "invalid order_by %q", field)
 	}
 
-	if !isAllowedField(fieldName) {
-		return "", status.Errorf(codes.InvalidArgument, "order by %s not supported", fieldName)
+	of, ok := allowed(d, fieldName)
+	if !ok {
+		return sortKey{}, status.Errorf(codes.InvalidArgument, "order by %s not supported", fieldName)
 	}
 
-	if direction == "" {
-		return fieldName, nil
+	desc, err := orderByDirection(direction)
+	if err != nil {
+		return sortKey{}, err
 	}
-	return orderByDirection(fieldName, direction)
+	return sortKey{orderField: of, desc: desc}, nil
 }
 
-func isAllowedField(name string) bool {
-	for i := range allowedOrderByFields {
-		if name == allowedOrderByFields[i] {
-			return true
+// orderByDirection reports whether the direction sorts in descending order.
+func orderByDirection(direction string) (bool, error) {
+	switch strings.ToLower(direction) {
+	case "", "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, status.Errorf(codes.InvalidArgument, "invalid sort direction %q", direction)
+	}
+}
+
+// idDesc reports whether the id tiebreaker sorts in descending order. It
+// follows the direction of the last key.
+func (o sortOrder) idDesc() bool {
+	return len(o) > 0 && o[len(o)-1].desc
+}
+
+// String returns the order formatted for a SQL ORDER BY clause. NULLs are
+// explicitly sorted first since databases disagree on their default order.
+func (o sortOrder) String() string {
+	terms := make([]string, 0, len(o)+1)
+	for _, k := range o {
+		if k.nullable {
+			terms = append(terms, fmt.Sprintf("%s IS NULL %s", k.column, direction(!k.desc)))
 		}
+		terms = append(terms, fmt.Sprintf("%s %s", k.column, direction(k.desc)))
 	}
-	return false
+	terms = append(terms, fmt.Sprintf("id %s", direction(o.idDesc())))
+	return strings.Join(terms, ",")
 }
 
-func orderByDirection(field string, direction string) (string, error) {
-	switch strings.ToLower(direction) {
-	case "asc":
-		return fmt.Sprintf("%s ASC", field), nil
-	case "desc":
-		return fmt.Sprintf("%s DESC", field), nil
+// args returns the arguments of the placeholders of String.
+func (o sortOrder) args() []interface{} {
+	var args []interface{}
+	for _, k := range o {
+		if k.nullable {
+			args = append(args, k.args...)
+		}
+		args = append(args, k.args...)
+	}
+	return args
+}
+
+// apply adds the order to the query.
+func (o sortOrder) apply(q *gorm.DB) *gorm.DB {
+	return q.Clauses(clause.OrderBy{Expression: clause.Expr{SQL: o.String(), Vars: o.args(), WithoutParentheses: true}})
+}
+
+func direction(desc bool) string {
+	if desc {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// values returns the sort key of a database model.
+func (o sortOrder) values(m interface{}) []interface{} {
+	var values []interface{}
+	for _, k := range o {
+		values = append(values, k.value(m))
+	}
+	return values
+}
+
+// after returns a condition matching the rows sorted after the row with the
+// given sort key and id. The condition is expanded into
+// (a > ?) OR (a = ? AND b > ?) ... instead of using row value comparisons
+// since those do not support mixed directions.
+func (o sortOrder) after(values []interface{}, id string) (string, []interface{}) {
+	terms := make([]sortKey, 0, len(o)+1)
+	terms = append(terms, o...)
+	terms = append(terms, sortKey{orderField: orderField{column: "id"}, desc: o.idDesc()})
+	values = append(values[:len(o):len(o)], id)
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	for i, t := range terms {
+		gt, gtArgs, ok := t.greater(values[i])
+		if !ok {
+			continue
+		}
+		var cond []string
+		for j, eq := range terms[:i] {
+			c, a := eq.equal(values[j])
+			cond = append(cond, c)
+			args = append(args, a...)
+		}
+		cond = append(cond, gt)
+		args = append(args, gtArgs...)
+		conds = append(conds, "("+strings.Join(cond, " AND ")+")")
+	}
+	return strings.Join(conds, " OR "), args
+}
+
+// equal returns a condition matching the rows whose key equals v.
+func (k sortKey) equal(v interface{}) (string, []interface{}) {
+	if v == nil {
+		return fmt.Sprintf("%s IS NULL", k.column), k.args
+	}
+	return fmt.Sprintf("%s = ?", k.column), k.argsWith(v)
+}
+
+// argsWith returns the arguments of the column followed by v.
+func (f orderField) argsWith(v interface{}) []interface{} {
+	return append(append([]interface{}{}, f.args...), v)
+}
+
+// greater returns a condition matching the rows whose key sorts after v,
+// and false if there are none.
+func (k sortKey) greater(v interface{}) (string, []interface{}, bool) {
+	switch {
+	case v == nil && k.desc:
+		// NULLs sort last in descending order.
+		return "", nil, false
+	case v == nil:
+		return fmt.Sprintf("%s IS NOT NULL", k.column), k.args, true
+	case k.desc && k.nullable:
+		return fmt.Sprintf("(%s < ? OR %s IS NULL)", k.column, k.column), append(k.argsWith(v), k.args...), true
+	case k.desc:
+		return fmt.Sprintf("%s < ?", k.column), k.argsWith(v), true
 	default:
-		return "", status.Errorf(codes.InvalidArgument, "invalid sort direction %q", direction)
+		return fmt.Sprintf("%s > ?", k.column), k.argsWith(v), true
 	}
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/tektoncd/pipeline/test/diff"
+	"github.com/tektoncd/results/pkg/api/server/cel2sql"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -14,18 +15,27 @@ func TestOrderBy(t *testing.T) {
 		in  string
 		out string
 	}{{
+		in:  "",
+		out: "id ASC",
+	}, {
 		in:  "created_time DesC,updated_time aSc",
-		out: "created_time DESC,updated_time ASC",
+		out: "created_time DESC,updated_time ASC,id ASC",
 	}, {
 		in:  "   created_time    DesC   , updated_time deSC",
-		out: "created_time DESC,updated_time DESC",
+		out: "created_time DESC,updated_time DESC,id DESC",
+	}, {
+		in:  "summary.status, summary.start_time desc",
+		out: "recordsummary_status ASC,recordsummary_start_time IS NULL ASC,recordsummary_start_time DESC,id DESC",
+	}, {
+		in:  "annotations.team",
+		out: "json_extract(CAST(annotations AS TEXT), ?) IS NULL DESC,json_extract(CAST(annotations AS TEXT), ?) ASC,id ASC",
 	}} {
-		ob, err := orderBy(tc.in)
+		ob, err := orderBy(cel2sql.SQLite, resultOrderFields, tc.in)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
-		if ob != tc.out {
-			t.Errorf(diff.PrintWantGot(cmp.Diff(tc.out, ob)))
+		if ob.String() != tc.out {
+			t.Errorf(diff.PrintWantGot(cmp.Diff(tc.out, ob.String())))
 		}
 	}
 }
@@ -43,8 +53,13 @@ func TestOrderByInvalidArguments(t *testing.T) {
 		in: "x current_time asc",
 	}, {
 		in: " current_time asc x ",
+	}, {
+		in: "annotations.",
+	}, {
+		// Only Records can be sorted by data_type.
+		in: "data_type",
 	}} {
-		_, err := orderBy(tc.in)
+		_, err := orderBy(cel2sql.SQLite, resultOrderFields, tc.in)
 		if status.Code(err) != codes.InvalidArgument {
 			t.Errorf("expected error code %d received %d (error: %v)", codes.InvalidArgument, status.Code(err), err)
 		}
@@ -53,59 +68,107 @@ func TestOrderByInvalidArguments(t *testing.T) {
 
 func TestNormalizeOrderByField(t *testing.T) {
 	for _, tc := range []struct {
-		in  string
-		out string
+		in     string
+		column string
+		desc   bool
 	}{{
-		in:  "created_time",
-		out: "created_time",
+		in:     "created_time",
+		column: "created_time",
 	}, {
-		in:  "created_time asc",
-		out: "created_time ASC",
+		in:     "updated_time asc",
+		column: "updated_time",
 	}, {
-		in:  "   created_time    DesC   ",
-		out: "created_time DESC",
+		in:     "   created_time    DesC   ",
+		column: "created_time",
+		desc:   true,
 	}} {
-		n, err := normalizeOrderByField(tc.in)
+		k, err := normalizeOrderByField(cel2sql.SQLite, resultOrderFields, tc.in)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
-		if n != tc.out {
-			t.Errorf(diff.PrintWantGot(cmp.Diff(tc.out, n)))
-		}
-	}
-}
-
-func TestIsAllowedField(t *testing.T) {
-	if isAllowedField("not_an_allowed_field") {
-		t.Errorf("field unexpectedly allowed")
-	}
-	for _, s := range allowedOrderByFields {
-		if !isAllowedField(s) {
-			t.Errorf("%s expected to be allowed field", s)
+		if k.column != tc.column || k.desc != tc.desc {
+			t.Errorf("want (%s, %t), got (%s, %t)", tc.column, tc.desc, k.column, k.desc)
 		}
 	}
 }
 
 func TestOrderByDirection(t *testing.T) {
 	for _, tc := range []struct {
-		field     string
 		direction string
-		out       string
+		desc      bool
+		err       bool
 	}{{
-		field:     "foo",
+		direction: "",
+	}, {
 		direction: "asC",
-		out:       "foo ASC",
 	}, {
-		field:     "foo",
 		direction: "deSc",
-		out:       "foo DESC",
+		desc:      true,
+	}, {
+		direction: "up",
+		err:       true,
+	}} {
+		desc, err := orderByDirection(tc.direction)
+		if (err != nil) != tc.err {
+			t.Errorf("%q: want error %t, got %v", tc.direction, tc.err, err)
+		}
+		if desc != tc.desc {
+			t.Errorf("%q: want desc %t, got %t", tc.direction, tc.desc, desc)
+		}
+	}
+}
+
+func TestSortOrderAfter(t *testing.T) {
+	for _, tc := range []struct {
+		orderBy string
+		keys    []interface{}
+		sql     string
+		args    []interface{}
+	}{{
+		orderBy: "",
+		sql:     "(id > ?)",
+		args:    []interface{}{"x"},
+	}, {
+		orderBy: "created_time desc",
+		keys:    []interface{}{"t"},
+		sql:     "(created_time < ?) OR (created_time = ? AND id < ?)",
+		args:    []interface{}{"t", "t", "x"},
+	}, {
+		orderBy: "created_time desc, updated_time",
+		keys:    []interface{}{"c", "u"},
+		sql:     "(created_time < ?) OR (created_time = ? AND updated_time > ?) OR (created_time = ? AND updated_time = ? AND id > ?)",
+		args:    []interface{}{"c", "c", "u", "c", "u", "x"},
+	}, {
+		orderBy: "summary.end_time",
+		keys:    []interface{}{nil},
+		sql:     "(recordsummary_end_time IS NOT NULL) OR (recordsummary_end_time IS NULL AND id > ?)",
+		args:    []interface{}{"x"},
+	}, {
+		orderBy: "summary.end_time desc",
+		keys:    []interface{}{nil},
+		sql:     "(recordsummary_end_time IS NULL AND id < ?)",
+		args:    []interface{}{"x"},
+	}, {
+		orderBy: "summary.end_time desc",
+		keys:    []interface{}{"t"},
+		sql:     "((recordsummary_end_time < ? OR recordsummary_end_time IS NULL)) OR (recordsummary_end_time = ? AND id < ?)",
+		args:    []interface{}{"t", "t", "x"},
+	}, {
+		orderBy: "annotations.team",
+		keys:    []interface{}{"a"},
+		sql:     "(json_extract(CAST(annotations AS TEXT), ?) > ?) OR (json_extract(CAST(annotations AS TEXT), ?) = ? AND id > ?)",
+		args:    []interface{}{`$."team"`, "a", `$."team"`, "a", "x"},
 	}} {
-		s, err := orderByDirection(tc.field, tc.direction)
+		order, err := orderBy(cel2sql.SQLite, resultOrderFields, tc.orderBy)
 		if err != nil {
-			t.Errorf("unexpected error: %v", err)
+			t.Fatalf("orderBy: %v", err)
+		}
+		sql, args := order.after(tc.keys, "x")
+		if sql != tc.sql {
+			t.Errorf(diff.PrintWantGot(cmp.Diff(tc.sql, sql)))
 		}
-		if s != tc.out {
-			t.Errorf("expected %s received %s", tc.out, s)
+		if d := cmp.Diff(tc.args, args); d != "" {
+			t.Errorf(diff.PrintWantGot(d))
 		}
 	}
 }
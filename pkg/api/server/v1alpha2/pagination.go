@@ -38,17 +38,37 @@ func pageSize(in int) (int, error) {
 	return in, nil
 }
 
-func pageStart(token, filter string) (string, error) {
+// pageStart validates the page token against the query it is used with and
+// returns the position to resume listing from. A nil token is returned when
+// listing should start from the beginning.
+func pageStart(token, filter, orderBy string, order sortOrder) (*pagination.Token, error) {
 	if token == "" {
-		return "", nil
+		return nil, nil
 	}
 
-	tokenName, tokenFilter, err := pagination.DecodeToken(token)
+	t, err := pagination.DecodeToken(token)
 	if err != nil {
-		return "", status.Error(codes.InvalidArgument, fmt.Sprintf("invalid PageToken: %v", err))
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid PageToken: %v", err))
+	}
+	if filter != t.Filter {
+		return nil, status.Error(codes.InvalidArgument, "filter does not match previous query")
+	}
+	if orderBy != t.OrderBy {
+		return nil, status.Error(codes.InvalidArgument, "order_by does not match previous query")
+	}
+	if len(t.Keys) != len(order) {
+		return nil, status.Error(codes.InvalidArgument, "invalid PageToken: sort key does not match order_by")
 	}
-	if filter != tokenFilter {
-		return "", status.Error(codes.InvalidArgument, "filter does not match previous query")
+	return t, nil
+}
+
+// nextPageToken returns the token resuming a List query after the given
+// position, or an empty string when there are no more items.
+func nextPageToken(next *pagination.Token, filter, orderBy string) (string, error) {
+	if next == nil {
+		return "", nil
 	}
-	return tokenName, nil
+	next.Filter = filter
+	next.OrderBy = orderBy
+	return pagination.EncodeToken(next)
 }
@@ -17,6 +17,11 @@ package server
 import (
 	"fmt"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/results/pkg/api/server/cel2sql"
+	"github.com/tektoncd/results/pkg/api/server/db/pagination"
 )
 
 func TestPageSize(t *testing.T) {
@@ -48,24 +53,56 @@ func TestPageSize(t *testing.T) {
 }
 
 func TestPageStart(t *testing.T) {
+	order, err := orderBy(cel2sql.SQLite, resultOrderFields, "created_time desc")
+	if err != nil {
+		t.Fatalf("orderBy: %v", err)
+	}
+	ts := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
 	for _, tc := range []struct {
-		name   string
-		token  string
-		filter string
-		want   string
-		err    bool
+		name    string
+		token   string
+		filter  string
+		orderBy string
+		order   sortOrder
+		want    *pagination.Token
+		err     bool
 	}{
 		{
 			name:   "success",
 			token:  pagetoken(t, "a", "b"),
 			filter: "b",
-			want:   "a",
+			want:   &pagination.Token{Name: "a", Filter: "b"},
+		},
+		{
+			name:    "success with order by",
+			token:   sortedPagetoken(t, "a", "", "created_time desc", ts),
+			orderBy: "created_time desc",
+			order:   order,
+			want:    &pagination.Token{Name: "a", OrderBy: "created_time desc", Keys: []interface{}{ts}},
+		},
+		{
+			name:  "no token",
+			token: "",
 		},
 		{
 			name:  "wrong filter",
 			token: pagetoken(t, "a", "c"),
 			err:   true,
 		},
+		{
+			name:    "wrong order by",
+			token:   sortedPagetoken(t, "a", "", "created_time desc", ts),
+			orderBy: "created_time asc",
+			order:   order,
+			err:     true,
+		},
+		{
+			name:    "missing sort key",
+			token:   sortedPagetoken(t, "a", "", "created_time desc"),
+			orderBy: "created_time desc",
+			order:   order,
+			err:     true,
+		},
 		{
 			name:  "invalid token",
 			token: "tacocat",
@@ -73,9 +110,12 @@ func TestPageStart(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			got, err := pageStart(tc.token, tc.filter)
-			if got != tc.want || (err == nil && tc.err) {
-				t.Errorf("want (%s, %t), got (%s, %v)", tc.want, tc.err, got, err)
+			got, err := pageStart(tc.token, tc.filter, tc.orderBy, tc.order)
+			if (err != nil) != tc.err {
+				t.Fatalf("want error %t, got %v", tc.err, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("-want,+got: %s", diff)
 			}
 		})
 	}
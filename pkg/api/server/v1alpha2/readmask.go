@@ -0,0 +1,433 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tektoncd/results/pkg/api/server/cel2sql"
+	"github.com/tektoncd/results/pkg/api/server/db"
+
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"gorm.io/gorm"
+)
+
+// anyName is the name of the message holding Record data. Paths below it
+// that aren't fields of the message select parts of its JSON value.
+var anyName = (&pb.Any{}).ProtoReflect().Descriptor().FullName()
+
+// readMask is a parsed read mask, keyed by path segment. A nil readMask
+// selects all fields, as does an empty subtree.
+// See https://google.aip.dev/157 for more information.
+type readMask map[string]readMask
+
+// parseReadMask validates the paths of mask against the fields of m.
+func parseReadMask(mask *fieldmaskpb.FieldMask, m proto.Message) (readMask, error) {
+	var out readMask
+	for _, path := range mask.GetPaths() {
+		if path == "*" {
+			return nil, nil
+		}
+		segments, err := splitPath(path)
+		if err != nil {
+			return nil, err
+		}
+		segments, err = resolvePath(m.ProtoReflect().Descriptor(), segments)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid read mask path %q: %v", path, err)
+		}
+		if out == nil {
+			out = readMask{}
+		}
+		out.add(segments)
+	}
+	return out, nil
+}
+
+// resolvePath checks that path names fields of md, rewriting JSON paths
+// into data to paths into its value.
+func resolvePath(md protoreflect.MessageDescriptor, path []string) ([]string, error) {
+	for i := 0; i < len(path); i++ {
+		if md.FullName() == anyName && md.Fields().ByName(protoreflect.Name(path[i])) == nil {
+			return append(append(path[:i:i], "value"), path[i:]...), nil
+		}
+		fd := md.Fields().ByName(protoreflect.Name(path[i]))
+		switch {
+		case fd == nil:
+			return nil, status.Errorf(codes.InvalidArgument, "unknown field %q", path[i])
+		case fd.IsMap():
+			// The next segment, if any, is a map key.
+			if len(path) > i+2 {
+				return nil, status.Error(codes.InvalidArgument, "map values have no subfields")
+			}
+			return path, nil
+		case fd.Kind() == protoreflect.MessageKind && !fd.IsList():
+			md = fd.Message()
+		case fd.Kind() == protoreflect.BytesKind && md.FullName() == anyName:
+			// JSON path into the data value.
+			return path, nil
+		default:
+			if len(path) > i+1 {
+				return nil, status.Errorf(codes.InvalidArgument, "%s has no subfields", path[i])
+			}
+		}
+	}
+	return path, nil
+}
+
+func (m readMask) add(path []string) {
+	sub, ok := m[path[0]]
+	if ok && sub == nil {
+		// The whole field is already selected.
+		return
+	}
+	if len(path) == 1 {
+		m[path[0]] = nil
+		return
+	}
+	if sub == nil {
+		sub = readMask{}
+		m[path[0]] = sub
+	}
+	sub.add(path[1:])
+}
+
+// has reports whether any part of the field at path is selected.
+func (m readMask) has(path ...string) bool {
+	for _, p := range path {
+		if m == nil {
+			return true
+		}
+		sub, ok := m[p]
+		if !ok {
+			return false
+		}
+		m = sub
+	}
+	return true
+}
+
+// apply clears all fields of msg that aren't selected by the mask.
+func (m readMask) apply(msg proto.Message) error {
+	if m == nil {
+		return nil
+	}
+	return m.prune(msg.ProtoReflect())
+}
+
+func (m readMask) prune(msg protoreflect.Message) error {
+	var (
+		clear []protoreflect.FieldDescriptor
+		err   error
+	)
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		sub, ok := m[string(fd.Name())]
+		switch {
+		case !ok:
+			clear = append(clear, fd)
+		case sub == nil:
+		case fd.IsMap():
+			var keys []protoreflect.MapKey
+			v.Map().Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+				if _, ok := sub[k.String()]; !ok {
+					keys = append(keys, k)
+				}
+				return true
+			})
+			for _, k := range keys {
+				v.Map().Clear(k)
+			}
+		case fd.Kind() == protoreflect.MessageKind:
+			err = sub.prune(v.Message())
+		case fd.Kind() == protoreflect.BytesKind:
+			var b []byte
+			if b, err = sub.pruneJSON(v.Bytes()); err == nil {
+				msg.Set(fd, protoreflect.ValueOfBytes(b))
+			}
+		}
+		return err == nil
+	})
+	for _, fd := range clear {
+		msg.Clear(fd)
+	}
+	return err
+}
+
+// pruneJSON returns the parts of the JSON document b selected by the mask.
+func (m readMask) pruneJSON(b []byte) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, status.Errorf(codes.Internal, "error decoding data: %v", err)
+	}
+	v, err := m.pruneValue(v, nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// pruneValue returns the parts of the JSON value v at path selected by the
+// mask. The mask applies to every element of lists and selects nothing from
+// nulls, which are left out of objects. Scalars have no subfields to select.
+func (m readMask) pruneValue(v interface{}, path []string) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(m))
+		for k, sub := range m {
+			e, ok := v[k]
+			if !ok || sub != nil && e == nil {
+				continue
+			}
+			if sub != nil {
+				var err error
+				if e, err = sub.pruneValue(e, append(path[:len(path):len(path)], k)); err != nil {
+					return nil, err
+				}
+			}
+			out[k] = e
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			e, err := m.pruneValue(e, path)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, e)
+		}
+		return out, nil
+	case nil:
+		return nil, nil
+	}
+	return nil, status.Errorf(codes.InvalidArgument, "invalid read mask: %s has no subfields", strings.Join(append([]string{"data"}, path...), "."))
+}
+
+// at returns the part of the mask below path.
+func (m readMask) at(path []string) readMask {
+	for _, p := range path {
+		m = m[p]
+	}
+	return m
+}
+
+// leaves returns the paths of the fields selected whole by the mask, below
+// prefix, in order.
+func (m readMask) leaves(prefix []string) [][]string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var out [][]string
+	for _, k := range keys {
+		path := append(prefix[:len(prefix):len(prefix)], k)
+		if m[k] == nil {
+			out = append(out, path)
+		} else {
+			out = append(out, m[k].leaves(path)...)
+		}
+	}
+	return out
+}
+
+// dataSelection extracts the parts of the data of Records selected by a read
+// mask in the database, so that the data isn't read whole to be pruned.
+//
+// The data column is replaced by a JSON list holding a pair [depth, value]
+// for each field selected whole: depth is the length of the shortest prefix
+// of the path of the field whose value isn't an object, and value the value
+// at that prefix, left out if the prefix is missing from the data. The list is
+// assembled back into the selected parts of the data once read.
+type dataSelection struct {
+	mask   readMask
+	leaves [][]string
+	expr   string
+	args   []interface{}
+}
+
+// dataSelection returns the selection of the parts of data made by the mask,
+// nil if the mask selects all of the data or none of it, or if the paths it
+// selects can't be expressed in dialect d.
+func (m readMask) dataSelection(d cel2sql.Dialect) *dataSelection {
+	mask := m["data"]["value"]
+	if mask == nil {
+		return nil
+	}
+	s := &dataSelection{mask: mask, leaves: mask.leaves(nil)}
+	parts := []string{"'['"}
+	for i, leaf := range s.leaves {
+		if i > 0 {
+			parts = append(parts, "','")
+		}
+		part, args, err := selectLeaf(d, leaf)
+		if err != nil {
+			return nil
+		}
+		parts = append(parts, part)
+		s.args = append(s.args, args...)
+	}
+	s.expr = d.Concat(append(parts, "']'")...)
+	return s
+}
+
+// selectLeaf returns an expression yielding the [depth, value] pair of the
+// field at path.
+func selectLeaf(d cel2sql.Dialect, path []string) (string, []interface{}, error) {
+	var (
+		b    strings.Builder
+		args []interface{}
+	)
+	b.WriteString("CASE")
+	for depth := 0; depth <= len(path); depth++ {
+		prefix := make([]interface{}, depth)
+		for i := range prefix {
+			prefix[i] = path[i]
+		}
+		value, vargs, err := d.JSON("data", prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		pair := d.Concat(fmt.Sprintf("'[%d,'", depth), value, "']'")
+		if depth == len(path) {
+			fmt.Fprintf(&b, " WHEN %s IS NULL THEN '[%d]' ELSE %s END", value, depth, pair)
+			args = append(append(args, vargs...), vargs...)
+			break
+		}
+		object, oargs, err := d.JSONIsObject("data", prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		fmt.Fprintf(&b, " WHEN (%s) IS NULL THEN '[%d]' WHEN NOT (%s) THEN %s", object, depth, object, pair)
+		args = append(append(append(args, oargs...), oargs...), vargs...)
+	}
+	return b.String(), args, nil
+}
+
+// apply selects the columns of Records from q, with the selected parts of
+// the data in place of the data column.
+func (s *dataSelection) apply(q *gorm.DB) (*gorm.DB, error) {
+	stmt := &gorm.Statement{DB: q}
+	if err := stmt.Parse(&db.Record{}); err != nil {
+		return nil, status.Errorf(codes.Internal, "error reading Record columns: %v", err)
+	}
+	var columns []string
+	for _, name := range stmt.Schema.DBNames {
+		if name != "data" {
+			columns = append(columns, stmt.Quote(name))
+		}
+	}
+	columns = append(columns, fmt.Sprintf("%s AS %s", s.expr, stmt.Quote("data")))
+	return q.Select(strings.Join(columns, ", "), s.args...), nil
+}
+
+// assemble replaces the selected parts of the data of r, as read from the
+// database, with the JSON document holding them.
+func (s *dataSelection) assemble(r *db.Record) error {
+	var pairs [][]json.RawMessage
+	if err := json.Unmarshal(r.Data, &pairs); err != nil {
+		return status.Errorf(codes.Internal, "error decoding selected data: %v", err)
+	}
+	if len(pairs) != len(s.leaves) {
+		return status.Error(codes.Internal, "error decoding selected data")
+	}
+	var (
+		out    interface{} = map[string]interface{}{}
+		pruned             = map[string]bool{}
+	)
+	for i, leaf := range s.leaves {
+		var (
+			pair  = pairs[i]
+			depth int
+			value interface{}
+		)
+		if len(pair) == 0 || len(pair) > 2 {
+			return status.Error(codes.Internal, "error decoding selected data")
+		}
+		if err := json.Unmarshal(pair[0], &depth); err != nil || depth < 0 || depth > len(leaf) {
+			return status.Errorf(codes.Internal, "error decoding selected data: %v", err)
+		}
+		prefix := leaf[:depth]
+		if len(pair) == 1 {
+			// Missing values leave the objects along their path.
+			if depth > 0 {
+				out = withObjects(out, prefix[:depth-1])
+			}
+			continue
+		}
+		if err := json.Unmarshal(pair[1], &value); err != nil {
+			return status.Errorf(codes.Internal, "error decoding selected data: %v", err)
+		}
+		if depth < len(leaf) {
+			// The value at prefix isn't an object: select from it as from
+			// the whole data, once for all the fields below it.
+			key := strings.Join(prefix, "\x00")
+			if pruned[key] {
+				continue
+			}
+			pruned[key] = true
+			var err error
+			if value, err = s.mask.at(prefix).pruneValue(value, prefix); err != nil {
+				return err
+			}
+			if value == nil && depth > 0 {
+				out = withObjects(out, prefix[:depth-1])
+				continue
+			}
+		}
+		out = setValue(out, prefix, value)
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		return status.Errorf(codes.Internal, "error encoding data: %v", err)
+	}
+	r.Data = b
+	return nil
+}
+
+// withObjects returns the JSON document doc with objects along path,
+// creating the missing ones.
+func withObjects(doc interface{}, path []string) interface{} {
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		obj = map[string]interface{}{}
+	}
+	if len(path) > 0 {
+		obj[path[0]] = withObjects(obj[path[0]], path[1:])
+	}
+	return obj
+}
+
+// setValue sets the value at path in the JSON document doc, creating the
+// objects along it, and returns the document.
+func setValue(doc interface{}, path []string, v interface{}) interface{} {
+	if len(path) == 0 {
+		return v
+	}
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		obj = map[string]interface{}{}
+	}
+	obj[path[0]] = setValue(obj[path[0]], path[1:], v)
+	return obj
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/pipeline/test/diff"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestParseReadMask(t *testing.T) {
+	for _, tc := range []struct {
+		paths []string
+		want  readMask
+	}{{
+		paths: nil,
+		want:  nil,
+	}, {
+		paths: []string{"name", "*"},
+		want:  nil,
+	}, {
+		paths: []string{"name", "data.type"},
+		want:  readMask{"name": nil, "data": readMask{"type": nil}},
+	}, {
+		paths: []string{"data.metadata.name", "data.value.status"},
+		want:  readMask{"data": readMask{"value": readMask{"metadata": readMask{"name": nil}, "status": nil}}},
+	}, {
+		paths: []string{"data.metadata.name", "data"},
+		want:  readMask{"data": nil},
+	}} {
+		got, err := parseReadMask(&fieldmaskpb.FieldMask{Paths: tc.paths}, &pb.Record{})
+		if err != nil {
+			t.Fatalf("parseReadMask(%v): %v", tc.paths, err)
+		}
+		if d := cmp.Diff(tc.want, got); d != "" {
+			t.Errorf("parseReadMask(%v): %s", tc.paths, diff.PrintWantGot(d))
+		}
+	}
+
+	for _, path := range []string{"foo", "name.foo", "data.type.foo", "summary.foo", "annotations.a.b"} {
+		if _, err := parseReadMask(&fieldmaskpb.FieldMask{Paths: []string{path}}, &pb.Result{}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("parseReadMask(%q): want InvalidArgument, got %v", path, err)
+		}
+	}
+}
+
+func TestReadMaskApply(t *testing.T) {
+	result := func() *pb.Result {
+		return &pb.Result{
+			Name:        "foo/results/bar",
+			Annotations: map[string]string{"a": "1", "b": "2"},
+			Summary: &pb.RecordSummary{
+				Record: "foo/results/bar/records/baz",
+				Status: pb.RecordSummary_SUCCESS,
+			},
+		}
+	}
+	for _, tc := range []struct {
+		paths []string
+		want  *pb.Result
+	}{{
+		paths: nil,
+		want:  result(),
+	}, {
+		paths: []string{"name", "annotations.a", "summary.status"},
+		want: &pb.Result{
+			Name:        "foo/results/bar",
+			Annotations: map[string]string{"a": "1"},
+			Summary:     &pb.RecordSummary{Status: pb.RecordSummary_SUCCESS},
+		},
+	}} {
+		mask, err := parseReadMask(&fieldmaskpb.FieldMask{Paths: tc.paths}, &pb.Result{})
+		if err != nil {
+			t.Fatalf("parseReadMask(%v): %v", tc.paths, err)
+		}
+		got := result()
+		if err := mask.apply(got); err != nil {
+			t.Fatalf("apply(%v): %v", tc.paths, err)
+		}
+		if d := cmp.Diff(tc.want, got, protocmp.Transform()); d != "" {
+			t.Errorf("apply(%v): %s", tc.paths, diff.PrintWantGot(d))
+		}
+	}
+
+	mask, err := parseReadMask(&fieldmaskpb.FieldMask{Paths: []string{"data.status.conditions.type", "data.missing"}}, &pb.Record{})
+	if err != nil {
+		t.Fatalf("parseReadMask: %v", err)
+	}
+	got := &pb.Record{
+		Name: "foo/results/bar/records/baz",
+		Data: &pb.Any{
+			Type:  "TaskRun",
+			Value: []byte(`{"metadata":{"name":"baz"},"status":{"conditions":[{"type":"Succeeded","status":"True"}]}}`),
+		},
+	}
+	if err := mask.apply(got); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	want := &pb.Record{
+		Data: &pb.Any{
+			Value: []byte(`{"status":{"conditions":[{"type":"Succeeded"}]}}`),
+		},
+	}
+	if d := cmp.Diff(want, got, protocmp.Transform()); d != "" {
+		t.Errorf(diff.PrintWantGot(d))
+	}
+}
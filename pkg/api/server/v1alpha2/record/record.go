@@ -142,6 +142,9 @@ func Match(r *pb.Record, prg cel.Program) (bool, error) {
 	if r == nil {
 		return false, nil
 	}
+	if prg == nil {
+		return true, nil
+	}
 
 	var m map[string]interface{}
 	if d := r.GetData().GetValue(); d != nil {
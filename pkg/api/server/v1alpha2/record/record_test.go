@@ -274,6 +274,22 @@ func TestToAPI(t *testing.T) {
 				Uid:  "a",
 			},
 		},
+		{
+			name: "type without data",
+			in: &db.Record{
+				Parent:     "foo",
+				ResultID:   "1",
+				ResultName: "bar",
+				Name:       "baz",
+				ID:         "a",
+				Type:       "TaskRun",
+			},
+			want: &pb.Record{
+				Name: "foo/results/bar/records/baz",
+				Id:   "a",
+				Uid:  "a",
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			got, err := ToAPI(tc.in)
@@ -0,0 +1,85 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/tektoncd/results/pkg/api/server/db"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	// RevisionNameRegex matches valid name specs for a RecordRevision.
+	RevisionNameRegex = regexp.MustCompile("(^[a-z0-9_-]{1,63})/results/([a-z0-9_-]{1,63})/records/([a-z0-9_-]{1,63})/revisions/([a-z0-9_-]{1,63}$)")
+)
+
+// ParseRevisionName splits a full RecordRevision name into its individual
+// (parent, result, record, name) components.
+func ParseRevisionName(raw string) (parent, result, record, name string, err error) {
+	s := RevisionNameRegex.FindStringSubmatch(raw)
+	if len(s) != 5 {
+		return "", "", "", "", status.Errorf(codes.InvalidArgument, "name must match %s", RevisionNameRegex.String())
+	}
+	return s[1], s[2], s[3], s[4], nil
+}
+
+// FormatRevisionName takes in a Record name ("a/results/b/records/c") and
+// revision name ("d") and returns the full resource name
+// ("a/results/b/records/c/revisions/d").
+func FormatRevisionName(record, name string) string {
+	return fmt.Sprintf("%s/revisions/%s", record, name)
+}
+
+// NewRevision returns a revision holding the current state of the Record,
+// which is being replaced at the given time.
+func NewRevision(r *db.Record, id string, replaced time.Time) *db.RecordRevision {
+	return &db.RecordRevision{
+		Parent:      r.Parent,
+		ResultID:    r.ResultID,
+		RecordID:    r.ID,
+		ID:          id,
+		Type:        r.Type,
+		Data:        r.Data,
+		Etag:        r.Etag,
+		UpdatedTime: r.UpdatedTime,
+		CreatedTime: replaced,
+	}
+}
+
+// RevisionToAPI converts a database storage RecordRevision of the Record r
+// into its corresponding API equivalent.
+func RevisionToAPI(r *db.Record, rev *db.RecordRevision) (*pb.RecordRevision, error) {
+	snapshot := *r
+	snapshot.Type = rev.Type
+	snapshot.Data = rev.Data
+	snapshot.Etag = rev.Etag
+	snapshot.UpdatedTime = rev.UpdatedTime
+	record, err := ToAPI(&snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RecordRevision{
+		Name:       FormatRevisionName(record.GetName(), rev.ID),
+		Uid:        rev.ID,
+		Record:     record,
+		CreateTime: timestamppb.New(rev.CreatedTime),
+	}, nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/results/pkg/api/server/db"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestParseRevisionName(t *testing.T) {
+	parent, result, record, name, err := ParseRevisionName("a/results/b/records/c/revisions/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := []string{parent, result, record, name}, []string{"a", "b", "c", "d"}; !cmp.Equal(want, got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+
+	for _, in := range []string{
+		"a/results/b/records/c",
+		"a/results/b/records/c/revisions/",
+		"a/results/b/records/c/revisions/d/e",
+		"a/results/b/records/c/tacocat/d",
+	} {
+		if _, _, _, _, err := ParseRevisionName(in); err == nil {
+			t.Errorf("ParseRevisionName(%q): expected error", in)
+		}
+	}
+}
+
+func TestFormatRevisionName(t *testing.T) {
+	got := FormatRevisionName("a/results/b/records/c", "d")
+	want := "a/results/b/records/c/revisions/d"
+	if want != got {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+func TestRevisionToAPI(t *testing.T) {
+	created := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Minute)
+	replaced := updated.Add(time.Minute)
+
+	r := &db.Record{
+		Parent:      "foo",
+		ResultID:    "1",
+		ResultName:  "bar",
+		Name:        "baz",
+		ID:          "a",
+		Type:        "new",
+		Data:        []byte(`{"new": true}`),
+		CreatedTime: created,
+		UpdatedTime: replaced,
+		Etag:        "new",
+	}
+	rev := NewRevision(&db.Record{
+		Parent:      "foo",
+		ResultID:    "1",
+		ID:          "a",
+		Type:        "old",
+		Data:        []byte(`{"old": true}`),
+		UpdatedTime: updated,
+		Etag:        "old",
+	}, "rev", replaced)
+
+	got, err := RevisionToAPI(r, rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &pb.RecordRevision{
+		Name: "foo/results/bar/records/baz/revisions/rev",
+		Uid:  "rev",
+		Record: &pb.Record{
+			Name: "foo/results/bar/records/baz",
+			Id:   "a",
+			Uid:  "a",
+			Data: &pb.Any{
+				Type:  "old",
+				Value: []byte(`{"old": true}`),
+			},
+			Etag:        "old",
+			CreatedTime: timestamppb.New(created),
+			CreateTime:  timestamppb.New(created),
+			UpdatedTime: timestamppb.New(updated),
+			UpdateTime:  timestamppb.New(updated),
+		},
+		CreateTime: timestamppb.New(replaced),
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("-want,+got: %s", diff)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"github.com/tektoncd/pipeline/pkg/pod"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+// summaryKinds are the kinds of the Records which summarize their Result.
+var summaryKinds = map[string]bool{
+	"TaskRun":     true,
+	"PipelineRun": true,
+	"CustomRun":   true,
+}
+
+// run holds the fields of a TaskRun, PipelineRun or CustomRun needed to
+// summarize it.
+type run struct {
+	Metadata struct {
+		OwnerReferences []metav1.OwnerReference `json:"ownerReferences"`
+	} `json:"metadata"`
+	Status struct {
+		duckv1.Status  `json:",inline"`
+		StartTime      *metav1.Time `json:"startTime"`
+		CompletionTime *metav1.Time `json:"completionTime"`
+	} `json:"status"`
+}
+
+// Summary returns the RecordSummary of a Record, nil if the Record is not a
+// top-level TaskRun, PipelineRun or CustomRun. Runs are top-level if they are
+// not owned by another object, e.g. a PipelineRun.
+func Summary(r *pb.Record) (*pb.RecordSummary, error) {
+	if !summaryKinds[kind(r.GetData().GetType())] {
+		return nil, nil
+	}
+	in := new(run)
+	if err := json.Unmarshal(r.GetData().GetValue(), in); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to summarize record: %v", err)
+	}
+	if len(in.Metadata.OwnerReferences) > 0 {
+		return nil, nil
+	}
+	return &pb.RecordSummary{
+		Record:    r.GetName(),
+		Type:      r.GetData().GetType(),
+		Status:    Status(&in.Status),
+		StartTime: timestamp(in.Status.StartTime),
+		EndTime:   timestamp(in.Status.CompletionTime),
+	}, nil
+}
+
+// kind returns the kind of a Record data type, e.g. TaskRun for
+// tekton.dev/v1beta1.TaskRun or pipeline.tekton.dev/TaskRun.
+func kind(t string) string {
+	switch {
+	case strings.HasPrefix(t, "tekton.dev/"):
+		return t[strings.LastIndex(t, ".")+1:]
+	case strings.HasPrefix(t, "pipeline.tekton.dev/"):
+		return strings.TrimPrefix(t, "pipeline.tekton.dev/")
+	}
+	return ""
+}
+
+func timestamp(t *metav1.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(t.Time)
+}
+
+// Status maps a Run condition to a general Record status.
+func Status(ca apis.ConditionAccessor) pb.RecordSummary_Status {
+	c := ca.GetCondition(apis.ConditionSucceeded)
+	if c == nil {
+		return pb.RecordSummary_UNKNOWN
+	}
+
+	switch v1beta1.TaskRunReason(c.Reason) {
+	case v1beta1.TaskRunReasonSuccessful:
+		return pb.RecordSummary_SUCCESS
+	case v1beta1.TaskRunReasonFailed:
+		return pb.RecordSummary_FAILURE
+	case v1beta1.TaskRunReasonTimedOut:
+		return pb.RecordSummary_TIMEOUT
+	case v1beta1.TaskRunReasonCancelled:
+		return pb.RecordSummary_CANCELLED
+	case v1beta1.TaskRunReasonRunning, v1beta1.TaskRunReasonStarted:
+		return pb.RecordSummary_UNKNOWN
+	}
+
+	switch v1beta1.PipelineRunReason(c.Reason) {
+	case v1beta1.PipelineRunReasonSuccessful, v1beta1.PipelineRunReasonCompleted:
+		return pb.RecordSummary_SUCCESS
+	case v1beta1.PipelineRunReasonFailed:
+		return pb.RecordSummary_FAILURE
+	case v1beta1.PipelineRunReasonTimedOut:
+		return pb.RecordSummary_TIMEOUT
+	case v1beta1.PipelineRunReasonCancelled:
+		return pb.RecordSummary_CANCELLED
+	case v1beta1.PipelineRunReasonRunning, v1beta1.PipelineRunReasonStarted, v1beta1.PipelineRunReasonPending, v1beta1.PipelineRunReasonStopping, v1beta1.PipelineRunReasonCancelledRunningFinally, v1beta1.PipelineRunReasonStoppedRunningFinally:
+		return pb.RecordSummary_UNKNOWN
+	}
+
+	switch c.Reason {
+	case pod.ReasonCouldntGetTask, pod.ReasonFailedResolution, pod.ReasonFailedValidation, pod.ReasonExceededResourceQuota, pod.ReasonExceededNodeResources, pod.ReasonCreateContainerConfigError, pod.ReasonPodCreationFailed:
+		return pb.RecordSummary_FAILURE
+	case pod.ReasonPending:
+		return pb.RecordSummary_UNKNOWN
+	}
+	return pb.RecordSummary_UNKNOWN
+}
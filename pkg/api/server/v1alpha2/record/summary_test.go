@@ -0,0 +1,133 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"github.com/tektoncd/results/pkg/internal/jsonutil"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+)
+
+func TestSummary(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Minute)
+	succeeded := duckv1beta1.Status{Conditions: duckv1beta1.Conditions{{
+		Type:   apis.ConditionSucceeded,
+		Status: corev1.ConditionTrue,
+		Reason: string(v1beta1.TaskRunReasonSuccessful),
+	}}}
+
+	for _, tc := range []struct {
+		name string
+		data *pb.Any
+		want *pb.RecordSummary
+	}{
+		{
+			name: "task run",
+			data: &pb.Any{
+				Type: "tekton.dev/v1beta1.TaskRun",
+				Value: jsonutil.AnyBytes(t, &v1beta1.TaskRun{Status: v1beta1.TaskRunStatus{
+					Status: succeeded,
+					TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+						StartTime:      &metav1.Time{Time: start},
+						CompletionTime: &metav1.Time{Time: end},
+					},
+				}}),
+			},
+			want: &pb.RecordSummary{
+				Record:    "a/results/b/records/c",
+				Type:      "tekton.dev/v1beta1.TaskRun",
+				Status:    pb.RecordSummary_SUCCESS,
+				StartTime: timestamppb.New(start),
+				EndTime:   timestamppb.New(end),
+			},
+		},
+		{
+			name: "running pipeline run",
+			data: &pb.Any{
+				Type: "pipeline.tekton.dev/PipelineRun",
+				Value: jsonutil.AnyBytes(t, &v1beta1.PipelineRun{Status: v1beta1.PipelineRunStatus{
+					PipelineRunStatusFields: v1beta1.PipelineRunStatusFields{
+						StartTime: &metav1.Time{Time: start},
+					},
+				}}),
+			},
+			want: &pb.RecordSummary{
+				Record:    "a/results/b/records/c",
+				Type:      "pipeline.tekton.dev/PipelineRun",
+				Status:    pb.RecordSummary_UNKNOWN,
+				StartTime: timestamppb.New(start),
+			},
+		},
+		{
+			name: "custom run",
+			data: &pb.Any{
+				Type: "tekton.dev/v1beta1.CustomRun",
+				Value: jsonutil.AnyBytes(t, &v1beta1.CustomRun{Status: v1beta1.CustomRunStatus{
+					Status: duckv1.Status{Conditions: duckv1.Conditions{{
+						Type:   apis.ConditionSucceeded,
+						Status: corev1.ConditionFalse,
+						Reason: "Failed",
+					}}},
+				}}),
+			},
+			want: &pb.RecordSummary{
+				Record: "a/results/b/records/c",
+				Type:   "tekton.dev/v1beta1.CustomRun",
+				Status: pb.RecordSummary_FAILURE,
+			},
+		},
+		{
+			name: "owned task run",
+			data: &pb.Any{
+				Type: "tekton.dev/v1beta1.TaskRun",
+				Value: jsonutil.AnyBytes(t, &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{
+					OwnerReferences: []metav1.OwnerReference{{Kind: "PipelineRun", Name: "p"}},
+				}}),
+			},
+		},
+		{
+			name: "other type",
+			data: &pb.Any{Type: "results.tekton.dev/v1alpha2.Log", Value: []byte("{}")},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Summary(&pb.Record{Name: "a/results/b/records/c", Data: tc.data})
+			if err != nil {
+				t.Fatalf("Summary: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("-want, +got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestSummary_Invalid(t *testing.T) {
+	if _, err := Summary(&pb.Record{Data: &pb.Any{Type: "tekton.dev/v1beta1.TaskRun", Value: []byte("[]")}}); err == nil {
+		t.Error("Summary: want error for invalid run")
+	}
+}
@@ -19,8 +19,8 @@ import (
 
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/google/cel-go/cel"
-	"github.com/google/cel-go/checker/decls"
 	celenv "github.com/tektoncd/results/pkg/api/server/cel"
+	"github.com/tektoncd/results/pkg/api/server/cel2sql"
 	"github.com/tektoncd/results/pkg/api/server/db"
 	"github.com/tektoncd/results/pkg/api/server/db/errors"
 	"github.com/tektoncd/results/pkg/api/server/db/pagination"
@@ -37,27 +37,55 @@ import (
 )
 
 func (s *Server) CreateRecord(ctx context.Context, req *pb.CreateRecordRequest) (*pb.Record, error) {
+	check := func(parent string) error {
+		return s.auth.Check(ctx, parent, auth.ResourceRecords, auth.PermissionCreate)
+	}
+	cr, err := s.newCreateRequest(req.GetParent(), req.GetRequestId(), req)
+	if err != nil {
+		return nil, err
+	}
+	store, out, err := s.newRecord(ctx, req, check, s.getResultID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		out, err = s.createRecord(tx, cr, store, out)
+		return err
+	})
+	if prev, ok := cr.concurrent(s.db.WithContext(ctx), err); ok {
+		// A concurrent retry of the request created the Record first.
+		return storedRecord(s.db.WithContext(ctx), prev)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// newRecord validates the request, checks whether the Record's parent may be
+// written to, and returns the Record to store along with its API equivalent.
+func (s *Server) newRecord(ctx context.Context, req *pb.CreateRecordRequest, check func(parent string) error, getResultID getResultID) (*db.Record, *pb.Record, error) {
 	r := req.GetRecord()
 
 	// Validate the incoming request
 	parent, resultName, name, err := record.ParseName(r.GetName())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if req.GetParent() != result.FormatName(parent, resultName) {
-		return nil, status.Error(codes.InvalidArgument, "requested parent does not match resource name")
+		return nil, nil, status.Error(codes.InvalidArgument, "requested parent does not match resource name")
 	}
-	if err := s.auth.Check(ctx, parent, auth.ResourceRecords, auth.PermissionCreate); err != nil {
-		return nil, err
+	if err := check(parent); err != nil {
+		return nil, nil, err
 	}
 
 	// Look up the result ID from the name. This does not have to happen
 	// transactionally with the insert since name<->ID mappings are immutable,
 	// and if the parent result is deleted mid-request, the insert should
 	// fail due to foreign key constraints.
-	resultID, err := s.getResultID(ctx, parent, resultName)
+	resultID, err := getResultID(ctx, parent, resultName)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Populate Result with server provided fields.
@@ -71,19 +99,108 @@ func (s *Server) CreateRecord(ctx context.Context, req *pb.CreateRecordRequest)
 
 	store, err := record.ToStorage(parent, resultName, resultID, name, req.GetRecord(), s.config)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if err := record.UpdateEtag(store); err != nil {
+		return nil, nil, err
+	}
+	out, err := record.ToAPI(store)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, out, nil
+}
+
+// createRecord stores a new Record in the transaction tx, unless the request
+// is a retry, in which case the Record created by the first attempt is
+// returned instead.
+func (s *Server) createRecord(tx *gorm.DB, cr *createRequest, store *db.Record, out *pb.Record) (*pb.Record, error) {
+	prev, err := cr.previous(tx)
+	if err != nil {
 		return nil, err
 	}
-	q := s.db.WithContext(ctx).
-		Model(store).
-		Create(store).Error
-	if err := errors.Wrap(q); err != nil {
+	if prev != "" {
+		return storedRecord(tx, prev)
+	}
+
+	if err := s.insertRecord(tx, store, out); err != nil {
 		return nil, err
 	}
+	return out, cr.save(tx, out.GetName())
+}
 
-	return record.ToAPI(store)
+// storedRecord returns the Record with the given full name.
+func storedRecord(tx *gorm.DB, name string) (*pb.Record, error) {
+	parent, result, name, err := record.ParseName(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := getRecord(tx, parent, result, name)
+	if err != nil {
+		return nil, err
+	}
+	return record.ToAPI(r)
+}
+
+// insertRecord stores a new Record in the transaction tx.
+func (s *Server) insertRecord(tx *gorm.DB, store *db.Record, out *pb.Record) error {
+	q := tx.Model(store).Create(store).Error
+	if err := errors.Wrap(q); err != nil {
+		return err
+	}
+	if err := s.addChange(tx, pb.EventType_ADDED, out); err != nil {
+		return err
+	}
+	return s.summarizeResult(tx, store, out)
+}
+
+// summarizeResult updates the summary of the Result of r in the transaction tx
+// if r is a top-level run or of a type with a configured summary. The summary
+// is left alone if it refers to another Record.
+func (s *Server) summarizeResult(tx *gorm.DB, store *db.Record, r *pb.Record) error {
+	summary, err := s.summarizer.Summary(r)
+	if err == nil && summary == nil {
+		summary, err = record.Summary(r)
+	}
+	if err != nil || summary == nil {
+		return err
+	}
+	res := &db.Result{}
+	if err := errors.Wrap(tx.Where(&db.Result{Parent: store.Parent, ID: store.ResultID}).First(res).Error); err != nil {
+		return err
+	}
+	prev := result.ToAPI(res)
+	if name := prev.GetSummary().GetRecord(); name != "" && name != r.GetName() {
+		return nil
+	}
+	// Extracted annotations take precedence over the previous ones.
+	if prevAnnotations := prev.GetSummary().GetAnnotations(); len(prevAnnotations) > 0 {
+		annotations := make(map[string]string, len(prevAnnotations)+len(summary.GetAnnotations()))
+		for k, v := range prevAnnotations {
+			annotations[k] = v
+		}
+		for k, v := range summary.GetAnnotations() {
+			annotations[k] = v
+		}
+		summary.Annotations = annotations
+	}
+	if proto.Equal(prev.GetSummary(), summary) {
+		return nil
+	}
+
+	prev.Summary = summary
+	toDB, err := result.ToStorage(prev)
+	if err != nil {
+		return err
+	}
+	toDB.UpdatedTime = clock.Now()
+	if err := result.UpdateEtag(toDB); err != nil {
+		return err
+	}
+	if err := errors.Wrap(tx.Save(toDB).Error); err != nil {
+		return err
+	}
+	return s.addChange(tx, pb.EventType_MODIFIED, result.ToAPI(toDB))
 }
 
 // resultID is a utility struct to extract partial Result data representing
@@ -115,11 +232,61 @@ func (s *Server) GetRecord(ctx context.Context, req *pb.GetRecordRequest) (*pb.R
 		return nil, err
 	}
 
-	r, err := getRecord(s.db.WithContext(ctx), parent, result, name)
+	mask, err := parseReadMask(req.GetReadMask(), &pb.Record{})
 	if err != nil {
 		return nil, err
 	}
-	return record.ToAPI(r)
+
+	omitData := !mask.has("data", "value")
+	q, sel, err := selectRecordData(s.db.WithContext(ctx), mask, false)
+	if err != nil {
+		return nil, err
+	}
+	r, err := getRecord(q, parent, result, name)
+	if err != nil {
+		return nil, err
+	}
+	if sel != nil {
+		if err := sel.assemble(r); err != nil {
+			return nil, err
+		}
+	}
+	out, err := maskedRecordToAPI(r, omitData)
+	if err != nil {
+		return nil, err
+	}
+	return out, mask.apply(out)
+}
+
+// selectRecordData restricts the data of the Records read by q to the parts
+// selected by mask, unless the whole data is needed. The data selection
+// returned, if any, must be assembled into the Records read.
+func selectRecordData(q *gorm.DB, mask readMask, whole bool) (*gorm.DB, *dataSelection, error) {
+	switch {
+	case whole:
+		return q, nil, nil
+	case !mask.has("data", "value"):
+		return q.Omit("data"), nil, nil
+	}
+	sel := mask.dataSelection(cel2sql.Dialect(q.Dialector.Name()))
+	if sel == nil {
+		return q, nil, nil
+	}
+	q, err := sel.apply(q)
+	return q, sel, err
+}
+
+// maskedRecordToAPI converts r to its API representation, keeping the type of
+// its data when the data was not read from the database.
+func maskedRecordToAPI(r *db.Record, omitData bool) (*pb.Record, error) {
+	out, err := record.ToAPI(r)
+	if err != nil {
+		return nil, err
+	}
+	if omitData && r.Type != "" {
+		out.Data = &pb.Any{Type: r.Type}
+	}
+	return out, nil
 }
 
 func getRecord(txn *gorm.DB, parent, result, name string) (*db.Record, error) {
@@ -155,63 +322,77 @@ func (s *Server) ListRecords(ctx context.Context, req *pb.ListRecordsRequest) (*
 		return nil, err
 	}
 
-	start, err := pageStart(req.GetPageToken(), req.GetFilter())
+	sortOrder, err := orderBy(cel2sql.Dialect(s.db.Dialector.Name()), recordOrderFields, req.GetOrderBy())
 	if err != nil {
 		return nil, err
 	}
 
-	sortOrder, err := orderBy(req.GetOrderBy())
+	start, err := pageStart(req.GetPageToken(), req.GetFilter(), req.GetOrderBy(), sortOrder)
 	if err != nil {
 		return nil, err
 	}
 
-	env, err := recordCEL()
+	f, err := s.parseRecordFilter(req.GetFilter())
 	if err != nil {
 		return nil, err
 	}
-	prg, err := celenv.ParseFilter(env, req.GetFilter())
+	mask, err := parseReadMask(req.GetReadMask(), &pb.Record{})
 	if err != nil {
 		return nil, err
 	}
-	// Fetch n+1 items to get the next token.
-	out, err := s.getFilteredPaginatedSortedRecords(ctx, req.GetParent(), start, userPageSize+1, prg, sortOrder)
+	out, next, err := s.getFilteredPaginatedSortedRecords(ctx, req.GetParent(), start, userPageSize, f, sortOrder, mask)
 	if err != nil {
 		return nil, err
 	}
 
-	// If we returned the full n+1 items, use the last element as the next page
-	// token.
-	var nextToken string
-	if len(out) > userPageSize {
-		next := out[len(out)-1]
-		var err error
-		nextToken, err = pagination.EncodeToken(next.GetId(), req.GetFilter())
-		if err != nil {
-			return nil, err
-		}
-		out = out[:len(out)-1]
+	nextToken, err := nextPageToken(next, req.GetFilter(), req.GetOrderBy())
+	if err != nil {
+		return nil, err
 	}
 
-	return &pb.ListRecordsResponse{
+	resp := &pb.ListRecordsResponse{
 		Records:       out,
 		NextPageToken: nextToken,
-	}, nil
+	}
+	if req.GetIncludeTotalSize() {
+		n, err := countRecords(s.db.WithContext(ctx), req.GetParent(), f)
+		if err != nil {
+			return nil, err
+		}
+		resp.TotalSize = totalSize(n)
+		resp.TotalSizeApproximate = f.prg != nil
+	}
+	return resp, nil
 }
 
-// getFilteredPaginatedSortedRecords returns the specified number of results that
-// match the given CEL program.
-func (s *Server) getFilteredPaginatedSortedRecords(ctx context.Context, parent, start string, pageSize int, prg cel.Program, sortOrder string) ([]*pb.Record, error) {
+// getFilteredPaginatedSortedRecords returns the specified number of records that
+// match the given filter, starting after the start position. If there are
+// more records, the position of the last record returned is returned as well.
+// Only the fields selected by mask are returned; data is not read from the
+// database unless it is selected or needed to evaluate the filter.
+func (s *Server) getFilteredPaginatedSortedRecords(ctx context.Context, parent string, start *pagination.Token, pageSize int, f *filter, order sortOrder, mask readMask) ([]*pb.Record, *pagination.Token, error) {
 	parent, result, err := result.ParseName(parent)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	out := make([]*pb.Record, 0, pageSize)
-	batcher := pagination.NewBatcher(pageSize, minPageSize, maxPageSize)
-	for len(out) < pageSize {
+	// Fetch n+1 items to know whether there is a next page.
+	out := make([]*pb.Record, 0, pageSize+1)
+	var last *db.Record
+	batcher := pagination.NewBatcher(pageSize+1, minPageSize, maxPageSize)
+	for {
 		batchSize := batcher.Next()
 		dbrecords := make([]*db.Record, 0, batchSize)
-		q := s.db.WithContext(ctx).Where("id > ?", start)
+		// Filters evaluated in Go need the whole data.
+		omitData := f.prg == nil && !mask.has("data", "value")
+		q, sel, err := selectRecordData(s.db.WithContext(ctx), mask, f.prg != nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if start != nil {
+			cond, args := order.after(start.Keys, start.Name)
+			q = q.Where(cond, args...)
+		}
 		// Specifying `-` allows users to read Records across Results.
 		// See https://google.aip.dev/159 for more details.
 		if parent != "-" {
@@ -220,32 +401,39 @@ func (s *Server) getFilteredPaginatedSortedRecords(ctx context.Context, parent,
 		if result != "-" {
 			q = q.Where("result_name = ?", result)
 		}
-		if sortOrder != "" {
-			q = q.Order(sortOrder)
-		}
-		q = q.Limit(batchSize).Find(&dbrecords)
+		q = f.apply(q)
+		q = order.apply(q).Limit(batchSize).Find(&dbrecords)
 		if err := errors.Wrap(q.Error); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		// Only return results that match the filter.
 		for _, r := range dbrecords {
-			api, err := record.ToAPI(r)
+			if sel != nil {
+				if err := sel.assemble(r); err != nil {
+					return nil, nil, err
+				}
+			}
+			api, err := maskedRecordToAPI(r, omitData)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
-			ok, err := record.Match(api, prg)
+			ok, err := record.Match(api, f.prg)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			if !ok {
 				continue
 			}
 
-			out = append(out, api)
-			if len(out) >= pageSize {
-				return out, nil
+			if len(out) == pageSize {
+				return out, &pagination.Token{Name: last.ID, Keys: order.values(last)}, nil
 			}
+			if err := mask.apply(api); err != nil {
+				return nil, nil, err
+			}
+			out = append(out, api)
+			last = r
 		}
 
 		// We fetched fewer results than requested - this means we've exhausted
@@ -255,10 +443,11 @@ func (s *Server) getFilteredPaginatedSortedRecords(ctx context.Context, parent,
 		}
 
 		// Set params for next batch.
-		start = dbrecords[len(dbrecords)-1].ID
+		end := dbrecords[len(dbrecords)-1]
+		start = &pagination.Token{Name: end.ID, Keys: order.values(end)}
 		batcher.Update(len(dbrecords), batchSize)
 	}
-	return out, nil
+	return out, nil, nil
 }
 
 // UpdateRecord updates a record in the database.
@@ -289,32 +478,43 @@ func (s *Server) UpdateRecord(ctx context.Context, req *pb.UpdateRecordRequest)
 		}
 
 		// Merge existing data with user request.
-		pb, err := record.ToAPI(r)
+		updated, err := record.ToAPI(r)
 		if err != nil {
 			return err
 		}
-		// TODO: field mask support.
-		proto.Merge(pb, in)
+		if err := updateRecord(updated, in, req.GetUpdateMask()); err != nil {
+			return err
+		}
 
 		updateTime := timestamppb.New(clock.Now())
-		pb.UpdatedTime = updateTime
-		pb.UpdateTime = updateTime
+		updated.UpdatedTime = updateTime
+		updated.UpdateTime = updateTime
 
 		// Convert back to storage and store.
-		s, err := record.ToStorage(r.Parent, r.ResultName, r.ResultID, r.Name, pb, s.config)
+		store, err := record.ToStorage(r.Parent, r.ResultName, r.ResultID, r.Name, updated, s.config)
 		if err != nil {
 			return err
 		}
-		if err := record.UpdateEtag(s); err != nil {
+		if err := record.UpdateEtag(store); err != nil {
 			return err
 		}
-		if err := errors.Wrap(tx.Save(s).Error); err != nil {
+		if s.config.RECORD_REVISIONS {
+			// Keep the replaced data as a revision of the Record.
+			rev := record.NewRevision(r, uid(), updateTime.AsTime())
+			if err := errors.Wrap(tx.Create(rev).Error); err != nil {
+				return err
+			}
+		}
+		if err := errors.Wrap(tx.Save(store).Error); err != nil {
 			return err
 		}
 
-		pb.Etag = s.Etag
-		out = pb
-		return nil
+		updated.Etag = store.Etag
+		out = updated
+		if err := s.addChange(tx, pb.EventType_MODIFIED, out); err != nil {
+			return err
+		}
+		return s.summarizeResult(tx, store, out)
 	})
 	return out, err
 }
@@ -337,17 +537,30 @@ func (s *Server) DeleteRecord(ctx context.Context, req *pb.DeleteRecordRequest)
 	if err != nil {
 		return &empty.Empty{}, err
 	}
-	return &empty.Empty{}, errors.Wrap(s.db.WithContext(ctx).Delete(&db.Record{}, r).Error)
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return s.deleteRecord(tx, r)
+	})
+	return &empty.Empty{}, err
+}
+
+// deleteRecord deletes the Record r in the transaction tx, unless its Result
+// is held.
+func (s *Server) deleteRecord(tx *gorm.DB, r *db.Record) error {
+	if err := checkResultHold(tx, r.Parent, r.ResultID); err != nil {
+		return err
+	}
+	out, err := record.ToAPI(r)
+	if err != nil {
+		return err
+	}
+	if err := errors.Wrap(tx.Delete(&db.Record{}, r).Error); err != nil {
+		return err
+	}
+	return s.addChange(tx, pb.EventType_DELETED, out)
 }
 
-// recordCEL defines the CEL environment for querying Record data.
-// Fields are broken up explicitly in order to support dynamic handling of the
-// data field as a key-value document.
+// recordCEL defines the CEL environment for querying Record data, with data
+// as a dynamic key-value document.
 func recordCEL() (*cel.Env, error) {
-	return cel.NewEnv(
-		cel.Types(&pb.Record{}),
-		cel.Declarations(decls.NewVar("name", decls.String)),
-		cel.Declarations(decls.NewVar("data_type", decls.String)),
-		cel.Declarations(decls.NewVar("data", decls.Dyn)),
-	)
+	return celenv.NewRecordEnv("")
 }
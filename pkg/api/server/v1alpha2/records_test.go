@@ -31,6 +31,7 @@ import (
 	recordutil "github.com/tektoncd/results/pkg/api/server/v1alpha2/record"
 	"github.com/tektoncd/results/pkg/api/server/v1alpha2/result"
 	resultutil "github.com/tektoncd/results/pkg/api/server/v1alpha2/result"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/summary"
 	"github.com/tektoncd/results/pkg/internal/jsonutil"
 	ppb "github.com/tektoncd/results/proto/pipeline/v1beta1/pipeline_go_proto"
 	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
@@ -38,8 +39,11 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
 )
 
 func TestCreateRecord(t *testing.T) {
@@ -216,6 +220,132 @@ func TestGetRecord(t *testing.T) {
 		}
 	})
 
+	t.Run("read mask", func(t *testing.T) {
+		tr := &ppb.TaskRun{
+			Metadata: &ppb.ObjectMeta{
+				Name:      "taskrun",
+				Namespace: "foo",
+			},
+		}
+		created, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+			Parent: result.GetName(),
+			Record: &pb.Record{
+				Name: recordutil.FormatName(result.GetName(), "masked"),
+				Data: &pb.Any{
+					Type:  "tekton.dev/v1beta1.TaskRun",
+					Value: jsonutil.AnyBytes(t, tr),
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("CreateRecord: %v", err)
+		}
+
+		for _, tc := range []struct {
+			paths []string
+			want  *pb.Record
+		}{
+			{
+				paths: []string{"name", "data.type"},
+				want: &pb.Record{
+					Name: created.GetName(),
+					Data: &pb.Any{Type: "tekton.dev/v1beta1.TaskRun"},
+				},
+			},
+			{
+				paths: []string{"data.metadata.name"},
+				want: &pb.Record{
+					Data: &pb.Any{Value: []byte(`{"metadata":{"name":"taskrun"}}`)},
+				},
+			},
+			{
+				paths: []string{"*"},
+				want:  created,
+			},
+		} {
+			got, err := srv.GetRecord(ctx, &pb.GetRecordRequest{
+				Name:     created.GetName(),
+				ReadMask: &fieldmaskpb.FieldMask{Paths: tc.paths},
+			})
+			if err != nil {
+				t.Fatalf("GetRecord(%v): %v", tc.paths, err)
+			}
+			if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("GetRecord(%v) -want, +got: %s", tc.paths, diff)
+			}
+		}
+	})
+
+	t.Run("data read mask", func(t *testing.T) {
+		data := []byte(`{"metadata":{"name":"doc","labels":null},"spec":{"params":[{"name":"a","value":true},{"name":"b","value":"1"}],"empty":[]},"status":"done"}`)
+		created, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+			Parent: result.GetName(),
+			Record: &pb.Record{
+				Name: recordutil.FormatName(result.GetName(), "masked-data"),
+				Data: &pb.Any{Type: "example.dev/Doc", Value: data},
+			},
+		})
+		if err != nil {
+			t.Fatalf("CreateRecord: %v", err)
+		}
+
+		for _, tc := range []struct {
+			paths []string
+			want  string
+		}{
+			{
+				paths: []string{"data.status", "data.metadata.name"},
+				want:  `{"metadata":{"name":"doc"},"status":"done"}`,
+			},
+			{
+				paths: []string{"data.spec.params.name", "data.spec.params.value", "data.spec.empty.name"},
+				want:  `{"spec":{"empty":[],"params":[{"name":"a","value":true},{"name":"b","value":"1"}]}}`,
+			},
+			{
+				paths: []string{"data.metadata.labels.foo", "data.metadata.missing.foo", "data.missing.foo"},
+				want:  `{"metadata":{}}`,
+			},
+			{
+				paths: []string{"data.metadata.labels"},
+				want:  `{"metadata":{"labels":null}}`,
+			},
+		} {
+			got, err := srv.GetRecord(ctx, &pb.GetRecordRequest{
+				Name:     created.GetName(),
+				ReadMask: &fieldmaskpb.FieldMask{Paths: tc.paths},
+			})
+			if err != nil {
+				t.Fatalf("GetRecord(%v): %v", tc.paths, err)
+			}
+			if got := string(got.GetData().GetValue()); got != tc.want {
+				t.Errorf("GetRecord(%v): want %s, got %s", tc.paths, tc.want, got)
+			}
+
+			// Data selected in the database matches data pruned whole.
+			mask, err := parseReadMask(&fieldmaskpb.FieldMask{Paths: tc.paths}, &pb.Record{})
+			if err != nil {
+				t.Fatalf("parseReadMask(%v): %v", tc.paths, err)
+			}
+			pruned, err := mask["data"]["value"].pruneJSON(data)
+			if err != nil {
+				t.Fatalf("pruneJSON(%v): %v", tc.paths, err)
+			}
+			if string(pruned) != tc.want {
+				t.Errorf("pruneJSON(%v): want %s, got %s", tc.paths, tc.want, pruned)
+			}
+		}
+
+		for _, path := range []string{"data.status.foo", "data.spec.params.name.foo"} {
+			_, err := srv.GetRecord(ctx, &pb.GetRecordRequest{
+				Name:     created.GetName(),
+				ReadMask: &fieldmaskpb.FieldMask{Paths: []string{path}},
+			})
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("GetRecord(%q): want InvalidArgument, got %v", path, err)
+			}
+		}
+	})
+
 	// Errors
 	for _, tc := range []struct {
 		name string
@@ -237,6 +367,14 @@ func TestGetRecord(t *testing.T) {
 			req:  &pb.GetRecordRequest{Name: recordutil.FormatName(result.GetName(), "doesnotexist")},
 			want: codes.NotFound,
 		},
+		{
+			name: "invalid read mask",
+			req: &pb.GetRecordRequest{
+				Name:     record.GetName(),
+				ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"foo"}},
+			},
+			want: codes.InvalidArgument,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			if _, err := srv.GetRecord(ctx, tc.req); status.Code(err) != tc.want {
@@ -385,6 +523,34 @@ func TestListRecords(t *testing.T) {
 				Records: records[:3],
 			},
 		},
+		{
+			name: "filter by record data in list",
+			req: &pb.ListRecordsRequest{
+				Parent: result.GetName(),
+				Filter: `data.metadata.name in ["0", "4"]`,
+			},
+			want: &pb.ListRecordsResponse{
+				Records: []*pb.Record{records[0], records[4]},
+			},
+		},
+		{
+			name: "filter by missing record data",
+			req: &pb.ListRecordsRequest{
+				Parent: result.GetName(),
+				Filter: `data.metadata.labels["app"] == "foo"`,
+			},
+			want: &pb.ListRecordsResponse{},
+		},
+		{
+			name: "filter partially evaluated in memory",
+			req: &pb.ListRecordsRequest{
+				Parent: result.GetName(),
+				Filter: `data_type == "PipelineRun" && size(data.metadata.name) == 1`,
+			},
+			want: &pb.ListRecordsResponse{
+				Records: records[3:],
+			},
+		},
 		{
 			name: "filter by parent",
 			req: &pb.ListRecordsRequest{
@@ -395,6 +561,51 @@ func TestListRecords(t *testing.T) {
 				Records: records,
 			},
 		},
+		{
+			name: "read mask",
+			req: &pb.ListRecordsRequest{
+				Parent:   result.GetName(),
+				Filter:   `data_type == "TaskRun"`,
+				ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"name", "data.type"}},
+			},
+			want: &pb.ListRecordsResponse{
+				Records: []*pb.Record{
+					{Name: records[0].GetName(), Data: &pb.Any{Type: "TaskRun"}},
+					{Name: records[1].GetName(), Data: &pb.Any{Type: "TaskRun"}},
+					{Name: records[2].GetName(), Data: &pb.Any{Type: "TaskRun"}},
+				},
+			},
+		},
+		{
+			name: "data read mask",
+			req: &pb.ListRecordsRequest{
+				Parent:   result.GetName(),
+				Filter:   `data_type == "PipelineRun"`,
+				ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"data.metadata.name"}},
+			},
+			want: &pb.ListRecordsResponse{
+				Records: []*pb.Record{
+					{Data: &pb.Any{Value: []byte(`{"metadata":{"name":"3"}}`)}},
+					{Data: &pb.Any{Value: []byte(`{"metadata":{"name":"4"}}`)}},
+					{Data: &pb.Any{Value: []byte(`{"metadata":{"name":"5"}}`)}},
+				},
+			},
+		},
+		{
+			name: "read mask with filter evaluated in memory",
+			req: &pb.ListRecordsRequest{
+				Parent:   result.GetName(),
+				Filter:   `data_type == "PipelineRun" && size(data.metadata.name) == 1`,
+				ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"data.metadata.name"}},
+			},
+			want: &pb.ListRecordsResponse{
+				Records: []*pb.Record{
+					{Data: &pb.Any{Value: []byte(`{"metadata":{"name":"3"}}`)}},
+					{Data: &pb.Any{Value: []byte(`{"metadata":{"name":"4"}}`)}},
+					{Data: &pb.Any{Value: []byte(`{"metadata":{"name":"5"}}`)}},
+				},
+			},
+		},
 		// Pagination
 		{
 			name: "filter and page size",
@@ -405,7 +616,7 @@ func TestListRecords(t *testing.T) {
 			},
 			want: &pb.ListRecordsResponse{
 				Records:       records[:1],
-				NextPageToken: pagetoken(t, records[1].GetId(), `data_type == "TaskRun"`),
+				NextPageToken: pagetoken(t, records[0].GetId(), `data_type == "TaskRun"`),
 			},
 		},
 		{
@@ -416,7 +627,7 @@ func TestListRecords(t *testing.T) {
 			},
 			want: &pb.ListRecordsResponse{
 				Records:       records[:1],
-				NextPageToken: pagetoken(t, records[1].GetId(), ""),
+				NextPageToken: pagetoken(t, records[0].GetId(), ""),
 			},
 		},
 		// Order By
@@ -460,6 +671,16 @@ func TestListRecords(t *testing.T) {
 				Records: records,
 			},
 		},
+		{
+			name: "with order by data type and name",
+			req: &pb.ListRecordsRequest{
+				Parent:  result.GetName(),
+				OrderBy: "data_type, name desc",
+			},
+			want: &pb.ListRecordsResponse{
+				Records: []*pb.Record{records[5], records[4], records[3], records[2], records[1], records[0]},
+			},
+		},
 
 		// Errors
 		{
@@ -509,6 +730,14 @@ func TestListRecords(t *testing.T) {
 			},
 			status: codes.InvalidArgument,
 		},
+		{
+			name: "unsupported order field",
+			req: &pb.ListRecordsRequest{
+				Parent:  result.GetName(),
+				OrderBy: "summary.status",
+			},
+			status: codes.InvalidArgument,
+		},
 	}
 
 	for _, tc := range tt {
@@ -520,8 +749,8 @@ func TestListRecords(t *testing.T) {
 
 			if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
 				t.Errorf("-want, +got: %s", diff)
-				if name, filter, err := pagination.DecodeToken(got.GetNextPageToken()); err == nil {
-					t.Logf("Next (name, filter) = (%s, %s)", name, filter)
+				if next, err := pagination.DecodeToken(got.GetNextPageToken()); err == nil {
+					t.Logf("Next token = %+v", next)
 				}
 			}
 		})
@@ -593,7 +822,44 @@ func TestUpdateRecord(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "update mask",
+			record: &pb.Record{
+				Name: record.FormatName(result.GetName(), "c"),
+				Data: &pb.Any{
+					Type:  "foo",
+					Value: jsonutil.AnyBytes(t, tr),
+				},
+			},
+			req: &pb.UpdateRecordRequest{
+				Record: &pb.Record{
+					Name: record.FormatName(result.GetName(), "c"),
+					Data: &pb.Any{
+						Type: "bar",
+					},
+				},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"data.type"}},
+			},
+			diff: &pb.Record{
+				Data: &pb.Any{
+					Type: "bar",
+				},
+			},
+		},
 		// Errors
+		{
+			name: "unknown update mask path",
+			record: &pb.Record{
+				Name: record.FormatName(result.GetName(), "d"),
+			},
+			req: &pb.UpdateRecordRequest{
+				Record: &pb.Record{
+					Name: record.FormatName(result.GetName(), "d"),
+				},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"data.foo"}},
+			},
+			status: codes.InvalidArgument,
+		},
 		{
 			name: "rename",
 			req: &pb.UpdateRecordRequest{
@@ -723,6 +989,8 @@ func TestListRecords_multiresult(t *testing.T) {
 		t.Fatalf("failed to setup db: %v", err)
 	}
 	ctx := context.Background()
+	// Reset the IDs so the records are listed in creation order.
+	lastID = 0
 
 	records := make([]*pb.Record, 0, 8)
 	for i := 0; i < 2; i++ {
@@ -764,3 +1032,145 @@ func TestListRecords_multiresult(t *testing.T) {
 		t.Error(diff)
 	}
 }
+
+func TestRecordSummary(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to setup db: %v", err)
+	}
+	ctx := context.Background()
+
+	res, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{Name: "foo/results/bar"},
+	})
+	if err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+
+	start := fakeClock.Now().Add(-time.Minute)
+	pr := &v1beta1.PipelineRun{
+		ObjectMeta: v1.ObjectMeta{Name: "pr"},
+		Status: v1beta1.PipelineRunStatus{
+			PipelineRunStatusFields: v1beta1.PipelineRunStatusFields{StartTime: &v1.Time{Time: start}},
+		},
+	}
+	rec, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+		Parent: res.GetName(),
+		Record: &pb.Record{
+			Name: res.GetName() + "/records/pr",
+			Data: &pb.Any{Type: "tekton.dev/v1beta1.PipelineRun", Value: jsonutil.AnyBytes(t, pr)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateRecord: %v", err)
+	}
+	want := &pb.RecordSummary{
+		Record:    rec.GetName(),
+		Type:      "tekton.dev/v1beta1.PipelineRun",
+		Status:    pb.RecordSummary_UNKNOWN,
+		StartTime: timestamppb.New(start),
+	}
+	checkSummary := func(want *pb.RecordSummary) {
+		t.Helper()
+		got, err := srv.GetResult(ctx, &pb.GetResultRequest{Name: res.GetName()})
+		if err != nil {
+			t.Fatalf("GetResult: %v", err)
+		}
+		if diff := cmp.Diff(want, got.GetSummary(), protocmp.Transform()); diff != "" {
+			t.Errorf("summary -want, +got: %s", diff)
+		}
+	}
+	checkSummary(want)
+
+	// Child TaskRuns and other top-level runs do not change the summary.
+	tr := &v1beta1.TaskRun{ObjectMeta: v1.ObjectMeta{
+		Name:            "tr",
+		OwnerReferences: []v1.OwnerReference{{Kind: "PipelineRun", Name: "pr"}},
+	}}
+	for _, r := range []*pb.Record{
+		{Name: res.GetName() + "/records/tr", Data: &pb.Any{Type: "tekton.dev/v1beta1.TaskRun", Value: jsonutil.AnyBytes(t, tr)}},
+		{Name: res.GetName() + "/records/other", Data: &pb.Any{Type: "tekton.dev/v1beta1.PipelineRun", Value: jsonutil.AnyBytes(t, &v1beta1.PipelineRun{})}},
+	} {
+		if _, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{Parent: res.GetName(), Record: r}); err != nil {
+			t.Fatalf("CreateRecord: %v", err)
+		}
+	}
+	checkSummary(want)
+
+	fakeClock.Advance(time.Second)
+	pr.Status.CompletionTime = &v1.Time{Time: fakeClock.Now()}
+	pr.Status.SetCondition(&apis.Condition{
+		Type:   apis.ConditionSucceeded,
+		Status: corev1.ConditionFalse,
+		Reason: v1beta1.PipelineRunReasonTimedOut.String(),
+	})
+	rec.Data.Value = jsonutil.AnyBytes(t, pr)
+	if _, err := srv.UpdateRecord(ctx, &pb.UpdateRecordRequest{Record: rec}); err != nil {
+		t.Fatalf("UpdateRecord: %v", err)
+	}
+	want.Status = pb.RecordSummary_TIMEOUT
+	want.EndTime = timestamppb.New(fakeClock.Now())
+	checkSummary(want)
+}
+
+func TestRecordSummary_Custom(t *testing.T) {
+	summarizer, err := summary.Parse([]byte(`
+types:
+  example.dev/v1.Deploy:
+    status: 'data.phase == "Done" ? "SUCCESS" : "UNKNOWN"'
+    annotations:
+      git.sha: data.revision
+`))
+	if err != nil {
+		t.Fatalf("summary.Parse: %v", err)
+	}
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t), WithSummarizer(summarizer))
+	if err != nil {
+		t.Fatalf("failed to setup db: %v", err)
+	}
+	ctx := context.Background()
+
+	res, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{
+			Name: "foo/results/bar",
+			Summary: &pb.RecordSummary{
+				Record:      "foo/results/bar/records/deploy",
+				Type:        "example.dev/v1.Deploy",
+				Annotations: map[string]string{"team": "a"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+	rec, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+		Parent: res.GetName(),
+		Record: &pb.Record{
+			Name: res.GetName() + "/records/deploy",
+			Data: &pb.Any{Type: "example.dev/v1.Deploy", Value: []byte(`{"revision": "abc", "phase": "Running"}`)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateRecord: %v", err)
+	}
+	rec.Data.Value = []byte(`{"revision": "abc", "phase": "Done"}`)
+	if _, err := srv.UpdateRecord(ctx, &pb.UpdateRecordRequest{Record: rec}); err != nil {
+		t.Fatalf("UpdateRecord: %v", err)
+	}
+
+	got, err := srv.GetResult(ctx, &pb.GetResultRequest{Name: res.GetName()})
+	if err != nil {
+		t.Fatalf("GetResult: %v", err)
+	}
+	want := &pb.RecordSummary{
+		Record:      rec.GetName(),
+		Type:        "example.dev/v1.Deploy",
+		Status:      pb.RecordSummary_SUCCESS,
+		Annotations: map[string]string{"team": "a", "git.sha": "abc"},
+	}
+	if diff := cmp.Diff(want, got.GetSummary(), protocmp.Transform()); diff != "" {
+		t.Errorf("summary -want, +got: %s", diff)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/db/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"gorm.io/gorm"
+)
+
+// createRequest is a create request carrying a request ID, which makes it
+// idempotent: retries return the resource created by the first attempt.
+// See https://google.aip.dev/155.
+type createRequest struct {
+	parent string
+	id     string
+	hash   string
+	ttl    time.Duration
+}
+
+// newCreateRequest returns the idempotent request for req, nil if req has no
+// request ID or if request IDs are disabled. It must be called before req is
+// modified.
+func (s *Server) newCreateRequest(parent, id string, req proto.Message) (*createRequest, error) {
+	if id == "" || s.config.REQUEST_ID_TTL <= 0 {
+		return nil, nil
+	}
+	// Requests are hashed without their ID.
+	m := proto.Clone(req).ProtoReflect()
+	if f := m.Descriptor().Fields().ByName("request_id"); f != nil {
+		m.Clear(f)
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(m.Interface())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to hash request: %v", err)
+	}
+	sum := sha256.Sum256(b)
+	return &createRequest{
+		parent: parent,
+		id:     id,
+		hash:   hex.EncodeToString(sum[:]),
+		ttl:    s.config.REQUEST_ID_TTL,
+	}, nil
+}
+
+// previous returns the name of the resource created by an earlier request
+// with the same ID, or an empty string if there was none within the TTL.
+func (c *createRequest) previous(tx *gorm.DB) (string, error) {
+	if c == nil {
+		return "", nil
+	}
+	r := &db.Request{}
+	q := tx.Where(&db.Request{Parent: c.parent, ID: c.id}).Limit(1).Find(r)
+	if err := errors.Wrap(q.Error); err != nil || q.RowsAffected == 0 {
+		return "", err
+	}
+	if r.CreatedTime.Before(clock.Now().Add(-c.ttl)) {
+		// The ID expired and may be reused.
+		return "", errors.Wrap(tx.Delete(r).Error)
+	}
+	if r.Hash != c.hash {
+		return "", status.Errorf(codes.InvalidArgument, "request_id %q was already used by a different request", c.id)
+	}
+	return r.Name, nil
+}
+
+// save remembers that the request created the resource name.
+func (c *createRequest) save(tx *gorm.DB, name string) error {
+	if c == nil {
+		return nil
+	}
+	return errors.Wrap(tx.Create(&db.Request{
+		Parent:      c.parent,
+		ID:          c.id,
+		Name:        name,
+		Hash:        c.hash,
+		CreatedTime: clock.Now(),
+	}).Error)
+}
+
+// concurrent returns the name of the resource created by a concurrent request
+// with the same ID, if err is the ALREADY_EXISTS error raised when both create
+// the resource or save the ID. The transaction of the failed request must be
+// rolled back first. Unlike previous, it doesn't delete expired IDs.
+func (c *createRequest) concurrent(tx *gorm.DB, err error) (string, bool) {
+	if c == nil || status.Code(err) != codes.AlreadyExists {
+		return "", false
+	}
+	r := &db.Request{}
+	q := tx.Where(&db.Request{Parent: c.parent, ID: c.id, Hash: c.hash}).
+		Where("created_time >= ?", clock.Now().Add(-c.ttl)).
+		Limit(1).Find(r)
+	return r.Name, q.Error == nil && q.RowsAffected > 0
+}
+
+// PruneRequests deletes the request IDs older than the configured TTL.
+func (s *Server) PruneRequests(ctx context.Context) (int64, error) {
+	if s.config.REQUEST_ID_TTL <= 0 {
+		return 0, nil
+	}
+	q := s.db.WithContext(ctx).
+		Where("created_time < ?", clock.Now().Add(-s.config.REQUEST_ID_TTL)).
+		Delete(&db.Request{})
+	return q.RowsAffected, errors.Wrap(q.Error)
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/api/server/logger"
+	"github.com/tektoncd/results/pkg/api/server/test"
+	"github.com/tektoncd/results/pkg/internal/jsonutil"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestCreateRequestID(t *testing.T) {
+	srv, err := New(&config.Config{
+		DB_ENABLE_AUTO_MIGRATION: true,
+		REQUEST_ID_TTL:           time.Hour,
+	}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+
+	newResult := func(requestID string, annotations map[string]string) *pb.CreateResultRequest {
+		return &pb.CreateResultRequest{
+			Parent:    "foo",
+			Result:    &pb.Result{Name: "foo/results/bar", Annotations: annotations},
+			RequestId: requestID,
+		}
+	}
+	result, err := srv.CreateResult(ctx, newResult("1", nil))
+	if err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+
+	newRecord := func(requestID, name string) *pb.CreateRecordRequest {
+		return &pb.CreateRecordRequest{
+			Parent: result.GetName(),
+			Record: &pb.Record{
+				Name: result.GetName() + "/records/" + name,
+				Data: &pb.Any{Type: "TaskRun", Value: jsonutil.AnyBytes(t, map[string]string{"a": "b"})},
+			},
+			RequestId: requestID,
+		}
+	}
+	record, err := srv.CreateRecord(ctx, newRecord("1", "baz"))
+	if err != nil {
+		t.Fatalf("CreateRecord: %v", err)
+	}
+
+	t.Run("retry", func(t *testing.T) {
+		fakeClock.Advance(time.Minute)
+		got, err := srv.CreateResult(ctx, newResult("1", nil))
+		if err != nil {
+			t.Fatalf("CreateResult: %v", err)
+		}
+		if diff := cmp.Diff(result, got, protocmp.Transform()); diff != "" {
+			t.Errorf("CreateResult -want, +got: %s", diff)
+		}
+
+		gotRecord, err := srv.CreateRecord(ctx, newRecord("1", "baz"))
+		if err != nil {
+			t.Fatalf("CreateRecord: %v", err)
+		}
+		if diff := cmp.Diff(record, gotRecord, protocmp.Transform()); diff != "" {
+			t.Errorf("CreateRecord -want, +got: %s", diff)
+		}
+
+		batch, err := srv.BatchCreateRecords(ctx, &pb.BatchCreateRecordsRequest{
+			Parent:   result.GetName(),
+			Requests: []*pb.CreateRecordRequest{newRecord("1", "baz"), newRecord("2", "qux")},
+		})
+		if err != nil {
+			t.Fatalf("BatchCreateRecords: %v", err)
+		}
+		if len(batch.GetErrors()) != 0 || batch.GetRecords()[0].GetId() != record.GetId() {
+			t.Errorf("BatchCreateRecords: want %s to be returned again, got %v", record.GetName(), batch)
+		}
+	})
+
+	t.Run("concurrent retry", func(t *testing.T) {
+		// A retry which didn't see the ID saved by the first attempt fails to
+		// create the Result, which returns the created one instead.
+		cr, err := srv.newCreateRequest("foo", "1", newResult("1", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conflict := status.Error(codes.AlreadyExists, "conflict")
+		name, ok := cr.concurrent(srv.db, conflict)
+		if !ok || name != result.GetName() {
+			t.Fatalf("concurrent: want (%s, true), got (%s, %t)", result.GetName(), name, ok)
+		}
+		got, err := storedResult(srv.db, name)
+		if err != nil {
+			t.Fatalf("storedResult: %v", err)
+		}
+		if diff := cmp.Diff(result, got, protocmp.Transform()); diff != "" {
+			t.Errorf("storedResult -want, +got: %s", diff)
+		}
+
+		if _, ok := cr.concurrent(srv.db, status.Error(codes.Internal, "internal")); ok {
+			t.Error("concurrent: want other errors returned")
+		}
+		other, err := srv.newCreateRequest("foo", "3", newResult("3", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := other.concurrent(srv.db, conflict); ok {
+			t.Error("concurrent: want the conflict returned without a saved request ID")
+		}
+	})
+
+	t.Run("different request", func(t *testing.T) {
+		if _, err := srv.CreateResult(ctx, newResult("1", map[string]string{"a": "b"})); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("CreateResult: want %v, got %v", codes.InvalidArgument, err)
+		}
+		if _, err := srv.CreateRecord(ctx, newRecord("1", "other")); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("CreateRecord: want %v, got %v", codes.InvalidArgument, err)
+		}
+	})
+
+	t.Run("without request ID", func(t *testing.T) {
+		if _, err := srv.CreateResult(ctx, newResult("", nil)); status.Code(err) != codes.AlreadyExists {
+			t.Errorf("CreateResult: want %v, got %v", codes.AlreadyExists, err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		fakeClock.Advance(time.Hour)
+		if _, err := srv.CreateResult(ctx, newResult("1", nil)); status.Code(err) != codes.AlreadyExists {
+			t.Errorf("CreateResult: want %v, got %v", codes.AlreadyExists, err)
+		}
+		// The expired ID may be reused for a new request.
+		if _, err := srv.CreateRecord(ctx, newRecord("1", "other")); err != nil {
+			t.Errorf("CreateRecord: %v", err)
+		}
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		fakeClock.Advance(time.Hour)
+		// The IDs of the Result and of the second Record of the batch. The
+		// reused ID is exactly one hour old and kept.
+		if n, err := srv.PruneRequests(ctx); err != nil || n != 2 {
+			t.Errorf("PruneRequests: got (%d, %v), want 2 request IDs pruned", n, err)
+		}
+	})
+}
+
+func TestCreateRequestID_Disabled(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+
+	req := &pb.CreateResultRequest{
+		Parent:    "foo",
+		Result:    &pb.Result{Name: "foo/results/bar"},
+		RequestId: "1",
+	}
+	if _, err := srv.CreateResult(ctx, req); err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+	if _, err := srv.CreateResult(ctx, req); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("CreateResult: want %v, got %v", codes.AlreadyExists, err)
+	}
+}
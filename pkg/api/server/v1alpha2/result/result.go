@@ -69,9 +69,13 @@ func ToStorage(r *pb.Result) (*db.Result, error) {
 		ID:          id,
 		Name:        name,
 		Annotations: r.Annotations,
+		Retain:      r.GetRetain(),
 		Etag:        r.Etag,
 	}
 
+	if r.HoldUntil.IsValid() {
+		result.HoldUntil = ptr.Time(r.HoldUntil.AsTime())
+	}
 	if r.CreatedTime.IsValid() {
 		result.CreatedTime = r.CreatedTime.AsTime()
 	}
@@ -140,9 +144,23 @@ func ToAPI(r *db.Result) *pb.Result {
 		Annotations: r.Annotations,
 		Etag:        r.Etag,
 		Summary:     summary,
+		Retain:      r.Retain,
+		HoldUntil:   newTS(r.HoldUntil),
 	}
 }
 
+// Held reports whether the Result is held at the given time, in which case
+// neither it nor its Records and logs may be deleted.
+func Held(r *pb.Result, now time.Time) bool {
+	return r.GetRetain() || r.GetHoldUntil().IsValid() && r.GetHoldUntil().AsTime().After(now)
+}
+
+// HeldError returns the error reported when deleting the held Result name or
+// its children.
+func HeldError(name string) error {
+	return status.Errorf(codes.FailedPrecondition, "result %s is held", name)
+}
+
 func newTS(t *time.Time) *timestamppb.Timestamp {
 	if t == nil {
 		return nil
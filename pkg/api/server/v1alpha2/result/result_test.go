@@ -323,3 +323,23 @@ func TestFormatName(t *testing.T) {
 		t.Errorf("want %s, got %s", want, got)
 	}
 }
+
+func TestHeld(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		name   string
+		result *pb.Result
+		want   bool
+	}{
+		{name: "not held", result: &pb.Result{}},
+		{name: "retained", result: &pb.Result{Retain: true}, want: true},
+		{name: "held", result: &pb.Result{HoldUntil: timestamppb.New(now.Add(time.Minute))}, want: true},
+		{name: "hold expired", result: &pb.Result{HoldUntil: timestamppb.New(now.Add(-time.Minute))}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Held(tc.result, now); got != tc.want {
+				t.Errorf("want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
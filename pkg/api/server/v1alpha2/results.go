@@ -21,12 +21,12 @@ import (
 	"github.com/golang/protobuf/ptypes/empty"
 	"gorm.io/gorm"
 
-	"github.com/google/cel-go/cel"
-	celenv "github.com/tektoncd/results/pkg/api/server/cel"
+	"github.com/tektoncd/results/pkg/api/server/cel2sql"
 	"github.com/tektoncd/results/pkg/api/server/db"
 	"github.com/tektoncd/results/pkg/api/server/db/errors"
 	"github.com/tektoncd/results/pkg/api/server/db/pagination"
 	"github.com/tektoncd/results/pkg/api/server/v1alpha2/auth"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/record"
 	"github.com/tektoncd/results/pkg/api/server/v1alpha2/result"
 	"github.com/tektoncd/results/pkg/internal/protoutil"
 	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
@@ -50,6 +50,10 @@ func (s *Server) CreateResult(ctx context.Context, req *pb.CreateResultRequest)
 	if err := s.auth.Check(ctx, parent, auth.ResourceResults, auth.PermissionCreate); err != nil {
 		return nil, err
 	}
+	cr, err := s.newCreateRequest(parent, req.GetRequestId(), req)
+	if err != nil {
+		return nil, err
+	}
 
 	// Populate Result with server provided fields.
 	protoutil.ClearOutputOnly(r)
@@ -71,10 +75,47 @@ func (s *Server) CreateResult(ctx context.Context, req *pb.CreateResultRequest)
 		return nil, err
 	}
 
-	if err := errors.Wrap(s.db.WithContext(ctx).Create(store).Error); err != nil {
+	out := result.ToAPI(store)
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		prev, err := cr.previous(tx)
+		if err != nil {
+			return err
+		}
+		if prev != "" {
+			// Retry of a request, return the Result it created.
+			out, err = storedResult(tx, prev)
+			return err
+		}
+
+		if err := errors.Wrap(tx.Create(store).Error); err != nil {
+			return err
+		}
+		if err := cr.save(tx, out.GetName()); err != nil {
+			return err
+		}
+		return s.addChange(tx, pb.EventType_ADDED, out)
+	})
+	if prev, ok := cr.concurrent(s.db.WithContext(ctx), err); ok {
+		// A concurrent retry of the request created the Result first.
+		return storedResult(s.db.WithContext(ctx), prev)
+	}
+	if err != nil {
 		return nil, err
 	}
-	return result.ToAPI(store), nil
+	return out, nil
+}
+
+// storedResult returns the Result with the given full name.
+func storedResult(tx *gorm.DB, name string) (*pb.Result, error) {
+	parent, name, err := result.ParseName(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := getResultByParentName(tx, parent, name)
+	if err != nil {
+		return nil, err
+	}
+	return result.ToAPI(r), nil
 }
 
 // GetResult returns a single Result.
@@ -86,11 +127,16 @@ func (s *Server) GetResult(ctx context.Context, req *pb.GetResultRequest) (*pb.R
 	if err := s.auth.Check(ctx, parent, auth.ResourceResults, auth.PermissionGet); err != nil {
 		return nil, err
 	}
+	mask, err := parseReadMask(req.GetReadMask(), &pb.Result{})
+	if err != nil {
+		return nil, err
+	}
 	store, err := getResultByParentName(s.db, parent, name)
 	if err != nil {
 		return nil, err
 	}
-	return result.ToAPI(store), nil
+	out := result.ToAPI(store)
+	return out, mask.apply(out)
 }
 
 // UpdateResult updates a Result in the database.
@@ -124,9 +170,10 @@ func (s *Server) UpdateResult(ctx context.Context, req *pb.UpdateResultRequest)
 		// making sure to filter out any OUTPUT_ONLY fields, and only
 		// updatable fields.
 		// We can't use proto.Merge, since empty fields in the req should take
-		// precedence, so set each updatable field here.
-		newpb.Annotations = reqpb.GetAnnotations()
-		newpb.Summary = reqpb.GetSummary()
+		// precedence, so only the fields selected by the update mask are set.
+		if err := updateResult(newpb, reqpb, req.GetUpdateMask()); err != nil {
+			return err
+		}
 		toDB, err := result.ToStorage(newpb)
 		if err != nil {
 			return err
@@ -145,7 +192,7 @@ func (s *Server) UpdateResult(ctx context.Context, req *pb.UpdateResultRequest)
 		}
 		out = result.ToAPI(toDB)
 
-		return nil
+		return s.addChange(tx, pb.EventType_MODIFIED, out)
 	})
 	return out, err
 }
@@ -172,9 +219,62 @@ func (s *Server) DeleteResult(ctx context.Context, req *pb.DeleteResultRequest)
 		return &empty.Empty{}, err
 	}
 
-	// Delete the result.
-	delete := s.db.WithContext(ctx).Delete(&db.Result{}, r)
-	return &empty.Empty{}, errors.Wrap(delete.Error)
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return s.deleteResult(tx, r)
+	})
+	return &empty.Empty{}, err
+}
+
+// checkHold returns FAILED_PRECONDITION if the Result r is held.
+func checkHold(r *db.Result) error {
+	if result.Held(result.ToAPI(r), clock.Now()) {
+		return result.HeldError(result.FormatName(r.Parent, r.Name))
+	}
+	return nil
+}
+
+// checkResultHold returns FAILED_PRECONDITION if the Result with the given
+// parent and ID is held.
+func checkResultHold(tx *gorm.DB, parent, id string) error {
+	r := &db.Result{}
+	if err := errors.Wrap(tx.Where(&db.Result{Parent: parent, ID: id}).First(r).Error); err != nil {
+		return err
+	}
+	return checkHold(r)
+}
+
+// PruneResult deletes the Result r expired by a retention policy in the
+// transaction tx, reporting its deletion to Watch streams like DeleteResult.
+func (s *Server) PruneResult(tx *gorm.DB, r *db.Result) error {
+	return s.deleteResult(tx, r)
+}
+
+// deleteResult deletes the Result r in the transaction tx, unless it is held,
+// reporting the deletion of its Records as well.
+func (s *Server) deleteResult(tx *gorm.DB, r *db.Result) error {
+	if err := checkResultHold(tx, r.Parent, r.ID); err != nil {
+		return err
+	}
+	var records []*db.Record
+	if s.watchEnabled() {
+		q := tx.Where(&db.Record{Parent: r.Parent, ResultID: r.ID}).Find(&records)
+		if err := errors.Wrap(q.Error); err != nil {
+			return err
+		}
+	}
+	if err := errors.Wrap(tx.Delete(&db.Result{Parent: r.Parent, ID: r.ID}).Error); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		api, err := record.ToAPI(rec)
+		if err != nil {
+			return err
+		}
+		if err := s.addChange(tx, pb.EventType_DELETED, api); err != nil {
+			return err
+		}
+	}
+	return s.addChange(tx, pb.EventType_DELETED, result.ToAPI(r))
 }
 
 func (s *Server) ListResults(ctx context.Context, req *pb.ListResultsRequest) (*pb.ListResultsResponse, error) {
@@ -190,83 +290,97 @@ func (s *Server) ListResults(ctx context.Context, req *pb.ListResultsRequest) (*
 		return nil, err
 	}
 
-	start, err := pageStart(req.GetPageToken(), req.GetFilter())
+	sortOrder, err := orderBy(cel2sql.Dialect(s.db.Dialector.Name()), resultOrderFields, req.GetOrderBy())
 	if err != nil {
 		return nil, err
 	}
 
-	sortOrder, err := orderBy(req.GetOrderBy())
+	start, err := pageStart(req.GetPageToken(), req.GetFilter(), req.GetOrderBy(), sortOrder)
 	if err != nil {
 		return nil, err
 	}
 
-	prg, err := celenv.ParseFilter(s.env, req.GetFilter())
+	f, err := s.parseFilter(s.env, resultFields, req.GetFilter())
 	if err != nil {
 		return nil, err
 	}
-	// Fetch n+1 items to get the next token.
-	out, err := s.getFilteredPaginatedSortedResults(ctx, req.GetParent(), start, userPageSize+1, prg, sortOrder)
+	mask, err := parseReadMask(req.GetReadMask(), &pb.Result{})
 	if err != nil {
 		return nil, err
 	}
-
-	// If we returned the full n+1 items, use the last element as the next page
-	// token.
-	var nextToken string
-	if len(out) > userPageSize {
-		next := out[len(out)-1]
-		var err error
-		nextToken, err = pagination.EncodeToken(next.GetId(), req.GetFilter())
-		if err != nil {
+	out, next, err := s.getFilteredPaginatedSortedResults(ctx, req.GetParent(), start, userPageSize, f, sortOrder)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range out {
+		if err := mask.apply(r); err != nil {
 			return nil, err
 		}
-		out = out[:len(out)-1]
 	}
 
-	return &pb.ListResultsResponse{
+	nextToken, err := nextPageToken(next, req.GetFilter(), req.GetOrderBy())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ListResultsResponse{
 		Results:       out,
 		NextPageToken: nextToken,
-	}, nil
+	}
+	if req.GetIncludeTotalSize() {
+		n, err := countResults(s.db.WithContext(ctx), req.GetParent(), f)
+		if err != nil {
+			return nil, err
+		}
+		resp.TotalSize = totalSize(n)
+		resp.TotalSizeApproximate = f.prg != nil
+	}
+	return resp, nil
 }
 
 // getFilteredPaginatedSortedResults returns the specified number of results that
-// match the given CEL program.
-func (s *Server) getFilteredPaginatedSortedResults(ctx context.Context, parent string, start string, pageSize int, prg cel.Program, sortOrder string) ([]*pb.Result, error) {
-	out := make([]*pb.Result, 0, pageSize)
-	batcher := pagination.NewBatcher(pageSize, minPageSize, maxPageSize)
-	for len(out) < pageSize {
+// match the given filter, starting after the start position. If there are
+// more results, the position of the last result returned is returned as well.
+func (s *Server) getFilteredPaginatedSortedResults(ctx context.Context, parent string, start *pagination.Token, pageSize int, f *filter, order sortOrder) ([]*pb.Result, *pagination.Token, error) {
+	// Fetch n+1 items to know whether there is a next page.
+	out := make([]*pb.Result, 0, pageSize+1)
+	var last *db.Result
+	batcher := pagination.NewBatcher(pageSize+1, minPageSize, maxPageSize)
+	for {
 		batchSize := batcher.Next()
 		dbresults := make([]*db.Result, 0, batchSize)
-		q := s.db.WithContext(ctx).Where("id > ?", start)
+		q := s.db.WithContext(ctx)
+		if start != nil {
+			cond, args := order.after(start.Keys, start.Name)
+			q = q.Where(cond, args...)
+		}
 		// Specifying `-` allows users to read Results from any parent.
 		// See https://google.aip.dev/159 for more details.
 		if parent != "-" {
 			q = q.Where("parent = ?", parent)
 		}
-
-		if sortOrder != "" {
-			q.Order(sortOrder)
-		}
-		q.Limit(batchSize).Find(&dbresults)
+		q = f.apply(q)
+		q = order.apply(q).Limit(batchSize).Find(&dbresults)
 		if err := errors.Wrap(q.Error); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		// Only return results that match the filter.
 		for _, r := range dbresults {
 			api := result.ToAPI(r)
-			ok, err := result.Match(api, prg)
+			ok, err := result.Match(api, f.prg)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			if !ok {
 				continue
 			}
 
-			out = append(out, api)
-			if len(out) >= pageSize {
-				return out, nil
+			if len(out) == pageSize {
+				return out, &pagination.Token{Name: last.ID, Keys: order.values(last)}, nil
 			}
+			out = append(out, api)
+			last = r
 		}
 
 		// We fetched fewer results than requested - this means we've exhausted
@@ -276,11 +390,11 @@ func (s *Server) getFilteredPaginatedSortedResults(ctx context.Context, parent s
 		}
 
 		// Set params for next batch.
-		start = dbresults[len(dbresults)-1].ID
+		end := dbresults[len(dbresults)-1]
+		start = &pagination.Token{Name: end.ID, Keys: order.values(end)}
 		batcher.Update(len(dbresults), batchSize)
-
 	}
-	return out, nil
+	return out, nil, nil
 }
 
 func getResultByParentName(gdb *gorm.DB, parent, name string) (*db.Result, error) {
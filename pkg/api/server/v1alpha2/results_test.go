@@ -32,6 +32,7 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -143,6 +144,7 @@ func TestUpdateResult(t *testing.T) {
 		requestName string // the `Name` field of the `UpdateResultRequest`
 		etag        string
 		update      *pb.Result
+		updateMask  []string
 		// `expect` is the expected result after an update request, it only contains two fields here: `Annotations` and `Etag`.
 		// the other fields will be set the same as the automatically created one.
 		expect  *pb.Result
@@ -170,6 +172,25 @@ func TestUpdateResult(t *testing.T) {
 			name:   "test update with empty result",
 			expect: &pb.Result{},
 		},
+		{
+			name: "update mask",
+			update: &pb.Result{
+				Name:        "ignored",
+				Annotations: map[string]string{"foo": "bar", "a.b": "c", "ignored": "true"},
+				Summary: &pb.RecordSummary{
+					Record: "foo/results/bar/records/baz",
+					Type:   "bar",
+				},
+			},
+			updateMask: []string{"name", "annotations.foo", "annotations.`a.b`", "summary"},
+			expect: &pb.Result{
+				Annotations: map[string]string{"foo": "bar", "a.b": "c"},
+				Summary: &pb.RecordSummary{
+					Record: "foo/results/bar/records/baz",
+					Type:   "bar",
+				},
+			},
+		},
 		// errors
 		{
 			name:        "test update with invalid name",
@@ -191,6 +212,12 @@ func TestUpdateResult(t *testing.T) {
 			update:  &pb.Result{Summary: &pb.RecordSummary{}},
 			errcode: codes.InvalidArgument,
 		},
+		{
+			name:       "unknown update mask path",
+			update:     &pb.Result{},
+			updateMask: []string{"summary.annotations.foo.bar"},
+			errcode:    codes.InvalidArgument,
+		},
 	}
 	for idx, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
@@ -208,7 +235,12 @@ func TestUpdateResult(t *testing.T) {
 			if tc.requestName == "" {
 				tc.requestName = created.GetName()
 			}
-			updated, err := srv.UpdateResult(ctx, &pb.UpdateResultRequest{Result: tc.update, Name: tc.requestName, Etag: tc.etag})
+			updated, err := srv.UpdateResult(ctx, &pb.UpdateResultRequest{
+				Result:     tc.update,
+				Name:       tc.requestName,
+				Etag:       tc.etag,
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: tc.updateMask},
+			})
 			if err != nil || tc.errcode != codes.OK {
 				if status.Code(err) == tc.errcode {
 					return
@@ -324,6 +356,77 @@ func TestDeleteResult(t *testing.T) {
 	})
 }
 
+func TestDeleteResult_Held(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	r, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{
+			Name:   "foo/results/bar",
+			Retain: true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not create result: %v", err)
+	}
+	rec, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+		Parent: r.GetName(),
+		Record: &pb.Record{
+			Name: recordutil.FormatName(r.GetName(), "baz"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateRecord: %v", err)
+	}
+
+	checkHeld := func(t *testing.T) {
+		t.Helper()
+		if _, err := srv.DeleteResult(ctx, &pb.DeleteResultRequest{Name: r.GetName()}); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("DeleteResult: expected FAILED_PRECONDITION, got: %v", err)
+		}
+		if _, err := srv.DeleteRecord(ctx, &pb.DeleteRecordRequest{Name: rec.GetName()}); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("DeleteRecord: expected FAILED_PRECONDITION, got: %v", err)
+		}
+		got, err := srv.DeleteResults(ctx, &pb.DeleteResultsRequest{Parent: "foo", Filter: "true"})
+		if err != nil || got.GetCount() != 0 {
+			t.Errorf("DeleteResults: expected no deletion, got: (%v, %v)", got, err)
+		}
+	}
+
+	t.Run("retained", func(t *testing.T) {
+		checkHeld(t)
+		// Updates without a mask don't release the hold.
+		if _, err := srv.UpdateResult(ctx, &pb.UpdateResultRequest{
+			Name:   r.GetName(),
+			Result: &pb.Result{Annotations: map[string]string{"a": "b"}},
+		}); err != nil {
+			t.Fatalf("UpdateResult: %v", err)
+		}
+		checkHeld(t)
+	})
+
+	t.Run("held until", func(t *testing.T) {
+		if _, err := srv.UpdateResult(ctx, &pb.UpdateResultRequest{
+			Name: r.GetName(),
+			Result: &pb.Result{
+				HoldUntil: timestamppb.New(fakeClock.Now().Add(time.Hour)),
+			},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"retain", "hold_until"}},
+		}); err != nil {
+			t.Fatalf("UpdateResult: %v", err)
+		}
+		checkHeld(t)
+
+		fakeClock.Advance(2 * time.Hour)
+		if _, err := srv.DeleteResult(ctx, &pb.DeleteResultRequest{Name: r.GetName()}); err != nil {
+			t.Fatalf("DeleteResult: %v", err)
+		}
+	})
+}
+
 func TestCascadeDelete(t *testing.T) {
 	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
 	if err != nil {
@@ -514,7 +617,7 @@ func TestListResults(t *testing.T) {
 			},
 			want: &pb.ListResultsResponse{
 				Results:       results[:1],
-				NextPageToken: pagetoken(t, results[1].GetId(), ""),
+				NextPageToken: pagetoken(t, results[0].GetId(), ""),
 			},
 		},
 		{
@@ -526,7 +629,7 @@ func TestListResults(t *testing.T) {
 			},
 			want: &pb.ListResultsResponse{
 				Results:       results[1:2],
-				NextPageToken: pagetoken(t, results[2].GetId(), `result.id > "1"`),
+				NextPageToken: pagetoken(t, results[1].GetId(), `result.id > "1"`),
 			},
 		},
 		{
@@ -549,7 +652,7 @@ func TestListResults(t *testing.T) {
 			},
 			want: &pb.ListResultsResponse{
 				Results:       results[1:2],
-				NextPageToken: pagetoken(t, results[2].GetId(), `result.id > "1"`),
+				NextPageToken: pagetoken(t, results[1].GetId(), `result.id > "1"`),
 			},
 		},
 		{
@@ -591,11 +694,43 @@ func TestListResults(t *testing.T) {
 				Results: results,
 			},
 		},
+		{
+			name: "with order by and page size",
+			req: &pb.ListResultsRequest{
+				Parent:   parent,
+				OrderBy:  `created_time desc`,
+				PageSize: 2,
+			},
+			want: &pb.ListResultsResponse{
+				Results:       reversedResults[:2],
+				NextPageToken: sortedPagetoken(t, reversedResults[1].GetId(), "", `created_time desc`, reversedResults[1].GetCreateTime().AsTime()),
+			},
+		},
+		{
+			name: "with order by and page token",
+			req: &pb.ListResultsRequest{
+				Parent:    parent,
+				OrderBy:   `created_time desc`,
+				PageToken: sortedPagetoken(t, reversedResults[1].GetId(), "", `created_time desc`, reversedResults[1].GetCreateTime().AsTime()),
+			},
+			want: &pb.ListResultsResponse{
+				Results: reversedResults[2:],
+			},
+		},
+		{
+			name: "with page token from a different order by",
+			req: &pb.ListResultsRequest{
+				Parent:    parent,
+				OrderBy:   `created_time asc`,
+				PageToken: sortedPagetoken(t, reversedResults[1].GetId(), "", `created_time desc`, reversedResults[1].GetCreateTime().AsTime()),
+			},
+			status: codes.InvalidArgument,
+		},
 		{
 			name: "with invalid order field name",
 			req: &pb.ListResultsRequest{
 				Parent:  parent,
-				OrderBy: `name`,
+				OrderBy: `data_type`,
 			},
 			status: codes.InvalidArgument,
 		},
@@ -624,16 +759,150 @@ func TestListResults(t *testing.T) {
 			}
 			if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
 				t.Errorf("-want,+got: %s", diff)
-				if name, filter, err := pagination.DecodeToken(got.GetNextPageToken()); err == nil {
-					t.Logf("Next (name, filter) = (%s, %s)", name, filter)
+				if next, err := pagination.DecodeToken(got.GetNextPageToken()); err == nil {
+					t.Logf("Next token = %+v", next)
+				}
+			}
+		})
+	}
+}
+
+func TestListResults_OrderBy(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+
+	start := fakeClock.Now()
+	for _, r := range []struct {
+		name        string
+		status      pb.RecordSummary_Status
+		duration    time.Duration
+		annotations map[string]string
+	}{
+		{name: "a", status: pb.RecordSummary_SUCCESS, duration: 3 * time.Second, annotations: map[string]string{"team": "y"}},
+		{name: "b", status: pb.RecordSummary_FAILURE, duration: 1500 * time.Millisecond, annotations: map[string]string{"team": "x"}},
+		// Still running, so without end time.
+		{name: "c"},
+		{name: "d", status: pb.RecordSummary_SUCCESS, duration: 2 * time.Second, annotations: map[string]string{"team": "x"}},
+	} {
+		summary := &pb.RecordSummary{
+			Record:    "foo/results/" + r.name + "/records/" + r.name,
+			Type:      "TaskRun",
+			Status:    r.status,
+			StartTime: timestamppb.New(start),
+		}
+		if r.duration > 0 {
+			summary.EndTime = timestamppb.New(start.Add(r.duration))
+		}
+		if _, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+			Parent: "foo",
+			Result: &pb.Result{Name: "foo/results/" + r.name, Summary: summary, Annotations: r.annotations},
+		}); err != nil {
+			t.Fatalf("CreateResult: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		orderBy string
+		want    []string
+	}{
+		{orderBy: "name desc", want: []string{"d", "c", "b", "a"}},
+		{orderBy: "summary.duration", want: []string{"c", "b", "d", "a"}},
+		{orderBy: "summary.duration desc", want: []string{"a", "d", "b", "c"}},
+		{orderBy: "summary.end_time desc", want: []string{"a", "d", "b", "c"}},
+		{orderBy: "summary.status, name desc", want: []string{"c", "d", "a", "b"}},
+		{orderBy: "annotations.team, summary.duration desc", want: []string{"c", "d", "b", "a"}},
+		{orderBy: "annotations.team desc, name", want: []string{"a", "b", "d", "c"}},
+	} {
+		t.Run(tc.orderBy, func(t *testing.T) {
+			// List one Result per page to resume from every sort key.
+			var got []string
+			req := &pb.ListResultsRequest{Parent: "foo", OrderBy: tc.orderBy, PageSize: 1}
+			for {
+				resp, err := srv.ListResults(ctx, req)
+				if err != nil {
+					t.Fatalf("ListResults: %v", err)
+				}
+				for _, r := range resp.GetResults() {
+					got = append(got, strings.TrimPrefix(r.GetName(), "foo/results/"))
 				}
+				if resp.GetNextPageToken() == "" {
+					break
+				}
+				req.PageToken = resp.GetNextPageToken()
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("-want,+got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestListResults_UnsetSummary(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, r := range []*pb.Result{
+		// Without summary, so with unset start and end times.
+		{Name: "foo/results/a"},
+		{Name: "foo/results/b", Summary: &pb.RecordSummary{
+			Record:    "foo/results/b/records/b",
+			Type:      "TaskRun",
+			StartTime: timestamppb.New(start),
+			EndTime:   timestamppb.New(start.Add(time.Minute)),
+		}},
+		// Still running, so without end time.
+		{Name: "foo/results/c", Summary: &pb.RecordSummary{
+			Record:    "foo/results/c/records/c",
+			Type:      "TaskRun",
+			StartTime: timestamppb.New(start),
+		}},
+	} {
+		if _, err := srv.CreateResult(ctx, &pb.CreateResultRequest{Parent: "foo", Result: r}); err != nil {
+			t.Fatalf("CreateResult: %v", err)
+		}
+	}
+
+	// Unset timestamps are the epoch in CEL, which filters translated to
+	// SQL have to match.
+	for _, tc := range []struct {
+		filter string
+		want   []string
+	}{
+		{filter: `result.summary.end_time < timestamp("2021-01-01T00:00:00Z")`, want: []string{"a", "c"}},
+		{filter: `!(result.summary.end_time > timestamp("2021-01-01T00:00:00Z"))`, want: []string{"a", "c"}},
+		{filter: `result.summary.end_time != timestamp("1970-01-01T00:00:00Z")`, want: []string{"b"}},
+		{filter: `result.summary.end_time in [timestamp("1970-01-01T00:00:00Z")]`, want: []string{"a", "c"}},
+		{filter: `result.summary.start_time != result.summary.end_time`, want: []string{"b", "c"}},
+	} {
+		t.Run(tc.filter, func(t *testing.T) {
+			resp, err := srv.ListResults(ctx, &pb.ListResultsRequest{Parent: "foo", Filter: tc.filter})
+			if err != nil {
+				t.Fatalf("ListResults: %v", err)
+			}
+			var got []string
+			for _, r := range resp.GetResults() {
+				got = append(got, strings.TrimPrefix(r.GetName(), "foo/results/"))
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("-want,+got: %s", diff)
 			}
 		})
 	}
 }
 
 func pagetoken(t *testing.T, name, filter string) string {
-	if token, err := pagination.EncodeToken(name, filter); err != nil {
+	return sortedPagetoken(t, name, filter, "")
+}
+
+func sortedPagetoken(t *testing.T, name, filter, orderBy string, keys ...interface{}) string {
+	if token, err := pagination.EncodeToken(&pagination.Token{Name: name, Filter: filter, OrderBy: orderBy, Keys: keys}); err != nil {
 		t.Fatalf("Failed to get encoded token: %v", err)
 		return ""
 	} else {
@@ -0,0 +1,53 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	prunedRows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "results",
+		Subsystem: "retention",
+		Name:      "pruned_rows_total",
+		Help:      "Number of database rows deleted by retention policies, by table.",
+	}, []string{"table", "dry_run"})
+
+	prunedLogBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "results",
+		Subsystem: "retention",
+		Name:      "pruned_log_bytes_total",
+		Help:      "Number of bytes of logs deleted by retention policies.",
+	}, []string{"dry_run"})
+
+	pruneErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "results",
+		Subsystem: "retention",
+		Name:      "errors_total",
+		Help:      "Number of errors while enforcing retention policies.",
+	})
+
+	lastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "results",
+		Subsystem: "retention",
+		Name:      "last_run_timestamp_seconds",
+		Help:      "Time the retention policies were last enforced.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(prunedRows, prunedLogBytes, pruneErrors, lastRun)
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retention deletes Results, along with their Records and logs, once
+// they are no longer covered by a retention policy.
+package retention
+
+import (
+	"fmt"
+	"os"
+
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultGroupBy is the label used to group Results for MaxResults.
+const DefaultGroupBy = "tekton.dev/pipeline"
+
+// Policies holds the retention policies of all parents.
+type Policies struct {
+	// Default applies to parents without a policy of their own. If unset,
+	// such parents are kept forever.
+	Default *Policy `json:"default,omitempty"`
+	// Parents maps parent names to their policy.
+	Parents map[string]*Policy `json:"parents,omitempty"`
+}
+
+// Policy configures how long the Results of a parent are kept. Results
+// matching any of the limits are deleted.
+type Policy struct {
+	// MaxAge is how long Results are kept after they were last updated.
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+	// MaxAgeByStatus overrides MaxAge for Results whose summary has the
+	// given status, e.g. FAILURE.
+	MaxAgeByStatus map[string]metav1.Duration `json:"maxAgeByStatus,omitempty"`
+	// MaxResults is the number of most recently updated Results kept per
+	// value of the GroupBy label.
+	MaxResults int `json:"maxResults,omitempty"`
+	// GroupBy is the label of the Records of a Result which groups Results
+	// for MaxResults. Defaults to DefaultGroupBy.
+	GroupBy string `json:"groupBy,omitempty"`
+}
+
+// Load reads the policies from a YAML or JSON file.
+func Load(path string) (*Policies, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse parses and validates YAML or JSON encoded policies.
+func Parse(b []byte) (*Policies, error) {
+	p := &Policies{}
+	if err := yaml.UnmarshalStrict(b, p); err != nil {
+		return nil, fmt.Errorf("error parsing retention policies: %w", err)
+	}
+	if err := p.Default.validate(); err != nil {
+		return nil, fmt.Errorf("invalid default retention policy: %w", err)
+	}
+	for parent, policy := range p.Parents {
+		if err := policy.validate(); err != nil {
+			return nil, fmt.Errorf("invalid retention policy for parent %q: %w", parent, err)
+		}
+	}
+	return p, nil
+}
+
+// For returns the policy of the given parent, or nil if its Results are kept
+// forever.
+func (p *Policies) For(parent string) *Policy {
+	if policy, ok := p.Parents[parent]; ok {
+		return policy
+	}
+	return p.Default
+}
+
+func (p *Policy) validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxAge != nil && p.MaxAge.Duration <= 0 {
+		return fmt.Errorf("maxAge must be positive")
+	}
+	for s, d := range p.MaxAgeByStatus {
+		if _, ok := pb.RecordSummary_Status_value[s]; !ok {
+			return fmt.Errorf("unknown status %q", s)
+		}
+		if d.Duration <= 0 {
+			return fmt.Errorf("maxAgeByStatus %s must be positive", s)
+		}
+	}
+	if p.MaxResults < 0 {
+		return fmt.Errorf("maxResults must not be negative")
+	}
+	if p.GroupBy == "" {
+		p.GroupBy = DefaultGroupBy
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/pipeline/test/diff"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParse(t *testing.T) {
+	got, err := Parse([]byte(`
+default:
+  maxAge: 720h
+  maxAgeByStatus:
+    FAILURE: 2160h
+parents:
+  team-a:
+    maxResults: 10
+  team-b:
+    maxResults: 5
+    groupBy: app
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := &Policies{
+		Default: &Policy{
+			MaxAge:         &metav1.Duration{Duration: 720 * time.Hour},
+			MaxAgeByStatus: map[string]metav1.Duration{"FAILURE": {Duration: 2160 * time.Hour}},
+			GroupBy:        DefaultGroupBy,
+		},
+		Parents: map[string]*Policy{
+			"team-a": {MaxResults: 10, GroupBy: DefaultGroupBy},
+			"team-b": {MaxResults: 5, GroupBy: "app"},
+		},
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Fatalf(diff.PrintWantGot(d))
+	}
+
+	for parent, want := range map[string]*Policy{
+		"team-a": want.Parents["team-a"],
+		"team-c": want.Default,
+	} {
+		if d := cmp.Diff(want, got.For(parent)); d != "" {
+			t.Errorf("For(%q): %s", parent, diff.PrintWantGot(d))
+		}
+	}
+	if got := (&Policies{}).For("team-a"); got != nil {
+		t.Errorf("For() without policies: want nil, got %+v", got)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{
+		"unknown: true",
+		"default: {maxAge: -1h}",
+		"default: {maxAge: forever}",
+		"parents: {a: {maxAgeByStatus: {BROKEN: 1h}}}",
+		"parents: {a: {maxAgeByStatus: {FAILURE: 0s}}}",
+		"parents: {a: {maxResults: -1}}",
+	} {
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Errorf("Parse(%q): want error", in)
+		}
+	}
+}
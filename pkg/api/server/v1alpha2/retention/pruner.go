@@ -0,0 +1,457 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	cw "github.com/jonboulle/clockwork"
+	"github.com/tektoncd/results/pkg/api/server/cel2sql"
+	"github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/db/errors"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/log"
+	"github.com/tektoncd/results/pkg/apis/v1alpha2"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultInterval  = time.Hour
+	defaultBatchSize = 100
+)
+
+var clock cw.Clock = cw.NewRealClock()
+
+// Pruner enforces retention policies by deleting expired Results. Records of
+// deleted Results are deleted by the database, logs are deleted from their
+// storage backend. Held Results are never deleted, nor counted against the
+// MaxResults of their group.
+type Pruner struct {
+	db       *gorm.DB
+	config   *config.Config
+	policies *Policies
+	logger   *zap.SugaredLogger
+	// deleteResult deletes a Result in a transaction.
+	deleteResult DeleteFunc
+}
+
+// DeleteFunc deletes the Result r in the transaction tx.
+type DeleteFunc func(tx *gorm.DB, r *db.Result) error
+
+// Option configures a Pruner.
+type Option func(*Pruner)
+
+// WithDeleteResult sets how Results are deleted, e.g. to record their
+// deletion for Watch streams. By default, rows are deleted directly.
+func WithDeleteResult(f DeleteFunc) Option {
+	return func(p *Pruner) {
+		p.deleteResult = f
+	}
+}
+
+// Report summarizes what was, or would have been in a dry run, deleted.
+type Report struct {
+	DryRun   bool
+	Results  int64
+	Records  int64
+	Logs     int64
+	LogBytes int64
+}
+
+// NewPruner returns a Pruner enforcing the given policies.
+func NewPruner(db *gorm.DB, config *config.Config, policies *Policies, logger *zap.SugaredLogger, opts ...Option) *Pruner {
+	p := &Pruner{
+		db:           db,
+		config:       config,
+		policies:     policies,
+		logger:       logger,
+		deleteResult: deleteResult,
+	}
+	for _, o := range opts {
+		o(p)
+	}
+	return p
+}
+
+func deleteResult(tx *gorm.DB, r *db.Result) error {
+	return errors.Wrap(tx.Delete(&db.Result{Parent: r.Parent, ID: r.ID}).Error)
+}
+
+// Run enforces the policies at the configured interval until ctx is done.
+func (p *Pruner) Run(ctx context.Context) {
+	interval := p.config.RETENTION_INTERVAL
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	ticker := clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report, err := p.Prune(ctx)
+		if err != nil {
+			pruneErrors.Inc()
+			p.logger.Errorw("Failed to enforce retention policies", zap.Error(err))
+		} else {
+			p.logger.Infow("Enforced retention policies",
+				"dry_run", report.DryRun,
+				"results", report.Results,
+				"records", report.Records,
+				"logs", report.Logs,
+				"log_bytes", report.LogBytes,
+			)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.Chan():
+		}
+	}
+}
+
+// Prune deletes the Results of all parents which are expired according to
+// their policy, in batches.
+func (p *Pruner) Prune(ctx context.Context) (*Report, error) {
+	var parents []string
+	q := p.db.WithContext(ctx).Model(&db.Result{}).Distinct("parent").Order("parent").Pluck("parent", &parents)
+	if err := errors.Wrap(q.Error); err != nil {
+		return nil, err
+	}
+
+	report := &Report{DryRun: p.config.RETENTION_DRY_RUN}
+	for _, parent := range parents {
+		policy := p.policies.For(parent)
+		if policy == nil {
+			continue
+		}
+		if err := p.prune(ctx, parent, policy, report); err != nil {
+			return report, err
+		}
+	}
+	lastRun.Set(float64(clock.Now().Unix()))
+	return report, nil
+}
+
+func (p *Pruner) prune(ctx context.Context, parent string, policy *Policy, report *Report) error {
+	batchSize := p.config.RETENTION_BATCH_SIZE
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	// Results already counted in a dry run, since nothing is deleted.
+	seen := map[string]bool{}
+
+	now := clock.Now()
+	held := heldCondition("results", now)
+	for _, c := range ageConditions(policy, now) {
+		var after string
+		for {
+			var batch []*db.Result
+			q := p.db.WithContext(ctx).
+				Select("parent", "id", "name").
+				Where("parent = ? AND id > ?", parent, after).
+				Where(c.query, c.args...).
+				Not(held.query, held.args...).
+				Order("id").
+				Limit(batchSize).
+				Find(&batch)
+			if err := errors.Wrap(q.Error); err != nil {
+				return err
+			}
+			if err := p.delete(ctx, parent, batch, seen, report); err != nil {
+				return err
+			}
+			if len(batch) < batchSize {
+				break
+			}
+			after = batch[len(batch)-1].ID
+		}
+	}
+
+	if policy.MaxResults == 0 {
+		return nil
+	}
+	var after *groupedResult
+	for {
+		excess, err := p.excess(ctx, parent, policy, held, after, batchSize)
+		if err != nil {
+			return err
+		}
+		batch := make([]*db.Result, 0, len(excess))
+		for _, r := range excess {
+			batch = append(batch, &db.Result{Parent: parent, ID: r.ID, Name: r.Name})
+		}
+		if err := p.delete(ctx, parent, batch, seen, report); err != nil {
+			return err
+		}
+		if len(excess) < batchSize {
+			return nil
+		}
+		after = excess[len(excess)-1]
+	}
+}
+
+type condition struct {
+	query string
+	args  []interface{}
+}
+
+// heldCondition returns the condition matching the Results of table which
+// are held at the given time. It is never NULL, so that it can be negated.
+func heldCondition(table string, now time.Time) condition {
+	return condition{
+		query: table + ".retain = ? OR " + table + ".hold_until IS NOT NULL AND " + table + ".hold_until > ?",
+		args:  []interface{}{true, now},
+	}
+}
+
+// ageConditions returns the conditions matching the Results which are
+// older than allowed by the policy.
+func ageConditions(policy *Policy, now time.Time) []condition {
+	names := make([]string, 0, len(policy.MaxAgeByStatus))
+	for name := range policy.MaxAgeByStatus {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		out      []condition
+		statuses []int32
+	)
+	for _, name := range names {
+		status := pb.RecordSummary_Status_value[name]
+		statuses = append(statuses, status)
+		out = append(out, condition{
+			query: "recordsummary_status = ? AND updated_time < ?",
+			args:  []interface{}{status, now.Add(-policy.MaxAgeByStatus[name].Duration)},
+		})
+	}
+	if policy.MaxAge == nil {
+		return out
+	}
+	cutoff := now.Add(-policy.MaxAge.Duration)
+	if len(statuses) == 0 {
+		return append(out, condition{query: "updated_time < ?", args: []interface{}{cutoff}})
+	}
+	return append(out, condition{
+		query: "recordsummary_status NOT IN ? AND updated_time < ?",
+		args:  []interface{}{statuses, cutoff},
+	})
+}
+
+// groupedResult is a Result exceeding the MaxResults of its group.
+type groupedResult struct {
+	ID          string
+	Name        string
+	UpdatedTime time.Time
+}
+
+// excess returns at most limit Results exceeding the MaxResults of their
+// group, oldest first, after the given one if not nil. Results are ranked in
+// their group by the database, so that only the excess is read. Results
+// without the GroupBy label are not limited.
+func (p *Pruner) excess(ctx context.Context, parent string, policy *Policy, held condition, after *groupedResult, limit int) ([]*groupedResult, error) {
+	label, args, err := cel2sql.Dialect(p.db.Dialector.Name()).JSONText("records.data", []interface{}{"metadata", "labels", policy.GroupBy})
+	if err != nil {
+		return nil, err
+	}
+	groups := p.db.
+		Table("results").
+		Select("results.id AS id, results.name AS name, results.updated_time AS updated_time, MAX("+label+") AS grp", args...).
+		Joins("LEFT JOIN records ON records.parent = results.parent AND records.result_id = results.id").
+		Where("results.parent = ?", parent).
+		Not(held.query, held.args...).
+		Group("results.id, results.name, results.updated_time")
+	ranked := p.db.
+		Table("(?) AS grouped", groups).
+		Select("id, name, updated_time, ROW_NUMBER() OVER (PARTITION BY grp ORDER BY updated_time DESC, id DESC) AS row_num").
+		Where("grp IS NOT NULL AND grp <> ''")
+	q := p.db.WithContext(ctx).
+		Table("(?) AS ranked", ranked).
+		Select("id, name, updated_time").
+		Where("row_num > ?", policy.MaxResults)
+	if after != nil {
+		q = q.Where("updated_time > ? OR updated_time = ? AND id > ?", after.UpdatedTime, after.UpdatedTime, after.ID)
+	}
+	var rows []*groupedResult
+	q = q.Order("updated_time, id").Limit(limit).Scan(&rows)
+	return rows, errors.Wrap(q.Error)
+}
+
+// delete deletes the given Results, then their logs, so that the logs of
+// Results deleted or held since they were selected are kept. Results whose
+// logs can't be opened are kept, so they are retried on the next run.
+func (p *Pruner) delete(ctx context.Context, parent string, results []*db.Result, seen map[string]bool, report *Report) error {
+	ids := make([]string, 0, len(results))
+	for _, r := range results {
+		if !seen[r.ID] {
+			ids = append(ids, r.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	dryRun := p.config.RETENTION_DRY_RUN
+	var (
+		d   *deletion
+		err error
+	)
+	if dryRun {
+		d, err = p.selectResults(ctx, p.db.WithContext(ctx), parent, ids)
+		if err != nil {
+			return err
+		}
+		for _, r := range d.results {
+			seen[r.ID] = true
+			p.logger.Infow("Result would be deleted by retention policy", "parent", parent, "result", r.Name)
+		}
+	} else if d, err = p.deleteResults(ctx, parent, ids); err != nil {
+		return err
+	}
+
+	var logCount, logBytes int64
+	for _, l := range d.logs {
+		if !dryRun {
+			if err := l.stream.Delete(); err != nil {
+				pruneErrors.Inc()
+				p.logger.Warnw("Failed to delete the log of a deleted Result",
+					"parent", parent, "result_id", l.record.ResultID, "record", l.record.Name, zap.Error(err))
+				continue
+			}
+		}
+		logCount++
+		logBytes += l.size
+	}
+
+	deleted := int64(len(d.results))
+	report.Results += deleted
+	report.Records += d.records
+	report.Logs += logCount
+	report.LogBytes += logBytes
+
+	label := strconv.FormatBool(dryRun)
+	prunedRows.WithLabelValues("results", label).Add(float64(deleted))
+	prunedRows.WithLabelValues("records", label).Add(float64(d.records))
+	prunedLogBytes.WithLabelValues(label).Add(float64(logBytes))
+	return nil
+}
+
+// deletion is the Results deleted, or to delete, with the number of their
+// Records and their logs, read before the Results are deleted.
+type deletion struct {
+	results []*db.Result
+	records int64
+	logs    []*prunedLog
+}
+
+// prunedLog is the log of a deleted Result.
+type prunedLog struct {
+	record *db.Record
+	stream log.Stream
+	size   int64
+}
+
+// deleteResults deletes the Results of parent with the given IDs in a
+// transaction. Results deleted or held since they were selected are skipped,
+// their logs are only returned for the deleted Results.
+func (p *Pruner) deleteResults(ctx context.Context, parent string, ids []string) (*deletion, error) {
+	var d *deletion
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if d, err = p.selectResults(ctx, tx, parent, ids); err != nil {
+			return err
+		}
+		for _, r := range d.results {
+			if err := p.deleteResult(tx, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// selectResults locks the Results of parent with the given IDs which aren't
+// held, and opens their logs. Results whose logs can't be opened are skipped.
+func (p *Pruner) selectResults(ctx context.Context, tx *gorm.DB, parent string, ids []string) (*deletion, error) {
+	held := heldCondition("results", clock.Now())
+	var results []*db.Result
+	q := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("parent = ? AND id IN ?", parent, ids).
+		Not(held.query, held.args...).
+		Order("id").
+		Find(&results)
+	if err := errors.Wrap(q.Error); err != nil {
+		return nil, err
+	}
+	d := &deletion{}
+	if len(results) == 0 {
+		return d, nil
+	}
+	ids = make([]string, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+
+	var records []*db.Record
+	q = tx.Where("parent = ? AND result_id IN ? AND type = ?", parent, ids, v1alpha2.LogRecordType).Find(&records)
+	if err := errors.Wrap(q.Error); err != nil {
+		return nil, err
+	}
+	failed := map[string]bool{}
+	for _, rec := range records {
+		// Logs are deleted once the transaction commits, outside of it.
+		stream, l, err := log.ToStream(ctx, p.db.WithContext(ctx), rec, p.config)
+		if err != nil {
+			pruneErrors.Inc()
+			p.logger.Warnw("Failed to open log, keeping its Result",
+				"parent", parent, "result_id", rec.ResultID, "record", rec.Name, zap.Error(err))
+			failed[rec.ResultID] = true
+			continue
+		}
+		d.logs = append(d.logs, &prunedLog{record: rec, stream: stream, size: l.Status.Size})
+	}
+
+	ids = ids[:0]
+	for _, r := range results {
+		if !failed[r.ID] {
+			d.results = append(d.results, r)
+			ids = append(ids, r.ID)
+		}
+	}
+	logs := d.logs[:0]
+	for _, l := range d.logs {
+		if !failed[l.record.ResultID] {
+			logs = append(logs, l)
+		}
+	}
+	d.logs = logs
+	if len(ids) == 0 {
+		return d, nil
+	}
+
+	q = tx.Model(&db.Record{}).Where("parent = ? AND result_id IN ?", parent, ids).Count(&d.records)
+	if err := errors.Wrap(q.Error); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
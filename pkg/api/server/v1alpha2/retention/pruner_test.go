@@ -0,0 +1,358 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	cw "github.com/jonboulle/clockwork"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tektoncd/pipeline/test/diff"
+	"github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/db/migrate"
+	"github.com/tektoncd/results/pkg/api/server/logger"
+	"github.com/tektoncd/results/pkg/api/server/test"
+	"github.com/tektoncd/results/pkg/apis/v1alpha2"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"gorm.io/gorm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var now = time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+func TestMain(m *testing.M) {
+	clock = cw.NewFakeClockAt(now)
+	os.Exit(m.Run())
+}
+
+// fixture creates the Results, Records and logs used by the pruner tests.
+type fixture struct {
+	t      *testing.T
+	db     *gorm.DB
+	config *config.Config
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	gdb := test.NewDB(t)
+	if _, err := migrate.New(gdb).Up(); err != nil {
+		t.Fatalf("Up(): %v", err)
+	}
+	return &fixture{
+		t:  t,
+		db: gdb,
+		config: &config.Config{
+			LOGS_TYPE:            string(v1alpha2.FileLogType),
+			LOGS_PATH:            t.TempDir(),
+			RETENTION_BATCH_SIZE: 2,
+		},
+	}
+}
+
+// result creates a Result last updated age ago, with a Record labelled with
+// the given pipeline name if not empty.
+func (f *fixture) result(parent, name string, age time.Duration, status pb.RecordSummary_Status, pipeline string) {
+	f.t.Helper()
+	r := &db.Result{
+		Parent:      parent,
+		ID:          parent + "-" + name,
+		Name:        name,
+		UpdatedTime: now.Add(-age),
+		Summary:     db.RecordSummary{Status: int32(status)},
+	}
+	if err := f.db.Create(r).Error; err != nil {
+		f.t.Fatalf("failed to create result: %v", err)
+	}
+	if pipeline == "" {
+		return
+	}
+	data := fmt.Sprintf(`{"metadata":{"labels":{"tekton.dev/pipeline":%q}}}`, pipeline)
+	f.record(r, "run", "tekton.dev/v1beta1.PipelineRun", []byte(data))
+}
+
+func (f *fixture) record(r *db.Result, name, typ string, data []byte) {
+	f.t.Helper()
+	rec := &db.Record{
+		Parent:     r.Parent,
+		ResultID:   r.ID,
+		ResultName: r.Name,
+		ID:         r.ID + "-" + name,
+		Name:       name,
+		Type:       typ,
+		Data:       data,
+	}
+	if err := f.db.Create(rec).Error; err != nil {
+		f.t.Fatalf("failed to create record: %v", err)
+	}
+}
+
+// log stores a log file for the Result.
+func (f *fixture) log(parent, name, content string) string {
+	f.t.Helper()
+	path := filepath.Join(parent, name, "log")
+	l := &v1alpha2.Log{
+		Spec:   v1alpha2.LogSpec{Type: v1alpha2.FileLogType},
+		Status: v1alpha2.LogStatus{Path: path, Size: int64(len(content))},
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		f.t.Fatalf("failed to encode log: %v", err)
+	}
+	f.record(&db.Result{Parent: parent, ID: parent + "-" + name, Name: name}, "log", v1alpha2.LogRecordType, data)
+
+	path = filepath.Join(f.config.LOGS_PATH, path)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		f.t.Fatalf("failed to create log dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		f.t.Fatalf("failed to write log: %v", err)
+	}
+	return path
+}
+
+func (f *fixture) remaining() []string {
+	f.t.Helper()
+	var names []string
+	if err := f.db.Model(&db.Result{}).Order("parent, name").Pluck("parent || '/' || name", &names).Error; err != nil {
+		f.t.Fatalf("failed to list results: %v", err)
+	}
+	return names
+}
+
+func duration(d time.Duration) *metav1.Duration {
+	return &metav1.Duration{Duration: d}
+}
+
+func TestPrune(t *testing.T) {
+	f := newFixture(t)
+	day := 24 * time.Hour
+
+	// Age based policy, failures are kept longer.
+	f.result("a", "new-success", 1*day, pb.RecordSummary_SUCCESS, "")
+	f.result("a", "old-success", 10*day, pb.RecordSummary_SUCCESS, "")
+	f.result("a", "old-unknown", 10*day, pb.RecordSummary_UNKNOWN, "")
+	f.result("a", "old-failure", 10*day, pb.RecordSummary_FAILURE, "")
+	f.result("a", "ancient-failure", 40*day, pb.RecordSummary_FAILURE, "")
+	logPath := f.log("a", "old-success", "hello")
+
+	// Count based policy.
+	for i := 0; i < 4; i++ {
+		f.result("b", fmt.Sprintf("build-%d", i), time.Duration(i)*day, pb.RecordSummary_SUCCESS, "build")
+	}
+	f.result("b", "test-0", 5*day, pb.RecordSummary_SUCCESS, "test")
+	f.result("b", "unlabelled", 5*day, pb.RecordSummary_SUCCESS, "")
+
+	// No policy.
+	f.result("c", "old", 100*day, pb.RecordSummary_SUCCESS, "")
+
+	policies := &Policies{
+		Default: &Policy{
+			MaxAge:         duration(7 * day),
+			MaxAgeByStatus: map[string]metav1.Duration{"FAILURE": {Duration: 30 * day}},
+		},
+		Parents: map[string]*Policy{
+			"b": {MaxResults: 2, GroupBy: DefaultGroupBy},
+			"c": nil,
+		},
+	}
+
+	results := testutil.ToFloat64(prunedRows.WithLabelValues("results", "false"))
+	bytes := testutil.ToFloat64(prunedLogBytes.WithLabelValues("false"))
+
+	p := NewPruner(f.db, f.config, policies, logger.Get("info"))
+	got, err := p.Prune(context.Background())
+	if err != nil {
+		t.Fatalf("Prune(): %v", err)
+	}
+	want := &Report{Results: 5, Records: 3, Logs: 1, LogBytes: 5}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("Report: %s", diff.PrintWantGot(d))
+	}
+
+	wantRemaining := []string{
+		"a/new-success",
+		"a/old-failure",
+		"b/build-0",
+		"b/build-1",
+		"b/test-0",
+		"b/unlabelled",
+		"c/old",
+	}
+	if d := cmp.Diff(wantRemaining, f.remaining()); d != "" {
+		t.Errorf("remaining Results: %s", diff.PrintWantGot(d))
+	}
+	var records int64
+	if err := f.db.Model(&db.Record{}).Count(&records).Error; err != nil {
+		t.Fatalf("failed to count records: %v", err)
+	}
+	if records != 3 {
+		t.Errorf("want 3 remaining Records, got %d", records)
+	}
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		t.Errorf("log file should be deleted, got: %v", err)
+	}
+
+	if d := testutil.ToFloat64(prunedRows.WithLabelValues("results", "false")) - results; d != 5 {
+		t.Errorf("pruned results metric increased by %v, want 5", d)
+	}
+	if d := testutil.ToFloat64(prunedLogBytes.WithLabelValues("false")) - bytes; d != 5 {
+		t.Errorf("pruned log bytes metric increased by %v, want 5", d)
+	}
+}
+
+func TestPruneDryRun(t *testing.T) {
+	f := newFixture(t)
+	f.config.RETENTION_DRY_RUN = true
+	day := 24 * time.Hour
+
+	for i := 0; i < 3; i++ {
+		f.result("a", fmt.Sprintf("build-%d", i), time.Duration(i*5)*day, pb.RecordSummary_SUCCESS, "build")
+	}
+	logPath := f.log("a", "build-2", "hello")
+
+	// build-2 is both too old and exceeds the count, it is reported once.
+	policies := &Policies{Default: &Policy{MaxAge: duration(7 * day), MaxResults: 1, GroupBy: DefaultGroupBy}}
+	got, err := NewPruner(f.db, f.config, policies, logger.Get("info")).Prune(context.Background())
+	if err != nil {
+		t.Fatalf("Prune(): %v", err)
+	}
+	want := &Report{DryRun: true, Results: 2, Records: 3, Logs: 1, LogBytes: 5}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("Report: %s", diff.PrintWantGot(d))
+	}
+
+	if d := cmp.Diff([]string{"a/build-0", "a/build-1", "a/build-2"}, f.remaining()); d != "" {
+		t.Errorf("remaining Results: %s", diff.PrintWantGot(d))
+	}
+	if _, err := os.Stat(logPath); err != nil {
+		t.Errorf("log file should be kept: %v", err)
+	}
+}
+
+func TestPruneWithDeleteResult(t *testing.T) {
+	f := newFixture(t)
+	f.result("a", "old", 10*24*time.Hour, pb.RecordSummary_SUCCESS, "")
+	f.result("a", "new", time.Minute, pb.RecordSummary_SUCCESS, "")
+
+	var deleted []string
+	policies := &Policies{Default: &Policy{MaxAge: duration(time.Hour)}}
+	p := NewPruner(f.db, f.config, policies, logger.Get("info"), WithDeleteResult(func(tx *gorm.DB, r *db.Result) error {
+		deleted = append(deleted, r.Parent+"/"+r.Name)
+		return tx.Delete(&db.Result{Parent: r.Parent, ID: r.ID}).Error
+	}))
+	got, err := p.Prune(context.Background())
+	if err != nil {
+		t.Fatalf("Prune(): %v", err)
+	}
+	if d := cmp.Diff(&Report{Results: 1}, got); d != "" {
+		t.Errorf("Report: %s", diff.PrintWantGot(d))
+	}
+	if d := cmp.Diff([]string{"a/old"}, deleted); d != "" {
+		t.Errorf("deleted Results: %s", diff.PrintWantGot(d))
+	}
+	if d := cmp.Diff([]string{"a/new"}, f.remaining()); d != "" {
+		t.Errorf("remaining Results: %s", diff.PrintWantGot(d))
+	}
+}
+
+func TestDeleteSkipsHeldResults(t *testing.T) {
+	f := newFixture(t)
+	f.result("a", "old", 10*24*time.Hour, pb.RecordSummary_SUCCESS, "")
+	logPath := f.log("a", "old", "hello")
+
+	// The Result is held after it was selected for deletion.
+	if err := f.db.Model(&db.Result{}).Where("parent = ? AND name = ?", "a", "old").Update("retain", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	p := NewPruner(f.db, f.config, &Policies{}, logger.Get("info"))
+	report := &Report{}
+	if err := p.delete(context.Background(), "a", []*db.Result{{Parent: "a", ID: "a-old", Name: "old"}}, map[string]bool{}, report); err != nil {
+		t.Fatalf("delete(): %v", err)
+	}
+	if d := cmp.Diff(&Report{}, report); d != "" {
+		t.Errorf("Report: %s", diff.PrintWantGot(d))
+	}
+	if d := cmp.Diff([]string{"a/old"}, f.remaining()); d != "" {
+		t.Errorf("remaining Results: %s", diff.PrintWantGot(d))
+	}
+	if _, err := os.Stat(logPath); err != nil {
+		t.Errorf("log file of the held Result should be kept: %v", err)
+	}
+}
+
+func TestPruneKeepsResultsWithUndeletableLogs(t *testing.T) {
+	f := newFixture(t)
+	f.result("a", "old", 10*24*time.Hour, pb.RecordSummary_SUCCESS, "")
+	f.record(&db.Result{Parent: "a", ID: "a-old", Name: "old"}, "log", v1alpha2.LogRecordType, []byte(`{"spec":{"type":"Unknown"}}`))
+
+	policies := &Policies{Default: &Policy{MaxAge: duration(time.Hour)}}
+	got, err := NewPruner(f.db, f.config, policies, logger.Get("info")).Prune(context.Background())
+	if err != nil {
+		t.Fatalf("Prune(): %v", err)
+	}
+	if d := cmp.Diff(&Report{}, got); d != "" {
+		t.Errorf("Report: %s", diff.PrintWantGot(d))
+	}
+	if d := cmp.Diff([]string{"a/old"}, f.remaining()); d != "" {
+		t.Errorf("remaining Results: %s", diff.PrintWantGot(d))
+	}
+}
+
+func TestPruneSkipsHeldResults(t *testing.T) {
+	f := newFixture(t)
+	day := 24 * time.Hour
+	for _, name := range []string{"retained", "held", "hold-expired", "expired"} {
+		f.result("a", name, 10*day, pb.RecordSummary_SUCCESS, "")
+	}
+	holds := map[string]map[string]interface{}{
+		"retained":     {"retain": true},
+		"held":         {"hold_until": now.Add(day)},
+		"hold-expired": {"hold_until": now.Add(-day)},
+	}
+	for name, hold := range holds {
+		if err := f.db.Model(&db.Result{}).Where("parent = ? AND name = ?", "a", name).Updates(hold).Error; err != nil {
+			t.Fatalf("failed to hold result: %v", err)
+		}
+	}
+	// Held Results don't count against MaxResults either.
+	for i := 0; i < 3; i++ {
+		f.result("b", fmt.Sprintf("build-%d", i), time.Duration(i)*time.Hour, pb.RecordSummary_SUCCESS, "build")
+	}
+	if err := f.db.Model(&db.Result{}).Where("parent = ? AND name = ?", "b", "build-2").Update("retain", true).Error; err != nil {
+		t.Fatalf("failed to hold result: %v", err)
+	}
+
+	policies := &Policies{
+		Default: &Policy{MaxAge: duration(7 * day)},
+		Parents: map[string]*Policy{
+			"b": {MaxResults: 1, GroupBy: DefaultGroupBy},
+		},
+	}
+	if _, err := NewPruner(f.db, f.config, policies, logger.Get("info")).Prune(context.Background()); err != nil {
+		t.Fatalf("Prune(): %v", err)
+	}
+	want := []string{"a/held", "a/retained", "b/build-0", "b/build-2"}
+	if d := cmp.Diff(want, f.remaining()); d != "" {
+		t.Errorf("remaining Results: %s", diff.PrintWantGot(d))
+	}
+}
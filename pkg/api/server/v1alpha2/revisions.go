@@ -0,0 +1,115 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/db/errors"
+	"github.com/tektoncd/results/pkg/api/server/db/pagination"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/auth"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/record"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+)
+
+// revisionOrder lists revisions from the most recent one.
+var revisionOrder = sortOrder{{orderField: createdTimeField, desc: true}}
+
+// ListRecordRevisions returns the previous revisions of a Record.
+func (s *Server) ListRecordRevisions(ctx context.Context, req *pb.ListRecordRevisionsRequest) (*pb.ListRecordRevisionsResponse, error) {
+	parent, result, name, err := record.ParseName(req.GetParent())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.auth.Check(ctx, parent, auth.ResourceRecords, auth.PermissionList); err != nil {
+		return nil, err
+	}
+
+	userPageSize, err := pageSize(int(req.GetPageSize()))
+	if err != nil {
+		return nil, err
+	}
+	start, err := pageStart(req.GetPageToken(), "", "", revisionOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := getRecord(s.db.WithContext(ctx).Omit("data"), parent, result, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch n+1 items to know whether there is a next page.
+	var revisions []*db.RecordRevision
+	q := s.db.WithContext(ctx).
+		Where(&db.RecordRevision{Parent: r.Parent, ResultID: r.ResultID, RecordID: r.ID})
+	if start != nil {
+		cond, args := revisionOrder.after(start.Keys, start.Name)
+		q = q.Where(cond, args...)
+	}
+	q = revisionOrder.apply(q).Limit(userPageSize + 1).Find(&revisions)
+	if err := errors.Wrap(q.Error); err != nil {
+		return nil, err
+	}
+
+	var next *pagination.Token
+	if len(revisions) > userPageSize {
+		revisions = revisions[:userPageSize]
+		last := revisions[userPageSize-1]
+		next = &pagination.Token{Name: last.ID, Keys: revisionOrder.values(last)}
+	}
+	out := make([]*pb.RecordRevision, 0, len(revisions))
+	for _, rev := range revisions {
+		api, err := record.RevisionToAPI(r, rev)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, api)
+	}
+
+	nextToken, err := nextPageToken(next, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ListRecordRevisionsResponse{
+		Revisions:     out,
+		NextPageToken: nextToken,
+	}, nil
+}
+
+// GetRecordRevision returns a single revision of a Record.
+func (s *Server) GetRecordRevision(ctx context.Context, req *pb.GetRecordRevisionRequest) (*pb.RecordRevision, error) {
+	parent, result, name, revision, err := record.ParseRevisionName(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.auth.Check(ctx, parent, auth.ResourceRecords, auth.PermissionGet); err != nil {
+		return nil, err
+	}
+
+	r, err := getRecord(s.db.WithContext(ctx).Omit("data"), parent, result, name)
+	if err != nil {
+		return nil, err
+	}
+	rev := &db.RecordRevision{}
+	q := s.db.WithContext(ctx).
+		Where(&db.RecordRevision{Parent: r.Parent, ResultID: r.ResultID, RecordID: r.ID, ID: revision}).
+		First(rev)
+	if err := errors.Wrap(q.Error); err != nil {
+		return nil, err
+	}
+	return record.RevisionToAPI(r, rev)
+}
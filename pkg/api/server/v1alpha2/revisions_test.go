@@ -0,0 +1,201 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/api/server/logger"
+	"github.com/tektoncd/results/pkg/api/server/test"
+	recordutil "github.com/tektoncd/results/pkg/api/server/v1alpha2/record"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestRecordRevisions(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true, RECORD_REVISIONS: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+
+	result, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{Name: "foo/results/bar"},
+	})
+	if err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+	record, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+		Parent: result.GetName(),
+		Record: &pb.Record{
+			Name: recordutil.FormatName(result.GetName(), "baz"),
+			Data: &pb.Any{Type: "TaskRun", Value: []byte(`{"status":"0"}`)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateRecord: %v", err)
+	}
+
+	// Update the Record 3 times, keeping what each update replaced.
+	var want []*pb.RecordRevision
+	for i := 1; i <= 3; i++ {
+		fakeClock.Advance(time.Second)
+		updated, err := srv.UpdateRecord(ctx, &pb.UpdateRecordRequest{
+			Record: &pb.Record{
+				Name: record.GetName(),
+				Data: &pb.Any{Type: "TaskRun", Value: []byte(fmt.Sprintf(`{"status":"%d"}`, i))},
+			},
+		})
+		if err != nil {
+			t.Fatalf("UpdateRecord: %v", err)
+		}
+		id := fmt.Sprint(lastID)
+		want = append([]*pb.RecordRevision{{
+			Name:       recordutil.FormatRevisionName(record.GetName(), id),
+			Uid:        id,
+			Record:     record,
+			CreateTime: timestamppb.New(fakeClock.Now()),
+		}}, want...)
+		record = updated
+	}
+
+	t.Run("list", func(t *testing.T) {
+		var got []*pb.RecordRevision
+		req := &pb.ListRecordRevisionsRequest{Parent: record.GetName(), PageSize: 2}
+		for {
+			resp, err := srv.ListRecordRevisions(ctx, req)
+			if err != nil {
+				t.Fatalf("ListRecordRevisions: %v", err)
+			}
+			got = append(got, resp.GetRevisions()...)
+			if resp.GetNextPageToken() == "" {
+				break
+			}
+			req.PageToken = resp.GetNextPageToken()
+		}
+		if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+			t.Errorf("-want, +got: %s", diff)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		got, err := srv.GetRecordRevision(ctx, &pb.GetRecordRevisionRequest{Name: want[1].GetName()})
+		if err != nil {
+			t.Fatalf("GetRecordRevision: %v", err)
+		}
+		if diff := cmp.Diff(want[1], got, protocmp.Transform()); diff != "" {
+			t.Errorf("-want, +got: %s", diff)
+		}
+	})
+
+	// Errors
+	for _, tc := range []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{
+			name: "list invalid name",
+			call: func() error {
+				_, err := srv.ListRecordRevisions(ctx, &pb.ListRecordRevisionsRequest{Parent: result.GetName()})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "list unknown record",
+			call: func() error {
+				_, err := srv.ListRecordRevisions(ctx, &pb.ListRecordRevisionsRequest{Parent: recordutil.FormatName(result.GetName(), "doesnotexist")})
+				return err
+			},
+			want: codes.NotFound,
+		},
+		{
+			name: "get invalid name",
+			call: func() error {
+				_, err := srv.GetRecordRevision(ctx, &pb.GetRecordRevisionRequest{Name: record.GetName()})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "get unknown revision",
+			call: func() error {
+				_, err := srv.GetRecordRevision(ctx, &pb.GetRecordRevisionRequest{Name: recordutil.FormatRevisionName(record.GetName(), "doesnotexist")})
+				return err
+			},
+			want: codes.NotFound,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.call(); status.Code(err) != tc.want {
+				t.Fatalf("want: %v, got: %v - %+v", tc.want, status.Code(err), err)
+			}
+		})
+	}
+
+	t.Run("deleted with record", func(t *testing.T) {
+		if _, err := srv.DeleteRecord(ctx, &pb.DeleteRecordRequest{Name: record.GetName()}); err != nil {
+			t.Fatalf("DeleteRecord: %v", err)
+		}
+		var count int64
+		if err := srv.db.Table("record_revisions").Count(&count).Error; err != nil || count != 0 {
+			t.Errorf("want no revisions, got (%d, %v)", count, err)
+		}
+	})
+}
+
+func TestRecordRevisionsDisabled(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+
+	result, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{Name: "foo/results/bar"},
+	})
+	if err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+	record, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+		Parent: result.GetName(),
+		Record: &pb.Record{Name: recordutil.FormatName(result.GetName(), "baz")},
+	})
+	if err != nil {
+		t.Fatalf("CreateRecord: %v", err)
+	}
+	if _, err := srv.UpdateRecord(ctx, &pb.UpdateRecordRequest{Record: record}); err != nil {
+		t.Fatalf("UpdateRecord: %v", err)
+	}
+
+	got, err := srv.ListRecordRevisions(ctx, &pb.ListRecordRevisionsRequest{Parent: record.GetName()})
+	if err != nil {
+		t.Fatalf("ListRecordRevisions: %v", err)
+	}
+	if len(got.GetRevisions()) != 0 {
+		t.Errorf("want no revisions, got %v", got.GetRevisions())
+	}
+}
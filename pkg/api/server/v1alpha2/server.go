@@ -24,8 +24,9 @@ import (
 	"github.com/google/uuid"
 	cw "github.com/jonboulle/clockwork"
 	resultscel "github.com/tektoncd/results/pkg/api/server/cel"
-	model "github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/db/migrate"
 	"github.com/tektoncd/results/pkg/api/server/v1alpha2/auth"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/summary"
 	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
 	"gorm.io/gorm"
 )
@@ -49,6 +50,9 @@ type Server struct {
 	db     *gorm.DB
 	auth   auth.Checker
 
+	// summarizer computes the summary of Records of custom types.
+	summarizer *summary.Summarizer
+
 	// enableDatabaseAutoMigration controls whether the API server will
 	// auto-migrate the database upon startup.
 	enableDatabaseAutoMigration bool
@@ -81,11 +85,15 @@ func New(config *config.Config, logger *zap.SugaredLogger, db *gorm.DB, opts ...
 		o(srv)
 	}
 
+	migrator := migrate.New(db)
 	if config.DB_ENABLE_AUTO_MIGRATION {
-		if err := db.AutoMigrate(&model.Result{}, &model.Record{}); err != nil {
-			return nil, fmt.Errorf("error automigrating DB: %w", err)
+		if _, err := migrator.Up(); err != nil {
+			return nil, fmt.Errorf("error migrating DB: %w", err)
 		}
 	}
+	if err := migrator.Check(); err != nil {
+		return nil, err
+	}
 
 	return srv, nil
 }
@@ -98,6 +106,13 @@ func WithAuth(c auth.Checker) Option {
 	}
 }
 
+// WithSummarizer configures the summaries of Records of custom types.
+func WithSummarizer(s *summary.Summarizer) Option {
+	return func(srv *Server) {
+		srv.summarizer = s
+	}
+}
+
 func withGetResultID(f getResultID) Option {
 	return func(s *Server) {
 		s.getResultID = f
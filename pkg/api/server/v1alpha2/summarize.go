@@ -0,0 +1,380 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tektoncd/results/pkg/api/server/cel2sql"
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/db/errors"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/auth"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"gorm.io/gorm"
+)
+
+// SummarizeResults aggregates the Results of a parent in the database.
+func (s *Server) SummarizeResults(ctx context.Context, req *pb.SummarizeResultsRequest) (*pb.SummarizeResultsResponse, error) {
+	if req.GetParent() == "" {
+		return nil, status.Error(codes.InvalidArgument, "parent missing")
+	}
+	if err := s.auth.Check(ctx, req.GetParent(), auth.ResourceResults, auth.PermissionList); err != nil {
+		return nil, err
+	}
+
+	d := cel2sql.Dialect(s.db.Dialector.Name())
+	keys, err := parseGroupBy(d, req.GetGroupBy())
+	if err != nil {
+		return nil, err
+	}
+	aggs, err := parseAggregates(req.GetAggregates())
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.parseFilter(s.env, resultFields, req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	if f.prg != nil {
+		return nil, status.Error(codes.InvalidArgument, "the filter can't be fully evaluated by the database")
+	}
+
+	// Select the group keys and duration of every Result, the aggregates are
+	// computed over this query.
+	duration, err := d.Seconds("recordsummary_start_time", "recordsummary_end_time")
+	if err != nil {
+		return nil, status.Error(codes.Unimplemented, err.Error())
+	}
+	cols := make([]string, 0, len(keys)+1)
+	var args []interface{}
+	for i, k := range keys {
+		cols = append(cols, fmt.Sprintf("%s AS g%d", k.expr, i))
+		args = append(args, k.args...)
+	}
+	cols = append(cols, duration+" AS duration")
+
+	// The counts and the percentiles are read in a single transaction so
+	// that both see the same Results. SQLite transactions are serializable
+	// and ignore the options.
+	var groups *resultsGroups
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		q := tx.Model(&db.Result{}).Select(strings.Join(cols, ", "), args...)
+		// Specifying `-` allows users to read Results from any parent.
+		// See https://google.aip.dev/159 for more details.
+		if req.GetParent() != "-" {
+			q = q.Where("parent = ?", req.GetParent())
+		}
+		if req.GetStartTime() != nil {
+			q = q.Where("created_time >= ?", req.GetStartTime().AsTime())
+		}
+		if req.GetEndTime() != nil {
+			q = q.Where("created_time < ?", req.GetEndTime().AsTime())
+		}
+		q = f.apply(q)
+
+		var err error
+		if groups, err = summarizeGroups(tx, q, keys, aggs); err != nil {
+			return err
+		}
+		if len(aggs.percentiles) > 0 {
+			return summarizePercentiles(tx, q, keys, aggs, groups)
+		}
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	out := &pb.SummarizeResultsResponse{Groups: make([]*pb.ResultsGroup, 0, len(groups.order))}
+	for _, k := range groups.order {
+		out.Groups = append(out.Groups, groups.byKey[k])
+	}
+	return out, nil
+}
+
+// groupKey is a key Results are grouped by.
+type groupKey struct {
+	name string
+	expr string
+	args []interface{}
+	// format converts the values of the key read from the database.
+	format func(string) (string, error)
+}
+
+// parseGroupBy parses the group_by field of a SummarizeResults request.
+func parseGroupBy(d cel2sql.Dialect, groupBy string) ([]*groupKey, error) {
+	if strings.TrimSpace(groupBy) == "" {
+		return nil, nil
+	}
+	var keys []*groupKey
+	seen := map[string]bool{}
+	for _, name := range strings.Split(groupBy, ",") {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			return nil, status.Errorf(codes.InvalidArgument, "duplicate group_by key %q", name)
+		}
+		seen[name] = true
+
+		k := &groupKey{name: name}
+		var err error
+		switch {
+		case name == "summary.type":
+			k.expr = "recordsummary_type"
+		case name == "summary.status":
+			k.expr = "recordsummary_status"
+			k.format = formatStatus
+		case strings.HasPrefix(name, "annotations.") && len(name) > len("annotations."):
+			k.expr, k.args, err = d.JSONText("annotations", []interface{}{strings.TrimPrefix(name, "annotations.")})
+		case strings.HasPrefix(name, "summary.annotations.") && len(name) > len("summary.annotations."):
+			k.expr, k.args, err = d.JSONText("recordsummary_annotations", []interface{}{strings.TrimPrefix(name, "summary.annotations.")})
+		case strings.HasPrefix(name, "create_time."):
+			k.expr, err = d.TimeBucket("created_time", strings.TrimPrefix(name, "create_time."))
+			k.format = formatTimeBucket
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported group_by key %q", name)
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "group_by key %q: %v", name, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func formatStatus(v string) (string, error) {
+	i, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		return "", err
+	}
+	if name, ok := pb.RecordSummary_Status_name[int32(i)]; ok {
+		return name, nil
+	}
+	return v, nil
+}
+
+func formatTimeBucket(v string) (string, error) {
+	t, err := time.ParseInLocation(cel2sql.TimeBucketLayout, v, time.UTC)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(time.RFC3339), nil
+}
+
+// percentile is a requested duration percentile.
+type percentile struct {
+	name  string
+	value float64
+}
+
+// aggregates are the aggregates requested in addition to the count.
+type aggregates struct {
+	min, avg, max bool
+	percentiles   []percentile
+}
+
+// parseAggregates parses the aggregates field of a SummarizeResults request.
+func parseAggregates(s string) (*aggregates, error) {
+	out := &aggregates{}
+	if strings.TrimSpace(s) == "" {
+		return out, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "count":
+		case "min":
+			out.min = true
+		case "avg":
+			out.avg = true
+		case "max":
+			out.max = true
+		default:
+			v, err := strconv.ParseFloat(strings.TrimPrefix(name, "p"), 64)
+			if !strings.HasPrefix(name, "p") || err != nil || v <= 0 || v > 100 {
+				return nil, status.Errorf(codes.InvalidArgument, "unsupported aggregate %q", name)
+			}
+			out.percentiles = append(out.percentiles, percentile{name: name, value: v})
+		}
+	}
+	return out, nil
+}
+
+// resultsGroups are the groups of a summary, by their encoded keys.
+type resultsGroups struct {
+	order []string
+	byKey map[string]*pb.ResultsGroup
+}
+
+// summarizeGroups counts the Results selected by q by group, and computes the
+// duration min, avg and max.
+func summarizeGroups(gdb *gorm.DB, q *gorm.DB, keys []*groupKey, aggs *aggregates) (*resultsGroups, error) {
+	cols := groupColumns(keys)
+	sel := append(append([]string{}, cols...), "COUNT(*)", "MIN(duration)", "AVG(duration)", "MAX(duration)")
+	agg := gdb.Table("(?) AS d", q).Select(strings.Join(sel, ", "))
+	if len(cols) > 0 {
+		agg = agg.Group(strings.Join(cols, ", ")).Order(strings.Join(cols, ", "))
+	}
+	rows, err := agg.Rows()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	defer rows.Close()
+
+	out := &resultsGroups{byKey: map[string]*pb.ResultsGroup{}}
+	for rows.Next() {
+		vals := make([]sql.NullString, len(keys))
+		var count int64
+		var min, avg, max sql.NullFloat64
+		dest := make([]interface{}, 0, len(keys)+4)
+		for i := range vals {
+			dest = append(dest, &vals[i])
+		}
+		dest = append(dest, &count, &min, &avg, &max)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, errors.Wrap(err)
+		}
+
+		g := &pb.ResultsGroup{Keys: map[string]string{}, Count: count}
+		id, err := groupID(keys, vals, g.Keys)
+		if err != nil {
+			return nil, err
+		}
+		if aggs.min {
+			g.MinDuration = seconds(min)
+		}
+		if aggs.avg {
+			g.AvgDuration = seconds(avg)
+		}
+		if aggs.max {
+			g.MaxDuration = seconds(max)
+		}
+		out.order = append(out.order, id)
+		out.byKey[id] = g
+	}
+	return out, errors.Wrap(rows.Err())
+}
+
+// summarizePercentiles computes the duration percentiles of the groups with
+// the nearest rank method: the p-th percentile of n durations is the
+// smallest one whose rank is at least p*n/100.
+func summarizePercentiles(gdb *gorm.DB, q *gorm.DB, keys []*groupKey, aggs *aggregates, groups *resultsGroups) error {
+	cols := groupColumns(keys)
+	partition := ""
+	if len(cols) > 0 {
+		partition = "PARTITION BY " + strings.Join(cols, ", ")
+	}
+	ranked := append(append([]string{}, cols...),
+		"duration",
+		fmt.Sprintf("ROW_NUMBER() OVER (%s ORDER BY duration) AS rn", partition),
+		fmt.Sprintf("COUNT(*) OVER (%s) AS n", partition))
+	sel := append([]string{}, cols...)
+	for _, p := range aggs.percentiles {
+		// The percentile is inlined since it was parsed as a number.
+		sel = append(sel, fmt.Sprintf("MIN(CASE WHEN rn * 100 >= n * %s THEN duration END)", strconv.FormatFloat(p.value, 'f', -1, 64)))
+	}
+
+	r := gdb.Table("(?) AS d", q).Select(strings.Join(ranked, ", ")).Where("duration IS NOT NULL")
+	agg := gdb.Table("(?) AS r", r).Select(strings.Join(sel, ", "))
+	if len(cols) > 0 {
+		agg = agg.Group(strings.Join(cols, ", "))
+	}
+	rows, err := agg.Rows()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		vals := make([]sql.NullString, len(keys))
+		ps := make([]sql.NullFloat64, len(aggs.percentiles))
+		dest := make([]interface{}, 0, len(vals)+len(ps))
+		for i := range vals {
+			dest = append(dest, &vals[i])
+		}
+		for i := range ps {
+			dest = append(dest, &ps[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return errors.Wrap(err)
+		}
+		id, err := groupID(keys, vals, nil)
+		if err != nil {
+			return err
+		}
+		g, ok := groups.byKey[id]
+		if !ok {
+			return status.Errorf(codes.Internal, "no count for the percentiles of group %s", id)
+		}
+		g.Percentiles = make(map[string]*durationpb.Duration, len(ps))
+		for i, p := range aggs.percentiles {
+			if d := seconds(ps[i]); d != nil {
+				g.Percentiles[p.name] = d
+			}
+		}
+	}
+	return errors.Wrap(rows.Err())
+}
+
+// groupColumns returns the columns of the group keys in the summarized query.
+func groupColumns(keys []*groupKey) []string {
+	cols := make([]string, 0, len(keys))
+	for i := range keys {
+		cols = append(cols, fmt.Sprintf("g%d", i))
+	}
+	return cols
+}
+
+// groupID formats the values of the group keys, adding them to out if it is
+// not nil, and returns an identifier of the group.
+func groupID(keys []*groupKey, vals []sql.NullString, out map[string]string) (string, error) {
+	id := make([]string, 0, len(keys))
+	for i, k := range keys {
+		if !vals[i].Valid {
+			id = append(id, "")
+			continue
+		}
+		v := vals[i].String
+		if k.format != nil {
+			var err error
+			if v, err = k.format(v); err != nil {
+				return "", status.Errorf(codes.Internal, "invalid value %q of group_by key %q: %v", vals[i].String, k.name, err)
+			}
+		}
+		if out != nil {
+			out[k.name] = v
+		}
+		// Quote the values so that missing and empty values differ.
+		id = append(id, strconv.Quote(v))
+	}
+	return strings.Join(id, ","), nil
+}
+
+// seconds converts a number of seconds read from the database to a Duration,
+// rounded to the millisecond since not all databases are more precise.
+func seconds(v sql.NullFloat64) *durationpb.Duration {
+	if !v.Valid {
+		return nil
+	}
+	return durationpb.New(time.Duration(math.Round(v.Float64*1000)) * time.Millisecond)
+}
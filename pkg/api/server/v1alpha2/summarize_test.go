@@ -0,0 +1,213 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/api/server/logger"
+	"github.com/tektoncd/results/pkg/api/server/test"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestSummarizeResults(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+
+	// Start at midnight to get predictable time buckets.
+	now := fakeClock.Now()
+	day := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	fakeClock.Advance(day.Sub(now))
+
+	for i, r := range []struct {
+		parent      string
+		at          time.Duration
+		summary     *pb.RecordSummary
+		duration    time.Duration
+		annotations map[string]string
+	}{
+		{parent: "foo", at: time.Hour, summary: &pb.RecordSummary{Type: "TaskRun", Status: pb.RecordSummary_SUCCESS}, duration: 10 * time.Second, annotations: map[string]string{"team": "a"}},
+		{parent: "foo", at: 2 * time.Hour, summary: &pb.RecordSummary{Type: "TaskRun", Status: pb.RecordSummary_FAILURE}, duration: 20 * time.Second, annotations: map[string]string{"team": "a"}},
+		{parent: "foo", at: 3 * time.Hour, summary: &pb.RecordSummary{Type: "TaskRun", Status: pb.RecordSummary_SUCCESS}, duration: 30 * time.Second, annotations: map[string]string{"team": "b"}},
+		{parent: "foo", at: 25 * time.Hour, summary: &pb.RecordSummary{Type: "PipelineRun", Status: pb.RecordSummary_SUCCESS}, duration: 40 * time.Second},
+		// Still running, so without duration.
+		{parent: "foo", at: 26 * time.Hour, summary: &pb.RecordSummary{Type: "PipelineRun"}},
+		{parent: "bar", at: 27 * time.Hour, summary: &pb.RecordSummary{Type: "TaskRun", Status: pb.RecordSummary_SUCCESS}, duration: 100 * time.Second},
+	} {
+		fakeClock.Advance(day.Add(r.at).Sub(fakeClock.Now()))
+		r.summary.Record = fmt.Sprintf("%s/results/%d/records/%d", r.parent, i, i)
+		if r.duration > 0 {
+			r.summary.StartTime = timestamppb.New(fakeClock.Now())
+			r.summary.EndTime = timestamppb.New(fakeClock.Now().Add(r.duration))
+		}
+		if _, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+			Parent: r.parent,
+			Result: &pb.Result{
+				Name:        fmt.Sprintf("%s/results/%d", r.parent, i),
+				Summary:     r.summary,
+				Annotations: r.annotations,
+			},
+		}); err != nil {
+			t.Fatalf("CreateResult: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		req  *pb.SummarizeResultsRequest
+		want []*pb.ResultsGroup
+	}{
+		{
+			name: "all",
+			req:  &pb.SummarizeResultsRequest{Parent: "foo", Aggregates: "count,min,avg,max,p50"},
+			want: []*pb.ResultsGroup{{
+				Count:       5,
+				MinDuration: durationpb.New(10 * time.Second),
+				AvgDuration: durationpb.New(25 * time.Second),
+				MaxDuration: durationpb.New(40 * time.Second),
+				Percentiles: map[string]*durationpb.Duration{"p50": durationpb.New(20 * time.Second)},
+			}},
+		},
+		{
+			name: "count by default",
+			req:  &pb.SummarizeResultsRequest{Parent: "-"},
+			want: []*pb.ResultsGroup{{Count: 6}},
+		},
+		{
+			name: "by type",
+			req:  &pb.SummarizeResultsRequest{Parent: "foo", GroupBy: "summary.type", Aggregates: "p100, max"},
+			want: []*pb.ResultsGroup{
+				{
+					Keys:        map[string]string{"summary.type": "PipelineRun"},
+					Count:       2,
+					MaxDuration: durationpb.New(40 * time.Second),
+					Percentiles: map[string]*durationpb.Duration{"p100": durationpb.New(40 * time.Second)},
+				},
+				{
+					Keys:        map[string]string{"summary.type": "TaskRun"},
+					Count:       3,
+					MaxDuration: durationpb.New(30 * time.Second),
+					Percentiles: map[string]*durationpb.Duration{"p100": durationpb.New(30 * time.Second)},
+				},
+			},
+		},
+		{
+			name: "by type and status",
+			req:  &pb.SummarizeResultsRequest{Parent: "-", GroupBy: "summary.type,summary.status"},
+			want: []*pb.ResultsGroup{
+				{Keys: map[string]string{"summary.type": "PipelineRun", "summary.status": "UNKNOWN"}, Count: 1},
+				{Keys: map[string]string{"summary.type": "PipelineRun", "summary.status": "SUCCESS"}, Count: 1},
+				{Keys: map[string]string{"summary.type": "TaskRun", "summary.status": "SUCCESS"}, Count: 3},
+				{Keys: map[string]string{"summary.type": "TaskRun", "summary.status": "FAILURE"}, Count: 1},
+			},
+		},
+		{
+			name: "by annotation",
+			req:  &pb.SummarizeResultsRequest{Parent: "foo", GroupBy: "annotations.team", Aggregates: "min"},
+			want: []*pb.ResultsGroup{
+				// Results without the annotation.
+				{Keys: map[string]string{}, Count: 2, MinDuration: durationpb.New(40 * time.Second)},
+				{Keys: map[string]string{"annotations.team": "a"}, Count: 2, MinDuration: durationpb.New(10 * time.Second)},
+				{Keys: map[string]string{"annotations.team": "b"}, Count: 1, MinDuration: durationpb.New(30 * time.Second)},
+			},
+		},
+		{
+			name: "by day",
+			req:  &pb.SummarizeResultsRequest{Parent: "-", GroupBy: "create_time.day"},
+			want: []*pb.ResultsGroup{
+				{Keys: map[string]string{"create_time.day": day.Format(time.RFC3339)}, Count: 3},
+				{Keys: map[string]string{"create_time.day": day.Add(24 * time.Hour).Format(time.RFC3339)}, Count: 3},
+			},
+		},
+		{
+			name: "time range",
+			req: &pb.SummarizeResultsRequest{
+				Parent:    "-",
+				GroupBy:   "create_time.hour",
+				StartTime: timestamppb.New(day.Add(2 * time.Hour)),
+				EndTime:   timestamppb.New(day.Add(25 * time.Hour)),
+			},
+			want: []*pb.ResultsGroup{
+				{Keys: map[string]string{"create_time.hour": day.Add(2 * time.Hour).Format(time.RFC3339)}, Count: 1},
+				{Keys: map[string]string{"create_time.hour": day.Add(3 * time.Hour).Format(time.RFC3339)}, Count: 1},
+			},
+		},
+		{
+			name: "filter",
+			req:  &pb.SummarizeResultsRequest{Parent: "-", Filter: `result.summary.type == "TaskRun"`, Aggregates: "count,avg"},
+			want: []*pb.ResultsGroup{{Count: 4, AvgDuration: durationpb.New(40 * time.Second)}},
+		},
+		{
+			name: "no results",
+			req:  &pb.SummarizeResultsRequest{Parent: "baz", GroupBy: "summary.type"},
+			want: []*pb.ResultsGroup{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := srv.SummarizeResults(ctx, tc.req)
+			if err != nil {
+				t.Fatalf("SummarizeResults: %v", err)
+			}
+			for _, g := range tc.want {
+				if g.Keys == nil {
+					g.Keys = map[string]string{}
+				}
+			}
+			if diff := cmp.Diff(&pb.SummarizeResultsResponse{Groups: tc.want}, got, protocmp.Transform()); diff != "" {
+				t.Errorf("groups mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSummarizeResults_Errors(t *testing.T) {
+	srv, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	for _, tc := range []struct {
+		name string
+		req  *pb.SummarizeResultsRequest
+	}{
+		{name: "missing parent", req: &pb.SummarizeResultsRequest{}},
+		{name: "unknown key", req: &pb.SummarizeResultsRequest{Parent: "foo", GroupBy: "name"}},
+		{name: "duplicate key", req: &pb.SummarizeResultsRequest{Parent: "foo", GroupBy: "summary.type,summary.type"}},
+		{name: "empty annotation", req: &pb.SummarizeResultsRequest{Parent: "foo", GroupBy: "annotations."}},
+		{name: "unknown time bucket", req: &pb.SummarizeResultsRequest{Parent: "foo", GroupBy: "create_time.year"}},
+		{name: "unknown aggregate", req: &pb.SummarizeResultsRequest{Parent: "foo", Aggregates: "sum"}},
+		{name: "percentile out of range", req: &pb.SummarizeResultsRequest{Parent: "foo", Aggregates: "p101"}},
+		{name: "invalid percentile", req: &pb.SummarizeResultsRequest{Parent: "foo", Aggregates: "pfoo"}},
+		{name: "filter not in SQL", req: &pb.SummarizeResultsRequest{Parent: "foo", Filter: `size(result.annotations) > 0`}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := srv.SummarizeResults(context.Background(), tc.req); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("SummarizeResults: got %v, want %v", err, codes.InvalidArgument)
+			}
+		})
+	}
+}
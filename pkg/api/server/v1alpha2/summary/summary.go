@@ -0,0 +1,209 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package summary extracts the RecordSummary of Records of custom types with
+// CEL expressions configured by the administrator.
+package summary
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	resultscel "github.com/tektoncd/results/pkg/api/server/cel"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"sigs.k8s.io/yaml"
+)
+
+// Config maps Record data types to the expressions summarizing them.
+type Config struct {
+	Types map[string]*Rule `json:"types,omitempty"`
+}
+
+// Rule holds the CEL expressions yielding the fields of a RecordSummary.
+// Expressions can refer to the Record as `record` and to its decoded JSON data
+// as `data`. Unset expressions leave their field empty.
+type Rule struct {
+	// Status yields the name of a RecordSummary status, e.g. SUCCESS.
+	Status string `json:"status,omitempty"`
+	// StartTime yields a timestamp or an RFC 3339 string.
+	StartTime string `json:"startTime,omitempty"`
+	// EndTime yields a timestamp or an RFC 3339 string.
+	EndTime string `json:"endTime,omitempty"`
+	// Annotations maps summary annotation keys to expressions yielding
+	// strings.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Summarizer computes the summary of Records of the configured types.
+type Summarizer struct {
+	rules map[string]*rule
+}
+
+// rule holds the compiled expressions of a Rule.
+type rule struct {
+	status      cel.Program
+	startTime   cel.Program
+	endTime     cel.Program
+	annotations map[string]cel.Program
+}
+
+// Load reads the summary configuration from a YAML or JSON file.
+func Load(path string) (*Summarizer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse parses and compiles a YAML or JSON encoded summary configuration.
+func Parse(b []byte) (*Summarizer, error) {
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, fmt.Errorf("error parsing summary config: %w", err)
+	}
+	return New(c)
+}
+
+// New compiles the expressions of the configuration.
+func New(c *Config) (*Summarizer, error) {
+	env, err := resultscel.NewEnv()
+	if err != nil {
+		return nil, err
+	}
+	env, err = env.Extend(cel.Variable("data", cel.DynType))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Summarizer{rules: make(map[string]*rule, len(c.Types))}
+	for t, r := range c.Types {
+		if r == nil {
+			continue
+		}
+		out := &rule{annotations: make(map[string]cel.Program, len(r.Annotations))}
+		if out.status, err = compile(env, r.Status, cel.StringType); err != nil {
+			return nil, fmt.Errorf("invalid status of type %q: %w", t, err)
+		}
+		if out.startTime, err = compile(env, r.StartTime, cel.TimestampType, cel.StringType); err != nil {
+			return nil, fmt.Errorf("invalid startTime of type %q: %w", t, err)
+		}
+		if out.endTime, err = compile(env, r.EndTime, cel.TimestampType, cel.StringType); err != nil {
+			return nil, fmt.Errorf("invalid endTime of type %q: %w", t, err)
+		}
+		for k, expr := range r.Annotations {
+			if out.annotations[k], err = compile(env, expr, cel.StringType); err != nil {
+				return nil, fmt.Errorf("invalid annotation %q of type %q: %w", k, t, err)
+			}
+		}
+		s.rules[t] = out
+	}
+	return s, nil
+}
+
+// compile compiles an expression yielding one of the given types. Empty
+// expressions compile to a nil program. now() and ago() are evaluated relative
+// to the time of the evaluation.
+func compile(env *cel.Env, expr string, types ...*cel.Type) (cel.Program, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	out := ast.OutputType()
+	valid := false
+	for _, t := range types {
+		// Dynamic outputs, e.g. fields of data, are checked on evaluation.
+		if t.IsAssignableType(out) || out.IsAssignableType(t) {
+			valid = true
+		}
+	}
+	if !valid {
+		return nil, fmt.Errorf("expression yields %s", out)
+	}
+	return env.Program(ast, resultscel.Functions(time.Now))
+}
+
+// Summary returns the summary of a Record, nil if the type of the Record is
+// not configured. Expressions which fail to evaluate, e.g. because they refer
+// to a missing field, or which yield a value of the wrong type leave their
+// field empty.
+func (s *Summarizer) Summary(r *pb.Record) (*pb.RecordSummary, error) {
+	if s == nil {
+		return nil, nil
+	}
+	rule, ok := s.rules[r.GetData().GetType()]
+	if !ok {
+		return nil, nil
+	}
+	var data interface{}
+	if err := json.Unmarshal(r.GetData().GetValue(), &data); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to summarize record: %v", err)
+	}
+	vars := map[string]interface{}{
+		"record": r,
+		"data":   data,
+	}
+
+	out := &pb.RecordSummary{
+		Record:    r.GetName(),
+		Type:      r.GetData().GetType(),
+		StartTime: timestamp(eval(rule.startTime, vars)),
+		EndTime:   timestamp(eval(rule.endTime, vars)),
+	}
+	if v, ok := eval(rule.status, vars).(string); ok {
+		out.Status = pb.RecordSummary_Status(pb.RecordSummary_Status_value[v])
+	}
+	for k, prg := range rule.annotations {
+		if v, ok := eval(prg, vars).(string); ok {
+			if out.Annotations == nil {
+				out.Annotations = make(map[string]string, len(rule.annotations))
+			}
+			out.Annotations[k] = v
+		}
+	}
+	return out, nil
+}
+
+// eval evaluates prg, returning nil if prg is nil or fails.
+func eval(prg cel.Program, vars map[string]interface{}) interface{} {
+	if prg == nil {
+		return nil
+	}
+	out, _, err := prg.Eval(vars)
+	if err != nil {
+		return nil
+	}
+	return out.Value()
+}
+
+func timestamp(v interface{}) *timestamppb.Timestamp {
+	switch t := v.(type) {
+	case time.Time:
+		return timestamppb.New(t)
+	case string:
+		if parsed, err := time.Parse(time.RFC3339, t); err == nil {
+			return timestamppb.New(parsed)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const config = `
+types:
+  example.dev/v1.Deploy:
+    status: 'data.status.phase == "Done" ? "SUCCESS" : "FAILURE"'
+    startTime: data.status.startedAt
+    endTime: timestamp(data.status.finishedAt)
+    annotations:
+      git.sha: data.spec.revision
+      image.digest: data.status.digest
+      record: record.name
+`
+
+func TestSummary(t *testing.T) {
+	s, err := Parse([]byte(config))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name string
+		data *pb.Any
+		want *pb.RecordSummary
+	}{
+		{
+			name: "complete",
+			data: &pb.Any{
+				Type:  "example.dev/v1.Deploy",
+				Value: []byte(`{"spec": {"revision": "abc"}, "status": {"phase": "Done", "startedAt": "2023-01-01T00:00:00Z", "finishedAt": "2023-01-01T00:01:00Z", "digest": "sha256:1"}}`),
+			},
+			want: &pb.RecordSummary{
+				Record:    "a/results/b/records/c",
+				Type:      "example.dev/v1.Deploy",
+				Status:    pb.RecordSummary_SUCCESS,
+				StartTime: timestamppb.New(start),
+				EndTime:   timestamppb.New(start.Add(time.Minute)),
+				Annotations: map[string]string{
+					"git.sha":      "abc",
+					"image.digest": "sha256:1",
+					"record":       "a/results/b/records/c",
+				},
+			},
+		},
+		{
+			name: "missing and invalid fields",
+			data: &pb.Any{
+				Type:  "example.dev/v1.Deploy",
+				Value: []byte(`{"spec": {"revision": 1}, "status": {"phase": "Running", "startedAt": "yesterday"}}`),
+			},
+			want: &pb.RecordSummary{
+				Record:      "a/results/b/records/c",
+				Type:        "example.dev/v1.Deploy",
+				Status:      pb.RecordSummary_FAILURE,
+				Annotations: map[string]string{"record": "a/results/b/records/c"},
+			},
+		},
+		{
+			name: "other type",
+			data: &pb.Any{Type: "tekton.dev/v1beta1.TaskRun", Value: []byte("{}")},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := s.Summary(&pb.Record{Name: "a/results/b/records/c", Data: tc.data})
+			if err != nil {
+				t.Fatalf("Summary: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("-want, +got: %s", diff)
+			}
+		})
+	}
+
+	if _, err := s.Summary(&pb.Record{Data: &pb.Any{Type: "example.dev/v1.Deploy", Value: []byte("{")}}); err == nil {
+		t.Error("Summary: want error for invalid data")
+	}
+	if got, err := (*Summarizer)(nil).Summary(&pb.Record{}); got != nil || err != nil {
+		t.Errorf("Summary without config: want nil, got %v, %v", got, err)
+	}
+}
+
+func TestSummaryFunctions(t *testing.T) {
+	s, err := Parse([]byte(`
+types:
+  example.dev/v1.Job:
+    status: 'timestamp(data.finishedAt) > ago("1h") ? "SUCCESS" : "FAILURE"'
+    annotations:
+      duration: string(duration(timestamp(data.startedAt), timestamp(data.finishedAt)))
+      finished: 'timestamp(data.finishedAt) <= now() ? "yes" : "no"'
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		end  time.Time
+		want pb.RecordSummary_Status
+	}{
+		{name: "recent", end: time.Now().Add(-time.Minute), want: pb.RecordSummary_SUCCESS},
+		{name: "old", end: time.Now().Add(-2 * time.Hour), want: pb.RecordSummary_FAILURE},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start := tc.end.Add(-90 * time.Second)
+			data := fmt.Sprintf(`{"startedAt": %q, "finishedAt": %q}`, start.Format(time.RFC3339), tc.end.Format(time.RFC3339))
+			got, err := s.Summary(&pb.Record{Data: &pb.Any{Type: "example.dev/v1.Job", Value: []byte(data)}})
+			if err != nil {
+				t.Fatalf("Summary: %v", err)
+			}
+			want := &pb.RecordSummary{
+				Type:        "example.dev/v1.Job",
+				Status:      tc.want,
+				Annotations: map[string]string{"duration": "90s", "finished": "yes"},
+			}
+			if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("-want, +got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{
+		"unknown: true",
+		"types: {a: {status: 'data.'}}",
+		"types: {a: {status: '1'}}",
+		"types: {a: {startTime: 'true'}}",
+		"types: {a: {annotations: {b: 'record.data'}}}",
+	} {
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Errorf("Parse(%q): want error", in)
+		}
+	}
+}
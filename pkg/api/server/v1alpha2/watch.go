@@ -0,0 +1,339 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	celenv "github.com/tektoncd/results/pkg/api/server/cel"
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/db/errors"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/auth"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/record"
+	"github.com/tektoncd/results/pkg/api/server/v1alpha2/result"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"gorm.io/gorm"
+)
+
+const (
+	changeResults = "results"
+	changeRecords = "records"
+
+	// watchBatchSize is the maximum number of changes read at once by a
+	// Watch stream.
+	watchBatchSize = 100
+)
+
+// watchGapTimeout is how long a Watch stream waits for a change whose ID was
+// skipped before moving past it. Changes are numbered when inserted but only
+// become visible once their transaction commits, which may happen out of
+// order; IDs of rolled back transactions are never used. Changes committed
+// after the timeout are not sent to the streams which moved past them.
+var watchGapTimeout = 5 * time.Second
+
+// watchEnabled reports whether changes are recorded for Watch streams.
+func (s *Server) watchEnabled() bool {
+	return s.config.WATCH_EVENT_RETENTION > 0
+}
+
+// addChange records a change to the Result or Record m in the transaction tx,
+// if Watch streams are enabled.
+func (s *Server) addChange(tx *gorm.DB, t pb.EventType, m proto.Message) error {
+	if !s.watchEnabled() {
+		return nil
+	}
+	c := &db.Change{
+		Type:        t.String(),
+		CreatedTime: clock.Now(),
+	}
+	var err error
+	switch m := m.(type) {
+	case *pb.Result:
+		c.Resource = changeResults
+		c.Parent, c.ResultName, err = result.ParseName(m.GetName())
+	case *pb.Record:
+		c.Resource = changeRecords
+		c.Parent, c.ResultName, _, err = record.ParseName(m.GetName())
+	default:
+		return status.Errorf(codes.Internal, "unsupported change of %T", m)
+	}
+	if err != nil {
+		return err
+	}
+	if c.Object, err = proto.Marshal(m); err != nil {
+		return status.Errorf(codes.Internal, "failed to marshal change: %v", err)
+	}
+	return errors.Wrap(tx.Create(c).Error)
+}
+
+// PruneChanges deletes the changes older than the configured retention,
+// which Watch streams can no longer be resumed from.
+func (s *Server) PruneChanges(ctx context.Context) (int64, error) {
+	if !s.watchEnabled() {
+		return 0, nil
+	}
+	q := s.db.WithContext(ctx).
+		Where("created_time < ?", clock.Now().Add(-s.config.WATCH_EVENT_RETENTION)).
+		Delete(&db.Change{})
+	return q.RowsAffected, errors.Wrap(q.Error)
+}
+
+// WatchResults streams the changes made to the Results of a parent.
+func (s *Server) WatchResults(req *pb.WatchResultsRequest, stream pb.Results_WatchResultsServer) error {
+	ctx := stream.Context()
+	if req.GetParent() == "" {
+		return status.Error(codes.InvalidArgument, "parent missing")
+	}
+	if err := s.auth.Check(ctx, req.GetParent(), auth.ResourceResults, auth.PermissionList); err != nil {
+		return err
+	}
+	prg, err := watchFilter(s.env, req.GetFilter())
+	if err != nil {
+		return err
+	}
+	w, err := s.newWatch(ctx, changeResults, req.GetParent(), "-", req.GetResumeToken())
+	if err != nil {
+		return err
+	}
+	return w.run(ctx, func(c *db.Change) error {
+		r := &pb.Result{}
+		if err := proto.Unmarshal(c.Object, r); err != nil {
+			return status.Errorf(codes.Internal, "failed to unmarshal change: %v", err)
+		}
+		if !watchMatch(result.Match(r, prg)) {
+			return nil
+		}
+		return stream.Send(&pb.WatchResultsResponse{
+			Type:        pb.EventType(pb.EventType_value[c.Type]),
+			Result:      r,
+			ResumeToken: strconv.FormatUint(c.ID, 10),
+		})
+	})
+}
+
+// WatchRecords streams the changes made to the Records of a Result.
+func (s *Server) WatchRecords(req *pb.WatchRecordsRequest, stream pb.Results_WatchRecordsServer) error {
+	ctx := stream.Context()
+	if req.GetParent() == "" {
+		return status.Error(codes.InvalidArgument, "parent missing")
+	}
+	parent, resultName, err := result.ParseName(req.GetParent())
+	if err != nil {
+		return err
+	}
+	if err := s.auth.Check(ctx, parent, auth.ResourceRecords, auth.PermissionList); err != nil {
+		return err
+	}
+	prg, err := watchRecordFilter(req.GetFilter())
+	if err != nil {
+		return err
+	}
+	w, err := s.newWatch(ctx, changeRecords, parent, resultName, req.GetResumeToken())
+	if err != nil {
+		return err
+	}
+	return w.run(ctx, func(c *db.Change) error {
+		r := &pb.Record{}
+		if err := proto.Unmarshal(c.Object, r); err != nil {
+			return status.Errorf(codes.Internal, "failed to unmarshal change: %v", err)
+		}
+		if !watchMatch(record.Match(r, prg)) {
+			return nil
+		}
+		return stream.Send(&pb.WatchRecordsResponse{
+			Type:        pb.EventType(pb.EventType_value[c.Type]),
+			Record:      r,
+			ResumeToken: strconv.FormatUint(c.ID, 10),
+		})
+	})
+}
+
+// watchFilter compiles the filter of a Watch request. Changes store the
+// serialized resources, so the filter is evaluated in memory on the changes
+// read for the parent.
+func watchFilter(env *cel.Env, expr string) (cel.Program, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	ast, err := celenv.Compile(env, expr)
+	if err != nil {
+		return nil, err
+	}
+	return celenv.Program(env, ast, clock.Now())
+}
+
+// watchRecordFilter compiles the filter of a WatchRecords request, type
+// checking the data of the Records it constrains the type of.
+func watchRecordFilter(expr string) (cel.Program, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	env, ast, _, err := compileRecordFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	return celenv.Program(env, ast, clock.Now())
+}
+
+// watchMatch reports whether the resource of an event matches the filter.
+// Unlike List, Watch streams are not failed by resources the filter can't be
+// evaluated on, e.g. Records of another type missing a field: they do not
+// match.
+func watchMatch(ok bool, err error) bool {
+	return ok && err == nil
+}
+
+// watch reads the changes made to a kind of resource under a parent and
+// Result, either of which may be `-`.
+type watch struct {
+	db       *gorm.DB
+	interval time.Duration
+
+	resource string
+	parent   string
+	result   string
+
+	// last is the ID of the last change read.
+	last uint64
+	// gap is when the change following last was found missing, zero if it
+	// was not.
+	gap time.Time
+}
+
+// newWatch starts a watch after the change of the resume token, or after the
+// latest change if there is no token.
+func (s *Server) newWatch(ctx context.Context, resource, parent, result, token string) (*watch, error) {
+	if !s.watchEnabled() {
+		return nil, status.Error(codes.FailedPrecondition, "watch is disabled on this server: set WATCH_EVENT_RETENTION, e.g. to 1h, to record changes and enable it")
+	}
+	w := &watch{
+		db:       s.db,
+		interval: s.config.WATCH_POLL_INTERVAL,
+		resource: resource,
+		parent:   parent,
+		result:   result,
+	}
+	if w.interval <= 0 {
+		w.interval = time.Second
+	}
+
+	q := s.db.WithContext(ctx).Model(&db.Change{})
+	if token == "" {
+		err := q.Select("COALESCE(MAX(id), 0)").Scan(&w.last).Error
+		return w, errors.Wrap(err)
+	}
+	last, err := strconv.ParseUint(token, 10, 64)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid resume token")
+	}
+	var n int64
+	if err := errors.Wrap(q.Where("id = ?", last).Count(&n).Error); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, status.Error(codes.OutOfRange, "the resume token expired, restart the watch without it")
+	}
+	w.last = last
+	return w, nil
+}
+
+// run calls send with the changes matching the watch until ctx is done or
+// send fails.
+func (w *watch) run(ctx context.Context, send func(*db.Change) error) error {
+	for {
+		more, err := w.poll(ctx, send)
+		if err != nil {
+			return err
+		}
+		if more {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(w.interval):
+		}
+	}
+}
+
+// poll sends the changes following the last one read. It reports whether
+// more changes may be read right away.
+func (w *watch) poll(ctx context.Context, send func(*db.Change) error) (bool, error) {
+	// The IDs of the changes made to any resource are read first to find the
+	// gaps left by uncommitted transactions, then only the matching changes
+	// up to the first gap are read.
+	ids := make([]uint64, 0, watchBatchSize)
+	q := w.db.WithContext(ctx).
+		Model(&db.Change{}).
+		Where("id > ?", w.last).
+		Order("id").
+		Limit(watchBatchSize).
+		Pluck("id", &ids)
+	if err := errors.Wrap(q.Error); err != nil {
+		if ctx.Err() != nil {
+			return false, nil
+		}
+		return false, err
+	}
+	end, more := w.last, len(ids) == watchBatchSize
+	for _, id := range ids {
+		if id != end+1 {
+			if w.gap.IsZero() {
+				w.gap = time.Now()
+			}
+			if time.Since(w.gap) < watchGapTimeout {
+				more = false
+				break
+			}
+		}
+		w.gap = time.Time{}
+		end = id
+	}
+	if end == w.last {
+		return false, nil
+	}
+
+	var changes []*db.Change
+	q = w.db.WithContext(ctx).
+		Where("resource = ? AND id > ? AND id <= ?", w.resource, w.last, end)
+	// Specifying `-` allows users to watch across parents or Results.
+	// See https://google.aip.dev/159 for more details.
+	if w.parent != "-" {
+		q = q.Where("parent = ?", w.parent)
+	}
+	if w.result != "-" {
+		q = q.Where("result_name = ?", w.result)
+	}
+	if err := errors.Wrap(q.Order("id").Find(&changes).Error); err != nil {
+		if ctx.Err() != nil {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, c := range changes {
+		if err := send(c); err != nil {
+			return false, err
+		}
+		w.last = c.ID
+	}
+	w.last = end
+	return more, nil
+}
@@ -0,0 +1,442 @@
+// Copyright 2023 The Tekton Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/results/pkg/api/server/config"
+	"github.com/tektoncd/results/pkg/api/server/db"
+	"github.com/tektoncd/results/pkg/api/server/logger"
+	"github.com/tektoncd/results/pkg/api/server/test"
+	recordutil "github.com/tektoncd/results/pkg/api/server/v1alpha2/record"
+	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+type mockWatchResultsServer struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *pb.WatchResultsResponse
+}
+
+func (m *mockWatchResultsServer) Send(e *pb.WatchResultsResponse) error {
+	m.events <- e
+	return nil
+}
+
+func (m *mockWatchResultsServer) Context() context.Context {
+	return m.ctx
+}
+
+type mockWatchRecordsServer struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *pb.WatchRecordsResponse
+}
+
+func (m *mockWatchRecordsServer) Send(e *pb.WatchRecordsResponse) error {
+	m.events <- e
+	return nil
+}
+
+func (m *mockWatchRecordsServer) Context() context.Context {
+	return m.ctx
+}
+
+// event is the part of a Watch event compared by tests.
+type event struct {
+	Type pb.EventType
+	Name string
+}
+
+func watchResults(t *testing.T, srv *Server, req *pb.WatchResultsRequest, n int) []*pb.WatchResultsResponse {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &mockWatchResultsServer{ctx: ctx, events: make(chan *pb.WatchResultsResponse, n)}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.WatchResults(req, stream)
+	}()
+	defer func() {
+		cancel()
+		if err := <-errc; err != nil {
+			t.Errorf("WatchResults: %v", err)
+		}
+	}()
+
+	var out []*pb.WatchResultsResponse
+	timeout := time.After(5 * time.Second)
+	for len(out) < n {
+		select {
+		case e := <-stream.events:
+			out = append(out, e)
+		case <-timeout:
+			t.Fatalf("received %d events, want %d", len(out), n)
+		}
+	}
+	return out
+}
+
+func watchRecords(t *testing.T, srv *Server, req *pb.WatchRecordsRequest, n int) []event {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &mockWatchRecordsServer{ctx: ctx, events: make(chan *pb.WatchRecordsResponse, n)}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.WatchRecords(req, stream)
+	}()
+	defer func() {
+		cancel()
+		if err := <-errc; err != nil {
+			t.Errorf("WatchRecords: %v", err)
+		}
+	}()
+
+	var out []event
+	timeout := time.After(5 * time.Second)
+	for len(out) < n {
+		select {
+		case e := <-stream.events:
+			out = append(out, event{Type: e.GetType(), Name: e.GetRecord().GetName()})
+		case <-timeout:
+			t.Fatalf("received %d events, want %d", len(out), n)
+		}
+	}
+	return out
+}
+
+func resultEvents(in []*pb.WatchResultsResponse) []event {
+	out := make([]event, 0, len(in))
+	for _, e := range in {
+		out = append(out, event{Type: e.GetType(), Name: e.GetResult().GetName()})
+	}
+	return out
+}
+
+// latestChange returns the resume token of the latest change.
+func latestChange(t *testing.T, srv *Server) string {
+	t.Helper()
+	var id uint64
+	if err := srv.db.Model(&db.Change{}).Select("MAX(id)").Scan(&id).Error; err != nil {
+		t.Fatalf("failed to read the latest change: %v", err)
+	}
+	return strconv.FormatUint(id, 10)
+}
+
+func newWatchServer(t *testing.T) *Server {
+	t.Helper()
+	srv, err := New(&config.Config{
+		DB_ENABLE_AUTO_MIGRATION: true,
+		WATCH_EVENT_RETENTION:    time.Hour,
+		WATCH_POLL_INTERVAL:      time.Millisecond,
+	}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	return srv
+}
+
+func TestWatch(t *testing.T) {
+	srv := newWatchServer(t)
+	ctx := context.Background()
+
+	if _, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{Name: "foo/results/a"},
+	}); err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+	token := latestChange(t, srv)
+
+	// Changes made after the token.
+	if _, err := srv.UpdateResult(ctx, &pb.UpdateResultRequest{
+		Name:   "foo/results/a",
+		Result: &pb.Result{Annotations: map[string]string{"a": "b"}},
+	}); err != nil {
+		t.Fatalf("UpdateResult: %v", err)
+	}
+	for _, name := range []string{"foo/results/b", "bar/results/c"} {
+		parent := name[:3]
+		if _, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+			Parent: parent,
+			Result: &pb.Result{Name: name},
+		}); err != nil {
+			t.Fatalf("CreateResult: %v", err)
+		}
+	}
+	for _, name := range []string{"foo/results/a/records/1", "foo/results/b/records/2"} {
+		parent := name[:len("foo/results/a")]
+		if _, err := srv.CreateRecord(ctx, &pb.CreateRecordRequest{
+			Parent: parent,
+			Record: &pb.Record{Name: name, Data: &pb.Any{Type: "TaskRun", Value: []byte(`{"a":1}`)}},
+		}); err != nil {
+			t.Fatalf("CreateRecord: %v", err)
+		}
+	}
+	if _, err := srv.UpdateRecord(ctx, &pb.UpdateRecordRequest{
+		Record: &pb.Record{Name: "foo/results/b/records/2", Data: &pb.Any{Type: "TaskRun", Value: []byte(`{"a":2}`)}},
+	}); err != nil {
+		t.Fatalf("UpdateRecord: %v", err)
+	}
+	if _, err := srv.DeleteRecord(ctx, &pb.DeleteRecordRequest{Name: "foo/results/b/records/2"}); err != nil {
+		t.Fatalf("DeleteRecord: %v", err)
+	}
+	if _, err := srv.DeleteResult(ctx, &pb.DeleteResultRequest{Name: "foo/results/a"}); err != nil {
+		t.Fatalf("DeleteResult: %v", err)
+	}
+
+	t.Run("results", func(t *testing.T) {
+		got := watchResults(t, srv, &pb.WatchResultsRequest{Parent: "foo", ResumeToken: token}, 3)
+		want := []event{
+			{Type: pb.EventType_MODIFIED, Name: "foo/results/a"},
+			{Type: pb.EventType_ADDED, Name: "foo/results/b"},
+			{Type: pb.EventType_DELETED, Name: "foo/results/a"},
+		}
+		if diff := cmp.Diff(want, resultEvents(got)); diff != "" {
+			t.Errorf("events mismatch (-want +got):\n%s", diff)
+		}
+		if a := got[0].GetResult().GetAnnotations()["a"]; a != "b" {
+			t.Errorf("modified result annotation: got %q, want %q", a, "b")
+		}
+
+		// Resuming from an event continues after it.
+		got = watchResults(t, srv, &pb.WatchResultsRequest{Parent: "foo", ResumeToken: got[1].GetResumeToken()}, 1)
+		if diff := cmp.Diff(want[2:], resultEvents(got)); diff != "" {
+			t.Errorf("resumed events mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("results filter", func(t *testing.T) {
+		got := watchResults(t, srv, &pb.WatchResultsRequest{
+			Parent:      "-",
+			Filter:      `result.name.endsWith("c")`,
+			ResumeToken: token,
+		}, 1)
+		want := []event{{Type: pb.EventType_ADDED, Name: "bar/results/c"}}
+		if diff := cmp.Diff(want, resultEvents(got)); diff != "" {
+			t.Errorf("events mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("records", func(t *testing.T) {
+		got := watchRecords(t, srv, &pb.WatchRecordsRequest{Parent: "foo/results/a", ResumeToken: token}, 2)
+		want := []event{
+			{Type: pb.EventType_ADDED, Name: "foo/results/a/records/1"},
+			// Deleted along with its Result.
+			{Type: pb.EventType_DELETED, Name: "foo/results/a/records/1"},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("events mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("records filter", func(t *testing.T) {
+		got := watchRecords(t, srv, &pb.WatchRecordsRequest{
+			Parent:      "foo/results/-",
+			Filter:      `data.a == 2`,
+			ResumeToken: token,
+		}, 2)
+		want := []event{
+			{Type: pb.EventType_MODIFIED, Name: "foo/results/b/records/2"},
+			{Type: pb.EventType_DELETED, Name: recordutil.FormatName("foo/results/b", "2")},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("events mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("without resume token", func(t *testing.T) {
+		w, err := srv.newWatch(ctx, changeResults, "foo", "-", "")
+		if err != nil {
+			t.Fatalf("newWatch: %v", err)
+		}
+		if got := strconv.FormatUint(w.last, 10); got != latestChange(t, srv) {
+			t.Errorf("watch starts after change %s, want the latest one", got)
+		}
+	})
+}
+
+func TestWatchPrunedResult(t *testing.T) {
+	srv := newWatchServer(t)
+	ctx := context.Background()
+
+	if _, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{Name: "foo/results/a"},
+	}); err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+	token := latestChange(t, srv)
+
+	r := &db.Result{}
+	if err := srv.db.Where(&db.Result{Parent: "foo", Name: "a"}).First(r).Error; err != nil {
+		t.Fatalf("failed to read result: %v", err)
+	}
+	if err := srv.db.Transaction(func(tx *gorm.DB) error {
+		return srv.PruneResult(tx, r)
+	}); err != nil {
+		t.Fatalf("PruneResult: %v", err)
+	}
+
+	got := watchResults(t, srv, &pb.WatchResultsRequest{Parent: "foo", ResumeToken: token}, 1)
+	want := []event{{Type: pb.EventType_DELETED, Name: "foo/results/a"}}
+	if diff := cmp.Diff(want, resultEvents(got)); diff != "" {
+		t.Errorf("events mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWatchGap(t *testing.T) {
+	srv := newWatchServer(t)
+	ctx := context.Background()
+	defer func(d time.Duration) { watchGapTimeout = d }(watchGapTimeout)
+	watchGapTimeout = time.Hour
+
+	// Change 2 is not visible (yet), change 3 is.
+	if err := srv.db.Create(&db.Change{ID: 3, Resource: changeResults, Type: "ADDED", Parent: "foo", ResultName: "a"}).Error; err != nil {
+		t.Fatalf("failed to create change: %v", err)
+	}
+	w := &watch{db: srv.db, resource: changeResults, parent: "foo", result: "-", last: 1}
+	var got []uint64
+	send := func(c *db.Change) error {
+		got = append(got, c.ID)
+		return nil
+	}
+	if _, err := w.poll(ctx, send); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("poll sent changes %v past the gap", got)
+	}
+
+	// Gaps are skipped once the timeout is reached.
+	watchGapTimeout = 0
+	if _, err := w.poll(ctx, send); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if diff := cmp.Diff([]uint64{3}, got); diff != "" {
+		t.Errorf("sent changes mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWatchOtherChanges(t *testing.T) {
+	srv := newWatchServer(t)
+	ctx := context.Background()
+
+	for _, c := range []*db.Change{
+		{ID: 1, Resource: changeResults, Type: "ADDED", Parent: "foo", ResultName: "a"},
+		{ID: 2, Resource: changeResults, Type: "ADDED", Parent: "bar", ResultName: "a"},
+		{ID: 3, Resource: changeRecords, Type: "ADDED", Parent: "foo", ResultName: "a"},
+		{ID: 4, Resource: changeResults, Type: "MODIFIED", Parent: "foo", ResultName: "a"},
+	} {
+		if err := srv.db.Create(c).Error; err != nil {
+			t.Fatalf("failed to create change: %v", err)
+		}
+	}
+	w := &watch{db: srv.db, resource: changeResults, parent: "foo", result: "-"}
+	var got []uint64
+	send := func(c *db.Change) error {
+		got = append(got, c.ID)
+		return nil
+	}
+	if _, err := w.poll(ctx, send); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if diff := cmp.Diff([]uint64{1, 4}, got); diff != "" {
+		t.Errorf("sent changes mismatch (-want +got):\n%s", diff)
+	}
+	if w.last != 4 {
+		t.Errorf("last: want 4, got %d", w.last)
+	}
+}
+
+func TestWatchErrors(t *testing.T) {
+	srv := newWatchServer(t)
+	ctx := context.Background()
+	if _, err := srv.CreateResult(ctx, &pb.CreateResultRequest{
+		Parent: "foo",
+		Result: &pb.Result{Name: "foo/results/a"},
+	}); err != nil {
+		t.Fatalf("CreateResult: %v", err)
+	}
+	token := latestChange(t, srv)
+
+	disabled, err := New(&config.Config{DB_ENABLE_AUTO_MIGRATION: true}, logger.Get("info"), test.NewDB(t))
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	stream := &mockWatchResultsServer{ctx: ctx}
+	for _, tc := range []struct {
+		name string
+		srv  *Server
+		req  *pb.WatchResultsRequest
+		want codes.Code
+	}{
+		{
+			name: "missing parent",
+			srv:  srv,
+			req:  &pb.WatchResultsRequest{},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "invalid filter",
+			srv:  srv,
+			req:  &pb.WatchResultsRequest{Parent: "foo", Filter: "result.foo"},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "invalid resume token",
+			srv:  srv,
+			req:  &pb.WatchResultsRequest{Parent: "foo", ResumeToken: "a"},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "unknown resume token",
+			srv:  srv,
+			req:  &pb.WatchResultsRequest{Parent: "foo", ResumeToken: "100"},
+			want: codes.OutOfRange,
+		},
+		{
+			name: "disabled",
+			srv:  disabled,
+			req:  &pb.WatchResultsRequest{Parent: "foo"},
+			want: codes.FailedPrecondition,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.srv.WatchResults(tc.req, stream); status.Code(err) != tc.want {
+				t.Errorf("WatchResults: got %v, want %v", err, tc.want)
+			}
+		})
+	}
+
+	// Changes expire after the retention.
+	fakeClock.Advance(2 * time.Hour)
+	if n, err := srv.PruneChanges(ctx); err != nil || n != 1 {
+		t.Fatalf("PruneChanges: got (%d, %v), want 1 change pruned", n, err)
+	}
+	err = srv.WatchResults(&pb.WatchResultsRequest{Parent: "foo", ResumeToken: token}, stream)
+	if status.Code(err) != codes.OutOfRange {
+		t.Errorf("WatchResults with expired token: got %v, want %v", err, codes.OutOfRange)
+	}
+}
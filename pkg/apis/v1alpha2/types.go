@@ -32,13 +32,43 @@ type Resource struct {
 type LogType string
 
 const (
-	FileLogType LogType = "File"
-	S3LogType   LogType = "S3"
+	FileLogType      LogType = "File"
+	S3LogType        LogType = "S3"
+	GCSLogType       LogType = "GCS"
+	AzureBlobLogType LogType = "AzureBlob"
+	DatabaseLogType  LogType = "Database"
+)
+
+// LogCompression is the compression of a stored log.
+type LogCompression string
+
+const (
+	GzipLogCompression LogCompression = "gzip"
+	ZstdLogCompression LogCompression = "zstd"
 )
 
 type LogStatus struct {
 	Path string `json:"path,omitempty"`
-	Size int64  `json:"size"`
+	// Size is the size of the log, uncompressed.
+	Size int64 `json:"size"`
+	// Storing is set while UpdateLog is appending to the log.
+	Storing bool `json:"storing,omitempty"`
+	// Compression is the compression of the stored log, which is stored
+	// uncompressed if empty.
+	Compression LogCompression `json:"compression,omitempty"`
+	// CompressedSize is the number of bytes stored for compressed logs.
+	CompressedSize int64 `json:"compressedSize,omitempty"`
+	// Segments are the objects the log is stored in, in order, for storage
+	// types which can't append to objects.
+	Segments []LogSegment `json:"segments,omitempty"`
+}
+
+// LogSegment is one of the objects a log is stored in, one per UpdateLog
+// session.
+type LogSegment struct {
+	Path string `json:"path"`
+	// Size is the number of bytes stored in the segment.
+	Size int64 `json:"size"`
 }
 
 func (t *Log) Default() {
@@ -71,11 +71,18 @@ func (w *BufferedLog) Write(p []byte) (n int, err error) {
 	return len(p), err
 }
 
+// Flush sends the buffered bytes, if any, and empties the buffer so they are
+// not resent by a later Flush.
 func (w *BufferedLog) Flush() (int, error) {
-	if len(w.buffer.Bytes()) > 0 {
-		return w.sendBytes(w.buffer.Bytes())
+	if w.buffer.Len() == 0 {
+		return 0, nil
 	}
-	return 0, nil
+	n, err := w.sendBytes(w.buffer.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	w.buffer.Reset()
+	return n, nil
 }
 
 // sendBytes sends the provided byte array over gRPC.
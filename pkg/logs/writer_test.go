@@ -84,6 +84,10 @@ func TestBufferedLog_Flush(t *testing.T) {
 			if err != nil {
 				t.Error("failed to write remaining bytes")
 			}
+			// Flushing again must not resend the remaining bytes.
+			if n, err := writer.Flush(); n != 0 || err != nil {
+				t.Errorf("second flush should send nothing, but sent %d bytes: %v", n, err)
+			}
 
 			received := sender.receivedData.String()
 			if received != tc.data {
@@ -26,9 +26,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	types "k8s.io/apimachinery/pkg/types"
 
-	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	"github.com/tektoncd/pipeline/pkg/client/clientset/versioned/scheme"
-	"github.com/tektoncd/pipeline/pkg/pod"
 	rpb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -125,42 +123,5 @@ func InferGVK(o runtime.Object) (schema.GroupVersionKind, error) {
 
 // Status maps a Run condition to a general Record status.
 func Status(ca apis.ConditionAccessor) rpb.RecordSummary_Status {
-	c := ca.GetCondition(apis.ConditionSucceeded)
-	if c == nil {
-		return rpb.RecordSummary_UNKNOWN
-	}
-
-	switch v1beta1.TaskRunReason(c.Reason) {
-	case v1beta1.TaskRunReasonSuccessful:
-		return rpb.RecordSummary_SUCCESS
-	case v1beta1.TaskRunReasonFailed:
-		return rpb.RecordSummary_FAILURE
-	case v1beta1.TaskRunReasonTimedOut:
-		return rpb.RecordSummary_TIMEOUT
-	case v1beta1.TaskRunReasonCancelled:
-		return rpb.RecordSummary_CANCELLED
-	case v1beta1.TaskRunReasonRunning, v1beta1.TaskRunReasonStarted:
-		return rpb.RecordSummary_UNKNOWN
-	}
-
-	switch v1beta1.PipelineRunReason(c.Reason) {
-	case v1beta1.PipelineRunReasonSuccessful, v1beta1.PipelineRunReasonCompleted:
-		return rpb.RecordSummary_SUCCESS
-	case v1beta1.PipelineRunReasonFailed:
-		return rpb.RecordSummary_FAILURE
-	case v1beta1.PipelineRunReasonTimedOut:
-		return rpb.RecordSummary_TIMEOUT
-	case v1beta1.PipelineRunReasonCancelled:
-		return rpb.RecordSummary_CANCELLED
-	case v1beta1.PipelineRunReasonRunning, v1beta1.PipelineRunReasonStarted, v1beta1.PipelineRunReasonPending, v1beta1.PipelineRunReasonStopping, v1beta1.PipelineRunReasonCancelledRunningFinally, v1beta1.PipelineRunReasonStoppedRunningFinally:
-		return rpb.RecordSummary_UNKNOWN
-	}
-
-	switch c.Reason {
-	case pod.ReasonCouldntGetTask, pod.ReasonFailedResolution, pod.ReasonFailedValidation, pod.ReasonExceededResourceQuota, pod.ReasonExceededNodeResources, pod.ReasonCreateContainerConfigError, pod.ReasonPodCreationFailed:
-		return rpb.RecordSummary_FAILURE
-	case pod.ReasonPending:
-		return rpb.RecordSummary_UNKNOWN
-	}
-	return rpb.RecordSummary_UNKNOWN
+	return record.Status(ca)
 }
@@ -131,7 +131,7 @@ func (r *Reconciler) Reconcile(ctx context.Context, o results.Object) error {
 		return err
 	}
 
-	return r.deleteUponCompletion(logging.WithLogger(ctx, logger), o)
+	return r.deleteUponCompletion(logging.WithLogger(ctx, logger), o, res)
 }
 
 // addResultsAnnotations adds Results annotations to the object in question if
@@ -161,9 +161,10 @@ func (r *Reconciler) addResultsAnnotations(ctx context.Context, o results.Object
 // than 0).
 // * The object is done, and it isn't owned by other object.
 // * The configured grace period has elapsed since the object's completion.
+// * The object's Result isn't held.
 // * The object satisfies all label requirements defined in the supplied config.
 // * The assigned IsReadyForDeletionFunc returns true.
-func (r *Reconciler) deleteUponCompletion(ctx context.Context, o results.Object) error {
+func (r *Reconciler) deleteUponCompletion(ctx context.Context, o results.Object, res *pb.Result) error {
 	logger := logging.FromContext(ctx)
 
 	gracePeriod := r.cfg.GetCompletedResourceGracePeriod()
@@ -201,6 +202,15 @@ func (r *Reconciler) deleteUponCompletion(ctx context.Context, o results.Object)
 		return controller.NewRequeueAfter(requeueAfter)
 	}
 
+	if now := clock.Now(); result.Held(res, now) {
+		requeueAfter := r.cfg.RequeueInterval
+		if !res.GetRetain() {
+			requeueAfter = res.GetHoldUntil().AsTime().Sub(now)
+		}
+		logger.Debugw("Object's Result is held - requeuing to process later", zap.Duration("results.tekton.dev/requeueAfter", requeueAfter))
+		return controller.NewRequeueAfter(requeueAfter)
+	}
+
 	// Verify whether this object matches the provided label selectors
 	if selectors := r.cfg.GetLabelSelector(); !selectors.Matches(labels.Set(o.GetLabels())) {
 		logger.Debugw("Object doesn't match the required label selectors - requeuing to process later", zap.String("results.tekton.dev/label-selectors", selectors.String()))
@@ -36,6 +36,7 @@ import (
 	"github.com/tektoncd/results/pkg/watcher/reconciler"
 	"github.com/tektoncd/results/pkg/watcher/reconciler/annotation"
 	pb "github.com/tektoncd/results/proto/v1alpha2/results_go_proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -326,6 +327,39 @@ func TestReconcile_TaskRun(t *testing.T) {
 			t.Fatalf("Want NotFound, but got %v", err)
 		}
 	})
+
+	t.Run("keep the object while its Result is held", func(t *testing.T) {
+		// Recreate the object to retest the deletion
+		if _, err := trclient.Create(ctx, taskrun, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		hold := func(retain bool) {
+			t.Helper()
+			if _, err := resultsClient.UpdateResult(ctx, &pb.UpdateResultRequest{
+				Name:       result.FormatName(taskrun.GetNamespace(), string(taskrun.GetUID())),
+				Result:     &pb.Result{Retain: retain},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"retain"}},
+			}); err != nil {
+				t.Fatalf("UpdateResult: %v", err)
+			}
+		}
+
+		hold(true)
+		if err := r.Reconcile(ctx, taskrun); !isRequeueKey(err) {
+			t.Fatalf("Want a controller.RequeueKey error, but got %v", err)
+		}
+		if _, err := trclient.Get(ctx, taskrun.GetName(), metav1.GetOptions{}); err != nil {
+			t.Fatalf("Want the held object to be kept, but got %v", err)
+		}
+
+		hold(false)
+		if err := r.Reconcile(ctx, taskrun); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := trclient.Get(ctx, taskrun.GetName(), metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+			t.Fatalf("Want NotFound, but got %v", err)
+		}
+	})
 }
 
 // This is a simpler test than TaskRun, since most of this behavior is
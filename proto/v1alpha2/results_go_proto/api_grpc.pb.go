@@ -27,12 +27,42 @@ type ResultsClient interface {
 	UpdateResult(ctx context.Context, in *UpdateResultRequest, opts ...grpc.CallOption) (*Result, error)
 	GetResult(ctx context.Context, in *GetResultRequest, opts ...grpc.CallOption) (*Result, error)
 	DeleteResult(ctx context.Context, in *DeleteResultRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// DeleteResults deletes the Results matching a filter, along with their
+	// Records and logs, in batches.
+	DeleteResults(ctx context.Context, in *DeleteResultsRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
 	ListResults(ctx context.Context, in *ListResultsRequest, opts ...grpc.CallOption) (*ListResultsResponse, error)
+	// CountResults counts the Results matching a filter.
+	CountResults(ctx context.Context, in *CountResultsRequest, opts ...grpc.CallOption) (*CountResponse, error)
+	// SummarizeResults aggregates the Results of a parent, e.g. to compute the
+	// number of runs or their duration percentiles by type.
+	SummarizeResults(ctx context.Context, in *SummarizeResultsRequest, opts ...grpc.CallOption) (*SummarizeResultsResponse, error)
 	CreateRecord(ctx context.Context, in *CreateRecordRequest, opts ...grpc.CallOption) (*Record, error)
 	UpdateRecord(ctx context.Context, in *UpdateRecordRequest, opts ...grpc.CallOption) (*Record, error)
 	GetRecord(ctx context.Context, in *GetRecordRequest, opts ...grpc.CallOption) (*Record, error)
 	ListRecords(ctx context.Context, in *ListRecordsRequest, opts ...grpc.CallOption) (*ListRecordsResponse, error)
 	DeleteRecord(ctx context.Context, in *DeleteRecordRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// CountRecords counts the Records matching a filter.
+	CountRecords(ctx context.Context, in *CountRecordsRequest, opts ...grpc.CallOption) (*CountResponse, error)
+	// DeleteRecords deletes the Records matching a filter, along with their
+	// logs, in batches.
+	DeleteRecords(ctx context.Context, in *DeleteRecordsRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// BatchCreateRecords creates several Records in a single transaction.
+	// See https://google.aip.dev/233 for more information.
+	BatchCreateRecords(ctx context.Context, in *BatchCreateRecordsRequest, opts ...grpc.CallOption) (*BatchCreateRecordsResponse, error)
+	// BatchGetRecords reads several Records in a single transaction.
+	// See https://google.aip.dev/231 for more information.
+	BatchGetRecords(ctx context.Context, in *BatchGetRecordsRequest, opts ...grpc.CallOption) (*BatchGetRecordsResponse, error)
+	// BatchDeleteRecords deletes several Records in a single transaction.
+	// See https://google.aip.dev/235 for more information.
+	BatchDeleteRecords(ctx context.Context, in *BatchDeleteRecordsRequest, opts ...grpc.CallOption) (*BatchDeleteRecordsResponse, error)
+	ListRecordRevisions(ctx context.Context, in *ListRecordRevisionsRequest, opts ...grpc.CallOption) (*ListRecordRevisionsResponse, error)
+	GetRecordRevision(ctx context.Context, in *GetRecordRevisionRequest, opts ...grpc.CallOption) (*RecordRevision, error)
+	// WatchResults streams the changes made to the Results of a parent.
+	WatchResults(ctx context.Context, in *WatchResultsRequest, opts ...grpc.CallOption) (Results_WatchResultsClient, error)
+	// WatchRecords streams the changes made to the Records of a Result.
+	WatchRecords(ctx context.Context, in *WatchRecordsRequest, opts ...grpc.CallOption) (Results_WatchRecordsClient, error)
+	// ValidateFilter parses and type checks a filter without running it.
+	ValidateFilter(ctx context.Context, in *ValidateFilterRequest, opts ...grpc.CallOption) (*ValidateFilterResponse, error)
 }
 
 type resultsClient struct {
@@ -79,6 +109,15 @@ func (c *resultsClient) DeleteResult(ctx context.Context, in *DeleteResultReques
 	return out, nil
 }
 
+func (c *resultsClient) DeleteResults(ctx context.Context, in *DeleteResultsRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/tekton.results.v1alpha2.Results/DeleteResults", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *resultsClient) ListResults(ctx context.Context, in *ListResultsRequest, opts ...grpc.CallOption) (*ListResultsResponse, error) {
 	out := new(ListResultsResponse)
 	err := c.cc.Invoke(ctx, "/tekton.results.v1alpha2.Results/ListResults", in, out, opts...)
@@ -88,6 +127,24 @@ func (c *resultsClient) ListResults(ctx context.Context, in *ListResultsRequest,
 	return out, nil
 }
 
+func (c *resultsClient) CountResults(ctx context.Context, in *CountResultsRequest, opts ...grpc.CallOption) (*CountResponse, error) {
+	out := new(CountResponse)
+	err := c.cc.Invoke(ctx, "/tekton.results.v1alpha2.Results/CountResults", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *resultsClient) SummarizeResults(ctx context.Context, in *SummarizeResultsRequest, opts ...grpc.CallOption) (*SummarizeResultsResponse, error) {
+	out := new(SummarizeResultsResponse)
+	err := c.cc.Invoke(ctx, "/tekton.results.v1alpha2.Results/SummarizeResults", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *resultsClient) CreateRecord(ctx context.Context, in *CreateRecordRequest, opts ...grpc.CallOption) (*Record, error) {
 	out := new(Record)
 	err := c.cc.Invoke(ctx, "/tekton.results.v1alpha2.Results/CreateRecord", in, out, opts...)
@@ -133,6 +190,142 @@ func (c *resultsClient) DeleteRecord(ctx context.Context, in *DeleteRecordReques
 	return out, nil
 }
 
+func (c *resultsClient) CountRecords(ctx context.Context, in *CountRecordsRequest, opts ...grpc.CallOption) (*CountResponse, error) {
+	out := new(CountResponse)
+	err := c.cc.Invoke(ctx, "/tekton.results.v1alpha2.Results/CountRecords", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *resultsClient) DeleteRecords(ctx context.Context, in *DeleteRecordsRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/tekton.results.v1alpha2.Results/DeleteRecords", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *resultsClient) BatchCreateRecords(ctx context.Context, in *BatchCreateRecordsRequest, opts ...grpc.CallOption) (*BatchCreateRecordsResponse, error) {
+	out := new(BatchCreateRecordsResponse)
+	err := c.cc.Invoke(ctx, "/tekton.results.v1alpha2.Results/BatchCreateRecords", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *resultsClient) BatchGetRecords(ctx context.Context, in *BatchGetRecordsRequest, opts ...grpc.CallOption) (*BatchGetRecordsResponse, error) {
+	out := new(BatchGetRecordsResponse)
+	err := c.cc.Invoke(ctx, "/tekton.results.v1alpha2.Results/BatchGetRecords", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *resultsClient) BatchDeleteRecords(ctx context.Context, in *BatchDeleteRecordsRequest, opts ...grpc.CallOption) (*BatchDeleteRecordsResponse, error) {
+	out := new(BatchDeleteRecordsResponse)
+	err := c.cc.Invoke(ctx, "/tekton.results.v1alpha2.Results/BatchDeleteRecords", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *resultsClient) ListRecordRevisions(ctx context.Context, in *ListRecordRevisionsRequest, opts ...grpc.CallOption) (*ListRecordRevisionsResponse, error) {
+	out := new(ListRecordRevisionsResponse)
+	err := c.cc.Invoke(ctx, "/tekton.results.v1alpha2.Results/ListRecordRevisions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *resultsClient) GetRecordRevision(ctx context.Context, in *GetRecordRevisionRequest, opts ...grpc.CallOption) (*RecordRevision, error) {
+	out := new(RecordRevision)
+	err := c.cc.Invoke(ctx, "/tekton.results.v1alpha2.Results/GetRecordRevision", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *resultsClient) WatchResults(ctx context.Context, in *WatchResultsRequest, opts ...grpc.CallOption) (Results_WatchResultsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Results_ServiceDesc.Streams[0], "/tekton.results.v1alpha2.Results/WatchResults", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &resultsWatchResultsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Results_WatchResultsClient interface {
+	Recv() (*WatchResultsResponse, error)
+	grpc.ClientStream
+}
+
+type resultsWatchResultsClient struct {
+	grpc.ClientStream
+}
+
+func (x *resultsWatchResultsClient) Recv() (*WatchResultsResponse, error) {
+	m := new(WatchResultsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *resultsClient) WatchRecords(ctx context.Context, in *WatchRecordsRequest, opts ...grpc.CallOption) (Results_WatchRecordsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Results_ServiceDesc.Streams[1], "/tekton.results.v1alpha2.Results/WatchRecords", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &resultsWatchRecordsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Results_WatchRecordsClient interface {
+	Recv() (*WatchRecordsResponse, error)
+	grpc.ClientStream
+}
+
+type resultsWatchRecordsClient struct {
+	grpc.ClientStream
+}
+
+func (x *resultsWatchRecordsClient) Recv() (*WatchRecordsResponse, error) {
+	m := new(WatchRecordsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *resultsClient) ValidateFilter(ctx context.Context, in *ValidateFilterRequest, opts ...grpc.CallOption) (*ValidateFilterResponse, error) {
+	out := new(ValidateFilterResponse)
+	err := c.cc.Invoke(ctx, "/tekton.results.v1alpha2.Results/ValidateFilter", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ResultsServer is the server API for Results service.
 // All implementations must embed UnimplementedResultsServer
 // for forward compatibility
@@ -141,12 +334,42 @@ type ResultsServer interface {
 	UpdateResult(context.Context, *UpdateResultRequest) (*Result, error)
 	GetResult(context.Context, *GetResultRequest) (*Result, error)
 	DeleteResult(context.Context, *DeleteResultRequest) (*emptypb.Empty, error)
+	// DeleteResults deletes the Results matching a filter, along with their
+	// Records and logs, in batches.
+	DeleteResults(context.Context, *DeleteResultsRequest) (*DeleteResponse, error)
 	ListResults(context.Context, *ListResultsRequest) (*ListResultsResponse, error)
+	// CountResults counts the Results matching a filter.
+	CountResults(context.Context, *CountResultsRequest) (*CountResponse, error)
+	// SummarizeResults aggregates the Results of a parent, e.g. to compute the
+	// number of runs or their duration percentiles by type.
+	SummarizeResults(context.Context, *SummarizeResultsRequest) (*SummarizeResultsResponse, error)
 	CreateRecord(context.Context, *CreateRecordRequest) (*Record, error)
 	UpdateRecord(context.Context, *UpdateRecordRequest) (*Record, error)
 	GetRecord(context.Context, *GetRecordRequest) (*Record, error)
 	ListRecords(context.Context, *ListRecordsRequest) (*ListRecordsResponse, error)
 	DeleteRecord(context.Context, *DeleteRecordRequest) (*emptypb.Empty, error)
+	// CountRecords counts the Records matching a filter.
+	CountRecords(context.Context, *CountRecordsRequest) (*CountResponse, error)
+	// DeleteRecords deletes the Records matching a filter, along with their
+	// logs, in batches.
+	DeleteRecords(context.Context, *DeleteRecordsRequest) (*DeleteResponse, error)
+	// BatchCreateRecords creates several Records in a single transaction.
+	// See https://google.aip.dev/233 for more information.
+	BatchCreateRecords(context.Context, *BatchCreateRecordsRequest) (*BatchCreateRecordsResponse, error)
+	// BatchGetRecords reads several Records in a single transaction.
+	// See https://google.aip.dev/231 for more information.
+	BatchGetRecords(context.Context, *BatchGetRecordsRequest) (*BatchGetRecordsResponse, error)
+	// BatchDeleteRecords deletes several Records in a single transaction.
+	// See https://google.aip.dev/235 for more information.
+	BatchDeleteRecords(context.Context, *BatchDeleteRecordsRequest) (*BatchDeleteRecordsResponse, error)
+	ListRecordRevisions(context.Context, *ListRecordRevisionsRequest) (*ListRecordRevisionsResponse, error)
+	GetRecordRevision(context.Context, *GetRecordRevisionRequest) (*RecordRevision, error)
+	// WatchResults streams the changes made to the Results of a parent.
+	WatchResults(*WatchResultsRequest, Results_WatchResultsServer) error
+	// WatchRecords streams the changes made to the Records of a Result.
+	WatchRecords(*WatchRecordsRequest, Results_WatchRecordsServer) error
+	// ValidateFilter parses and type checks a filter without running it.
+	ValidateFilter(context.Context, *ValidateFilterRequest) (*ValidateFilterResponse, error)
 	mustEmbedUnimplementedResultsServer()
 }
 
@@ -166,9 +389,18 @@ func (UnimplementedResultsServer) GetResult(context.Context, *GetResultRequest)
 func (UnimplementedResultsServer) DeleteResult(context.Context, *DeleteResultRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteResult not implemented")
 }
+func (UnimplementedResultsServer) DeleteResults(context.Context, *DeleteResultsRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteResults not implemented")
+}
 func (UnimplementedResultsServer) ListResults(context.Context, *ListResultsRequest) (*ListResultsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListResults not implemented")
 }
+func (UnimplementedResultsServer) CountResults(context.Context, *CountResultsRequest) (*CountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CountResults not implemented")
+}
+func (UnimplementedResultsServer) SummarizeResults(context.Context, *SummarizeResultsRequest) (*SummarizeResultsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SummarizeResults not implemented")
+}
 func (UnimplementedResultsServer) CreateRecord(context.Context, *CreateRecordRequest) (*Record, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateRecord not implemented")
 }
@@ -184,6 +416,36 @@ func (UnimplementedResultsServer) ListRecords(context.Context, *ListRecordsReque
 func (UnimplementedResultsServer) DeleteRecord(context.Context, *DeleteRecordRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteRecord not implemented")
 }
+func (UnimplementedResultsServer) CountRecords(context.Context, *CountRecordsRequest) (*CountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CountRecords not implemented")
+}
+func (UnimplementedResultsServer) DeleteRecords(context.Context, *DeleteRecordsRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRecords not implemented")
+}
+func (UnimplementedResultsServer) BatchCreateRecords(context.Context, *BatchCreateRecordsRequest) (*BatchCreateRecordsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchCreateRecords not implemented")
+}
+func (UnimplementedResultsServer) BatchGetRecords(context.Context, *BatchGetRecordsRequest) (*BatchGetRecordsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetRecords not implemented")
+}
+func (UnimplementedResultsServer) BatchDeleteRecords(context.Context, *BatchDeleteRecordsRequest) (*BatchDeleteRecordsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchDeleteRecords not implemented")
+}
+func (UnimplementedResultsServer) ListRecordRevisions(context.Context, *ListRecordRevisionsRequest) (*ListRecordRevisionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRecordRevisions not implemented")
+}
+func (UnimplementedResultsServer) GetRecordRevision(context.Context, *GetRecordRevisionRequest) (*RecordRevision, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRecordRevision not implemented")
+}
+func (UnimplementedResultsServer) WatchResults(*WatchResultsRequest, Results_WatchResultsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchResults not implemented")
+}
+func (UnimplementedResultsServer) WatchRecords(*WatchRecordsRequest, Results_WatchRecordsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRecords not implemented")
+}
+func (UnimplementedResultsServer) ValidateFilter(context.Context, *ValidateFilterRequest) (*ValidateFilterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateFilter not implemented")
+}
 func (UnimplementedResultsServer) mustEmbedUnimplementedResultsServer() {}
 
 // UnsafeResultsServer may be embedded to opt out of forward compatibility for this service.
@@ -269,6 +531,24 @@ func _Results_DeleteResult_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Results_DeleteResults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteResultsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResultsServer).DeleteResults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tekton.results.v1alpha2.Results/DeleteResults",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResultsServer).DeleteResults(ctx, req.(*DeleteResultsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Results_ListResults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ListResultsRequest)
 	if err := dec(in); err != nil {
@@ -287,6 +567,42 @@ func _Results_ListResults_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Results_CountResults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CountResultsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResultsServer).CountResults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tekton.results.v1alpha2.Results/CountResults",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResultsServer).CountResults(ctx, req.(*CountResultsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Results_SummarizeResults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SummarizeResultsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResultsServer).SummarizeResults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tekton.results.v1alpha2.Results/SummarizeResults",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResultsServer).SummarizeResults(ctx, req.(*SummarizeResultsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Results_CreateRecord_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CreateRecordRequest)
 	if err := dec(in); err != nil {
@@ -377,6 +693,192 @@ func _Results_DeleteRecord_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Results_CountRecords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CountRecordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResultsServer).CountRecords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tekton.results.v1alpha2.Results/CountRecords",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResultsServer).CountRecords(ctx, req.(*CountRecordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Results_DeleteRecords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRecordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResultsServer).DeleteRecords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tekton.results.v1alpha2.Results/DeleteRecords",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResultsServer).DeleteRecords(ctx, req.(*DeleteRecordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Results_BatchCreateRecords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchCreateRecordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResultsServer).BatchCreateRecords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tekton.results.v1alpha2.Results/BatchCreateRecords",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResultsServer).BatchCreateRecords(ctx, req.(*BatchCreateRecordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Results_BatchGetRecords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetRecordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResultsServer).BatchGetRecords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tekton.results.v1alpha2.Results/BatchGetRecords",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResultsServer).BatchGetRecords(ctx, req.(*BatchGetRecordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Results_BatchDeleteRecords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchDeleteRecordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResultsServer).BatchDeleteRecords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tekton.results.v1alpha2.Results/BatchDeleteRecords",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResultsServer).BatchDeleteRecords(ctx, req.(*BatchDeleteRecordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Results_ListRecordRevisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRecordRevisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResultsServer).ListRecordRevisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tekton.results.v1alpha2.Results/ListRecordRevisions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResultsServer).ListRecordRevisions(ctx, req.(*ListRecordRevisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Results_GetRecordRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRecordRevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResultsServer).GetRecordRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tekton.results.v1alpha2.Results/GetRecordRevision",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResultsServer).GetRecordRevision(ctx, req.(*GetRecordRevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Results_WatchResults_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchResultsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ResultsServer).WatchResults(m, &resultsWatchResultsServer{stream})
+}
+
+type Results_WatchResultsServer interface {
+	Send(*WatchResultsResponse) error
+	grpc.ServerStream
+}
+
+type resultsWatchResultsServer struct {
+	grpc.ServerStream
+}
+
+func (x *resultsWatchResultsServer) Send(m *WatchResultsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Results_WatchRecords_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRecordsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ResultsServer).WatchRecords(m, &resultsWatchRecordsServer{stream})
+}
+
+type Results_WatchRecordsServer interface {
+	Send(*WatchRecordsResponse) error
+	grpc.ServerStream
+}
+
+type resultsWatchRecordsServer struct {
+	grpc.ServerStream
+}
+
+func (x *resultsWatchRecordsServer) Send(m *WatchRecordsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Results_ValidateFilter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateFilterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResultsServer).ValidateFilter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tekton.results.v1alpha2.Results/ValidateFilter",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResultsServer).ValidateFilter(ctx, req.(*ValidateFilterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Results_ServiceDesc is the grpc.ServiceDesc for Results service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -400,10 +902,22 @@ var Results_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteResult",
 			Handler:    _Results_DeleteResult_Handler,
 		},
+		{
+			MethodName: "DeleteResults",
+			Handler:    _Results_DeleteResults_Handler,
+		},
 		{
 			MethodName: "ListResults",
 			Handler:    _Results_ListResults_Handler,
 		},
+		{
+			MethodName: "CountResults",
+			Handler:    _Results_CountResults_Handler,
+		},
+		{
+			MethodName: "SummarizeResults",
+			Handler:    _Results_SummarizeResults_Handler,
+		},
 		{
 			MethodName: "CreateRecord",
 			Handler:    _Results_CreateRecord_Handler,
@@ -424,8 +938,51 @@ var Results_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteRecord",
 			Handler:    _Results_DeleteRecord_Handler,
 		},
+		{
+			MethodName: "CountRecords",
+			Handler:    _Results_CountRecords_Handler,
+		},
+		{
+			MethodName: "DeleteRecords",
+			Handler:    _Results_DeleteRecords_Handler,
+		},
+		{
+			MethodName: "BatchCreateRecords",
+			Handler:    _Results_BatchCreateRecords_Handler,
+		},
+		{
+			MethodName: "BatchGetRecords",
+			Handler:    _Results_BatchGetRecords_Handler,
+		},
+		{
+			MethodName: "BatchDeleteRecords",
+			Handler:    _Results_BatchDeleteRecords_Handler,
+		},
+		{
+			MethodName: "ListRecordRevisions",
+			Handler:    _Results_ListRecordRevisions_Handler,
+		},
+		{
+			MethodName: "GetRecordRevision",
+			Handler:    _Results_GetRecordRevision_Handler,
+		},
+		{
+			MethodName: "ValidateFilter",
+			Handler:    _Results_ValidateFilter_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchResults",
+			Handler:       _Results_WatchResults_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchRecords",
+			Handler:       _Results_WatchRecords_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "api.proto",
 }
 